@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+)
+
+// Exit codes of the check command, following the Nagios plugin convention
+const (
+	checkOK         = 0 // No violations
+	checkViolations = 1 // Some paths have the wrong owner or permissions
+	checkFailed     = 2 // Some folders or paths could not be checked
+	checkUnknown    = 3 // The configuration could not be loaded
+)
+
+// checkResult is the output of the check command
+type checkResult struct {
+	Status     string        `json:"status" yaml:"status"` // OK, WARNING, CRITICAL or UNKNOWN
+	Violations int           `json:"violations" yaml:"violations"`
+	Failed     int           `json:"failed" yaml:"failed"`
+	Folders    []folderCheck `json:"folders" yaml:"folders"`
+	Error      string        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// folderCheck is the result of checking one folder
+type folderCheck struct {
+	Name       string `json:"name" yaml:"name"`
+	Path       string `json:"path" yaml:"path"`
+	Violations int    `json:"violations" yaml:"violations"`
+	Failed     int    `json:"failed" yaml:"failed"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"` // The folder itself is not accessible
+
+	Paths []pathResult `json:"paths,omitempty" yaml:"paths,omitempty"` // Listed with -v and -vv
+}
+
+// runCheck scans the configured folders without changing anything and
+// prints the number of paths with the wrong owner or permissions per folder,
+// listing them with -v. It returns 1 if there are any, 2 if anything could
+// not be checked, or 3 if the configuration is invalid.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		output     = outputFlag(flags)
+		verbosity  = verboseFlags(flags)
+	)
+	colorFlag(flags)
+	_ = flags.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return checkUnknown
+	}
+
+	logger, cfg, err := load(*configPath)
+	if err != nil {
+		result := checkResult{Status: "UNKNOWN", Folders: []folderCheck{}, Error: "failed to load configuration: " + err.Error()}
+		printCheck(*output, result)
+		return checkUnknown
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	enf := enforcer.New(activity.NewHub(), logger)
+	defer enf.CloseRemotes()
+	enf.SetScanPriority(cfg.ScanNice, cfg.ScanIOClass)
+	showProgress(logger, enf, cfg.WatchDirs, cfg.LogColor)
+	collector := &pathCollector{
+		verbosity: verbosity(),
+		labels:    map[enforcer.Outcome]string{enforcer.Fixed: "violation", enforcer.Skipped: "ok", enforcer.Failed: "failed"},
+	}
+	result := checkResult{Folders: make([]folderCheck, 0, len(cfg.WatchDirs))}
+	for _, watchDir := range cfg.WatchDirs {
+		check := folderCheck{Name: watchDir.Name, Path: watchDir.Path}
+		if err := checkFolder(watchDir); err != nil {
+			check.Error = err.Error()
+			check.Failed = 1
+		} else {
+			run := enf.PlanEach(ctx, watchDir.Path, watchDir, collector.visit)
+			check.Violations, check.Failed = run.Fixed, run.Failed
+			check.Paths = collector.take()
+		}
+		result.Violations += check.Violations
+		result.Failed += check.Failed
+		result.Folders = append(result.Folders, check)
+	}
+
+	code := checkOK
+	result.Status = "OK"
+	switch {
+	case result.Failed > 0 || ctx.Err() != nil:
+		code, result.Status = checkFailed, "CRITICAL"
+	case result.Violations > 0:
+		code, result.Status = checkViolations, "WARNING"
+	}
+	printCheck(*output, result)
+	return code
+}
+
+// printCheck writes the result, as a status line followed by the result of
+// each folder and the paths listed in it for the table format
+func printCheck(format string, result checkResult) {
+	_ = writeOutput(os.Stdout, format, result, func(w io.Writer) {
+		affected := 0
+		for _, check := range result.Folders {
+			if check.Violations > 0 {
+				affected++
+			}
+		}
+
+		switch result.Status {
+		case "UNKNOWN":
+			fmt.Fprintf(w, "UNKNOWN: %s\n", result.Error)
+			return
+		case "CRITICAL":
+			fmt.Fprintf(w, "CRITICAL: %d paths could not be checked, %d violations in %d of %d folders\n",
+				result.Failed, result.Violations, affected, len(result.Folders))
+		case "WARNING":
+			fmt.Fprintf(w, "WARNING: %d violations in %d of %d folders\n", result.Violations, affected, len(result.Folders))
+		default:
+			fmt.Fprintf(w, "OK: no violations in %d folders\n", len(result.Folders))
+		}
+
+		for _, check := range result.Folders {
+			switch {
+			case check.Error != "":
+				fmt.Fprintf(w, "%s: not accessible: %s\n", check.Name, check.Error)
+			case check.Failed > 0:
+				fmt.Fprintf(w, "%s: %d violations, %d paths could not be checked\n", check.Name, check.Violations, check.Failed)
+			default:
+				fmt.Fprintf(w, "%s: %d violations\n", check.Name, check.Violations)
+			}
+			printPaths(w, check.Paths)
+		}
+	})
+}
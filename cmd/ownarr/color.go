@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/muesli/termenv"
+)
+
+// noColor is set by the -no-color flag, which overrides log_color
+var noColor bool
+
+// colorFlag adds the -no-color flag to a command that logs
+func colorFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&noColor, "no-color", false, "Log without colors, like NO_COLOR=1 or log_color: never")
+}
+
+// colorProfile returns the colors to log to out with for a log_color
+// setting: none with -no-color or never, at least 256 with always, and
+// otherwise what out supports, which is none for files, pipes and journald
+// or with NO_COLOR set
+func colorProfile(out io.Writer, mode string) termenv.Profile {
+	switch {
+	case noColor || mode == config.LogColorNever:
+		return termenv.Ascii
+	case mode == config.LogColorAlways:
+		// Lower profiles have more colors
+		return min(termenv.NewOutput(out).ColorProfile(), termenv.ANSI256)
+	default:
+		return termenv.NewOutput(out).EnvColorProfile()
+	}
+}
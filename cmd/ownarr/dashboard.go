@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/keksiqc/ownarr/internal/dashboard"
+	"github.com/keksiqc/ownarr/internal/metrics"
+)
+
+// runDashboard writes a monitoring dashboard for the exported metrics and
+// returns the process exit code
+func runDashboard(args []string) int {
+	flags := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	var (
+		format = flags.String("format", dashboard.FormatGrafana, "Dashboard format (grafana)")
+		output = flags.String("output", "", "File to write the dashboard to (default: stdout)")
+	)
+	_ = flags.Parse(args)
+
+	if *format != dashboard.FormatGrafana {
+		fmt.Fprintf(os.Stderr, "%s: unsupported dashboard format %q\n", appName, *format)
+		return exitUsage
+	}
+
+	data, err := dashboard.Grafana(metrics.Default)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*output, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to write dashboard: %v\n", appName, err)
+		return exitFailure
+	}
+	return exitOK
+}
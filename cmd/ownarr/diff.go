@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// Exit codes of the diff command, following diff(1)
+const (
+	diffSame    = 0 // Every path is correct
+	diffChanged = 1 // Some paths differ from the rules
+	diffTrouble = 2 // The configuration is invalid or some paths could not be checked
+)
+
+// diffNode is a path in the tree of differences of a folder. Nodes without
+// a result are directories that are correct but lead to paths that differ.
+type diffNode struct {
+	name     string
+	result   *enforcer.PathResult
+	children []*diffNode
+}
+
+// child returns the child named name, adding it if there is none
+func (n *diffNode) child(name string) *diffNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	child := &diffNode{name: name}
+	n.children = append(n.children, child)
+	return child
+}
+
+// isDir reports whether the node is shown as a directory
+func (n *diffNode) isDir() bool {
+	return n.result == nil || n.result.Kind == "directory" || len(n.children) > 0
+}
+
+// runDiff shows the paths of the configured folders, or the named ones,
+// whose owner or mode differ from the rules as a tree, or with -list as one
+// path per line. It returns 0 if nothing differs, 1 if something does, or 2
+// on trouble.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		list       = flags.Bool("list", false, "Print one path per line, like find, instead of a tree")
+	)
+	colorFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage:\n  %s diff [-config path] [-list] [folder...]\n\nFlags:\n", appName)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	logger, cfg, err := load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to load configuration: %v\n", appName, err)
+		return diffTrouble
+	}
+	watchDirs, err := selectFolders(cfg.WatchDirs, flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return diffTrouble
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	enf := enforcer.New(activity.NewHub(), logger)
+	defer enf.CloseRemotes()
+	enf.SetScanPriority(cfg.ScanNice, cfg.ScanIOClass)
+	showProgress(logger, enf, watchDirs, cfg.LogColor)
+
+	code := diffSame
+	for i, watchDir := range watchDirs {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := checkFolder(watchDir); err != nil {
+			fmt.Printf("%s (%s): not accessible: %v\n", watchDir.Name, watchDir.Path, err)
+			code = diffTrouble
+			continue
+		}
+
+		var differ []enforcer.PathResult
+		run := enf.PlanEach(ctx, watchDir.Path, watchDir, func(planned enforcer.PathResult) {
+			if planned.Outcome != enforcer.Skipped {
+				differ = append(differ, planned)
+			}
+		})
+		// Walks visit paths in directory order
+		slices.SortFunc(differ, func(a, b enforcer.PathResult) int { return strings.Compare(a.Path, b.Path) })
+
+		switch {
+		case len(differ) == 0:
+			fmt.Printf("%s (%s): no differences\n", watchDir.Name, watchDir.Path)
+		case *list:
+			fmt.Printf("%s (%s): %d paths differ\n", watchDir.Name, watchDir.Path, len(differ))
+			for _, result := range differ {
+				fmt.Printf("%s  %s\n", escape.Text(result.Path), describeDiff(result))
+			}
+		default:
+			fmt.Printf("%s (%s): %d paths differ\n", watchDir.Name, watchDir.Path, len(differ))
+			printDiffTree(os.Stdout, watchDir.Path, differ)
+		}
+
+		if run.Failed > 0 || ctx.Err() != nil {
+			code = diffTrouble
+		} else if run.Fixed > 0 && code == diffSame {
+			code = diffChanged
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return code
+}
+
+// selectFolders returns the watch directories with the given names in the
+// order configured, or all of them if no names are given
+func selectFolders(watchDirs []config.WatchDir, names []string) ([]config.WatchDir, error) {
+	if len(names) == 0 {
+		return watchDirs, nil
+	}
+
+	var selected []config.WatchDir
+	for _, name := range names {
+		if !slices.ContainsFunc(watchDirs, func(w config.WatchDir) bool { return w.Name == name }) {
+			return nil, fmt.Errorf("no folder named %q in the configuration", name)
+		}
+	}
+	for _, watchDir := range watchDirs {
+		if slices.Contains(names, watchDir.Name) {
+			selected = append(selected, watchDir)
+		}
+	}
+	return selected, nil
+}
+
+// printDiffTree draws the paths below root that differ, with the directories
+// leading to them
+func printDiffTree(w io.Writer, root string, differ []enforcer.PathResult) {
+	tree := &diffNode{name: "."}
+	for i := range differ {
+		node := tree
+		if rel, err := filepath.Rel(root, differ[i].Path); err == nil && rel != "." {
+			for _, name := range strings.Split(rel, string(filepath.Separator)) {
+				node = node.child(name)
+			}
+		}
+		node.result = &differ[i]
+	}
+
+	line := "."
+	if tree.result != nil {
+		line += "  " + describeDiff(*tree.result)
+	}
+	fmt.Fprintln(w, line)
+	printDiffChildren(w, tree, "")
+}
+
+// printDiffChildren draws the children of node, each line starting with
+// prefix
+func printDiffChildren(w io.Writer, node *diffNode, prefix string) {
+	for i, child := range node.children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		line := prefix + branch + escape.Text(child.name)
+		if child.isDir() {
+			line += "/"
+		}
+		if child.result != nil {
+			line += "  " + describeDiff(*child.result)
+		}
+		fmt.Fprintln(w, line)
+		printDiffChildren(w, child, prefix+indent)
+	}
+}
+
+// describeDiff describes what differs for a path, or why it could not be
+// checked
+func describeDiff(result enforcer.PathResult) string {
+	if result.Outcome == enforcer.Failed {
+		if result.Err != nil {
+			return "error: " + escape.Text(result.Err.Error())
+		}
+		return "error"
+	}
+
+	var parts []string
+	if result.OldOwner != result.NewOwner {
+		parts = append(parts, fmt.Sprintf("owner %s -> %s", result.OldOwner, result.NewOwner))
+	}
+	if result.OldMode != result.NewMode {
+		parts = append(parts, fmt.Sprintf("mode %04o -> %04o", result.OldMode, result.NewMode))
+	}
+	return strings.Join(parts, ", ")
+}
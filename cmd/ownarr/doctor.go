@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keksiqc/ownarr/internal/doctor"
+)
+
+// doctorResult is the outcome of the doctor command
+type doctorResult struct {
+	Findings []doctor.Finding `json:"findings" yaml:"findings"`
+}
+
+// runDoctor diagnoses the environment and configuration and returns the
+// process exit code: 0 when nothing keeps enforcement from working, 3 if the
+// configuration is invalid, 1 otherwise
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := configFlag(flags)
+	output := outputFlag(flags)
+	_ = flags.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitUsage
+	}
+
+	var result doctorResult
+	_, cfg, configErr := load(*configPath)
+	if err := configErr; err != nil {
+		result.Findings = []doctor.Finding{{
+			Check:    "config",
+			Severity: doctor.SeverityError,
+			Message:  fmt.Sprintf("%s is invalid: %v", *configPath, err),
+			Remedy:   "Fix the configuration file; the other checks need a valid one",
+		}}
+	} else {
+		result.Findings = doctor.Run(cfg)
+	}
+
+	if err := writeOutput(os.Stdout, *output, result, func(w io.Writer) { printDoctor(w, result) }); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+	switch {
+	case configErr != nil:
+		return exitConfig
+	case doctor.Failed(result.Findings):
+		return exitFailure
+	default:
+		return exitOK
+	}
+}
+
+// printDoctor writes each finding with its remedy
+func printDoctor(w io.Writer, result doctorResult) {
+	labels := map[doctor.Severity]string{
+		doctor.SeverityOK:      "OK",
+		doctor.SeverityWarning: "WARN",
+		doctor.SeverityError:   "FAIL",
+	}
+	for _, finding := range result.Findings {
+		fmt.Fprintf(w, "[%-4s] %s: %s\n", labels[finding.Severity], finding.Check, finding.Message)
+		if finding.Remedy != "" {
+			fmt.Fprintf(w, "       %s\n", finding.Remedy)
+		}
+	}
+}
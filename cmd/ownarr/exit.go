@@ -0,0 +1,29 @@
+package main
+
+// Exit codes shared by the commands, so wrapper scripts and cron jobs can
+// tell the outcomes apart. check follows the Nagios plugin convention
+// instead, diff that of diff(1), and healthcheck returns only 0 or 1 as
+// container runtimes expect.
+const (
+	exitOK      = 0 // Done; enforcement changed something and nothing failed
+	exitFailure = 1 // Nothing could be done, e.g. every path failed, or the daemon could not start or stop cleanly
+	exitUsage   = 2 // Invalid flags or arguments
+	exitConfig  = 3 // The configuration file is missing or invalid
+	exitPartial = 4 // Some paths could not be fixed, or the run was interrupted
+	exitNothing = 5 // Every path already had the right owner and permissions
+)
+
+// enforcementExitCode returns the exit code of an enforcement pass with
+// these totals
+func enforcementExitCode(fixed, skipped, failed int, interrupted bool) int {
+	switch {
+	case failed > 0 && fixed == 0 && skipped == 0:
+		return exitFailure
+	case failed > 0 || interrupted:
+		return exitPartial
+	case fixed == 0:
+		return exitNothing
+	default:
+		return exitOK
+	}
+}
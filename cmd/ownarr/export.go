@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runExportConfig prints the effective configuration: the file with the
+// defaults applied, validated, overridden by the flags given and with
+// secrets redacted. It returns the process exit code.
+func runExportConfig(args []string) int {
+	flags := flag.NewFlagSet("export-config", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		dryRun     = flags.Bool("dry-run", false, "Show the configuration of run -dry-run")
+		output     = flags.String("output", outputYAML, "Output format: yaml or json")
+	)
+	_ = flags.Parse(args)
+
+	if *output != outputYAML && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "%s: unsupported output format %q, expected yaml or json\n", appName, *output)
+		return exitUsage
+	}
+
+	_, cfg, err := load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s is invalid: %v\n", appName, *configPath, err)
+		return exitConfig
+	}
+	cfg.DryRun = cfg.DryRun || *dryRun
+
+	if *output == outputYAML {
+		fmt.Printf("# Effective configuration of %s, secrets redacted\n", *configPath)
+	}
+	if err := writeOutput(os.Stdout, *output, cfg.Redacted(), func(io.Writer) {}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+	return exitOK
+}
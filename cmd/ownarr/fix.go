@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/audit"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/hooks"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// fixResult is the output of the fix command
+type fixResult struct {
+	DryRun      bool        `json:"dry_run" yaml:"dry_run"`
+	Interrupted bool        `json:"interrupted" yaml:"interrupted"`
+	Fixed       int         `json:"fixed" yaml:"fixed"`
+	Skipped     int         `json:"skipped" yaml:"skipped"`
+	Failed      int         `json:"failed" yaml:"failed"`
+	Folders     []folderFix `json:"folders" yaml:"folders"` // Configured folders, or the paths given
+}
+
+// folderFix is the result of fixing one folder or path
+type folderFix struct {
+	Name    string `json:"name" yaml:"name"`
+	Path    string `json:"path" yaml:"path"`
+	Fixed   int    `json:"fixed" yaml:"fixed"`
+	Skipped int    `json:"skipped" yaml:"skipped"`
+	Failed  int    `json:"failed" yaml:"failed"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"` // The folder or path is not accessible
+
+	Paths []pathResult `json:"paths,omitempty" yaml:"paths,omitempty"` // Listed with -v and -vv
+}
+
+// add counts a folder towards the totals
+func (r *fixResult) add(folder folderFix) {
+	r.Fixed += folder.Fixed
+	r.Skipped += folder.Skipped
+	r.Failed += folder.Failed
+	r.Folders = append(r.Folders, folder)
+}
+
+// exitCode returns the exit code for the totals, see enforcementExitCode
+func (r fixResult) exitCode() int {
+	return enforcementExitCode(r.Fixed, r.Skipped, r.Failed, r.Interrupted)
+}
+
+// printFix writes the result in format, or nothing if format is empty
+func printFix(format string, result fixResult) {
+	if format == "" {
+		return
+	}
+	_ = writeOutput(os.Stdout, format, result, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FOLDER\tFIXED\tSKIPPED\tFAILED\tERROR")
+		for _, folder := range result.Folders {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", folder.Name, folder.Fixed, folder.Skipped, folder.Failed, folder.Error)
+		}
+		_ = tw.Flush()
+
+		for _, folder := range result.Folders {
+			if len(folder.Paths) > 0 {
+				fmt.Fprintf(w, "\n%s:\n", folder.Name)
+				printPaths(w, folder.Paths)
+			}
+		}
+	})
+}
+
+// newFixCollector gathers the paths fix lists at a verbosity level
+func newFixCollector(verbosity int, dryRun bool) *pathCollector {
+	fixed := "fixed"
+	if dryRun {
+		fixed = "would fix"
+	}
+	return &pathCollector{
+		verbosity: verbosity,
+		labels:    map[enforcer.Outcome]string{enforcer.Fixed: fixed, enforcer.Skipped: "skipped", enforcer.Failed: "failed"},
+	}
+}
+
+// runFix enforces permissions once on all configured folders, or on the
+// paths given with the flags instead of the configuration, and returns the
+// process exit code
+func runFix(args []string) int {
+	flags := flag.NewFlagSet("fix", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		owner      = flags.String("owner", "", "User name or UID to own the paths")
+		group      = flags.String("group", "", "Group name or GID of the paths")
+		fileMode   = flags.String("file-mode", "", "Octal permissions for files, e.g. 0644")
+		dirMode    = flags.String("dir-mode", "", "Octal permissions for directories, e.g. 0755")
+		recursive  = flags.Bool("recursive", false, "Fix everything below directories too")
+		dryRun     = flags.Bool("dry-run", false, "Log the changes without making them")
+		dangerous  = dangerousFlag(flags)
+		output     = outputFlag(flags)
+		verbosity  = verboseFlags(flags)
+	)
+	colorFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage:\n  %s fix [-config path]\n  %s fix [flags] path...\n\nFlags:\n", appName, appName)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitUsage
+	}
+
+	if flags.NArg() > 0 {
+		return fixPaths(flags.Args(), config.WatchDir{
+			Owner:     *owner,
+			Group:     *group,
+			FileMode:  *fileMode,
+			DirMode:   *dirMode,
+			Recursive: *recursive,
+		}, *dryRun, *dangerous, *output, verbosity())
+	}
+	if *owner != "" || *group != "" || *fileMode != "" || *dirMode != "" || *recursive {
+		fmt.Fprintf(os.Stderr, "%s: fix flags require paths to fix\n", appName)
+		flags.Usage()
+		return exitUsage
+	}
+
+	logger, cfg := setup(*configPath)
+	if err := checkDangerous(cfg, *dangerous); err != nil {
+		logger.Error("Refusing to fix permissions", "error", err)
+		return exitConfig
+	}
+	cfg.DryRun = cfg.DryRun || *dryRun
+	if cfg.DryRun {
+		markDryRun(logger)
+	}
+	return enforceOnce(logger, cfg, *output, verbosity())
+}
+
+// fixPaths applies the owner and modes of rule to paths without a
+// configuration file, prints the result in output, listing paths at the
+// verbosity level, and returns the process exit code. Recursive fixes of /
+// or a system directory are refused unless allowDangerous is set.
+func fixPaths(paths []string, rule config.WatchDir, dryRun, allowDangerous bool, output string, verbosity int) int {
+	if rule.Owner == "" && rule.Group == "" && rule.FileMode == "" && rule.DirMode == "" {
+		fmt.Fprintf(os.Stderr, "%s: set at least one of -owner, -group, -file-mode and -dir-mode\n", appName)
+		return exitUsage
+	}
+	if err := checkRule(&rule); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitUsage
+	}
+	if rule.Recursive && !allowDangerous {
+		for _, path := range paths {
+			if enforcer.Dangerous(path) {
+				fmt.Fprintf(os.Stderr, "%s: %s is / or a system directory; pass -allow-dangerous-paths if this is intended\n", appName, path)
+				return exitUsage
+			}
+		}
+	}
+
+	logger := newLogger()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	enf := enforcer.New(activity.NewHub(), logger)
+	if dryRun {
+		markDryRun(logger)
+		enf.SetDryRun(true)
+	}
+	showProgress(logger, enf, nil, config.LogColorAuto)
+	collector := newFixCollector(verbosity, dryRun)
+	total := fixResult{DryRun: dryRun, Folders: []folderFix{}}
+	for _, path := range paths {
+		folder := folderFix{Name: filepath.Base(path), Path: path}
+		info, err := os.Stat(path)
+		if err == nil {
+			folder.Path, err = filepath.Abs(path)
+		}
+		if err != nil {
+			logger.Error("Path is not accessible", "path", escape.Text(path), "error", err)
+			folder.Error, folder.Failed = err.Error(), 1
+			total.add(folder)
+			continue
+		}
+
+		watchDir := rule
+		watchDir.Name, watchDir.Path = folder.Name, folder.Path
+		var result status.RunResult
+		if rule.Recursive && info.IsDir() {
+			result = enf.TreeEach(ctx, folder.Path, watchDir, collector.visit)
+		} else {
+			fixed := enf.FixIn(watchDir, folder.Path, info.IsDir())
+			fixed.Outcome.AddTo(&result)
+			collector.visit(fixed)
+		}
+		folder.Fixed, folder.Skipped, folder.Failed = result.Fixed, result.Skipped, result.Failed
+		folder.Paths = collector.take()
+		total.add(folder)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	total.Interrupted = ctx.Err() != nil
+
+	logger.Info("Fix complete", "fixed", total.Fixed, "skipped", total.Skipped, "failed", total.Failed)
+	printFix(output, total)
+	return total.exitCode()
+}
+
+// checkRule validates the owner and modes given on the command line and
+// compiles the rule
+func checkRule(rule *config.WatchDir) error {
+	if rule.Owner != "" {
+		if _, err := config.ParseOwner(rule.Owner); err != nil {
+			return fmt.Errorf("invalid -owner: %w", err)
+		}
+	}
+	if rule.Group != "" {
+		if _, err := config.ParseGroup(rule.Group); err != nil {
+			return fmt.Errorf("invalid -group: %w", err)
+		}
+	}
+	for name, mode := range map[string]string{"-file-mode": rule.FileMode, "-dir-mode": rule.DirMode} {
+		if mode == "" {
+			continue
+		}
+		if value, err := strconv.ParseUint(mode, 8, 32); err != nil || value > 0o777 {
+			return fmt.Errorf("invalid %s %q, expected octal permissions such as 0644", name, mode)
+		}
+	}
+	return rule.Compile()
+}
+
+// enforceOnce makes a single enforcement pass over all configured folders,
+// running them at once within the worker budget, prints the result in
+// output unless it is empty, listing paths at the verbosity level, and
+// returns the process exit code.
+// Changes are recorded in the audit log and run the on_fixed hooks like
+// those of the daemon, except in dry runs.
+func enforceOnce(logger *log.Logger, cfg *config.Config, output string, verbosity int) int {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	hub := activity.NewHub()
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog, logger)
+		if err != nil {
+			logger.Error("Failed to open audit log", "error", err)
+			return exitFailure
+		}
+		defer func() { _ = auditLog.Close() }()
+		hub.AddRecorder(auditLog)
+	}
+	runner := hooks.New(cfg, logger)
+	hub.AddRecorder(runner)
+	enf := enforcer.New(hub, logger)
+	defer enf.CloseRemotes()
+	enf.SetDryRun(cfg.DryRun)
+	setCPULimit(logger, enf, cfg)
+	setSandbox(logger, enf, cfg.Sandbox)
+	enf.SetAllowProtected(cfg.AllowProtected)
+	enf.SetScanPriority(cfg.ScanNice, cfg.ScanIOClass)
+	setMemoryLimit(logger, enf, cfg.MemoryLimitMB)
+	showProgress(logger, enf, cfg.WatchDirs, cfg.LogColor)
+
+	folders := make([]folderFix, len(cfg.WatchDirs))
+	var running sync.WaitGroup
+	for i, watchDir := range cfg.WatchDirs {
+		running.Go(func() {
+			folders[i] = enforceFolder(ctx, logger, enf, watchDir, newFixCollector(verbosity, cfg.DryRun))
+		})
+	}
+	running.Wait()
+
+	total := fixResult{DryRun: cfg.DryRun, Folders: []folderFix{}}
+	for _, folder := range folders {
+		total.add(folder)
+	}
+	total.Interrupted = ctx.Err() != nil
+
+	hooksCtx, hooksCancel := context.WithTimeout(context.Background(), hooksDrainTimeout)
+	defer hooksCancel()
+	if err := runner.Close(hooksCtx); err != nil {
+		logger.Error("Error running hooks", "error", err)
+	}
+
+	logger.Info("Enforcement pass complete",
+		"folders", len(cfg.WatchDirs),
+		"fixed", total.Fixed,
+		"skipped", total.Skipped,
+		"failed", total.Failed,
+		"interrupted", total.Interrupted,
+	)
+	printFix(output, total)
+	return total.exitCode()
+}
+
+// enforceFolder enforces permissions on a configured folder, listing its
+// paths with collector
+func enforceFolder(ctx context.Context, logger *log.Logger, enf *enforcer.Enforcer, watchDir config.WatchDir, collector *pathCollector) folderFix {
+	folder := folderFix{Name: watchDir.Name, Path: watchDir.Path}
+	if err := checkFolder(watchDir); err != nil {
+		logger.Error("Folder is not accessible", "folder", watchDir.Name, "error", err)
+		folder.Error, folder.Failed = err.Error(), 1
+		return folder
+	}
+
+	result := enf.TreeEach(ctx, watchDir.Path, watchDir, collector.visit)
+	logger.Info("Enforced folder",
+		"folder", watchDir.Name,
+		"fixed", result.Fixed,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+		"duration", result.Duration,
+	)
+	folder.Fixed, folder.Skipped, folder.Failed = result.Fixed, result.Skipped, result.Failed
+	folder.Paths = collector.take()
+	return folder
+}
+
+// checkFolder returns why the path of watchDir cannot be accessed. Remote
+// folders are reached on their host only when enforced, which reports the
+// failure.
+func checkFolder(watchDir config.WatchDir) error {
+	if watchDir.Remote.Enabled() {
+		return nil
+	}
+	_, err := os.Stat(watchDir.Path)
+	return err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// runHealthcheck queries the readiness endpoint of a running instance and
+// returns the process exit code: 0 when ready, 1 otherwise
+func runHealthcheck(args []string) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		url        = flags.String("url", "", "URL of the readiness endpoint (default: derived from the configuration)")
+		socket     = flags.String("socket", "", "Unix socket of the running instance (default: server.socket)")
+		timeout    = flags.Duration("timeout", 5*time.Second, "Time to wait for a response")
+	)
+	_ = flags.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := checkReady(ctx, *configPath, *url, *socket); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: not ready: %v\n", appName, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// checkReady requests /readyz from the instance at url or socket, or from
+// the local server described by the configuration if neither is given
+func checkReady(ctx context.Context, configPath, url, socket string) error {
+	client := &http.Client{}
+	if url == "" {
+		var (
+			base string
+			err  error
+		)
+		if client, base, err = instanceClient(configPath, socket); err != nil {
+			return err
+		}
+		url = base + "/readyz"
+	}
+
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Reasons []string `json:"reasons"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &body) == nil && len(body.Reasons) > 0 {
+			return fmt.Errorf("%s (%s)", resp.Status, strings.Join(body.Reasons, ", "))
+		}
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// instanceClient returns a client and the base URL for the API of the
+// instance at socket, or of the local server described by the configuration
+// if no socket is given
+func instanceClient(configPath, socket string) (*http.Client, string, error) {
+	client := &http.Client{}
+
+	if socket == "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case cfg.Server.Socket != "":
+			socket = cfg.Server.Socket
+		case cfg.Server.Enabled:
+			scheme := "http"
+			if cfg.Server.TLS.Enabled() {
+				scheme = "https"
+				// The local certificate is usually self-signed or issued for
+				// a public name, and the connection never leaves the host
+				client.Transport = &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+				}
+			}
+			return client, fmt.Sprintf("%s://%s", scheme, localAddress(cfg.Server)), nil
+		default:
+			return nil, "", errors.New("server is not enabled in the configuration")
+		}
+	}
+
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return client, "http://" + appName, nil
+}
+
+// get requests url with client
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	return client.Do(req)
+}
+
+// localAddress returns the address to reach the server from the same host,
+// which is the bind address unless the server listens on all addresses
+func localAddress(server config.Server) string {
+	host := strings.Trim(server.Bind, "[]")
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(server.Port))
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/keksiqc/ownarr/internal/config"
-	"github.com/keksiqc/ownarr/internal/processor"
-	"github.com/keksiqc/ownarr/internal/watcher"
 )
 
 const (
@@ -20,115 +20,150 @@ const (
 	appVersion = "1.0.0"
 )
 
+// command is a subcommand of the command line
+type command struct {
+	name    string
+	usage   string // Arguments shown in the help
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands in the order they are shown in the help
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "[-config path] [-once] [-dry-run] [-pidfile path] [-allow-dangerous-paths]", "Watch the folders and enforce permissions (default)", runDaemon},
+		{"check", "[-config path] [-output format] [-v | -vv]", "List the changes enforcement would make, without making them", runCheck},
+		{"diff", "[-config path] [-list] [folder...]", "Show the paths whose owner or mode differ from the rules as a tree", runDiff},
+		{"fix", "[-config path] [-dry-run] [-allow-dangerous-paths] [-output format] [-v | -vv] | [-dry-run] [-allow-dangerous-paths] [-output format] [-v | -vv] [-owner user] [-group group] [-file-mode mode] [-dir-mode mode] [-recursive] path...", "Enforce permissions on all folders, or on the given paths, once and exit", runFix},
+		{"report", "[-config path] [-since duration] [-folder name] [-output format]", "Summarize the changes recorded in the audit log", runReport},
+		{"validate", "[-config path]", "Check the configuration file and exit", runValidate},
+		{"export-config", "[-config path] [-dry-run] [-output format]", "Print the effective configuration with secrets redacted", runExportConfig},
+		{"doctor", "[-config path] [-output format]", "Diagnose the system and configuration for problems", runDoctor},
+		{"status", "[-config path] [-url url] [-socket path] [-output format]", "Show the status of the running instance", runStatus},
+		{"reload", "[-config path] [-url url] [-socket path] [-pid pid | -pidfile path]", "Make the running instance reload its configuration file", runReload},
+		{"service", "install [-config path] | uninstall | start | stop [-timeout duration]", "Manage the Windows service", runService},
+		{"healthcheck", "[-config path] [-url url] [-socket path]", "Exit with 0 when the running instance is ready", runHealthcheck},
+		{"dashboard", "[-format grafana] [-output file]", "Write a monitoring dashboard for the metrics", runDashboard},
+		{"version", "", "Show version information", runVersion},
+	}
+}
+
 func main() {
-	// Parse command line flags
-	var (
-		configPath  = flag.String("config", "config.yaml", "Path to configuration file")
-		showVersion = flag.Bool("version", false, "Show version information")
-		showHelp    = flag.Bool("help", false, "Show help information")
-	)
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Printf("%s version %s\n", appName, appVersion)
-		os.Exit(0)
+	// Without a subcommand, flags belong to run as before subcommands existed
+	args := os.Args[1:]
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		printUsage(os.Stdout)
+		os.Exit(exitOK)
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
 	}
 
-	if *showHelp {
-		fmt.Printf("%s - A lightweight file watcher and permission manager\n\n", appName)
-		fmt.Println("Usage:")
-		flag.PrintDefaults()
-		os.Exit(0)
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", appName, name)
+	printUsage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+// printUsage lists the subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s - A lightweight file watcher and permission manager\n\n", appName)
+	fmt.Fprintln(w, "Usage:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n", strings.TrimSpace(appName+" "+cmd.name+" "+cmd.usage))
+		fmt.Fprintf(w, "      %s\n", cmd.summary)
 	}
+	fmt.Fprintf(w, "\nRun '%s <command> -help' for the flags of a command.\n", appName)
+}
+
+// runVersion prints the version and returns the process exit code
+func runVersion([]string) int {
+	fmt.Printf("%s version %s\n", appName, appVersion)
+	return exitOK
+}
+
+// configFlag adds the -config flag to a command. Its default is the file
+// found in the search order of config.DefaultPath.
+func configFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", config.DefaultPath(), "Path to configuration file; without it, $"+config.EnvPath+", ./config.yaml, $XDG_CONFIG_HOME/ownarr/config.yaml and /etc/ownarr/config.yaml are tried in order")
+}
 
-	// Initialize logger with default settings
+// newLogger creates the text logger written to stderr
+func newLogger() *log.Logger {
 	logger := log.NewWithOptions(os.Stderr, log.Options{
 		ReportCaller:    false,
 		ReportTimestamp: true,
 		TimeFormat:      time.RFC3339,
 		Prefix:          appName,
 	})
+	logger.SetColorProfile(colorProfile(os.Stderr, config.LogColorAuto))
+	return logger
+}
 
-	// Load configuration
-	cfg, err := config.Load(*configPath)
+// setup loads the configuration and creates a logger at its level, exiting
+// with exitConfig if either fails
+func setup(configPath string) (*log.Logger, *config.Config) {
+	logger, cfg, err := load(configPath)
 	if err != nil {
-		logger.Fatal("Failed to load configuration", "error", err)
+		logger.Error("Failed to load configuration", "error", err)
+		os.Exit(exitConfig)
 	}
+	return logger, cfg
+}
 
-	// Set log level from configuration
-	if err := setLogLevel(logger, cfg.LogLevel); err != nil {
-		logger.Fatal("Invalid log level", "level", cfg.LogLevel, "error", err)
-	}
-
-	logger.Info("Starting application",
-		"version", appVersion,
-		"config", *configPath,
-		"log_level", cfg.LogLevel,
-		"poll_interval", cfg.PollInterval,
-		"watch_dirs", len(cfg.WatchDirs),
-	)
-
-	// Create application context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// markDryRun tags every log line, so that dry runs are never mistaken for
+// real ones
+func markDryRun(logger *log.Logger) {
+	logger.SetPrefix(appName + " DRY-RUN")
+}
 
-	// Set up graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+// load loads the configuration and creates a logger at its level and with
+// its colors
+func load(configPath string) (*log.Logger, *config.Config, error) {
+	logger := newLogger()
 
-	// Initialize watcher
-	w, err := watcher.New(cfg, logger)
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		logger.Fatal("Failed to create watcher", "error", err)
-	}
-	// Watcher will be closed explicitly in shutdown sequence
-
-	// Initialize processor
-	proc := processor.New(logger)
-
-	// Start watching
-	if err := w.Start(ctx); err != nil {
-		logger.Fatal("Failed to start watcher", "error", err)
+		return logger, nil, err
 	}
 
-	// Start processing events
-	go proc.Process(ctx, w.Events(), w.Errors())
-
-	logger.Info("Application started successfully")
-
-	// Wait for shutdown signal
-	<-sigChan
-	logger.Info("Received shutdown signal, stopping...")
-
-	// Cancel context to signal all goroutines to stop
-	cancel()
-
-	// Close watcher properly
-	if err := w.Close(); err != nil {
-		logger.Error("Error during shutdown", "error", err)
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return logger, nil, err
 	}
+	logger.SetLevel(level)
+	logger.SetColorProfile(colorProfile(os.Stderr, cfg.LogColor))
+	return logger, cfg, nil
+}
 
-	// Give a moment for cleanup
-	time.Sleep(500 * time.Millisecond)
-
-	logger.Info("Application stopped")
+// interruptContext returns a context cancelled by an interrupt or SIGTERM,
+// so that one-shot commands stop between paths
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
 
-// setLogLevel sets the logger level based on the configuration
-func setLogLevel(logger *log.Logger, level string) error {
+// parseLogLevel returns the logger level named in the configuration
+func parseLogLevel(level string) (log.Level, error) {
 	switch level {
 	case "debug":
-		logger.SetLevel(log.DebugLevel)
+		return log.DebugLevel, nil
 	case "info":
-		logger.SetLevel(log.InfoLevel)
+		return log.InfoLevel, nil
 	case "warn", "warning":
-		logger.SetLevel(log.WarnLevel)
+		return log.WarnLevel, nil
 	case "error":
-		logger.SetLevel(log.ErrorLevel)
+		return log.ErrorLevel, nil
 	case "fatal", "critical":
-		logger.SetLevel(log.FatalLevel)
+		return log.FatalLevel, nil
 	default:
-		return fmt.Errorf("unknown log level: %s", level)
+		return 0, fmt.Errorf("unknown log level: %s", level)
 	}
-	return nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the commands that print results
+const (
+	outputTable = "table" // Human readable text
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFlag adds the -output flag to a command
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputTable, "Output format: table, json or yaml")
+}
+
+// checkOutput validates an output format
+func checkOutput(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, expected table, json or yaml", format)
+	}
+}
+
+// writeOutput writes v as JSON or YAML, or calls table for the text format
+func writeOutput(w io.Writer, format string, v any, table func(io.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		table(w)
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// progressWidth is the number of characters of the bar itself
+const progressWidth = 20
+
+// progressBar draws the progress of scans on the last line of a terminal.
+// Log lines written through it are printed above the bar.
+type progressBar struct {
+	out   *os.File
+	names map[string]string // Folder names by path
+
+	mu    sync.Mutex
+	scans map[string]status.Scan // Scans in progress by folder
+	line  string                 // Currently drawn line, empty if none
+}
+
+// showProgress draws the progress of the enforcer's scans on stderr if it is
+// a terminal, printing the log above the bar with the colors of the
+// log_color setting
+func showProgress(logger *log.Logger, enf *enforcer.Enforcer, watchDirs []config.WatchDir, logColor string) {
+	bar := newProgressBar(os.Stderr, watchDirs)
+	if bar == nil {
+		return
+	}
+	logger.SetOutput(bar)
+	// The bar is no terminal itself, so keep the colors of stderr
+	logger.SetColorProfile(colorProfile(os.Stderr, logColor))
+	enf.AddScanObserver(bar)
+}
+
+// newProgressBar returns a progress bar drawing on out, or nil if out is not
+// a terminal
+func newProgressBar(out *os.File, watchDirs []config.WatchDir) *progressBar {
+	info, err := out.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	names := make(map[string]string, len(watchDirs))
+	for _, watchDir := range watchDirs {
+		names[watchDir.Path] = watchDir.Name
+	}
+	return &progressBar{out: out, names: names, scans: make(map[string]status.Scan)}
+}
+
+// Write prints a log line above the bar
+func (b *progressBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+	n, err := b.out.Write(p)
+	b.draw()
+	return n, err
+}
+
+// ObserveScan redraws the bar for a scan, removing it once the scan is done.
+// Of folders scanned at once, the bar shows the one scanned longest.
+func (b *progressBar) ObserveScan(folder string, scan status.Scan, done bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if done {
+		delete(b.scans, folder)
+	} else {
+		b.scans[folder] = scan
+	}
+
+	b.clear()
+	b.line = ""
+	var shown string
+	for path, running := range b.scans {
+		if shown == "" || running.Started.Before(b.scans[shown].Started) {
+			shown = path
+		}
+	}
+	if shown != "" {
+		name := b.names[shown]
+		if name == "" {
+			name = shown
+		}
+		b.line = progressLine(name, b.scans[shown], time.Now())
+		if others := len(b.scans) - 1; others > 0 {
+			b.line += fmt.Sprintf(" (+%d more)", others)
+		}
+	}
+	b.draw()
+}
+
+// clear erases the drawn line. Callers must hold mu.
+func (b *progressBar) clear() {
+	if b.line != "" {
+		_, _ = fmt.Fprint(b.out, "\r\033[K")
+	}
+}
+
+// draw prints the current line. Callers must hold mu.
+func (b *progressBar) draw() {
+	if b.line != "" {
+		_, _ = fmt.Fprint(b.out, b.line)
+	}
+}
+
+// progressLine describes a scan in one line, with a bar and the time left
+// once the total is known
+func progressLine(name string, scan status.Scan, now time.Time) string {
+	counts := fmt.Sprintf("%d fixed, %d failed, %.0f/s", scan.Fixed, scan.Failed, scan.Rate(now))
+	if scan.Total == 0 {
+		return fmt.Sprintf("%s: %d paths, %s", name, scan.Processed, counts)
+	}
+
+	done := min(scan.Processed*progressWidth/scan.Total, progressWidth)
+	bar := strings.Repeat("#", done) + strings.Repeat("-", progressWidth-done)
+	line := fmt.Sprintf("%s [%s] %d/%d paths, %s", name, bar, scan.Processed, scan.Total, counts)
+	if eta := scan.ETA(now); eta > 0 {
+		line += ", " + eta.String() + " left"
+	}
+	return line
+}
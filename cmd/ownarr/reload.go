@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/heartbeat"
+	"github.com/keksiqc/ownarr/internal/hooks"
+	"github.com/keksiqc/ownarr/internal/mediaserver"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/notify"
+	"github.com/keksiqc/ownarr/internal/pidfile"
+	"github.com/keksiqc/ownarr/internal/processor"
+	"github.com/keksiqc/ownarr/internal/server"
+	"github.com/keksiqc/ownarr/internal/systemd"
+	"github.com/keksiqc/ownarr/internal/watcher"
+)
+
+// reloader applies configuration changes to the running components, from
+// SIGHUP or the config API
+type reloader struct {
+	mu             sync.Mutex // Serializes changes of the running configuration
+	logger         *log.Logger
+	cfg            config.Snapshot // Running configuration
+	allowDangerous bool            // Watch directories may be / or a system directory
+	watcher        *watcher.Watcher
+	enforcer       *enforcer.Enforcer
+	processor      *processor.Processor
+	server         *server.Server
+	notifier       *notify.Dispatcher
+	heartbeat      *heartbeat.Heartbeat
+	service        *systemd.Notifier
+	libraries      *mediaserver.Trigger
+	hooks          *hooks.Runner
+}
+
+// apply validates and applies a new configuration. Server listener settings
+// require a restart and are carried over from the running configuration.
+func (r *reloader) apply(cfg *config.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The caller keeps its configuration, which is changed below and then
+	// shared with every component
+	cfg = cfg.Clone()
+	running := r.cfg.Load()
+
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	if err := checkDangerous(cfg, r.allowDangerous); err != nil {
+		return err
+	}
+
+	// Listener settings are fixed while the server runs; authentication
+	// settings are checked per request and apply immediately
+	old := running.Server
+	if cfg.Server.Enabled != old.Enabled || cfg.Server.Bind != old.Bind || cfg.Server.Port != old.Port || cfg.Server.TLS != old.TLS {
+		r.logger.Warn("Server listener settings changed, restart to apply them")
+		cfg.Server.Enabled = old.Enabled
+		cfg.Server.Bind = old.Bind
+		cfg.Server.Port = old.Port
+		cfg.Server.TLS = old.TLS
+	}
+
+	if !reflect.DeepEqual(cfg.Tracing, running.Tracing) {
+		r.logger.Warn("Tracing settings changed, restart to apply them")
+		cfg.Tracing = running.Tracing
+	}
+	if cfg.AuditLog != running.AuditLog {
+		r.logger.Warn("Audit log path changed, restart to apply it")
+		cfg.AuditLog = running.AuditLog
+	}
+	if cfg.Sandbox != running.Sandbox {
+		r.logger.Warn("Sandbox setting changed, restart to apply it")
+		cfg.Sandbox = running.Sandbox
+	}
+	if !slices.Equal(cfg.AllowProtected, running.AllowProtected) {
+		r.logger.Warn("Allowed protected paths changed, restart to apply them")
+		cfg.AllowProtected = running.AllowProtected
+	}
+	if cfg.DryRun != running.DryRun {
+		r.logger.Warn("Dry run setting changed, restart to apply it")
+		cfg.DryRun = running.DryRun
+	}
+	if cfg.Workers != running.Workers {
+		r.logger.Warn("Worker budget changed, restart to apply it")
+		cfg.Workers = running.Workers
+	}
+	if cfg.CPULimit != running.CPULimit || cfg.ScanCPU != running.ScanCPU {
+		r.logger.Warn("CPU limit changed, restart to apply it")
+		cfg.CPULimit = running.CPULimit
+		cfg.ScanCPU = running.ScanCPU
+	}
+	if cfg.ScanNice != running.ScanNice || cfg.ScanIOClass != running.ScanIOClass {
+		r.logger.Warn("Scan priority changed, restart to apply it")
+		cfg.ScanNice = running.ScanNice
+		cfg.ScanIOClass = running.ScanIOClass
+	}
+	if cfg.MemoryLimitMB != running.MemoryLimitMB {
+		r.logger.Warn("Memory limit changed, restart to apply it")
+		cfg.MemoryLimitMB = running.MemoryLimitMB
+	}
+	if cfg.LogColor != running.LogColor {
+		r.logger.Warn("Log color setting changed, restart to apply it")
+		cfg.LogColor = running.LogColor
+	}
+	if cfg.Syslog != running.Syslog {
+		r.logger.Warn("Syslog settings changed, restart to apply them")
+		cfg.Syslog = running.Syslog
+	}
+	if cfg.StatsD != running.StatsD {
+		r.logger.Warn("StatsD settings changed, restart to apply them")
+		cfg.StatsD = running.StatsD
+	}
+
+	if err := r.watcher.Reload(cfg); err != nil {
+		return fmt.Errorf("failed to reload watcher: %w", err)
+	}
+
+	// Drop per-folder series of folders that are no longer watched
+	kept := make(map[string]bool, len(cfg.WatchDirs))
+	for _, watchDir := range cfg.WatchDirs {
+		kept[watchDir.Name] = true
+	}
+	for _, watchDir := range running.WatchDirs {
+		if !kept[watchDir.Name] {
+			metrics.LastSuccessfulScan.Delete(watchDir.Name)
+		}
+	}
+
+	r.logger.SetLevel(level)
+	r.notifier.SetConfig(cfg)
+	r.heartbeat.SetConfig(cfg)
+	r.service.SetConfig(cfg)
+	r.libraries.SetConfig(cfg)
+	r.hooks.SetConfig(cfg)
+	if r.server != nil {
+		r.server.SetConfig(cfg)
+	}
+	r.cfg.Store(cfg)
+	r.enforcer.RetainRemotes(cfg.WatchDirs)
+	r.enforcer.ResetIDs()
+	r.processor.Retain(cfg.WatchDirs)
+
+	r.logger.Info("Configuration reloaded",
+		"log_level", cfg.LogLevel,
+		"poll_interval", cfg.PollInterval,
+		"watch_dirs", len(cfg.WatchDirs),
+	)
+	warnUnknownIDs(r.logger, cfg)
+	return nil
+}
+
+// current returns the running configuration
+func (r *reloader) current() *config.Config {
+	return r.cfg.Load()
+}
+
+// setLogLevel changes the log level of the running configuration
+func (r *reloader) setLogLevel(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	level, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+
+	cfg := r.cfg.Load().Clone()
+	cfg.LogLevel = name
+	r.logger.SetLevel(level)
+	if r.server != nil {
+		r.server.SetConfig(cfg)
+	}
+	r.cfg.Store(cfg)
+
+	r.logger.Info("Log level changed", "log_level", name)
+	return nil
+}
+
+// toggleDebug switches between debug and info logging
+func (r *reloader) toggleDebug() {
+	level := "debug"
+	if r.cfg.Load().LogLevel == "debug" {
+		level = "info"
+	}
+
+	if err := r.setLogLevel(level); err != nil {
+		r.logger.Error("Failed to change log level", "error", err)
+	}
+}
+
+// reloadFile reloads the configuration file, keeping the running
+// configuration if it is invalid
+func (r *reloader) reloadFile(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		r.logger.Error("Failed to reload configuration", "config", path, "error", err)
+		return err
+	}
+
+	if err := r.apply(cfg); err != nil {
+		r.logger.Error("Failed to apply configuration", "config", path, "error", err)
+		return err
+	}
+	return nil
+}
+
+// runReload makes the running instance reload its configuration file and
+// returns the process exit code: 0 when the new configuration was applied,
+// 1 otherwise
+func runReload(args []string) int {
+	flags := flag.NewFlagSet("reload", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		url        = flags.String("url", "", "Base URL of the running instance (default: derived from the configuration)")
+		socket     = flags.String("socket", "", "Unix socket of the running instance (default: server.socket)")
+		pid        = flags.Int("pid", 0, "Send SIGHUP to this process instead of using the API")
+		pidPath    = flags.String("pidfile", "", "Send SIGHUP to the process in this PID file instead of using the API")
+		timeout    = flags.Duration("timeout", 30*time.Second, "Time to wait for the reload")
+	)
+	_ = flags.Parse(args)
+
+	// An invalid file would be rejected by the instance anyway, and a signal
+	// could not report it
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: configuration is invalid: %v\n", appName, err)
+		return exitConfig
+	}
+
+	if *pidPath != "" && *pid == 0 {
+		if *pid, err = pidfile.Read(*pidPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+			return exitFailure
+		}
+	}
+	if *pid > 0 {
+		if err := signalReload(*pid); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to signal process %d: %v\n", appName, *pid, err)
+			return exitFailure
+		}
+		fmt.Printf("Configuration is valid, sent SIGHUP to process %d; check its log for the result\n", *pid)
+		return exitOK
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := reloadInstance(ctx, cfg.Server, *configPath, *url, *socket); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: reload failed: %v\n", appName, err)
+		return exitFailure
+	}
+	fmt.Println("Configuration reloaded")
+	return exitOK
+}
+
+// reloadInstance asks the instance at url or socket, or the local server
+// described by the configuration, to reload its configuration file
+func reloadInstance(ctx context.Context, server config.Server, configPath, url, socket string) error {
+	client, base := &http.Client{}, strings.TrimSuffix(url, "/")
+	if base == "" {
+		var err error
+		if client, base, err = instanceClient(configPath, socket); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/v1/reload", nil)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	switch {
+	case server.APIKey != "":
+		req.Header.Set("X-Api-Key", server.APIKey)
+	case server.BasicAuth.Enabled():
+		req.SetBasicAuth(server.BasicAuth.Username, server.BasicAuth.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &body) == nil && body.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, body.Error)
+		}
+		return errors.New(resp.Status)
+	}
+	return nil
+}
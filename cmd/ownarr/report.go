@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/audit"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// reportResult is the output of the report command
+type reportResult struct {
+	Since   time.Time      `json:"since" yaml:"since"`
+	Folders []folderReport `json:"folders" yaml:"folders"`
+}
+
+// folderReport summarizes the changes recorded for one folder
+type folderReport struct {
+	Name      string `json:"name" yaml:"name"`
+	Path      string `json:"path" yaml:"path"`
+	Fixed     int    `json:"fixed" yaml:"fixed"`
+	Failed    int    `json:"failed" yaml:"failed"`
+	LastError string `json:"last_error,omitempty" yaml:"last_error,omitempty"` // Path and error of the most recent failure
+}
+
+// runReport summarizes the changes recorded in the audit log per folder and
+// returns the process exit code
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		since      = flags.Duration("since", 24*time.Hour, "How far back to report")
+		folder     = flags.String("folder", "", "Name of the only folder to report on")
+		output     = outputFlag(flags)
+	)
+	colorFlag(flags)
+	_ = flags.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitUsage
+	}
+
+	logger, cfg := setup(*configPath)
+	if cfg.AuditLog == "" {
+		logger.Error("The audit log is not enabled, set audit_log to record changes")
+		return exitConfig
+	}
+
+	watchDirs := cfg.WatchDirs
+	if *folder != "" {
+		watchDirs = nil
+		for _, watchDir := range cfg.WatchDirs {
+			if watchDir.Name == *folder {
+				watchDirs = append(watchDirs, watchDir)
+			}
+		}
+		if len(watchDirs) == 0 {
+			logger.Error("Folder not found", "folder", *folder)
+			return exitUsage
+		}
+	}
+
+	result := reportResult{Since: time.Now().Add(-*since), Folders: make([]folderReport, 0, len(watchDirs))}
+	for _, watchDir := range watchDirs {
+		report, err := summarize(cfg.AuditLog, watchDir, result.Since)
+		if err != nil {
+			logger.Error("Failed to read audit log", "error", err)
+			return exitFailure
+		}
+		result.Folders = append(result.Folders, report)
+	}
+
+	err := writeOutput(os.Stdout, *output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "Changes since %s\n\n", result.Since.Format(time.DateTime))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FOLDER\tFIXED\tFAILED\tLAST ERROR")
+		for _, report := range result.Folders {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", report.Name, report.Fixed, report.Failed, report.LastError)
+		}
+		_ = tw.Flush()
+	})
+	if err != nil {
+		logger.Error("Failed to write report", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// summarize counts the fixed and failed paths of a folder recorded since
+// start, and keeps the most recent error. Dry runs are left out.
+func summarize(path string, watchDir config.WatchDir, start time.Time) (folderReport, error) {
+	report := folderReport{Name: watchDir.Name, Path: watchDir.Path}
+	entries, err := audit.Read(path, audit.Query{Folder: watchDir.Path, Since: start})
+	if err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		if entry.DryRun {
+			continue
+		}
+		switch entry.Type {
+		case activity.TypeFixed:
+			report.Fixed++
+		case activity.TypeError:
+			if report.Failed == 0 {
+				report.LastError = escape.Text(entry.Path + ": " + entry.Error)
+			}
+			report.Failed++
+		}
+	}
+	return report, nil
+}
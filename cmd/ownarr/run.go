@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/audit"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/doctor"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/heartbeat"
+	"github.com/keksiqc/ownarr/internal/hooks"
+	"github.com/keksiqc/ownarr/internal/limits"
+	"github.com/keksiqc/ownarr/internal/mediaserver"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/notify"
+	"github.com/keksiqc/ownarr/internal/pidfile"
+	"github.com/keksiqc/ownarr/internal/processor"
+	"github.com/keksiqc/ownarr/internal/recovery"
+	"github.com/keksiqc/ownarr/internal/server"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/syslog"
+	"github.com/keksiqc/ownarr/internal/systemd"
+	"github.com/keksiqc/ownarr/internal/tracing"
+	"github.com/keksiqc/ownarr/internal/watcher"
+)
+
+const (
+	// serverDrainTimeout bounds how long shutdown waits for API requests
+	// and enforcement jobs before cancelling them
+	serverDrainTimeout = 30 * time.Second
+
+	// hooksDrainTimeout bounds how long shutdown waits for queued and
+	// running hooks before killing them
+	hooksDrainTimeout = 30 * time.Second
+
+	// notifyDrainTimeout bounds how long shutdown waits for queued
+	// notifications to be delivered
+	notifyDrainTimeout = 10 * time.Second
+)
+
+// runDaemon watches the configured folders and enforces permissions until a
+// shutdown signal arrives, or makes a single pass with -once, returning the
+// process exit code. The -version and -help flags are kept for
+// compatibility with the flag-only command line.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	var (
+		configPath  = configFlag(flags)
+		once        = flags.Bool("once", false, "Enforce permissions on all folders once and exit with the exit code of fix")
+		dryRun      = flags.Bool("dry-run", false, "Log and report changes without making them")
+		pidPath     = flags.String("pidfile", "", "Write the process ID to this file while running")
+		dangerous   = dangerousFlag(flags)
+		showVersion = flags.Bool("version", false, "Show version information")
+		showHelp    = flags.Bool("help", false, "Show help information")
+	)
+	colorFlag(flags)
+	_ = flags.Parse(args)
+
+	if *showVersion {
+		return runVersion(nil)
+	}
+	if *showHelp {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	logger, cfg := setup(*configPath)
+	if err := checkDangerous(cfg, *dangerous); err != nil {
+		logger.Error("Refusing to start", "error", err)
+		return exitConfig
+	}
+	warnUnknownIDs(logger, cfg)
+	cfg.DryRun = cfg.DryRun || *dryRun
+	if cfg.DryRun {
+		markDryRun(logger)
+	}
+
+	// Claim the PID file, refusing to start next to a running instance.
+	// Failures from here on return rather than exit, so it is removed.
+	if *pidPath != "" {
+		if err := pidfile.Write(*pidPath); err != nil {
+			logger.Error("Failed to write PID file", "error", err)
+			return exitFailure
+		}
+		defer func() {
+			if err := pidfile.Remove(*pidPath); err != nil {
+				logger.Error("Failed to remove PID file", "error", err)
+			}
+		}()
+	}
+
+	if *once {
+		return enforceOnce(logger, cfg, "", verboseOff)
+	}
+
+	// Copy logs to syslog if enabled
+	if cfg.Syslog.Enabled {
+		sink, err := syslog.Dial(cfg.Syslog)
+		if err != nil {
+			logger.Error("Failed to connect to syslog", "error", err)
+			return exitFailure
+		}
+		defer func() { _ = sink.Close() }()
+		output := io.MultiWriter(os.Stderr, sink)
+		logger.SetOutput(output)
+		logger.SetColorProfile(colorProfile(output, cfg.LogColor))
+	}
+
+	logger.Info("Starting application",
+		"version", appVersion,
+		"config", *configPath,
+		"log_level", cfg.LogLevel,
+		"dry_run", cfg.DryRun,
+		"poll_interval", cfg.PollInterval,
+		"watch_dirs", len(cfg.WatchDirs),
+	)
+
+	// Export traces if enabled
+	if cfg.Tracing.Enabled {
+		shutdownTracing := startTracing(cfg.Tracing, logger)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Error("Failed to flush traces", "error", err)
+			}
+		}()
+	}
+
+	// Create application context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up graceful shutdown and configuration reloads
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, controlSignals...)...)
+	reportStopped := serviceControl(sigChan, logger)
+
+	// Initialize watcher
+	w, err := watcher.New(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to create watcher", "error", err)
+		return exitFailure
+	}
+	// Watcher will be closed explicitly in shutdown sequence
+
+	// Initialize status tracking shared by the processor and HTTP server
+	tracker := status.NewTracker(appVersion)
+
+	// Raise a degraded condition, and with it an alert, when a component
+	// panics and restarts
+	recovery.SetReporter(func(reason string) { tracker.SetDegraded(recovery.Degraded, reason) })
+
+	// Initialize enforcement shared by the processor and HTTP server,
+	// publishing its actions for live subscribers
+	hub := activity.NewHub()
+	enf := enforcer.New(hub, logger)
+	enf.SetDryRun(cfg.DryRun)
+	setCPULimit(logger, enf, cfg)
+	setSandbox(logger, enf, cfg.Sandbox)
+	enf.SetAllowProtected(cfg.AllowProtected)
+	enf.SetScanPriority(cfg.ScanNice, cfg.ScanIOClass)
+	setMemoryLimit(logger, enf, cfg.MemoryLimitMB)
+	enf.AddScanObserver(tracker)
+
+	// Record every change in the audit log if enabled
+	var auditLog *audit.Log
+	if cfg.AuditLog != "" {
+		if auditLog, err = audit.Open(cfg.AuditLog, logger); err != nil {
+			logger.Error("Failed to open audit log", "error", err)
+			return exitFailure
+		}
+		defer func() { _ = auditLog.Close() }()
+		hub.AddRecorder(auditLog)
+		logger.Info("Recording changes in audit log", "path", cfg.AuditLog)
+	}
+
+	// Send notifications about runs, error bursts, degraded health, startup and shutdown
+	notifier := notify.New(cfg, logger)
+	hub.AddRecorder(notifier)
+	tracker.AddRunObserver(notifier)
+	tracker.AddDegradedObserver(notifier)
+
+	// Ping the heartbeat URL after each poll cycle if enabled
+	beat := heartbeat.New(cfg, logger)
+	tracker.AddRunObserver(beat)
+	if cfg.Heartbeat.URL != "" {
+		logger.Info("Sending heartbeats after each poll cycle", "fail_after", cfg.Heartbeat.FailAfter)
+	}
+
+	// Report readiness, run summaries and liveness when run by systemd
+	service := systemd.New(cfg, logger)
+	tracker.AddRunObserver(service)
+
+	// Refresh media server libraries after fixing permissions in them
+	libraries := mediaserver.New(cfg, logger)
+	hub.AddRecorder(libraries)
+	if libraries.Enabled() {
+		logger.Info("Refreshing media server libraries after fixes")
+	}
+
+	// Run the on_fixed commands of watch directories
+	runner := hooks.New(cfg, logger)
+	hub.AddRecorder(runner)
+	if runner.Enabled() {
+		logger.Info("Running hooks after fixes", "concurrency", cfg.Hooks.Concurrency, "timeout", cfg.Hooks.Timeout)
+	}
+
+	// Initialize processor
+	proc := processor.New(enf, tracker, logger)
+
+	// Start watching
+	if err := w.Start(ctx); err != nil {
+		logger.Error("Failed to start watcher", "error", err)
+		return exitFailure
+	}
+
+	// Report watch counts in metrics
+	metrics.Watches.SetFunc(func() map[string]float64 {
+		counts := make(map[string]float64)
+		for folder, count := range w.WatchCounts() {
+			counts[folder] = float64(count)
+		}
+		return counts
+	})
+
+	// Send metrics to StatsD if enabled
+	if cfg.StatsD.Enabled {
+		sink := metrics.NewStatsD(metrics.Default, metrics.StatsDOptions{
+			Address:  cfg.StatsD.Address,
+			Prefix:   cfg.StatsD.Prefix,
+			Format:   cfg.StatsD.Format,
+			Interval: time.Duration(cfg.StatsD.FlushInterval) * time.Second,
+		}, logger)
+		go func() {
+			if err := sink.Run(ctx); err != nil {
+				logger.Error("StatsD export stopped", "error", err)
+			}
+		}()
+		logger.Info("Sending metrics to StatsD", "address", cfg.StatsD.Address, "format", cfg.StatsD.Format)
+	}
+
+	// Start processing events. Processing stops with a context of its own,
+	// so that queued events are drained once everything else has stopped.
+	procCtx, stopProcessing := context.WithCancel(context.Background())
+	defer stopProcessing()
+	processed := make(chan int, 1)
+	go func() { processed <- proc.Process(procCtx, w.Events(), w.Errors()) }()
+
+	// Enforce every folder at once within the worker budget if enabled
+	var startupScan sync.WaitGroup
+	if cfg.ScanOnStart {
+		startupScan.Go(func() { scanOnStart(ctx, logger, enf, tracker, cfg.WatchDirs) })
+	}
+
+	// Configuration changes are applied through the reloader
+	reload := &reloader{logger: logger, allowDangerous: *dangerous, watcher: w, enforcer: enf, processor: proc, notifier: notifier, heartbeat: beat, service: service, libraries: libraries, hooks: runner}
+	reload.cfg.Store(cfg)
+
+	// Start HTTP server if enabled
+	var srv *server.Server
+	if cfg.Server.Enabled {
+		srv = server.New(cfg, server.Dependencies{
+			Enforcer:     enf,
+			Activity:     hub,
+			Tracker:      tracker,
+			Audit:        auditLog,
+			ApplyConfig:  reload.apply,
+			ReloadConfig: func() error { return reload.reloadFile(*configPath) },
+			SetLogLevel:  reload.setLogLevel,
+			Watches:      w.Watches,
+			Budget:       w.Budget,
+		}, logger)
+		reload.server = srv
+		if err := srv.Start(); err != nil {
+			logger.Error("Failed to start HTTP server", "error", err)
+			return exitFailure
+		}
+	}
+
+	tracker.SetReady(true)
+	service.Ready()
+	logger.Info("Application started successfully")
+	notifier.Notify(notify.Startup(appVersion, len(cfg.WatchDirs)))
+
+	// Ping the systemd watchdog from the main loop, so systemd restarts the
+	// instance if the loop hangs
+	var watchdog <-chan time.Time
+	if interval := service.WatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+		logger.Info("Pinging the systemd watchdog", "interval", interval)
+	}
+
+	// Reload on SIGHUP and toggle debug logging on SIGUSR1 until a shutdown
+	// signal arrives
+loop:
+	for {
+		select {
+		case <-watchdog:
+			service.Watchdog()
+		case sig := <-sigChan:
+			switch sig {
+			case reloadSignal:
+				logger.Info("Received SIGHUP, reloading configuration", "config", *configPath)
+				_ = reload.reloadFile(*configPath)
+			case debugSignal:
+				reload.toggleDebug()
+			default:
+				break loop
+			}
+		}
+	}
+	logger.Info("Received shutdown signal, stopping...")
+	code := exitOK
+	tracker.SetReady(false)
+	service.Stopping()
+	notifier.Notify(notify.Shutdown(appVersion))
+
+	// Let API requests and enforcement jobs finish before stopping the rest
+	if srv != nil {
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), serverDrainTimeout)
+		if err := srv.Shutdown(drainCtx); err != nil {
+			logger.Error("Error stopping HTTP server", "error", err)
+			code = exitFailure
+		}
+		drainCancel()
+	}
+
+	// Cancel context to signal all goroutines to stop
+	cancel()
+	startupScan.Wait()
+
+	// Stop accepting events, then handle the queued ones
+	if err := w.Close(); err != nil {
+		logger.Error("Error during shutdown", "error", err)
+		code = exitFailure
+	}
+	drainEvents(logger, processed, stopProcessing, time.Duration(reload.current().ShutdownWait)*time.Second)
+	enf.CloseRemotes()
+
+	// Let running library refreshes finish
+	libraries.Close()
+
+	// Finish queued and running hooks
+	hooksCtx, hooksCancel := context.WithTimeout(context.Background(), hooksDrainTimeout)
+	if err := runner.Close(hooksCtx); err != nil {
+		logger.Error("Error running hooks", "error", err)
+		code = exitFailure
+	}
+	hooksCancel()
+
+	// Deliver queued notifications
+	notifyCtx, notifyCancel := context.WithTimeout(context.Background(), notifyDrainTimeout)
+	if err := notifier.Close(notifyCtx); err != nil {
+		logger.Error("Error sending notifications", "error", err)
+		code = exitFailure
+	}
+	notifyCancel()
+
+	// Flush the audit log once the last change is recorded
+	if auditLog != nil {
+		if err := auditLog.Close(); err != nil {
+			logger.Error("Error closing audit log", "error", err)
+			code = exitFailure
+		}
+	}
+
+	logger.Info("Application stopped")
+	reportStopped(code)
+	return code
+}
+
+// drainEvents waits up to timeout for the processor to handle the queued
+// events, then abandons the rest, reporting how many were left
+func drainEvents(logger *log.Logger, processed <-chan int, stop context.CancelFunc, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var abandoned int
+	select {
+	case abandoned = <-processed:
+	case <-timer.C:
+		logger.Warn("Queued events not handled in time, abandoning them", "timeout", timeout)
+		stop()
+		abandoned = <-processed
+	}
+	if abandoned > 0 {
+		logger.Warn("Abandoned queued events on shutdown, the next scan or poll covers their paths", "events", abandoned)
+	}
+}
+
+// dangerousFlag defines the flag allowing watch directories that are / or a
+// system directory
+func dangerousFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("allow-dangerous-paths", false, "Allow watch directories that are / or a system directory")
+}
+
+// checkDangerous returns an error naming the first watch directory that is
+// / or a system directory, unless allowed for it or for all with allow
+func checkDangerous(cfg *config.Config, allow bool) error {
+	if allow {
+		return nil
+	}
+	for i, watchDir := range cfg.WatchDirs {
+		if !watchDir.AllowDangerous && enforcer.Dangerous(watchDir.Path) {
+			return &config.ValidationError{
+				Field: fmt.Sprintf("watch_dirs[%d].path", i),
+				Err:   fmt.Errorf("watch_dirs[%d] %s is / or a system directory; set allow_dangerous on it or pass -allow-dangerous-paths if this is intended", i, watchDir.Path),
+			}
+		}
+	}
+	return nil
+}
+
+// warnUnknownIDs warns of the numeric owners and groups configured that no
+// user or group of this host has, as files given them show as nobody in
+// Samba and ls, which is rarely intended
+func warnUnknownIDs(logger *log.Logger, cfg *config.Config) {
+	var unknown []string
+	for _, finding := range doctor.CheckIDs(cfg) {
+		if finding.Severity != doctor.SeverityOK {
+			unknown = append(unknown, finding.Message)
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+	logger.Warn("Configured owners or groups do not exist on this host",
+		"mismatches", strings.Join(unknown, "; "),
+		"hint", "files changed to them show as nobody in Samba and ls; check the IDs or run ownarr doctor",
+	)
+}
+
+// setSandbox confines the enforcer's changes to the watch directories if
+// sandbox is set, warning where the system does not support it
+func setSandbox(logger *log.Logger, enf *enforcer.Enforcer, sandbox bool) {
+	if !sandbox {
+		return
+	}
+	if err := enf.SetSandbox(true); err != nil {
+		logger.Warn("Sandbox not available, changes are not confined to the watch directories", "error", err)
+		return
+	}
+	logger.Debug("Confining changes to the watch directories")
+}
+
+// defaultWorkers is the worker budget without workers or a CPU limit below it
+const defaultWorkers = 4
+
+// setCPULimit sizes the worker budget to the CPU limit, from cpu_limit or
+// else the container's CPU quota, unless workers is set, and limits scans to
+// scan_cpu_percent of it
+func setCPULimit(logger *log.Logger, enf *enforcer.Enforcer, cfg *config.Config) {
+	cores := cfg.CPULimit
+	if cores == 0 {
+		cores = limits.CPUQuota()
+	}
+
+	workers := cfg.Workers
+	if workers == 0 {
+		workers = defaultWorkers
+		if cores > 0 {
+			workers = min(workers, max(int(cores), 1))
+		}
+	}
+	enf.SetWorkers(workers)
+
+	if cores == 0 {
+		return // No limit set
+	}
+	scanCores := cores * float64(cfg.ScanCPU) / 100
+	enf.SetCPULimit(scanCores)
+	logger.Info("Limiting CPU", "cores", cores, "scan_cores", scanCores, "workers", workers)
+}
+
+// setMemoryLimit sets the soft memory limit of the process and the
+// enforcer, from memory_limit_mb or else GOMEMLIMIT
+func setMemoryLimit(logger *log.Logger, enf *enforcer.Enforcer, limitMB int) {
+	limit := int64(limitMB) << 20
+	if limitMB > 0 {
+		debug.SetMemoryLimit(limit)
+	} else if limit = debug.SetMemoryLimit(-1); limit == math.MaxInt64 {
+		return // No limit set
+	}
+
+	enf.SetMemoryLimit(uint64(limit))
+	logger.Info("Limiting memory", "limit_mib", limit>>20)
+}
+
+// scanOnStart enforces all watch directories at once, recording their runs
+// like those of periodic checks
+func scanOnStart(ctx context.Context, logger *log.Logger, enf *enforcer.Enforcer, tracker *status.Tracker, watchDirs []config.WatchDir) {
+	logger.Info("Scanning folders on start", "folders", len(watchDirs))
+
+	var running sync.WaitGroup
+	for _, watchDir := range watchDirs {
+		running.Go(func() {
+			result := enf.Tree(ctx, watchDir.Path, watchDir)
+			if ctx.Err() != nil {
+				return
+			}
+			tracker.RecordRun(watchDir.Path, result)
+			metrics.ObserveScan(watchDir.Name, metrics.TriggerStartup, result)
+			logger.Info("Scanned folder on start",
+				"folder", watchDir.Name,
+				"fixed", result.Fixed,
+				"skipped", result.Skipped,
+				"failed", result.Failed,
+				"duration", result.Duration,
+			)
+		})
+	}
+	running.Wait()
+}
+
+// startTracing starts exporting traces over OTLP. The endpoint defaults to
+// the standard OTEL_EXPORTER_OTLP_ENDPOINT variable, then the local collector.
+func startTracing(cfg config.Tracing, logger *log.Logger) func(context.Context) error {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = "http://localhost:4318"
+	}
+
+	exporter := tracing.NewExporter(tracing.ExporterOptions{
+		Endpoint:       endpoint,
+		Headers:        cfg.Headers,
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: appVersion,
+	}, logger)
+
+	logger.Info("Exporting traces", "endpoint", endpoint, "sample_ratio", cfg.SampleRatio)
+	return tracing.Enable(exporter, cfg.SampleRatio)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+)
+
+// runService explains that services are managed by the init system outside
+// of Windows
+func runService([]string) int {
+	fmt.Fprintf(os.Stderr, "%s: the service command is only available on Windows; use systemd or another init system, see the README\n", appName)
+	return exitFailure
+}
+
+// serviceControl does nothing outside of Windows
+func serviceControl(chan<- os.Signal, *log.Logger) func(code int) {
+	return func(int) {}
+}
@@ -0,0 +1,223 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is the name ownarr is registered with in the service control
+// manager
+const serviceName = "ownarr"
+
+// runService installs, uninstalls, starts or stops the Windows service and
+// returns the process exit code
+func runService(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s service install|uninstall|start|stop [flags]\n", appName)
+		return exitUsage
+	}
+
+	action := args[0]
+	flags := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	var err error
+	switch action {
+	case "install":
+		configPath := configFlag(flags)
+		_ = flags.Parse(args[1:])
+		err = installService(*configPath)
+	case "uninstall":
+		_ = flags.Parse(args[1:])
+		err = uninstallService()
+	case "start":
+		_ = flags.Parse(args[1:])
+		err = startService()
+	case "stop":
+		timeout := flags.Duration("timeout", 60*time.Second, "Time to wait for the service to stop")
+		_ = flags.Parse(args[1:])
+		err = stopService(*timeout)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unknown service action %q, expected install, uninstall, start or stop\n", appName, action)
+		return exitUsage
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// installService registers this executable as a service starting with
+// Windows and running the daemon with the configuration file, restarting
+// it if it crashes
+func installService(configPath string) error {
+	// Services start in the system directory, so the paths must be absolute
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the executable: %w", err)
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return err
+	}
+	if _, _, err := load(configPath); err != nil {
+		return fmt.Errorf("%s is invalid: %w", configPath, err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: appName,
+		Description: "Enforces file ownership and permissions on media folders",
+		StartType:   mgr.StartAutomatic,
+	}, "run", "-config", configPath)
+	if err != nil {
+		return fmt.Errorf("failed to install service: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+
+	fmt.Printf("Installed service %s with %s\n", serviceName, configPath)
+	return nil
+}
+
+// uninstallService removes the service. A running service is removed once
+// it stops.
+func uninstallService() error {
+	return withService(func(s *mgr.Service) error {
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to uninstall service: %w", err)
+		}
+		fmt.Printf("Uninstalled service %s\n", serviceName)
+		return nil
+	})
+}
+
+// startService starts the installed service
+func startService() error {
+	return withService(func(s *mgr.Service) error {
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("failed to start service: %w", err)
+		}
+		fmt.Printf("Started service %s\n", serviceName)
+		return nil
+	})
+}
+
+// stopService stops the service and waits up to timeout for it to finish
+// its shutdown
+func stopService(timeout time.Duration) error {
+	return withService(func(s *mgr.Service) error {
+		status, err := s.Control(svc.Stop)
+		if err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+
+		deadline := time.Now().Add(timeout)
+		for status.State != svc.Stopped {
+			if time.Now().After(deadline) {
+				return errors.New("timed out waiting for the service to stop")
+			}
+			time.Sleep(300 * time.Millisecond)
+			if status, err = s.Query(); err != nil {
+				return fmt.Errorf("failed to query service: %w", err)
+			}
+		}
+		fmt.Printf("Stopped service %s\n", serviceName)
+		return nil
+	})
+}
+
+// withService calls fn with the installed service
+func withService(fn func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", serviceName, err)
+	}
+	defer func() { _ = s.Close() }()
+	return fn(s)
+}
+
+// scmHandler reports the daemon as running to the service control manager
+// and turns stop and shutdown requests into interrupts
+type scmHandler struct {
+	signals chan<- os.Signal
+	done    chan int // Exit code of the daemon once it has stopped
+}
+
+// Execute runs until the daemon has stopped
+func (h *scmHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				select {
+				case h.signals <- os.Interrupt:
+				default: // A shutdown is already pending
+				}
+			}
+		case code := <-h.done:
+			return code != exitOK, uint32(code)
+		}
+	}
+}
+
+// serviceControl answers the service control manager when the daemon runs
+// as a Windows service, stopping it like an interrupt on signals would. The
+// returned function reports the exit code once the daemon has stopped.
+func serviceControl(signals chan<- os.Signal, logger *log.Logger) func(code int) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return func(int) {}
+	}
+
+	handler := &scmHandler{signals: signals, done: make(chan int)}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := svc.Run(serviceName, handler); err != nil {
+			logger.Error("Failed to run as a Windows service", "error", err)
+		}
+	}()
+	logger.Info("Running as a Windows service", "service", serviceName)
+
+	return func(code int) {
+		select {
+		case handler.done <- code:
+			<-stopped
+		case <-stopped:
+		}
+	}
+}
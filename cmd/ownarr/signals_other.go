@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signals that reload the configuration and toggle debug logging
+var (
+	reloadSignal os.Signal = syscall.SIGHUP
+	debugSignal  os.Signal = syscall.SIGUSR1
+)
+
+// controlSignals are the signals the daemon acts on besides shutdown
+var controlSignals = []os.Signal{reloadSignal, debugSignal}
+
+// signalReload makes the process with the given PID reload its configuration
+func signalReload(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Windows has no signals to reload the configuration or toggle debug
+// logging; the API does both
+var (
+	reloadSignal os.Signal
+	debugSignal  os.Signal
+)
+
+// controlSignals are the signals the daemon acts on besides shutdown
+var controlSignals []os.Signal
+
+// signalReload fails, as Windows processes cannot be sent SIGHUP
+func signalReload(int) error {
+	return errors.New("signals are not supported on Windows, reload through the API instead")
+}
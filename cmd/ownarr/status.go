@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// instanceStatus is the part of the /status response shown by the status
+// command
+type instanceStatus struct {
+	Version       string   `json:"version"`
+	UptimeSeconds int64    `json:"uptime_seconds"`
+	Paused        bool     `json:"paused"`
+	DegradedFlags []string `json:"degraded_flags"`
+	Folders       []struct {
+		Name          string   `json:"name"`
+		Path          string   `json:"path"`
+		Exists        bool     `json:"exists"`
+		Paused        bool     `json:"paused"`
+		DegradedFlags []string `json:"degraded_flags"`
+		LastRun       *struct {
+			Started time.Time `json:"started"`
+			Fixed   int       `json:"fixed"`
+			Skipped int       `json:"skipped"`
+			Failed  int       `json:"failed"`
+		} `json:"last_run"`
+		Scan *struct {
+			Processed     int     `json:"processed"`
+			Fixed         int     `json:"fixed"`
+			Failed        int     `json:"failed"`
+			Total         int     `json:"total"`
+			RatePerSecond float64 `json:"rate_per_second"`
+			ETASeconds    *int64  `json:"eta_seconds"`
+		} `json:"scan"`
+	} `json:"folders"`
+}
+
+// runStatus shows the status of the running instance and returns the
+// process exit code
+func runStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	var (
+		configPath = configFlag(flags)
+		url        = flags.String("url", "", "Base URL of the running instance (default: derived from the configuration)")
+		socket     = flags.String("socket", "", "Unix socket of the running instance (default: server.socket)")
+		timeout    = flags.Duration("timeout", 5*time.Second, "Time to wait for a response")
+		output     = flags.String("output", outputTable, "Output format: table, or json or yaml for the full status")
+	)
+	_ = flags.Parse(args)
+
+	if err := checkOutput(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, base := &http.Client{}, strings.TrimSuffix(*url, "/")
+	if base == "" {
+		var err error
+		if client, base, err = instanceClient(*configPath, *socket); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+			return exitConfig
+		}
+	}
+
+	resp, err := get(ctx, client, base+"/status")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to read status: %v\n", appName, err)
+		return exitFailure
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", appName, resp.Status)
+		return exitFailure
+	}
+
+	// The formats for automation get the full status, as the server sent it
+	var (
+		full any
+		st   instanceStatus
+	)
+	if json.Unmarshal(data, &full) != nil || json.Unmarshal(data, &st) != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid status response\n", appName)
+		return exitFailure
+	}
+
+	err = writeOutput(os.Stdout, *output, full, func(w io.Writer) { printStatus(w, st) })
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", appName, err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// printStatus writes a human readable summary of the status
+func printStatus(w io.Writer, st instanceStatus) {
+	state := "running"
+	switch {
+	case st.Paused:
+		state = "paused"
+	case len(st.DegradedFlags) > 0:
+		state = "degraded (" + strings.Join(st.DegradedFlags, ", ") + ")"
+	}
+	fmt.Fprintf(w, "%s %s, %s, up %s\n\n", appName, st.Version, state, time.Duration(st.UptimeSeconds)*time.Second)
+
+	for _, folder := range st.Folders {
+		state := "watching"
+		switch {
+		case !folder.Exists:
+			state = "missing"
+		case folder.Paused:
+			state = "paused"
+		case len(folder.DegradedFlags) > 0:
+			state = "degraded (" + strings.Join(folder.DegradedFlags, ", ") + ")"
+		}
+		fmt.Fprintf(w, "%s (%s): %s\n", folder.Name, folder.Path, state)
+		if run := folder.LastRun; run != nil {
+			fmt.Fprintf(w, "  last run %s: %d fixed, %d skipped, %d failed\n",
+				run.Started.Local().Format(time.DateTime), run.Fixed, run.Skipped, run.Failed)
+		}
+		if scan := folder.Scan; scan != nil {
+			fmt.Fprintf(w, "  scanning: %d", scan.Processed)
+			if scan.Total > 0 {
+				fmt.Fprintf(w, " of %d", scan.Total)
+			}
+			fmt.Fprintf(w, " paths, %d fixed, %d failed, %.0f/s", scan.Fixed, scan.Failed, scan.RatePerSecond)
+			if scan.ETASeconds != nil {
+				fmt.Fprintf(w, ", %s left", time.Duration(*scan.ETASeconds)*time.Second)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
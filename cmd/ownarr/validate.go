@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runValidate loads the configuration file and returns the process exit
+// code, exitConfig if it is invalid
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := configFlag(flags)
+	_ = flags.Parse(args)
+
+	if _, _, err := load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s is invalid: %v\n", appName, *configPath, err)
+		return exitConfig
+	}
+
+	fmt.Printf("%s is valid\n", *configPath)
+	return exitOK
+}
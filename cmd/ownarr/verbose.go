@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// Levels of the -v and -vv flags of check and fix
+const (
+	verboseOff     = 0
+	verbosePaths   = 1 // List paths that were changed or need a change, and failures
+	verboseSkipped = 2 // List paths that were already correct too
+)
+
+// pathResult is a single path in the verbose output of check and fix
+type pathResult struct {
+	Path      string `json:"path" yaml:"path"`
+	Kind      string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Result    string `json:"result" yaml:"result"`
+	Owner     string `json:"owner,omitempty" yaml:"owner,omitempty"` // Found, as uid:gid
+	Mode      string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	WantOwner string `json:"want_owner,omitempty" yaml:"want_owner,omitempty"`
+	WantMode  string `json:"want_mode,omitempty" yaml:"want_mode,omitempty"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// verboseFlags adds the -v and -vv flags to a command, returning a function
+// that reports the level chosen once the flags are parsed
+func verboseFlags(flags *flag.FlagSet) func() int {
+	paths := flags.Bool("v", false, "List every path that violates the rules, with its current and desired owner and mode")
+	skipped := flags.Bool("vv", false, "Like -v, also listing the paths that are already correct")
+	return func() int {
+		switch {
+		case *skipped:
+			return verboseSkipped
+		case *paths:
+			return verbosePaths
+		default:
+			return verboseOff
+		}
+	}
+}
+
+// pathCollector gathers the results of the paths listed at a verbosity level,
+// naming their outcomes with labels
+type pathCollector struct {
+	verbosity int
+	labels    map[enforcer.Outcome]string
+	paths     []pathResult
+}
+
+// visit records a path result if the verbosity level lists it
+func (c *pathCollector) visit(result enforcer.PathResult) {
+	if c.verbosity == verboseOff || (result.Outcome == enforcer.Skipped && c.verbosity < verboseSkipped) {
+		return
+	}
+
+	path := pathResult{Path: escape.JSON(result.Path), Kind: result.Kind, Result: c.labels[result.Outcome]}
+	if result.Err != nil {
+		// The state may not have been read at all
+		path.Error = escape.JSON(result.Err.Error())
+	} else {
+		path.Owner, path.Mode = result.OldOwner.String(), fmt.Sprintf("%04o", result.OldMode)
+		path.WantOwner, path.WantMode = result.NewOwner.String(), fmt.Sprintf("%04o", result.NewMode)
+	}
+	c.paths = append(c.paths, path)
+}
+
+// take returns the paths gathered so far by path, as files fixed at once
+// finish out of order, and starts over
+func (c *pathCollector) take() []pathResult {
+	paths := c.paths
+	c.paths = nil
+	slices.SortFunc(paths, func(a, b pathResult) int { return strings.Compare(a.Path, b.Path) })
+	return paths
+}
+
+// printPaths lists path results, one per line, with the desired state after
+// an arrow where it differs from the current one
+func printPaths(w io.Writer, paths []pathResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, path := range paths {
+		state := path.Error
+		if path.Error == "" {
+			state = path.Owner + " " + path.Mode
+			if path.WantOwner != path.Owner || path.WantMode != path.Mode {
+				state += " -> " + path.WantOwner + " " + path.WantMode
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", path.Result, escape.Text(path.Path), escape.Text(state))
+	}
+	_ = tw.Flush()
+}
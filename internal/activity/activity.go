@@ -0,0 +1,115 @@
+package activity
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// subscriberBuffer is the number of entries buffered per subscriber before
+// further entries are dropped for it
+const subscriberBuffer = 64
+
+// Entry types
+const (
+	TypeFixed = "fixed" // Permissions were changed
+	TypeError = "error" // Enforcement failed
+)
+
+// Entry describes a single enforcement action
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Path     string    `json:"path"`
+	Kind     string    `json:"kind,omitempty"` // file or directory
+	OldMode  string    `json:"old_mode,omitempty"`
+	NewMode  string    `json:"new_mode,omitempty"`
+	OldOwner string    `json:"old_owner,omitempty"` // uid:gid, set when the owner changed
+	NewOwner string    `json:"new_owner,omitempty"`
+	DryRun   bool      `json:"dry_run,omitempty"` // The change was only reported
+	Error    string    `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the entry with a path or error that is not valid
+// UTF-8 escaped, as encoding would replace the invalid bytes
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type entry Entry
+	e.Path, e.Error = escape.JSON(e.Path), escape.JSON(e.Error)
+	return json.Marshal(entry(e))
+}
+
+// Recorder persists entries. Unlike subscribers, recorders see every entry.
+type Recorder interface {
+	Record(entry Entry)
+}
+
+// Hub fans out enforcement activity to subscribers. Publishing never blocks;
+// subscribers that fall behind miss entries.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan Entry]struct{}
+	recorders   []Recorder
+}
+
+// NewHub creates a new activity hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Publish sends an entry to all current subscribers
+func (h *Hub) Publish(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	recorders := h.recorders
+	h.mu.Unlock()
+
+	for _, recorder := range recorders {
+		recorder.Record(entry)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+// AddRecorder registers a recorder that is called synchronously for every
+// published entry
+func (h *Hub) AddRecorder(recorder Recorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recorders = append(h.recorders, recorder)
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes
+// and closes the channel.
+func (h *Hub) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, subscriberBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package activity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+
+	first, unsubscribeFirst := hub.Subscribe()
+	second, unsubscribeSecond := hub.Subscribe()
+	defer unsubscribeSecond()
+
+	hub.Publish(Entry{Type: TypeFixed, Path: "/data/a"})
+
+	for _, ch := range []<-chan Entry{first, second} {
+		entry := <-ch
+		assert.Equal(t, "/data/a", entry.Path)
+		assert.False(t, entry.Time.IsZero())
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst() // Safe to call twice
+	_, open := <-first
+	assert.False(t, open)
+
+	hub.Publish(Entry{Type: TypeError, Path: "/data/b"})
+	entry := <-second
+	assert.Equal(t, TypeError, entry.Type)
+}
+
+func TestHubDropsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+
+	ch, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for range subscriberBuffer + 10 {
+		hub.Publish(Entry{Type: TypeFixed})
+	}
+
+	require.Len(t, ch, subscriberBuffer)
+}
+
+// recorderFunc adapts a function to the Recorder interface
+type recorderFunc func(Entry)
+
+func (f recorderFunc) Record(entry Entry) { f(entry) }
+
+func TestHubRecorders(t *testing.T) {
+	hub := NewHub()
+
+	var recorded []Entry
+	hub.AddRecorder(recorderFunc(func(entry Entry) {
+		recorded = append(recorded, entry)
+	}))
+
+	// Recorders see every entry, even without subscribers
+	for range subscriberBuffer + 10 {
+		hub.Publish(Entry{Type: TypeFixed})
+	}
+
+	require.Len(t, recorded, subscriberBuffer+10)
+	assert.False(t, recorded[0].Time.IsZero())
+}
+
+func TestEntryJSON(t *testing.T) {
+	// Control characters survive encoding, invalid UTF-8 is escaped
+	for path, want := range map[string]string{
+		"/data/a\nb\x1b.mkv": "/data/a\nb\x1b.mkv",
+		"/data/caf\xe9.mkv":  `/data/caf\xe9.mkv`,
+	} {
+		data, err := json.Marshal(Entry{Type: TypeError, Path: path, Error: path + ": permission denied"})
+		require.NoError(t, err)
+		var entry Entry
+		require.NoError(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, want, entry.Path)
+		assert.Equal(t, want+": permission denied", entry.Error)
+	}
+}
@@ -0,0 +1,150 @@
+// Package audit keeps a persistent record of enforcement activity
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// maxLineSize is the longest audit line that is read back
+const maxLineSize = 64 * 1024
+
+// Log appends activity entries to a JSON Lines file
+type Log struct {
+	logger *log.Logger
+	path   string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Query selects audit entries. Zero fields match everything.
+type Query struct {
+	Path   string    // The path itself or anything below it
+	Folder string    // Watch directory path the entries must be within
+	Since  time.Time // Inclusive
+	Until  time.Time // Exclusive
+	Limit  int       // Most recent entries to return
+}
+
+// Open opens the audit log at path, creating it if needed
+func Open(path string, logger *log.Logger) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &Log{
+		logger: logger,
+		path:   path,
+		file:   file,
+	}, nil
+}
+
+// Record appends an entry to the log. Failures are logged rather than
+// returned so that enforcement never waits on a broken audit log.
+func (l *Log) Record(entry activity.Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		l.logger.Error("Failed to encode audit entry", "path", escape.Text(entry.Path), "error", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		l.logger.Error("Failed to write audit entry", "path", escape.Text(entry.Path), "error", err)
+	}
+}
+
+// Query returns the most recent entries matching q, newest first
+func (l *Log) Query(q Query) ([]activity.Entry, error) {
+	return Read(l.path, q)
+}
+
+// Read returns the most recent entries matching q from the audit log at
+// path, newest first, without opening it for writing
+func Read(path string, q Query) ([]activity.Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// Keep the last q.Limit matches in a ring buffer while reading oldest first
+	var (
+		ring  []activity.Entry
+		next  int
+		total int
+	)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for scanner.Scan() {
+		var entry activity.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line cut short by a crash must not hide the rest of the log
+			continue
+		}
+		if !q.matches(entry) {
+			continue
+		}
+
+		if q.Limit <= 0 || len(ring) < q.Limit {
+			ring = append(ring, entry)
+		} else {
+			ring[next] = entry
+			next = (next + 1) % q.Limit
+		}
+		total++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	entries := make([]activity.Entry, 0, len(ring))
+	for i := range ring {
+		entries = append(entries, ring[(next+len(ring)-1-i)%len(ring)])
+	}
+	return entries, nil
+}
+
+// Close flushes the log to disk and closes it. Entries recorded afterwards
+// are dropped.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Sync()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+// matches reports whether an entry is selected by the query
+func (q Query) matches(entry activity.Entry) bool {
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Time.Before(q.Until) {
+		return false
+	}
+	return (q.Path == "" || config.Within(entry.Path, q.Path)) && (q.Folder == "" || config.Within(entry.Path, q.Folder))
+}
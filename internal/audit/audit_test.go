@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := Open(path, log.New(os.Stderr))
+	require.NoError(t, err)
+	defer func() { _ = auditLog.Close() }()
+
+	start := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	for i, p := range []string{"/media/tv/a.mkv", "/media/tv/b.mkv", "/media/tvshows/c.mkv", "/media/tv/a.mkv"} {
+		auditLog.Record(activity.Entry{
+			Time:    start.Add(time.Duration(i) * time.Hour),
+			Type:    activity.TypeFixed,
+			Path:    p,
+			OldMode: "-rw-------",
+			NewMode: "-rw-r--r--",
+		})
+	}
+
+	// A partially written line is skipped
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString("{\"time\":\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	paths := func(q Query) []string {
+		entries, err := auditLog.Query(q)
+		require.NoError(t, err)
+		result := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			result = append(result, entry.Path)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"/media/tv/a.mkv", "/media/tvshows/c.mkv", "/media/tv/b.mkv", "/media/tv/a.mkv"}, paths(Query{}))
+	assert.Equal(t, []string{"/media/tv/a.mkv", "/media/tv/b.mkv", "/media/tv/a.mkv"}, paths(Query{Folder: "/media/tv"}))
+	assert.Equal(t, []string{"/media/tv/a.mkv", "/media/tv/a.mkv"}, paths(Query{Path: "/media/tv/a.mkv"}))
+	assert.Equal(t, []string{"/media/tv/a.mkv", "/media/tvshows/c.mkv"}, paths(Query{Limit: 2}))
+	assert.Equal(t, []string{"/media/tvshows/c.mkv", "/media/tv/b.mkv"},
+		paths(Query{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}))
+
+	entries, err := Read(path, Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/media/tv/a.mkv", entries[0].Path)
+}
+
+func TestRecordAfterClose(t *testing.T) {
+	auditLog, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"), log.New(os.Stderr))
+	require.NoError(t, err)
+	require.NoError(t, auditLog.Close())
+
+	auditLog.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/a"})
+
+	entries, err := auditLog.Query(Query{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRecordHostileNames(t *testing.T) {
+	auditLog, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"), log.New(os.Stderr))
+	require.NoError(t, err)
+	defer func() { _ = auditLog.Close() }()
+
+	auditLog.Record(activity.Entry{Type: activity.TypeFixed, Path: "/media/tv/a\nb.mkv"})
+	auditLog.Record(activity.Entry{Type: activity.TypeFixed, Path: "/media/tv/caf\xe9.mkv"})
+
+	// Each entry stays on its line, invalid bytes are kept as escapes
+	entries, err := auditLog.Query(Query{Folder: "/media/tv"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, `/media/tv/caf\xe9.mkv`, entries[0].Path)
+	assert.Equal(t, "/media/tv/a\nb.mkv", entries[1].Path)
+}
@@ -1,58 +1,665 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/user"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/keksiqc/ownarr/internal/cron"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
 )
 
+// RedactedValue replaces secrets in displayed configuration
+const RedactedValue = "REDACTED"
+
 // WatchDir represents a directory to watch for changes
 type WatchDir struct {
-	Path      string   `koanf:"path" yaml:"path"`
-	Recursive bool     `koanf:"recursive" yaml:"recursive"`
-	Exclude   []string `koanf:"exclude" yaml:"exclude"`
-	Include   []string `koanf:"include" yaml:"include"`
-	FileMode  string   `koanf:"file_mode" yaml:"file_mode"`
-	DirMode   string   `koanf:"dir_mode" yaml:"dir_mode"`
+	Name           string   `koanf:"name" yaml:"name" json:"name"`
+	Path           string   `koanf:"path" yaml:"path" json:"path"`
+	Recursive      bool     `koanf:"recursive" yaml:"recursive" json:"recursive"`
+	Exclude        []string `koanf:"exclude" yaml:"exclude" json:"exclude"`
+	Include        []string `koanf:"include" yaml:"include" json:"include"`
+	FileMode       string   `koanf:"file_mode" yaml:"file_mode" json:"file_mode"`
+	DirMode        string   `koanf:"dir_mode" yaml:"dir_mode" json:"dir_mode"`
+	Owner          string   `koanf:"owner" yaml:"owner" json:"owner"`    // User name or UID owning every path, unchanged when empty
+	Group          string   `koanf:"group" yaml:"group" json:"group"`    // Group name or GID of every path, unchanged when empty
+	Paused         bool     `koanf:"paused" yaml:"paused" json:"paused"` // Check but never change permissions
+	Notify         Notify   `koanf:"notify" yaml:"notify" json:"notify"`
+	OnFixed        []string `koanf:"on_fixed" yaml:"on_fixed" json:"on_fixed"`                      // Shell commands run after a path was fixed
+	PreCheck       string   `koanf:"pre_check" yaml:"pre_check" json:"pre_check"`                   // Shell command run before a change, vetoing it by exiting non-zero
+	AllowDangerous bool     `koanf:"allow_dangerous" yaml:"allow_dangerous" json:"allow_dangerous"` // Watch the directory though it is / or a system directory
+	Remote         Remote   `koanf:"remote" yaml:"remote" json:"remote"`                            // SSH server holding the directory, local when its host is empty
+
+	compiled *compiledWatchDir // Parsed modes and patterns, see Compile
+}
+
+// Remote represents an SSH server whose directory is enforced over SFTP
+type Remote struct {
+	Host         string `koanf:"host" yaml:"host" json:"host"`
+	Port         int    `koanf:"port" yaml:"port" json:"port"` // Defaults to 22
+	User         string `koanf:"user" yaml:"user" json:"user"`
+	Password     string `koanf:"password" yaml:"password" json:"password"`                // Logs in without identity_file, or decrypts it
+	IdentityFile string `koanf:"identity_file" yaml:"identity_file" json:"identity_file"` // Private key to log in with
+	KnownHosts   string `koanf:"known_hosts" yaml:"known_hosts" json:"known_hosts"`       // known_hosts file holding the server's host key
+}
+
+// Enabled reports whether the directory is on a remote host
+func (r Remote) Enabled() bool {
+	return r.Host != ""
+}
+
+// Address returns the host and port to connect to
+func (r Remote) Address() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// Notify represents the notification rules of a watch directory
+type Notify struct {
+	Targets   []string `koanf:"targets" yaml:"targets" json:"targets"`          // Notification targets receiving the folder's events, all when empty
+	MinFixed  int      `koanf:"min_fixed" yaml:"min_fixed" json:"min_fixed"`    // Fixed paths that make a run worth a summary, 0 to ignore
+	MinFailed int      `koanf:"min_failed" yaml:"min_failed" json:"min_failed"` // Failed paths that make a run worth a summary, 0 to ignore
+}
+
+// Summarizes reports whether a run with the given counts is sent as a
+// summary. Without thresholds, every run that fixed or failed anything is.
+func (n Notify) Summarizes(fixed, failed int) bool {
+	if n.MinFixed == 0 && n.MinFailed == 0 {
+		return fixed > 0 || failed > 0
+	}
+	return (n.MinFixed > 0 && fixed >= n.MinFixed) || (n.MinFailed > 0 && failed >= n.MinFailed)
+}
+
+// ParseOwner returns the UID of a user name or numeric ID. Numeric IDs need
+// not exist in the user database.
+func ParseOwner(owner string) (int, error) {
+	if uid, err := strconv.Atoi(owner); err == nil && uid >= 0 {
+		return uid, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// ParseGroup returns the GID of a group name or numeric ID. Numeric IDs need
+// not exist in the group database.
+func ParseGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil && gid >= 0 {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// ShouldProcess determines if a path should be processed based on include/exclude patterns
+func (w WatchDir) ShouldProcess(path string) bool {
+	filename := filepath.Base(path)
+
+	// Check exclude patterns first
+	if matchAny(w.compiledPatterns().exclude, w.Exclude, filename) {
+		return false
+	}
+
+	// If include patterns are specified, file must match at least one
+	if len(w.Include) > 0 {
+		return matchAny(w.compiledPatterns().include, w.Include, filename)
+	}
+
+	return true
+}
+
+// ShouldExclude determines if a directory should be excluded from watching
+func (w WatchDir) ShouldExclude(path string) bool {
+	return matchAny(w.compiledPatterns().exclude, w.Exclude, filepath.Base(path))
+}
+
+// compiledPatterns returns the compiled patterns, empty if not compiled
+func (w WatchDir) compiledPatterns() compiledWatchDir {
+	if w.compiled == nil {
+		return compiledWatchDir{}
+	}
+	return *w.compiled
+}
+
+// ErrNotWatched marks paths outside every watch directory
+var ErrNotWatched = errors.New("not within a watch directory")
+
+// WatchDirFor returns the watch directory containing path, the innermost of
+// nested ones, or an error wrapping ErrNotWatched if there is none.
+// /data/media2 is not within /data/media.
+func (c *Config) WatchDirFor(path string) (WatchDir, error) {
+	match, found := Innermost(c.WatchDirs, path, func(watchDir WatchDir) string { return watchDir.Path })
+	if !found {
+		return WatchDir{}, fmt.Errorf("%s: %w", path, ErrNotWatched)
+	}
+	return match, nil
+}
+
+// Innermost returns the item whose directory, given by dir, contains path
+// and is nested deepest, or false if none contains it
+func Innermost[T any](items []T, path string, dir func(T) string) (T, bool) {
+	var (
+		match T
+		found bool
+	)
+	for _, item := range items {
+		if Within(path, dir(item)) && (!found || len(dir(item)) > len(dir(match))) {
+			match, found = item, true
+		}
+	}
+	return match, found
+}
+
+// Within reports whether path is root or below it, comparing whole path
+// components, so /data/media2 is not within /data/media
+func Within(path, root string) bool {
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// TLS represents the HTTPS configuration of the server
+type TLS struct {
+	CertFile   string `koanf:"cert_file" yaml:"cert_file" json:"cert_file"`
+	KeyFile    string `koanf:"key_file" yaml:"key_file" json:"key_file"`
+	SelfSigned bool   `koanf:"self_signed" yaml:"self_signed" json:"self_signed"`
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// BasicAuth represents HTTP basic authentication credentials
+type BasicAuth struct {
+	Username string `koanf:"username" yaml:"username" json:"username"`
+	Password string `koanf:"password" yaml:"password" json:"password"`
+}
+
+// Enabled reports whether basic authentication is configured
+func (b BasicAuth) Enabled() bool {
+	return b.Username != ""
+}
+
+// ProxyAuth represents authentication by a trusted reverse proxy that passes
+// the user's identity in a header
+type ProxyAuth struct {
+	Header         string   `koanf:"header" yaml:"header" json:"header"`
+	TrustedProxies []string `koanf:"trusted_proxies" yaml:"trusted_proxies" json:"trusted_proxies"`
+}
+
+// Enabled reports whether proxy authentication is configured
+func (p ProxyAuth) Enabled() bool {
+	return len(p.TrustedProxies) > 0
+}
+
+// Trusts reports whether addr is one of the trusted proxies
+func (p ProxyAuth) Trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, proxy := range p.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefix parses an IP address or CIDR range
+func parsePrefix(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
+
+// AccessLog represents HTTP request logging
+type AccessLog struct {
+	Enabled bool   `koanf:"enabled" yaml:"enabled" json:"enabled"`
+	Format  string `koanf:"format" yaml:"format" json:"format"`
+}
+
+// Server represents the HTTP server configuration
+type Server struct {
+	Enabled   bool      `koanf:"enabled" yaml:"enabled" json:"enabled"`
+	Bind      string    `koanf:"bind" yaml:"bind" json:"bind"` // Address to listen on, empty for all interfaces
+	Port      int       `koanf:"port" yaml:"port" json:"port"`
+	Socket    string    `koanf:"socket" yaml:"socket" json:"socket"`
+	APIKey    string    `koanf:"api_key" yaml:"api_key" json:"api_key"`
+	BasicAuth BasicAuth `koanf:"basic_auth" yaml:"basic_auth" json:"basic_auth"`
+	ProxyAuth ProxyAuth `koanf:"proxy_auth" yaml:"proxy_auth" json:"proxy_auth"`
+	TLS       TLS       `koanf:"tls" yaml:"tls" json:"tls"`
+	AccessLog AccessLog `koanf:"access_log" yaml:"access_log" json:"access_log"`
+	Debug     bool      `koanf:"debug" yaml:"debug" json:"debug"` // Serve pprof and expvar under /debug/
+}
+
+// ListenAddress returns the host:port the server listens on
+func (s Server) ListenAddress() string {
+	return net.JoinHostPort(strings.Trim(s.Bind, "[]"), strconv.Itoa(s.Port))
+}
+
+// Tracing represents OpenTelemetry trace export
+type Tracing struct {
+	Enabled     bool              `koanf:"enabled" yaml:"enabled" json:"enabled"`
+	Endpoint    string            `koanf:"endpoint" yaml:"endpoint" json:"endpoint"`
+	Headers     map[string]string `koanf:"headers" yaml:"headers" json:"headers"`
+	ServiceName string            `koanf:"service_name" yaml:"service_name" json:"service_name"`
+	SampleRatio float64           `koanf:"sample_ratio" yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// StatsD represents metric export to a StatsD server
+type StatsD struct {
+	Enabled       bool   `koanf:"enabled" yaml:"enabled" json:"enabled"`
+	Address       string `koanf:"address" yaml:"address" json:"address"`
+	Prefix        string `koanf:"prefix" yaml:"prefix" json:"prefix"`
+	Format        string `koanf:"format" yaml:"format" json:"format"`
+	FlushInterval int    `koanf:"flush_interval" yaml:"flush_interval" json:"flush_interval"`
+}
+
+// Hooks represents the limits of the commands run by watch directory hooks
+type Hooks struct {
+	Concurrency int `koanf:"concurrency" yaml:"concurrency" json:"concurrency"` // Commands running at once
+	Timeout     int `koanf:"timeout" yaml:"timeout" json:"timeout"`             // Seconds before a command is killed
+}
+
+// Syslog represents log forwarding to the local syslog daemon or a remote
+// syslog server
+type Syslog struct {
+	Enabled  bool   `koanf:"enabled" yaml:"enabled" json:"enabled"`
+	Network  string `koanf:"network" yaml:"network" json:"network"`    // Empty for the local daemon, udp or tcp for a remote server
+	Address  string `koanf:"address" yaml:"address" json:"address"`    // host:port of a remote server, or the local socket path
+	Facility string `koanf:"facility" yaml:"facility" json:"facility"` // e.g. daemon or local0
+	Tag      string `koanf:"tag" yaml:"tag" json:"tag"`                // APP-NAME of the messages
+}
+
+// SyslogFacilities are the syslog facility names and their codes
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Heartbeat represents pings to a healthchecks.io compatible URL after each
+// poll cycle
+type Heartbeat struct {
+	URL       string `koanf:"url" yaml:"url" json:"url"`                      // Ping URL, empty to disable
+	FailAfter int    `koanf:"fail_after" yaml:"fail_after" json:"fail_after"` // Consecutive poll cycles with errors before pinging /fail
+	Timeout   int    `koanf:"timeout" yaml:"timeout" json:"timeout"`          // Seconds
+}
+
+// Plex represents a Plex server whose library sections are refreshed after
+// permissions were fixed in them
+type Plex struct {
+	URL      string        `koanf:"url" yaml:"url" json:"url"` // Empty to disable
+	Token    string        `koanf:"token" yaml:"token" json:"token"`
+	Delay    int           `koanf:"delay" yaml:"delay" json:"delay"` // Seconds to collect fixes before refreshing
+	Sections []PlexSection `koanf:"sections" yaml:"sections" json:"sections"`
+}
+
+// PlexSection maps a library section to the directory it holds
+type PlexSection struct {
+	ID       int    `koanf:"id" yaml:"id" json:"id"`
+	Path     string `koanf:"path" yaml:"path" json:"path"`                // Directory as seen by ownarr
+	PlexPath string `koanf:"plex_path" yaml:"plex_path" json:"plex_path"` // The same directory as seen by Plex, defaults to path
+}
+
+// Jellyfin server types
+const (
+	JellyfinServer = "jellyfin"
+	EmbyServer     = "emby"
+)
+
+// Jellyfin represents a Jellyfin or Emby server told about folders after
+// permissions were fixed in them
+type Jellyfin struct {
+	Name      string            `koanf:"name" yaml:"name" json:"name"`
+	Type      string            `koanf:"type" yaml:"type" json:"type"` // jellyfin or emby
+	URL       string            `koanf:"url" yaml:"url" json:"url"`
+	APIKey    string            `koanf:"api_key" yaml:"api_key" json:"api_key"`
+	Delay     int               `koanf:"delay" yaml:"delay" json:"delay"` // Seconds to collect fixes before refreshing, defaults to 30
+	Libraries []JellyfinLibrary `koanf:"libraries" yaml:"libraries" json:"libraries"`
+}
+
+// JellyfinLibrary maps a directory of the server's libraries to its path on
+// the server
+type JellyfinLibrary struct {
+	Path       string `koanf:"path" yaml:"path" json:"path"`                      // Directory as seen by ownarr
+	ServerPath string `koanf:"server_path" yaml:"server_path" json:"server_path"` // The same directory as seen by the server, defaults to path
+}
+
+// Notification event types
+var notificationEvents = []string{"summary", "errors", "degraded", "report", "startup", "shutdown"}
+
+// Template represents a custom title and message of an event type, as Go
+// text/template templates of the event
+type Template struct {
+	Title   string `koanf:"title" yaml:"title" json:"title"`       // Empty to keep the default title
+	Message string `koanf:"message" yaml:"message" json:"message"` // Empty to keep the default message
+}
+
+// Notification severities, lowest first
+var notificationSeverities = []string{"info", "warning", "error"}
+
+// Notifications represents alerts sent to external services
+type Notifications struct {
+	Timeout        int                 `koanf:"timeout" yaml:"timeout" json:"timeout"`                         // Seconds per delivery attempt
+	Retries        int                 `koanf:"retries" yaml:"retries" json:"retries"`                         // Further attempts after a failed delivery
+	ErrorThreshold int                 `koanf:"error_threshold" yaml:"error_threshold" json:"error_threshold"` // Errors within error_window that trigger an alert
+	ErrorWindow    int                 `koanf:"error_window" yaml:"error_window" json:"error_window"`          // Seconds
+	ReportTime     string              `koanf:"report_time" yaml:"report_time" json:"report_time"`             // Local time of the daily report as HH:MM, empty to disable
+	ReportSchedule string              `koanf:"report_schedule" yaml:"report_schedule" json:"report_schedule"` // Cron expression of the report, instead of report_time
+	Templates      map[string]Template `koanf:"templates" yaml:"templates" json:"templates"`                   // Custom titles and messages by event type
+	Webhooks       []Webhook           `koanf:"webhooks" yaml:"webhooks" json:"webhooks"`
+	Discord        []Discord           `koanf:"discord" yaml:"discord" json:"discord"`
+	Slack          []Slack             `koanf:"slack" yaml:"slack" json:"slack"`
+	Telegram       []Telegram          `koanf:"telegram" yaml:"telegram" json:"telegram"`
+	Ntfy           []Ntfy              `koanf:"ntfy" yaml:"ntfy" json:"ntfy"`
+	Gotify         []Gotify            `koanf:"gotify" yaml:"gotify" json:"gotify"`
+	Apprise        []Apprise           `koanf:"apprise" yaml:"apprise" json:"apprise"`
+	Email          []Email             `koanf:"email" yaml:"email" json:"email"`
+	Pushover       []Pushover          `koanf:"pushover" yaml:"pushover" json:"pushover"`
+	Notifiarr      []Notifiarr         `koanf:"notifiarr" yaml:"notifiarr" json:"notifiarr"`
+}
+
+// Webhook represents an HTTP endpoint receiving notification events as JSON
+type Webhook struct {
+	Name        string            `koanf:"name" yaml:"name" json:"name"`
+	URL         string            `koanf:"url" yaml:"url" json:"url"`
+	Headers     map[string]string `koanf:"headers" yaml:"headers" json:"headers"`
+	Events      []string          `koanf:"events" yaml:"events" json:"events"` // Event types to send, all when empty
+	Folders     []string          `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string            `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Discord represents a Discord channel webhook receiving events as embeds
+type Discord struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	URL         string   `koanf:"url" yaml:"url" json:"url"`
+	Username    string   `koanf:"username" yaml:"username" json:"username"` // Overrides the webhook's display name
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Slack represents a Slack incoming webhook receiving events as Block Kit
+// messages
+type Slack struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	URL         string   `koanf:"url" yaml:"url" json:"url"`
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`                // Watch directory names to send events for, all when empty
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"` // Lowest severity to send, all when empty
+}
+
+// Telegram represents a Telegram bot sending events to a chat
+type Telegram struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	Token       string   `koanf:"token" yaml:"token" json:"token"`       // Bot token from BotFather
+	ChatID      string   `koanf:"chat_id" yaml:"chat_id" json:"chat_id"` // Numeric chat ID or @channel
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
 }
 
+// Ntfy represents an ntfy topic receiving events as push notifications
+type Ntfy struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	URL         string   `koanf:"url" yaml:"url" json:"url"` // Server, defaults to https://ntfy.sh
+	Topic       string   `koanf:"topic" yaml:"topic" json:"topic"`
+	Token       string   `koanf:"token" yaml:"token" json:"token"` // Access token for protected topics
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Gotify represents a Gotify application receiving events as messages
+type Gotify struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	URL         string   `koanf:"url" yaml:"url" json:"url"`
+	Token       string   `koanf:"token" yaml:"token" json:"token"` // Application token
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Apprise represents an Apprise API server relaying events to the services
+// it supports. Either a configuration key stored on the server or the
+// Apprise URLs to notify must be set.
+type Apprise struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	URL         string   `koanf:"url" yaml:"url" json:"url"`    // Apprise API server
+	Key         string   `koanf:"key" yaml:"key" json:"key"`    // Configuration key on the server
+	URLs        []string `koanf:"urls" yaml:"urls" json:"urls"` // Apprise URLs to notify without a stored configuration
+	Tags        []string `koanf:"tags" yaml:"tags" json:"tags"` // Only notify services of the stored configuration with these tags
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Pushover represents a Pushover application sending events to a user or
+// group
+type Pushover struct {
+	Name        string         `koanf:"name" yaml:"name" json:"name"`
+	Token       string         `koanf:"token" yaml:"token" json:"token"`                // Application API token
+	User        string         `koanf:"user" yaml:"user" json:"user"`                   // User or group key
+	Device      string         `koanf:"device" yaml:"device" json:"device"`             // Only notify this device, all when empty
+	Sound       string         `koanf:"sound" yaml:"sound" json:"sound"`                // Notification sound, the user's default when empty
+	Priorities  map[string]int `koanf:"priorities" yaml:"priorities" json:"priorities"` // Pushover priority by severity, overriding the defaults
+	Events      []string       `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string       `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string         `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Notifiarr represents a Discord channel reached through Notifiarr's
+// passthrough API
+type Notifiarr struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	APIKey      string   `koanf:"api_key" yaml:"api_key" json:"api_key"`
+	ChannelID   string   `koanf:"channel_id" yaml:"channel_id" json:"channel_id"` // Discord channel ID
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Email TLS modes
+const (
+	EmailSTARTTLS = "starttls" // Upgrade a plain connection, usually on port 587
+	EmailTLS      = "tls"      // Connect over TLS, usually on port 465
+	EmailNoTLS    = "none"     // Plain connection, e.g. to a local relay
+)
+
+// Email represents an SMTP server sending events to email recipients
+type Email struct {
+	Name        string   `koanf:"name" yaml:"name" json:"name"`
+	Host        string   `koanf:"host" yaml:"host" json:"host"`
+	Port        int      `koanf:"port" yaml:"port" json:"port"` // Defaults to the usual port of the TLS mode
+	TLS         string   `koanf:"tls" yaml:"tls" json:"tls"`    // starttls, tls or none
+	Username    string   `koanf:"username" yaml:"username" json:"username"`
+	Password    string   `koanf:"password" yaml:"password" json:"password"`
+	From        string   `koanf:"from" yaml:"from" json:"from"`
+	To          []string `koanf:"to" yaml:"to" json:"to"`
+	Events      []string `koanf:"events" yaml:"events" json:"events"`
+	Folders     []string `koanf:"folders" yaml:"folders" json:"folders"`
+	MinSeverity string   `koanf:"min_severity" yaml:"min_severity" json:"min_severity"`
+}
+
+// Log color settings
+const (
+	LogColorAuto   = "auto"   // Colors on terminals, unless NO_COLOR is set
+	LogColorAlways = "always" // Colors even in files and pipes
+	LogColorNever  = "never"
+)
+
+// I/O scheduling classes of scans
+const (
+	IOClassBestEffort = "best-effort" // The lowest priority of the default class
+	IOClassIdle       = "idle"        // Only when no other process uses the disk
+)
+
 // Config represents the application configuration
 type Config struct {
-	LogLevel     string     `koanf:"log_level" yaml:"log_level"`
-	PollInterval int        `koanf:"poll_interval" yaml:"poll_interval"`
-	WatchDirs    []WatchDir `koanf:"watch_dirs" yaml:"watch_dirs"`
+	LogLevel       string        `koanf:"log_level" yaml:"log_level" json:"log_level"`
+	LogColor       string        `koanf:"log_color" yaml:"log_color" json:"log_color"` // auto, always or never
+	PollInterval   int           `koanf:"poll_interval" yaml:"poll_interval" json:"poll_interval"`
+	Debounce       int           `koanf:"debounce_ms" yaml:"debounce_ms" json:"debounce_ms"`
+	BurstPaths     int           `koanf:"burst_paths" yaml:"burst_paths" json:"burst_paths"`                // Paths changed in one directory within the burst window that escalate to a scan of it, 0 to disable
+	BurstWindow    int           `koanf:"burst_window_ms" yaml:"burst_window_ms" json:"burst_window_ms"`    // Milliseconds in which burst_paths must change, and a burst must be quiet before its scan
+	MaxWatches     int           `koanf:"max_watches" yaml:"max_watches" json:"max_watches"`                // Most directories watched, beyond which they are only polled; 0 for the system's limit
+	Workers        int           `koanf:"workers" yaml:"workers" json:"workers"`                            // Paths fixed at once across all folders, 0 to size by the CPU limit
+	CPULimit       float64       `koanf:"cpu_limit" yaml:"cpu_limit" json:"cpu_limit"`                      // Cores ownarr may use, 0 for the container's CPU quota
+	ScanCPU        int           `koanf:"scan_cpu_percent" yaml:"scan_cpu_percent" json:"scan_cpu_percent"` // Percent of the CPU limit scans may use, 100 for all of it
+	ScanOnStart    bool          `koanf:"scan_on_start" yaml:"scan_on_start" json:"scan_on_start"`          // Enforce every folder when the daemon starts
+	MemoryLimitMB  int           `koanf:"memory_limit_mb" yaml:"memory_limit_mb" json:"memory_limit_mb"`    // Soft memory limit in MiB, 0 for GOMEMLIMIT or none
+	ScanNice       int           `koanf:"scan_nice" yaml:"scan_nice" json:"scan_nice"`                      // Nice value of the threads walking scans, 0 to leave it
+	ScanIOClass    string        `koanf:"scan_io_class" yaml:"scan_io_class" json:"scan_io_class"`          // I/O class of the threads walking scans, empty to leave it
+	AuditLog       string        `koanf:"audit_log" yaml:"audit_log" json:"audit_log"`                      // JSON Lines file recording every change, empty to disable
+	Sandbox        bool          `koanf:"sandbox" yaml:"sandbox" json:"sandbox"`                            // Confine changes to the watch directories where the system supports it
+	AllowProtected []string      `koanf:"allow_protected" yaml:"allow_protected" json:"allow_protected"`    // Protected system paths enforcement may change nonetheless
+	DryRun         bool          `koanf:"dry_run" yaml:"dry_run" json:"dry_run"`                            // Log and report changes without making them
+	ShutdownWait   int           `koanf:"shutdown_timeout" yaml:"shutdown_timeout" json:"shutdown_timeout"` // Seconds shutdown waits for queued events to be handled, 0 to abandon them
+	Syslog         Syslog        `koanf:"syslog" yaml:"syslog" json:"syslog"`
+	Server         Server        `koanf:"server" yaml:"server" json:"server"`
+	Tracing        Tracing       `koanf:"tracing" yaml:"tracing" json:"tracing"`
+	StatsD         StatsD        `koanf:"statsd" yaml:"statsd" json:"statsd"`
+	Notifications  Notifications `koanf:"notifications" yaml:"notifications" json:"notifications"`
+	Heartbeat      Heartbeat     `koanf:"heartbeat" yaml:"heartbeat" json:"heartbeat"`
+	Plex           Plex          `koanf:"plex" yaml:"plex" json:"plex"`
+	Jellyfin       []Jellyfin    `koanf:"jellyfin" yaml:"jellyfin" json:"jellyfin"`
+	Hooks          Hooks         `koanf:"hooks" yaml:"hooks" json:"hooks"`
+	WatchDirs      []WatchDir    `koanf:"watch_dirs" yaml:"watch_dirs" json:"watch_dirs"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
 		LogLevel:     "info",
+		LogColor:     LogColorAuto,
 		PollInterval: 30,
-		WatchDirs:    []WatchDir{},
+		Debounce:     250,
+		BurstPaths:   100,
+		BurstWindow:  1000,
+		ScanCPU:      50,
+		Sandbox:      true,
+		ShutdownWait: 30,
+		Server: Server{
+			Enabled: false,
+			Port:    8080,
+			ProxyAuth: ProxyAuth{
+				Header: "X-Forwarded-User",
+			},
+			AccessLog: AccessLog{
+				Format: "text",
+			},
+		},
+		Tracing: Tracing{
+			ServiceName: "ownarr",
+			SampleRatio: 1,
+		},
+		Syslog: Syslog{
+			Facility: "daemon",
+			Tag:      "ownarr",
+		},
+		StatsD: StatsD{
+			Address:       "127.0.0.1:8125",
+			Prefix:        "ownarr",
+			Format:        "graphite",
+			FlushInterval: 10,
+		},
+		Notifications: Notifications{
+			Timeout:        10,
+			Retries:        3,
+			ErrorThreshold: 10,
+			ErrorWindow:    60,
+		},
+		Heartbeat: Heartbeat{
+			FailAfter: 3,
+			Timeout:   10,
+		},
+		Plex: Plex{
+			Delay: 30,
+		},
+		Hooks: Hooks{
+			Concurrency: 4,
+			Timeout:     60,
+		},
+		WatchDirs: []WatchDir{},
 	}
 }
 
-// Load loads configuration from a YAML file
-func Load(configPath string) (*Config, error) {
-	k := koanf.New(".")
+// EnvPath names the environment variable that sets the configuration file
+// when no path is given
+const EnvPath = "OWNARR_CONFIG"
 
-	// Load default configuration
-	cfg := DefaultConfig()
+// SearchPaths lists the configuration files used when no path is given and
+// EnvPath is unset, in order of preference
+func SearchPaths() []string {
+	paths := []string{"config.yaml"}
+	// $XDG_CONFIG_HOME, or ~/.config without it
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "ownarr", "config.yaml"))
+	}
+	return append(paths, "/etc/ownarr/config.yaml")
+}
+
+// DefaultPath returns the configuration file to use when no path is given:
+// the one named by EnvPath if set, otherwise the first of SearchPaths that
+// exists, or config.yaml if none does
+func DefaultPath() string {
+	if path := os.Getenv(EnvPath); path != "" {
+		return path
+	}
+	paths := SearchPaths()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return paths[0]
+}
 
+// Load loads configuration from a YAML file
+func Load(configPath string) (*Config, error) {
 	// Check if config file exists
 	if _, err := os.Stat(configPath); err != nil {
 		if os.IsNotExist(err) {
-			return cfg, fmt.Errorf("config file not found: %s", configPath)
+			return DefaultConfig(), fmt.Errorf("config file not found: %s", configPath)
 		}
-		return cfg, fmt.Errorf("error accessing config file: %w", err)
+		return DefaultConfig(), fmt.Errorf("error accessing config file: %w", err)
 	}
 
-	// Load configuration file
-	if err := k.Load(file.Provider(configPath), yaml.Parser()); err != nil {
+	return load(file.Provider(configPath))
+}
+
+// Parse loads configuration from YAML (or JSON) data
+func Parse(data []byte) (*Config, error) {
+	return load(bytesProvider(data))
+}
+
+// load reads configuration from a provider on top of the defaults and validates it
+func load(provider koanf.Provider) (*Config, error) {
+	k := koanf.New(".")
+
+	// Load default configuration
+	cfg := DefaultConfig()
+
+	// Load configuration data
+	if err := k.Load(provider, yaml.Parser()); err != nil {
 		return cfg, fmt.Errorf("error loading config file: %w", err)
 	}
 
@@ -69,24 +676,364 @@ func Load(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
-// validate performs basic configuration validation
+// Redacted returns a copy of the configuration with secrets replaced by
+// RedactedValue, safe for display
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.WatchDirs = append([]WatchDir(nil), c.WatchDirs...)
+	for i := range redacted.WatchDirs {
+		if redacted.WatchDirs[i].Remote.Password != "" {
+			redacted.WatchDirs[i].Remote.Password = RedactedValue
+		}
+	}
+	redacted.Server.ProxyAuth.TrustedProxies = append([]string(nil), c.Server.ProxyAuth.TrustedProxies...)
+	if redacted.Server.APIKey != "" {
+		redacted.Server.APIKey = RedactedValue
+	}
+	if redacted.Server.BasicAuth.Password != "" {
+		redacted.Server.BasicAuth.Password = RedactedValue
+	}
+	redacted.Tracing.Headers = redactHeaders(c.Tracing.Headers)
+	if redacted.Heartbeat.URL != "" {
+		redacted.Heartbeat.URL = RedactedValue
+	}
+	if redacted.Plex.Token != "" {
+		redacted.Plex.Token = RedactedValue
+	}
+	redacted.Plex.Sections = append([]PlexSection(nil), c.Plex.Sections...)
+	redacted.Jellyfin = append([]Jellyfin(nil), c.Jellyfin...)
+	for i := range redacted.Jellyfin {
+		redacted.Jellyfin[i].APIKey = RedactedValue
+	}
+	redacted.Notifications.Webhooks = append([]Webhook(nil), c.Notifications.Webhooks...)
+	for i := range redacted.Notifications.Webhooks {
+		redacted.Notifications.Webhooks[i].Headers = redactHeaders(c.Notifications.Webhooks[i].Headers)
+	}
+	redacted.Notifications.Discord = append([]Discord(nil), c.Notifications.Discord...)
+	for i := range redacted.Notifications.Discord {
+		redacted.Notifications.Discord[i].URL = RedactedValue
+	}
+	redacted.Notifications.Slack = append([]Slack(nil), c.Notifications.Slack...)
+	for i := range redacted.Notifications.Slack {
+		redacted.Notifications.Slack[i].URL = RedactedValue
+	}
+	redacted.Notifications.Telegram = append([]Telegram(nil), c.Notifications.Telegram...)
+	for i := range redacted.Notifications.Telegram {
+		redacted.Notifications.Telegram[i].Token = RedactedValue
+	}
+	redacted.Notifications.Pushover = append([]Pushover(nil), c.Notifications.Pushover...)
+	for i := range redacted.Notifications.Pushover {
+		redacted.Notifications.Pushover[i].Token = RedactedValue
+	}
+	redacted.Notifications.Notifiarr = append([]Notifiarr(nil), c.Notifications.Notifiarr...)
+	for i := range redacted.Notifications.Notifiarr {
+		redacted.Notifications.Notifiarr[i].APIKey = RedactedValue
+	}
+	redacted.Notifications.Gotify = append([]Gotify(nil), c.Notifications.Gotify...)
+	for i := range redacted.Notifications.Gotify {
+		redacted.Notifications.Gotify[i].Token = RedactedValue
+	}
+	redacted.Notifications.Apprise = append([]Apprise(nil), c.Notifications.Apprise...)
+	for i, apprise := range c.Notifications.Apprise {
+		redacted.Notifications.Apprise[i].URLs = make([]string, len(apprise.URLs))
+		for j := range apprise.URLs {
+			redacted.Notifications.Apprise[i].URLs[j] = RedactedValue
+		}
+	}
+	redacted.Notifications.Email = append([]Email(nil), c.Notifications.Email...)
+	for i := range redacted.Notifications.Email {
+		if redacted.Notifications.Email[i].Password != "" {
+			redacted.Notifications.Email[i].Password = RedactedValue
+		}
+	}
+	redacted.Notifications.Ntfy = append([]Ntfy(nil), c.Notifications.Ntfy...)
+	for i := range redacted.Notifications.Ntfy {
+		if redacted.Notifications.Ntfy[i].Token != "" {
+			redacted.Notifications.Ntfy[i].Token = RedactedValue
+		}
+	}
+	return &redacted
+}
+
+// redactHeaders returns a copy of HTTP headers with every value redacted
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	redacted := make(map[string]string, len(headers))
+	for key := range headers {
+		redacted[key] = RedactedValue
+	}
+	return redacted
+}
+
+// bytesProvider is a koanf provider for raw configuration data
+type bytesProvider []byte
+
+// ReadBytes returns the raw configuration data
+func (b bytesProvider) ReadBytes() ([]byte, error) {
+	return b, nil
+}
+
+// Read is not supported; the data must be parsed
+func (b bytesProvider) Read() (map[string]any, error) {
+	return nil, errors.New("bytes provider does not support Read")
+}
+
+// ValidationError is a setting that failed validation
+type ValidationError struct {
+	Field string // Setting at fault, such as poll_interval or watch_dirs[0].owner
+	Err   error  // What is wrong with it, naming the setting
+}
+
+// Error describes what is wrong with the setting
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalidf returns a ValidationError of field, formatted like fmt.Errorf
+func invalidf(field, format string, args ...any) error {
+	return &ValidationError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// validate performs basic configuration validation, returning a
+// ValidationError for the first setting at fault
 func (c *Config) validate() error {
 	if c.PollInterval <= 0 {
-		return fmt.Errorf("poll_interval must be greater than 0")
+		return invalidf("poll_interval", "poll_interval must be greater than 0")
+	}
+
+	if c.Debounce < 0 {
+		return invalidf("debounce_ms", "debounce_ms must not be negative")
+	}
+
+	if c.BurstPaths < 0 {
+		return invalidf("burst_paths", "burst_paths must not be negative")
+	}
+	if c.BurstWindow < 0 {
+		return invalidf("burst_window_ms", "burst_window_ms must not be negative")
+	}
+	if c.BurstWindow == 0 {
+		c.BurstWindow = 1000
+	}
+
+	if c.MaxWatches < 0 {
+		return invalidf("max_watches", "max_watches must not be negative")
+	}
+
+	if c.ShutdownWait < 0 {
+		return invalidf("shutdown_timeout", "shutdown_timeout must not be negative")
+	}
+
+	if c.Workers < 0 {
+		return invalidf("workers", "workers must not be negative")
+	}
+
+	if c.CPULimit < 0 {
+		return invalidf("cpu_limit", "cpu_limit must not be negative")
+	}
+	if c.ScanCPU < 0 || c.ScanCPU > 100 {
+		return invalidf("scan_cpu_percent", "scan_cpu_percent must be between 0 and 100")
+	}
+	if c.ScanCPU == 0 {
+		c.ScanCPU = 50
+	}
+
+	if c.MemoryLimitMB < 0 {
+		return invalidf("memory_limit_mb", "memory_limit_mb must not be negative")
+	}
+
+	if c.ScanNice < 0 || c.ScanNice > 19 {
+		return invalidf("scan_nice", "scan_nice must be between 0 and 19")
+	}
+	switch c.ScanIOClass {
+	case "", IOClassBestEffort, IOClassIdle:
+	default:
+		return invalidf("scan_io_class", "scan_io_class must be best-effort or idle")
+	}
+
+	for i, path := range c.AllowProtected {
+		if !filepath.IsAbs(path) {
+			return invalidf(fmt.Sprintf("allow_protected[%d]", i), "allow_protected[%d] must be an absolute path", i)
+		}
+		c.AllowProtected[i] = filepath.Clean(path)
 	}
 
+	if c.Server.Enabled && (c.Server.Port <= 0 || c.Server.Port > 65535) {
+		return invalidf("server.port", "server.port must be between 1 and 65535")
+	}
+
+	if c.Server.Bind != "" && strings.Contains(c.Server.Bind, ":") {
+		if _, err := netip.ParseAddr(strings.Trim(c.Server.Bind, "[]")); err != nil {
+			return invalidf("server.bind", "server.bind must be an IP address or host name without a port")
+		}
+	}
+
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return invalidf("server.tls.cert_file", "server.tls.cert_file and server.tls.key_file must be set together")
+	}
+
+	if c.Server.TLS.SelfSigned && c.Server.TLS.CertFile != "" {
+		return invalidf("server.tls.self_signed", "server.tls.self_signed cannot be combined with server.tls.cert_file")
+	}
+
+	if (c.Server.BasicAuth.Username == "") != (c.Server.BasicAuth.Password == "") {
+		return invalidf("server.basic_auth.username", "server.basic_auth.username and server.basic_auth.password must be set together")
+	}
+
+	if c.Server.ProxyAuth.Enabled() && c.Server.ProxyAuth.Header == "" {
+		return invalidf("server.proxy_auth.header", "server.proxy_auth.header is required with trusted_proxies")
+	}
+
+	for i, proxy := range c.Server.ProxyAuth.TrustedProxies {
+		if _, err := parsePrefix(proxy); err != nil {
+			return invalidf(fmt.Sprintf("server.proxy_auth.trusted_proxies[%d]", i), "invalid server.proxy_auth.trusted_proxies[%d]: %w", i, err)
+		}
+	}
+
+	switch c.LogColor {
+	case "", LogColorAuto, LogColorAlways, LogColorNever:
+	default:
+		return invalidf("log_color", "log_color must be auto, always or never")
+	}
+
+	switch c.Server.AccessLog.Format {
+	case "", "text", "json":
+	default:
+		return invalidf("server.access_log.format", "server.access_log.format must be text or json")
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return invalidf("tracing.sample_ratio", "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if c.Tracing.Endpoint != "" {
+		if !isHTTPURL(c.Tracing.Endpoint) {
+			return invalidf("tracing.endpoint", "tracing.endpoint must be an http or https URL")
+		}
+	}
+
+	if c.Syslog.Enabled {
+		switch c.Syslog.Network {
+		case "":
+		case "udp", "tcp":
+			if _, _, err := net.SplitHostPort(c.Syslog.Address); err != nil {
+				return invalidf("syslog.address", "syslog.address must be host:port: %w", err)
+			}
+		default:
+			return invalidf("syslog.network", "syslog.network must be empty, udp or tcp")
+		}
+		if _, ok := SyslogFacilities[c.Syslog.Facility]; !ok {
+			return invalidf("syslog.facility", "syslog.facility %q is not a syslog facility", c.Syslog.Facility)
+		}
+		// RFC 5424 limits APP-NAME to 48 printable ASCII characters
+		if c.Syslog.Tag == "" || len(c.Syslog.Tag) > 48 || strings.IndexFunc(c.Syslog.Tag, func(r rune) bool { return r <= ' ' || r > '~' }) >= 0 {
+			return invalidf("syslog.tag", "syslog.tag must be 1 to 48 printable ASCII characters without spaces")
+		}
+	}
+
+	if c.StatsD.Enabled {
+		if _, _, err := net.SplitHostPort(c.StatsD.Address); err != nil {
+			return invalidf("statsd.address", "statsd.address must be host:port: %w", err)
+		}
+		if c.StatsD.Format != "graphite" && c.StatsD.Format != "dogstatsd" {
+			return invalidf("statsd.format", "statsd.format must be graphite or dogstatsd")
+		}
+		if c.StatsD.FlushInterval <= 0 {
+			return invalidf("statsd.flush_interval", "statsd.flush_interval must be greater than 0")
+		}
+	}
+
+	if c.Heartbeat.URL != "" {
+		if !isHTTPURL(c.Heartbeat.URL) {
+			return invalidf("heartbeat.url", "heartbeat.url must be an http or https URL")
+		}
+		if c.PollInterval <= 0 {
+			return invalidf("heartbeat", "heartbeat requires poll_interval to be greater than 0")
+		}
+		if c.Heartbeat.FailAfter <= 0 {
+			return invalidf("heartbeat.fail_after", "heartbeat.fail_after must be greater than 0")
+		}
+		if c.Heartbeat.Timeout <= 0 {
+			return invalidf("heartbeat.timeout", "heartbeat.timeout must be greater than 0")
+		}
+	}
+
+	if err := c.Plex.validate(); err != nil {
+		return err
+	}
+	jellyfinNames := make(map[string]int)
+	for i := range c.Jellyfin {
+		if err := c.Jellyfin[i].validate(i, jellyfinNames); err != nil {
+			return err
+		}
+	}
+
+	if c.Hooks.Concurrency < 0 || c.Hooks.Timeout < 0 {
+		return invalidf("hooks.concurrency", "hooks.concurrency and hooks.timeout must not be negative")
+	}
+	if c.Hooks.Concurrency == 0 {
+		c.Hooks.Concurrency = 4
+	}
+	if c.Hooks.Timeout == 0 {
+		c.Hooks.Timeout = 60
+	}
+
+	names := make(map[string]int)
 	for i, watchDir := range c.WatchDirs {
 		if watchDir.Path == "" {
-			return fmt.Errorf("watch_dirs[%d].path is required", i)
+			return invalidf(fmt.Sprintf("watch_dirs[%d].path", i), "watch_dirs[%d].path is required", i)
+		}
+		if watchDir.Remote.Enabled() {
+			if err := c.WatchDirs[i].validateRemote(i); err != nil {
+				return err
+			}
+			watchDir = c.WatchDirs[i]
+		}
+		if watchDir.Owner != "" {
+			if _, err := ParseOwner(watchDir.Owner); err != nil {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].owner", i), "invalid watch_dirs[%d].owner: %w", i, err)
+			}
+		}
+		if watchDir.Group != "" {
+			if _, err := ParseGroup(watchDir.Group); err != nil {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].group", i), "invalid watch_dirs[%d].group: %w", i, err)
+			}
+		}
+		for j, command := range watchDir.OnFixed {
+			if strings.TrimSpace(command) == "" {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].on_fixed[%d]", i, j), "watch_dirs[%d].on_fixed[%d] must not be empty", i, j)
+			}
 		}
 
-		// Convert to absolute path
-		absPath, err := filepath.Abs(watchDir.Path)
-		if err != nil {
-			return fmt.Errorf("invalid path in watch_dirs[%d]: %w", i, err)
+		// Convert to absolute path; remote paths are absolute already
+		absPath := watchDir.Path
+		if !watchDir.Remote.Enabled() {
+			var err error
+			if absPath, err = filepath.Abs(watchDir.Path); err != nil {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].path", i), "invalid path in watch_dirs[%d]: %w", i, err)
+			}
 		}
 		c.WatchDirs[i].Path = absPath
 
+		// Names must be unique; unnamed directories default to their base name
+		if watchDir.Name != "" {
+			if j, ok := names[watchDir.Name]; ok {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].name", i), "watch_dirs[%d].name %q is already used by watch_dirs[%d]", i, watchDir.Name, j)
+			}
+		} else {
+			c.WatchDirs[i].Name = filepath.Base(absPath)
+			if j, ok := names[c.WatchDirs[i].Name]; ok {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].name", i), "watch_dirs[%d] defaults to name %q, which watch_dirs[%d] already uses; set a unique name", i, c.WatchDirs[i].Name, j)
+			}
+		}
+		names[c.WatchDirs[i].Name] = i
+
 		// Set default file and directory modes if not specified
 		if watchDir.FileMode == "" {
 			c.WatchDirs[i].FileMode = "0644"
@@ -94,7 +1041,431 @@ func (c *Config) validate() error {
 		if watchDir.DirMode == "" {
 			c.WatchDirs[i].DirMode = "0755"
 		}
+
+		if err := c.WatchDirs[i].Compile(); err != nil {
+			return invalidf(fmt.Sprintf("watch_dirs[%d]", i), "invalid watch_dirs[%d].%w", i, err)
+		}
+	}
+
+	folders := make([]string, len(c.WatchDirs))
+	for i, watchDir := range c.WatchDirs {
+		folders[i] = watchDir.Name
+	}
+	targets, err := c.Notifications.validate(folders)
+	if err != nil {
+		return err
+	}
+
+	for i, watchDir := range c.WatchDirs {
+		for _, name := range watchDir.Notify.Targets {
+			if _, ok := targets[name]; !ok {
+				return invalidf(fmt.Sprintf("watch_dirs[%d].notify.targets", i), "watch_dirs[%d].notify.targets: unknown notification target %q", i, name)
+			}
+		}
+		if watchDir.Notify.MinFixed < 0 || watchDir.Notify.MinFailed < 0 {
+			return invalidf(fmt.Sprintf("watch_dirs[%d].notify", i), "watch_dirs[%d].notify thresholds must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// validateRemote checks the remote host of the i-th watch directory and
+// applies its defaults. Names of owners and groups would be looked up on
+// this host rather than the remote one, so only IDs are accepted.
+func (w *WatchDir) validateRemote(i int) error {
+	field := func(name string) string { return fmt.Sprintf("watch_dirs[%d].%s", i, name) }
+
+	if !path.IsAbs(w.Path) {
+		return invalidf(field("path"), "watch_dirs[%d].path must be absolute on the remote host", i)
+	}
+	w.Path = path.Clean(w.Path)
+
+	if w.Remote.Port == 0 {
+		w.Remote.Port = 22
+	}
+	if w.Remote.Port < 0 || w.Remote.Port > 65535 {
+		return invalidf(field("remote.port"), "watch_dirs[%d].remote.port must be between 1 and 65535", i)
+	}
+	if w.Remote.User == "" {
+		return invalidf(field("remote.user"), "watch_dirs[%d].remote.user is required", i)
+	}
+	if w.Remote.Password == "" && w.Remote.IdentityFile == "" {
+		return invalidf(field("remote"), "watch_dirs[%d].remote needs an identity_file or a password", i)
+	}
+	if w.Remote.KnownHosts == "" {
+		return invalidf(field("remote.known_hosts"), "watch_dirs[%d].remote.known_hosts is required to verify the host", i)
+	}
+
+	if _, err := strconv.Atoi(w.Owner); w.Owner != "" && err != nil {
+		return invalidf(field("owner"), "watch_dirs[%d].owner must be a UID for remote folders", i)
+	}
+	if _, err := strconv.Atoi(w.Group); w.Group != "" && err != nil {
+		return invalidf(field("group"), "watch_dirs[%d].group must be a GID for remote folders", i)
+	}
+	return nil
+}
+
+// Enabled reports whether any notification target is configured
+func (n Notifications) Enabled() bool {
+	return len(n.Webhooks) > 0 || len(n.Discord) > 0 || len(n.Slack) > 0 || len(n.Telegram) > 0 || len(n.Ntfy) > 0 || len(n.Gotify) > 0 || len(n.Apprise) > 0 || len(n.Email) > 0 || len(n.Pushover) > 0 || len(n.Notifiarr) > 0
+}
+
+// validate checks the notification settings against the watch directory
+// names, names unnamed targets and returns the target names
+func (n *Notifications) validate(folders []string) (map[string]string, error) {
+	if !n.Enabled() {
+		return nil, nil
+	}
+
+	if n.Timeout <= 0 {
+		return nil, invalidf("notifications.timeout", "notifications.timeout must be greater than 0")
+	}
+	if n.Retries < 0 {
+		return nil, invalidf("notifications.retries", "notifications.retries must not be negative")
+	}
+	if n.ErrorThreshold <= 0 {
+		return nil, invalidf("notifications.error_threshold", "notifications.error_threshold must be greater than 0")
+	}
+	if n.ErrorWindow <= 0 {
+		return nil, invalidf("notifications.error_window", "notifications.error_window must be greater than 0")
+	}
+	if n.ReportTime != "" {
+		if _, err := time.Parse("15:04", n.ReportTime); err != nil {
+			return nil, invalidf("notifications.report_time", "notifications.report_time must be a time of day as HH:MM")
+		}
+	}
+	if n.ReportSchedule != "" {
+		if n.ReportTime != "" {
+			return nil, invalidf("notifications.report_schedule", "notifications.report_schedule cannot be combined with report_time")
+		}
+		if _, err := cron.Parse(n.ReportSchedule); err != nil {
+			return nil, invalidf("notifications.report_schedule", "notifications.report_schedule: %w", err)
+		}
+	}
+
+	for event, tmpl := range n.Templates {
+		if !slices.Contains(notificationEvents, event) {
+			return nil, invalidf("notifications.templates", "notifications.templates: unknown event %q, must be one of %s", event, strings.Join(notificationEvents, ", "))
+		}
+		if _, err := template.New("title").Parse(tmpl.Title); err != nil {
+			return nil, invalidf("notifications.templates."+event+".title", "notifications.templates.%s.title: %w", event, err)
+		}
+		if _, err := template.New("message").Parse(tmpl.Message); err != nil {
+			return nil, invalidf("notifications.templates."+event+".message", "notifications.templates.%s.message: %w", event, err)
+		}
+	}
+
+	names := make(map[string]string)
+	for i := range n.Webhooks {
+		webhook := &n.Webhooks[i]
+		field := fmt.Sprintf("notifications.webhooks[%d]", i)
+		if err := checkTarget(names, field, "webhook", i, &webhook.Name, webhook.Events); err != nil {
+			return nil, err
+		}
+		if !isHTTPURL(webhook.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if err := checkFilter(field, webhook.Folders, webhook.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Discord {
+		discord := &n.Discord[i]
+		field := fmt.Sprintf("notifications.discord[%d]", i)
+		if err := checkTarget(names, field, "discord", i, &discord.Name, discord.Events); err != nil {
+			return nil, err
+		}
+		if !isHTTPURL(discord.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if err := checkFilter(field, discord.Folders, discord.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Slack {
+		slack := &n.Slack[i]
+		field := fmt.Sprintf("notifications.slack[%d]", i)
+		if err := checkTarget(names, field, "slack", i, &slack.Name, slack.Events); err != nil {
+			return nil, err
+		}
+		if !isHTTPURL(slack.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if err := checkFilter(field, slack.Folders, slack.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Telegram {
+		telegram := &n.Telegram[i]
+		field := fmt.Sprintf("notifications.telegram[%d]", i)
+		if err := checkTarget(names, field, "telegram", i, &telegram.Name, telegram.Events); err != nil {
+			return nil, err
+		}
+		if telegram.Token == "" || telegram.ChatID == "" {
+			return nil, invalidf(field+".token", "%s.token and %s.chat_id are required", field, field)
+		}
+		if err := checkFilter(field, telegram.Folders, telegram.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Ntfy {
+		ntfy := &n.Ntfy[i]
+		field := fmt.Sprintf("notifications.ntfy[%d]", i)
+		if err := checkTarget(names, field, "ntfy", i, &ntfy.Name, ntfy.Events); err != nil {
+			return nil, err
+		}
+		if ntfy.URL == "" {
+			ntfy.URL = "https://ntfy.sh"
+		}
+		if !isHTTPURL(ntfy.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if ntfy.Topic == "" {
+			return nil, invalidf(field+".topic", "%s.topic is required", field)
+		}
+		if err := checkFilter(field, ntfy.Folders, ntfy.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Gotify {
+		gotify := &n.Gotify[i]
+		field := fmt.Sprintf("notifications.gotify[%d]", i)
+		if err := checkTarget(names, field, "gotify", i, &gotify.Name, gotify.Events); err != nil {
+			return nil, err
+		}
+		if !isHTTPURL(gotify.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if gotify.Token == "" {
+			return nil, invalidf(field+".token", "%s.token is required", field)
+		}
+		if err := checkFilter(field, gotify.Folders, gotify.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Apprise {
+		apprise := &n.Apprise[i]
+		field := fmt.Sprintf("notifications.apprise[%d]", i)
+		if err := checkTarget(names, field, "apprise", i, &apprise.Name, apprise.Events); err != nil {
+			return nil, err
+		}
+		if !isHTTPURL(apprise.URL) {
+			return nil, invalidf(field+".url", "%s.url must be an http or https URL", field)
+		}
+		if (apprise.Key == "") == (len(apprise.URLs) == 0) {
+			return nil, invalidf(field, "%s: set either key or urls", field)
+		}
+		if len(apprise.Tags) > 0 && apprise.Key == "" {
+			return nil, invalidf(field+".tags", "%s.tags require a key", field)
+		}
+		if err := checkFilter(field, apprise.Folders, apprise.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Email {
+		email := &n.Email[i]
+		field := fmt.Sprintf("notifications.email[%d]", i)
+		if err := checkTarget(names, field, "email", i, &email.Name, email.Events); err != nil {
+			return nil, err
+		}
+		if email.Host == "" {
+			return nil, invalidf(field+".host", "%s.host is required", field)
+		}
+		if email.TLS == "" {
+			email.TLS = EmailSTARTTLS
+		}
+		if email.Port == 0 {
+			email.Port = map[string]int{EmailSTARTTLS: 587, EmailTLS: 465, EmailNoTLS: 25}[email.TLS]
+		}
+		if email.Port <= 0 || email.Port > 65535 {
+			return nil, invalidf(field+".port", "%s.port must be between 1 and 65535", field)
+		}
+		switch email.TLS {
+		case EmailSTARTTLS, EmailTLS, EmailNoTLS:
+		default:
+			return nil, invalidf(field+".tls", "%s.tls must be starttls, tls or none", field)
+		}
+		if (email.Username == "") != (email.Password == "") {
+			return nil, invalidf(field+".username", "%s.username and %s.password must be set together", field, field)
+		}
+		if _, err := mail.ParseAddress(email.From); err != nil {
+			return nil, invalidf(field+".from", "invalid %s.from: %w", field, err)
+		}
+		if len(email.To) == 0 {
+			return nil, invalidf(field+".to", "%s.to requires at least one recipient", field)
+		}
+		for _, to := range email.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return nil, invalidf(field+".to", "invalid %s.to: %w", field, err)
+			}
+		}
+		if err := checkFilter(field, email.Folders, email.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range n.Pushover {
+		pushover := &n.Pushover[i]
+		field := fmt.Sprintf("notifications.pushover[%d]", i)
+		if err := checkTarget(names, field, "pushover", i, &pushover.Name, pushover.Events); err != nil {
+			return nil, err
+		}
+		if pushover.Token == "" || pushover.User == "" {
+			return nil, invalidf(field+".token", "%s.token and %s.user are required", field, field)
+		}
+		for severity, priority := range pushover.Priorities {
+			if !slices.Contains(notificationSeverities, severity) {
+				return nil, invalidf(field+".priorities", "%s.priorities has unknown severity %q", field, severity)
+			}
+			if priority < -2 || priority > 2 {
+				return nil, invalidf(field+".priorities", "%s.priorities.%s must be between -2 and 2", field, severity)
+			}
+		}
+		if err := checkFilter(field, pushover.Folders, pushover.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+	for i := range n.Notifiarr {
+		notifiarr := &n.Notifiarr[i]
+		field := fmt.Sprintf("notifications.notifiarr[%d]", i)
+		if err := checkTarget(names, field, "notifiarr", i, &notifiarr.Name, notifiarr.Events); err != nil {
+			return nil, err
+		}
+		if notifiarr.APIKey == "" {
+			return nil, invalidf(field+".api_key", "%s.api_key is required", field)
+		}
+		if _, err := strconv.ParseUint(notifiarr.ChannelID, 10, 64); err != nil {
+			return nil, invalidf(field+".channel_id", "%s.channel_id must be a Discord channel ID", field)
+		}
+		if err := checkFilter(field, notifiarr.Folders, notifiarr.MinSeverity, folders); err != nil {
+			return nil, err
+		}
+	}
+
+	return names, nil
+}
+
+// validate checks the Plex settings and sets defaults
+func (p *Plex) validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	if !isHTTPURL(p.URL) {
+		return invalidf("plex.url", "plex.url must be an http or https URL")
+	}
+	if p.Token == "" {
+		return invalidf("plex.token", "plex.token is required")
+	}
+	if p.Delay < 0 {
+		return invalidf("plex.delay", "plex.delay must not be negative")
+	}
+	if len(p.Sections) == 0 {
+		return invalidf("plex.sections", "plex.sections requires at least one section")
+	}
+	for i := range p.Sections {
+		section := &p.Sections[i]
+		if section.ID <= 0 {
+			return invalidf(fmt.Sprintf("plex.sections[%d].id", i), "plex.sections[%d].id must be greater than 0", i)
+		}
+		if !filepath.IsAbs(section.Path) {
+			return invalidf(fmt.Sprintf("plex.sections[%d].path", i), "plex.sections[%d].path must be an absolute path", i)
+		}
+		section.Path = filepath.Clean(section.Path)
+		if section.PlexPath == "" {
+			section.PlexPath = section.Path
+		}
+	}
+	return nil
+}
+
+// validate checks the settings of the i-th Jellyfin server and sets
+// defaults. names maps the names taken so far to their index.
+func (j *Jellyfin) validate(i int, names map[string]int) error {
+	field := fmt.Sprintf("jellyfin[%d]", i)
+	if j.Type == "" {
+		j.Type = JellyfinServer
+	}
+	if j.Type != JellyfinServer && j.Type != EmbyServer {
+		return invalidf(field+".type", "%s.type must be jellyfin or emby", field)
 	}
+	if j.Name == "" {
+		j.Name = fmt.Sprintf("%s-%d", j.Type, i+1)
+	}
+	if other, ok := names[j.Name]; ok {
+		return invalidf(field+".name", "%s.name %q is already used by jellyfin[%d]", field, j.Name, other)
+	}
+	names[j.Name] = i
+
+	if !isHTTPURL(j.URL) {
+		return invalidf(field+".url", "%s.url must be an http or https URL", field)
+	}
+	if j.APIKey == "" {
+		return invalidf(field+".api_key", "%s.api_key is required", field)
+	}
+	if j.Delay < 0 {
+		return invalidf(field+".delay", "%s.delay must not be negative", field)
+	}
+	if j.Delay == 0 {
+		j.Delay = 30
+	}
+	if len(j.Libraries) == 0 {
+		return invalidf(field+".libraries", "%s.libraries requires at least one library", field)
+	}
+	for k := range j.Libraries {
+		library := &j.Libraries[k]
+		if !filepath.IsAbs(library.Path) {
+			return invalidf(fmt.Sprintf("%s.libraries[%d].path", field, k), "%s.libraries[%d].path must be an absolute path", field, k)
+		}
+		library.Path = filepath.Clean(library.Path)
+		if library.ServerPath == "" {
+			library.ServerPath = library.Path
+		}
+	}
+	return nil
+}
 
+// checkFilter checks that a notification target selects known folders and a
+// known severity
+func checkFilter(field string, selected []string, minSeverity string, folders []string) error {
+	for _, folder := range selected {
+		if !slices.Contains(folders, folder) {
+			return invalidf(field+".folders", "%s.folders: unknown watch directory %q", field, folder)
+		}
+	}
+	if minSeverity != "" && !slices.Contains(notificationSeverities, minSeverity) {
+		return invalidf(field+".min_severity", "%s.min_severity must be one of %s", field, strings.Join(notificationSeverities, ", "))
+	}
 	return nil
 }
+
+// checkTarget names an unnamed notification target after its kind and
+// position, and checks that its name is unique and its events are known
+func checkTarget(names map[string]string, field, kind string, i int, name *string, events []string) error {
+	if *name == "" {
+		*name = fmt.Sprintf("%s-%d", kind, i+1)
+	}
+	if other, ok := names[*name]; ok {
+		return invalidf(field+".name", "%s.name %q is already used by %s", field, *name, other)
+	}
+	names[*name] = field
+
+	for _, event := range events {
+		if !slices.Contains(notificationEvents, event) {
+			return invalidf(field+".events", "%s.events: unknown event %q, must be one of %s", field, event, strings.Join(notificationEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
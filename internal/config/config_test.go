@@ -1,7 +1,9 @@
 package config
 
 import (
+	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,6 +15,7 @@ func TestDefaultConfig(t *testing.T) {
 
 	assert.Equal(t, "info", cfg.LogLevel)
 	assert.Equal(t, 30, cfg.PollInterval)
+	assert.Equal(t, 250, cfg.Debounce)
 	assert.Empty(t, cfg.WatchDirs)
 }
 
@@ -38,6 +41,141 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "numeric owner and group",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs:    []WatchDir{{Path: "/tmp", Owner: "1000", Group: "1000"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown owner",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs:    []WatchDir{{Path: "/tmp", Owner: "no-such-user-ownarr"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown group",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs:    []WatchDir{{Path: "/tmp", Group: "no-such-group-ownarr"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown log color",
+			config: &Config{
+				LogLevel:     "info",
+				LogColor:     "sometimes",
+				PollInterval: 30,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid file mode",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs:    []WatchDir{{Path: "/tmp", FileMode: "0999"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed exclude pattern",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs:    []WatchDir{{Path: "/tmp", Exclude: []string{"[a-"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative workers",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Workers:      -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative cpu limit",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				CPULimit:     -0.5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "scan cpu percent out of range",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				ScanCPU:      150,
+			},
+			wantErr: true,
+		},
+		{
+			name: "scan nice out of range",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				ScanNice:     20,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown scan io class",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				ScanIOClass:  "realtime",
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative allowed protected path",
+			config: &Config{
+				LogLevel:       "info",
+				PollInterval:   30,
+				AllowProtected: []string{"var/lib/docker/volumes"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max watches",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				MaxWatches:   -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative burst paths",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				BurstPaths:   -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative memory limit",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				MemoryLimitMB: -1,
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid poll interval",
 			config: &Config{
@@ -47,6 +185,402 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "tls cert without key",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					TLS: TLS{CertFile: "/etc/ownarr/cert.pem"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls self-signed with cert files",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					TLS: TLS{CertFile: "cert.pem", KeyFile: "key.pem", SelfSigned: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "basic auth without password",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					BasicAuth: BasicAuth{Username: "admin"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid trusted proxy",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					ProxyAuth: ProxyAuth{Header: "Remote-User", TrustedProxies: []string{"10.0.0.0/33"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "trusted proxies without header",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					ProxyAuth: ProxyAuth{TrustedProxies: []string{"10.0.0.1"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown access log format",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server: Server{
+					AccessLog: AccessLog{Format: "xml"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tracing sample ratio out of range",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Tracing:      Tracing{SampleRatio: 1.5},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tracing endpoint without scheme",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Tracing:      Tracing{Endpoint: "localhost:4318"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bind with port",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Server:       Server{Bind: "127.0.0.1:8080"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "statsd without port",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				StatsD:       StatsD{Enabled: true, Address: "localhost", Format: "graphite", FlushInterval: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook without scheme",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Webhooks: []Webhook{{URL: "example.com/hook"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook with unknown event",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Webhooks: []Webhook{{URL: "https://example.com/hook", Events: []string{"fixed"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate notification target names",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60,
+					Webhooks: []Webhook{{Name: "alerts", URL: "https://example.com/a"}},
+					Discord:  []Discord{{Name: "alerts", URL: "https://discord.com/api/webhooks/1/abc"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "slack for unknown folder",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Slack: []Slack{{URL: "https://hooks.slack.com/services/x", Folders: []string{"tv"}}}},
+				WatchDirs:     []WatchDir{{Path: "/data/movies"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "slack with unknown severity",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Slack: []Slack{{URL: "https://hooks.slack.com/services/x", MinSeverity: "critical"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "slack for named folder",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Slack: []Slack{{URL: "https://hooks.slack.com/services/x", Folders: []string{"movies"}, MinSeverity: "warning"}}},
+				WatchDirs:     []WatchDir{{Path: "/data/movies"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "telegram without chat id",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Telegram: []Telegram{{Token: "123:abc"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ntfy without topic",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Ntfy: []Ntfy{{}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "gotify without token",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Gotify: []Gotify{{URL: "https://gotify.example.com"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "apprise with key and urls",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Apprise: []Apprise{{URL: "http://apprise:8000", Key: "ownarr", URLs: []string{"pover://user@token"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email without recipients",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Email: []Email{{Host: "smtp.example.com", From: "ownarr@example.com"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email with invalid tls mode",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Email: []Email{{Host: "smtp.example.com", TLS: "ssl", From: "ownarr@example.com", To: []string{"admin@example.com"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pushover with invalid priority",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Pushover: []Pushover{{Token: "app", User: "user", Priorities: map[string]int{"error": 3}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notifiarr with invalid channel id",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Notifiarr: []Notifiarr{{APIKey: "key", ChannelID: "#media"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "syslog with unknown facility",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Syslog:       Syslog{Enabled: true, Facility: "local9", Tag: "ownarr"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "remote syslog without port",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Syslog:       Syslog{Enabled: true, Network: "udp", Address: "nas.local", Facility: "daemon", Tag: "ownarr"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "watch dir notifying an unknown target",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Discord: []Discord{{Name: "discord", URL: "https://discord.com/api/webhooks/1/abc"}}},
+				WatchDirs:     []WatchDir{{Path: "/tmp/test", FileMode: "0644", DirMode: "0755", Notify: Notify{Targets: []string{"slack"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "watch dir notifying a known target",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Discord: []Discord{{Name: "discord", URL: "https://discord.com/api/webhooks/1/abc"}}},
+				WatchDirs:     []WatchDir{{Path: "/tmp/test", FileMode: "0644", DirMode: "0755", Notify: Notify{Targets: []string{"discord"}, MinFailed: 1}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid report schedule",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, ReportSchedule: "0 8 * *", Discord: []Discord{{URL: "https://discord.com/api/webhooks/1/abc"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid notification template",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Templates: map[string]Template{"summary": {Title: "{{.Folder"}}, Webhooks: []Webhook{{URL: "https://example.com/hook"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "template for unknown event",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, Templates: map[string]Template{"fixed": {Title: "{{.Folder}}"}}, Webhooks: []Webhook{{URL: "https://example.com/hook"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid report time",
+			config: &Config{
+				LogLevel:      "info",
+				PollInterval:  30,
+				Notifications: Notifications{Timeout: 10, ErrorThreshold: 10, ErrorWindow: 60, ReportTime: "8am", Webhooks: []Webhook{{URL: "https://example.com/hook"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "heartbeat without polling",
+			config: &Config{
+				LogLevel:  "info",
+				Heartbeat: Heartbeat{URL: "https://hc-ping.com/uuid", FailAfter: 3, Timeout: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid heartbeat url",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Heartbeat:    Heartbeat{URL: "hc-ping.com/uuid", FailAfter: 3, Timeout: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "plex without sections",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Plex:         Plex{URL: "http://plex:32400", Token: "token"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "plex section with relative path",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Plex:         Plex{URL: "http://plex:32400", Token: "token", Sections: []PlexSection{{ID: 1, Path: "media/tv"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "jellyfin with unknown type",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Jellyfin:     []Jellyfin{{Type: "kodi", URL: "http://jellyfin:8096", APIKey: "key", Libraries: []JellyfinLibrary{{Path: "/data/tv"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "jellyfin without libraries",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				Jellyfin:     []Jellyfin{{URL: "http://jellyfin:8096", APIKey: "key"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate watch dir names",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs: []WatchDir{
+					{Name: "media", Path: "/data/a"},
+					{Name: "media", Path: "/data/b"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate default watch dir names",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs: []WatchDir{
+					{Path: "/media/tv"},
+					{Path: "/downloads/tv"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "default watch dir name used explicitly",
+			config: &Config{
+				LogLevel:     "info",
+				PollInterval: 30,
+				WatchDirs: []WatchDir{
+					{Path: "/media/tv"},
+					{Name: "tv", Path: "/downloads/tv"},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing watch dir path",
 			config: &Config{
@@ -67,12 +601,97 @@ func TestConfigValidation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			err := tt.config.validate()
 			if tt.wantErr {
-				assert.Error(t, err)
+				var invalid *ValidationError
+				require.ErrorAs(t, err, &invalid)
+				assert.NotEmpty(t, invalid.Field)
 			} else {
 				assert.NoError(t, err)
 			}
 		})
 	}
+
+	// Errors name the setting at fault
+	cfg := &Config{LogLevel: "info", PollInterval: 30, WatchDirs: []WatchDir{{Path: "/tmp"}, {Path: "/srv", Owner: "no-such-user-ownarr"}}}
+	var invalid *ValidationError
+	require.ErrorAs(t, cfg.validate(), &invalid)
+	assert.Equal(t, "watch_dirs[1].owner", invalid.Field)
+}
+
+func TestValidateRemote(t *testing.T) {
+	remote := func(watchDir WatchDir) *Config {
+		return &Config{LogLevel: "info", PollInterval: 30, WatchDirs: []WatchDir{watchDir}}
+	}
+	nas := Remote{Host: "nas.lan", User: "admin", IdentityFile: "/config/id_ed25519", KnownHosts: "/config/known_hosts"}
+
+	// Remote paths are kept as they are, and the port defaults to 22
+	cfg := remote(WatchDir{Path: "/volume1/media/", Owner: "1026", Group: "100", Remote: nas})
+	require.NoError(t, cfg.validate())
+	assert.Equal(t, "/volume1/media", cfg.WatchDirs[0].Path)
+	assert.Equal(t, "media", cfg.WatchDirs[0].Name)
+	assert.Equal(t, "nas.lan:22", cfg.WatchDirs[0].Remote.Address())
+
+	for field, watchDir := range map[string]WatchDir{
+		"watch_dirs[0].path":               {Path: "media", Remote: nas},
+		"watch_dirs[0].owner":              {Path: "/volume1/media", Owner: "root", Remote: nas},
+		"watch_dirs[0].group":              {Path: "/volume1/media", Group: "users", Remote: nas},
+		"watch_dirs[0].remote":             {Path: "/volume1/media", Remote: Remote{Host: "nas.lan", User: "admin", KnownHosts: "/config/known_hosts"}},
+		"watch_dirs[0].remote.user":        {Path: "/volume1/media", Remote: Remote{Host: "nas.lan", Password: "secret", KnownHosts: "/config/known_hosts"}},
+		"watch_dirs[0].remote.known_hosts": {Path: "/volume1/media", Remote: Remote{Host: "nas.lan", User: "admin", Password: "secret"}},
+		"watch_dirs[0].remote.port":        {Path: "/volume1/media", Remote: Remote{Host: "nas.lan", Port: 70000, User: "admin", Password: "secret", KnownHosts: "/config/known_hosts"}},
+	} {
+		var invalid *ValidationError
+		require.ErrorAs(t, remote(watchDir).validate(), &invalid, field)
+		assert.Equal(t, field, invalid.Field)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	uid, err := ParseOwner("1234")
+	require.NoError(t, err)
+	assert.Equal(t, 1234, uid)
+
+	uid, err = ParseOwner("root")
+	require.NoError(t, err)
+	assert.Equal(t, 0, uid)
+
+	_, err = ParseOwner("-1")
+	assert.Error(t, err)
+
+	gid, err := ParseGroup("5678")
+	require.NoError(t, err)
+	assert.Equal(t, 5678, gid)
+}
+
+func TestNotifySummarizes(t *testing.T) {
+	assert.True(t, Notify{}.Summarizes(1, 0))
+	assert.True(t, Notify{}.Summarizes(0, 1))
+	assert.False(t, Notify{}.Summarizes(0, 0))
+
+	failures := Notify{MinFailed: 1}
+	assert.False(t, failures.Summarizes(100, 0))
+	assert.True(t, failures.Summarizes(0, 1))
+
+	either := Notify{MinFixed: 10, MinFailed: 2}
+	assert.False(t, either.Summarizes(9, 1))
+	assert.True(t, either.Summarizes(10, 0))
+	assert.True(t, either.Summarizes(0, 2))
+}
+
+func TestMediaServerDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Plex = Plex{URL: "http://plex:32400", Token: "token", Sections: []PlexSection{{ID: 1, Path: "/data/tv/"}}}
+	cfg.Jellyfin = []Jellyfin{
+		{URL: "http://jellyfin:8096", APIKey: "key", Libraries: []JellyfinLibrary{{Path: "/data/tv"}}},
+		{Type: EmbyServer, URL: "http://emby:8096", APIKey: "key", Delay: 5, Libraries: []JellyfinLibrary{{Path: "/data/tv", ServerPath: "/tv"}}},
+	}
+	require.NoError(t, cfg.validate())
+
+	assert.Equal(t, PlexSection{ID: 1, Path: "/data/tv", PlexPath: "/data/tv"}, cfg.Plex.Sections[0])
+	assert.Equal(t, "jellyfin-1", cfg.Jellyfin[0].Name)
+	assert.Equal(t, 30, cfg.Jellyfin[0].Delay)
+	assert.Equal(t, "/data/tv", cfg.Jellyfin[0].Libraries[0].ServerPath)
+	assert.Equal(t, "emby-2", cfg.Jellyfin[1].Name)
+	assert.Equal(t, 5, cfg.Jellyfin[1].Delay)
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -113,6 +732,7 @@ watch_dirs:
 
 	watchDir := cfg.WatchDirs[0]
 	assert.Equal(t, "/data/media", watchDir.Path)
+	assert.Equal(t, "media", watchDir.Name)
 	assert.True(t, watchDir.Recursive)
 	assert.Equal(t, []string{"temp", "*.tmp"}, watchDir.Exclude)
 	assert.Equal(t, []string{"*.mp4", "*.mkv"}, watchDir.Include)
@@ -125,3 +745,165 @@ func TestLoadConfigFileNotFound(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "config file not found")
 }
+
+func TestDefaultPath(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv(EnvPath, "")
+	t.Chdir(t.TempDir())
+
+	assert.Equal(t, []string{"config.yaml", filepath.Join(xdg, "ownarr", "config.yaml"), "/etc/ownarr/config.yaml"}, SearchPaths())
+
+	// The user's configuration is found before the system one
+	userConfig := filepath.Join(xdg, "ownarr", "config.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(userConfig), 0o755))
+	require.NoError(t, os.WriteFile(userConfig, nil, 0o644))
+	assert.Equal(t, userConfig, DefaultPath())
+
+	// The working directory comes first
+	require.NoError(t, os.WriteFile("config.yaml", nil, 0o644))
+	assert.Equal(t, "config.yaml", DefaultPath())
+
+	// The environment wins, even if the file is missing
+	t.Setenv(EnvPath, "/missing/ownarr.yaml")
+	assert.Equal(t, "/missing/ownarr.yaml", DefaultPath())
+}
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`{"log_level": "debug", "watch_dirs": [{"path": "/data/tv"}]}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, 30, cfg.PollInterval, "defaults apply to unset fields")
+	require.Len(t, cfg.WatchDirs, 1)
+	assert.Equal(t, "tv", cfg.WatchDirs[0].Name)
+	assert.Equal(t, "0644", cfg.WatchDirs[0].FileMode)
+
+	_, err = Parse([]byte("poll_interval: -1"))
+	assert.Error(t, err)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.Server.BasicAuth = BasicAuth{Username: "admin", Password: "hunter2"}
+	cfg.Tracing.Headers = map[string]string{"Authorization": "Basic abc"}
+	cfg.Notifications.Webhooks = []Webhook{{URL: "https://example.com/hook", Headers: map[string]string{"X-Token": "abc"}}}
+	cfg.Notifications.Discord = []Discord{{URL: "https://discord.com/api/webhooks/1/abc"}}
+	cfg.Notifications.Telegram = []Telegram{{Token: "123:abc", ChatID: "-100"}}
+	cfg.Notifications.Apprise = []Apprise{{URL: "http://apprise:8000", URLs: []string{"pover://user@token"}}}
+	cfg.Notifications.Email = []Email{{Host: "smtp.example.com", Username: "ownarr", Password: "hunter2"}}
+	cfg.Notifications.Pushover = []Pushover{{Token: "app", User: "user"}}
+	cfg.Notifications.Notifiarr = []Notifiarr{{APIKey: "notifiarr-key", ChannelID: "123"}}
+	cfg.Heartbeat.URL = "https://hc-ping.com/uuid"
+	cfg.Plex = Plex{URL: "http://plex:32400", Token: "plex-token"}
+	cfg.Jellyfin = []Jellyfin{{Name: "jellyfin", APIKey: "jellyfin-key"}}
+	cfg.WatchDirs = []WatchDir{{Path: "/data"}, {Path: "/volume1/media", Remote: Remote{Host: "nas", User: "admin", Password: "hunter2"}}}
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, RedactedValue, redacted.Server.APIKey)
+	assert.Equal(t, RedactedValue, redacted.Server.BasicAuth.Password)
+	assert.Equal(t, "admin", redacted.Server.BasicAuth.Username)
+	assert.Equal(t, map[string]string{"Authorization": RedactedValue}, redacted.Tracing.Headers)
+	assert.Equal(t, "Basic abc", cfg.Tracing.Headers["Authorization"], "original headers are unchanged")
+	assert.Equal(t, map[string]string{"X-Token": RedactedValue}, redacted.Notifications.Webhooks[0].Headers)
+	assert.Equal(t, "abc", cfg.Notifications.Webhooks[0].Headers["X-Token"], "original webhook headers are unchanged")
+	assert.Equal(t, RedactedValue, redacted.Notifications.Discord[0].URL)
+	assert.Equal(t, "https://discord.com/api/webhooks/1/abc", cfg.Notifications.Discord[0].URL)
+	assert.Equal(t, RedactedValue, redacted.Notifications.Telegram[0].Token)
+	assert.Equal(t, "-100", redacted.Notifications.Telegram[0].ChatID)
+	assert.Equal(t, []string{RedactedValue}, redacted.Notifications.Apprise[0].URLs)
+	assert.Equal(t, []string{"pover://user@token"}, cfg.Notifications.Apprise[0].URLs)
+	assert.Equal(t, RedactedValue, redacted.Notifications.Email[0].Password)
+	assert.Equal(t, "ownarr", redacted.Notifications.Email[0].Username)
+	assert.Equal(t, RedactedValue, redacted.Notifications.Pushover[0].Token)
+	assert.Equal(t, "user", redacted.Notifications.Pushover[0].User)
+	assert.Equal(t, RedactedValue, redacted.Notifications.Notifiarr[0].APIKey)
+	assert.Equal(t, "123", redacted.Notifications.Notifiarr[0].ChannelID)
+	assert.Equal(t, RedactedValue, redacted.Heartbeat.URL)
+	assert.Equal(t, RedactedValue, redacted.Plex.Token)
+	assert.Equal(t, RedactedValue, redacted.Jellyfin[0].APIKey)
+	assert.Equal(t, "jellyfin-key", cfg.Jellyfin[0].APIKey)
+	assert.Equal(t, RedactedValue, redacted.WatchDirs[1].Remote.Password)
+	assert.Equal(t, "hunter2", cfg.WatchDirs[1].Remote.Password)
+	assert.Empty(t, redacted.WatchDirs[0].Remote.Password)
+	assert.Equal(t, "secret", cfg.Server.APIKey, "original is unchanged")
+
+	redacted.WatchDirs[0].Path = "/other"
+	assert.Equal(t, "/data", cfg.WatchDirs[0].Path, "watch dirs are copied")
+
+	assert.Empty(t, DefaultConfig().Redacted().Server.APIKey, "unset secrets stay empty")
+}
+
+func TestWatchDirFor(t *testing.T) {
+	cfg := &Config{WatchDirs: []WatchDir{
+		{Name: "media", Path: "/data/media"},
+		{Name: "tv", Path: "/data/media/tv"},
+	}}
+
+	watchDir, err := cfg.WatchDirFor("/data/media/tv/Show")
+	require.NoError(t, err)
+	assert.Equal(t, "tv", watchDir.Name)
+
+	watchDir, err = cfg.WatchDirFor("/data/media/movies")
+	require.NoError(t, err)
+	assert.Equal(t, "media", watchDir.Name)
+
+	_, err = cfg.WatchDirFor("/data/mediaserver/x")
+	assert.ErrorIs(t, err, ErrNotWatched)
+}
+
+func TestWithin(t *testing.T) {
+	assert.True(t, Within("/data/media", "/data/media"))
+	assert.True(t, Within("/data/media/tv", "/data/media/"))
+	assert.True(t, Within("/data/media/tv", "/"))
+	assert.False(t, Within("/data/media2", "/data/media"))
+	assert.False(t, Within("/data", "/data/media"))
+}
+
+func TestProxyAuthTrusts(t *testing.T) {
+	proxyAuth := ProxyAuth{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "fd00::/8"}}
+
+	assert.True(t, proxyAuth.Trusts(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, proxyAuth.Trusts(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, proxyAuth.Trusts(netip.MustParseAddr("::ffff:10.0.0.1")))
+	assert.True(t, proxyAuth.Trusts(netip.MustParseAddr("fd00::1")))
+	assert.False(t, proxyAuth.Trusts(netip.MustParseAddr("192.0.2.2")))
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := map[string]string{
+		"":          ":8080",
+		"127.0.0.1": "127.0.0.1:8080",
+		"::1":       "[::1]:8080",
+		"[::]":      "[::]:8080",
+		"nas.lan":   "nas.lan:8080",
+	}
+	for bind, want := range tests {
+		assert.Equal(t, want, Server{Bind: bind, Port: 8080}.ListenAddress(), bind)
+	}
+}
+
+func BenchmarkShouldProcess(b *testing.B) {
+	paths := []string{
+		"/data/media/Show/Season 01/Show.S01E01.1080p.mkv",
+		"/data/media/Show/Season 01/Show.S01E01.1080p.mkv.part",
+		"/data/media/Show/Season 01/Show.S01E01.en.srt",
+		"/data/media/Show/Season 01",
+		"/data/media/.DS_Store",
+	}
+	tests := map[string]WatchDir{
+		"none":    {},
+		"exclude": {Exclude: []string{"*.part", "*.tmp", ".*", "*.!qB"}},
+		"include": {Include: []string{"*.mkv", "*.mp4", "*.avi", "*.srt"}, Exclude: []string{"*.part", ".*"}},
+	}
+	for name, watchDir := range tests {
+		require.NoError(b, watchDir.Compile())
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				watchDir.ShouldProcess(paths[i%len(paths)])
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// patternKind is how a pattern is matched
+type patternKind int
+
+const (
+	patternGlob     patternKind = iota // Matched chunk by chunk like filepath.Match
+	patternLiteral                     // The whole name, e.g. .DS_Store
+	patternSuffix                      // A star then a literal, e.g. *.part
+	patternPrefix                      // A literal then a star, e.g. sample*
+	patternContains                    // A literal between stars, e.g. *sample*
+)
+
+// specialChars are the characters with a meaning in patterns
+const specialChars = `*?[\`
+
+// pattern is an include or exclude pattern prepared for matching. The
+// common shapes compare strings directly; other globs are split into chunks
+// once, so matching a name neither parses the pattern nor allocates.
+type pattern struct {
+	kind   patternKind
+	text   string      // The literal part, or the whole pattern for globs
+	chunks []globChunk // The parsed glob
+}
+
+// globChunk is a part of a glob up to the next star
+type globChunk struct {
+	star  bool       // Whether a star precedes the chunk
+	elems []globElem // Empty for a trailing star
+}
+
+// elemKind is what a glob element matches
+type elemKind int
+
+const (
+	elemLiteral elemKind = iota // A run of literal text
+	elemAny                     // ?, any single character
+	elemClass                   // A character class such as [a-z]
+)
+
+// globElem matches literal text or a single character
+type globElem struct {
+	kind    elemKind
+	text    string      // The text of a literal
+	ranges  []runeRange // The ranges of a class
+	negated bool        // Whether a class matches characters outside its ranges
+}
+
+// runeRange is a range of characters in a class, both ends included
+type runeRange struct {
+	lo, hi rune
+}
+
+// compilePattern prepares a pattern for matching, failing on malformed
+// globs, which match nothing
+func compilePattern(glob string) (pattern, error) {
+	switch i := strings.IndexAny(glob, specialChars); {
+	case i < 0:
+		return pattern{kind: patternLiteral, text: glob}, nil
+	case i == 0 && glob[0] == '*' && !strings.ContainsAny(glob[1:], specialChars):
+		return pattern{kind: patternSuffix, text: glob[1:]}, nil
+	case i == 0 && glob[0] == '*' && strings.IndexAny(glob[1:], specialChars) == len(glob)-2 && glob[len(glob)-1] == '*':
+		return pattern{kind: patternContains, text: glob[1 : len(glob)-1]}, nil
+	case i == len(glob)-1 && glob[i] == '*':
+		return pattern{kind: patternPrefix, text: glob[:i]}, nil
+	}
+
+	chunks, err := compileGlob(glob)
+	return pattern{kind: patternGlob, text: glob, chunks: chunks}, err
+}
+
+// match reports whether a file name matches the pattern
+func (p pattern) match(name string) bool {
+	switch p.kind {
+	case patternLiteral:
+		return name == p.text
+	case patternSuffix:
+		return strings.HasSuffix(name, p.text)
+	case patternPrefix:
+		return strings.HasPrefix(name, p.text)
+	case patternContains:
+		return strings.Contains(name, p.text)
+	default:
+		return p.chunks != nil && matchGlob(p.chunks, name)
+	}
+}
+
+// compileGlob splits a glob into chunks, failing with
+// filepath.ErrBadPattern where filepath.Match would
+func compileGlob(glob string) ([]globChunk, error) {
+	chunks := []globChunk{}
+	for glob != "" {
+		var chunk globChunk
+		for glob != "" && glob[0] == '*' {
+			chunk.star = true
+			glob = glob[1:]
+		}
+		for glob != "" && glob[0] != '*' {
+			var elem globElem
+			var err error
+			switch {
+			case glob[0] == '?':
+				elem, glob = globElem{kind: elemAny}, glob[1:]
+			case glob[0] == '[':
+				if elem, glob, err = compileClass(glob[1:]); err != nil {
+					return nil, err
+				}
+			case glob[0] == '\\' && runtime.GOOS != "windows":
+				// An escaped character
+				if len(glob) == 1 {
+					return nil, filepath.ErrBadPattern
+				}
+				_, n := utf8.DecodeRuneInString(glob[1:])
+				elem, glob = globElem{kind: elemLiteral, text: glob[1 : 1+n]}, glob[1+n:]
+			default:
+				end := strings.IndexAny(glob[1:], specialChars) + 1
+				if end == 0 {
+					end = len(glob)
+				}
+				elem, glob = globElem{kind: elemLiteral, text: glob[:end]}, glob[end:]
+			}
+
+			// Adjacent literals are compared at once
+			if last := len(chunk.elems) - 1; elem.kind == elemLiteral && last >= 0 && chunk.elems[last].kind == elemLiteral {
+				chunk.elems[last].text += elem.text
+				continue
+			}
+			chunk.elems = append(chunk.elems, elem)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// compileClass parses a character class, given the glob after its opening
+// bracket, and returns it with the rest of the glob
+func compileClass(glob string) (globElem, string, error) {
+	elem := globElem{kind: elemClass}
+	if glob != "" && glob[0] == '^' {
+		elem.negated = true
+		glob = glob[1:]
+	}
+	for {
+		if glob != "" && glob[0] == ']' && len(elem.ranges) > 0 {
+			return elem, glob[1:], nil
+		}
+		var r runeRange
+		var err error
+		if r.lo, glob, err = classRune(glob); err != nil {
+			return elem, "", err
+		}
+		r.hi = r.lo
+		if glob[0] == '-' {
+			if r.hi, glob, err = classRune(glob[1:]); err != nil {
+				return elem, "", err
+			}
+		}
+		elem.ranges = append(elem.ranges, r)
+	}
+}
+
+// classRune returns the possibly escaped character at the start of a class
+// range and the rest of the glob, which the class must not end in
+func classRune(glob string) (rune, string, error) {
+	if glob == "" || glob[0] == '-' || glob[0] == ']' {
+		return 0, "", filepath.ErrBadPattern
+	}
+	if glob[0] == '\\' && runtime.GOOS != "windows" {
+		glob = glob[1:]
+	}
+	r, n := utf8.DecodeRuneInString(glob)
+	if (r == utf8.RuneError && n <= 1) || n == len(glob) {
+		return 0, "", filepath.ErrBadPattern
+	}
+	return r, glob[n:], nil
+}
+
+// matchGlob reports whether name matches the chunks of a glob, the way
+// filepath.Match does
+func matchGlob(chunks []globChunk, name string) bool {
+Chunks:
+	for i, chunk := range chunks {
+		last := i == len(chunks)-1
+		if chunk.star && len(chunk.elems) == 0 {
+			// A trailing star matches the rest, up to a separator
+			return strings.IndexByte(name, filepath.Separator) < 0
+		}
+
+		if rest, ok := chunk.matchPrefix(name); ok && (rest == "" || !last) {
+			name = rest
+			continue
+		}
+		if chunk.star {
+			// The star absorbs ever more of the name, but no separator
+			lead := ""
+			if chunk.elems[0].kind == elemLiteral {
+				lead = chunk.elems[0].text
+			}
+			for j := 0; j < len(name) && name[j] != filepath.Separator; j++ {
+				// Jump to where the chunk's leading literal occurs next
+				if lead != "" {
+					k := strings.Index(name[j+1:], lead)
+					if k < 0 || strings.IndexByte(name[j+1:j+1+k], filepath.Separator) >= 0 {
+						break
+					}
+					j += k
+				}
+				if rest, ok := chunk.matchPrefix(name[j+1:]); ok {
+					if last && rest != "" {
+						continue
+					}
+					name = rest
+					continue Chunks
+				}
+			}
+		}
+		return false
+	}
+	return name == ""
+}
+
+// matchPrefix matches the elements of the chunk against the start of s and
+// returns the rest of s
+func (c globChunk) matchPrefix(s string) (string, bool) {
+	for _, elem := range c.elems {
+		switch elem.kind {
+		case elemLiteral:
+			if !strings.HasPrefix(s, elem.text) {
+				return "", false
+			}
+			s = s[len(elem.text):]
+		case elemAny:
+			if s == "" || s[0] == filepath.Separator {
+				return "", false
+			}
+			_, n := utf8.DecodeRuneInString(s)
+			s = s[n:]
+		case elemClass:
+			if s == "" {
+				return "", false
+			}
+			r, n := utf8.DecodeRuneInString(s)
+			if elem.inClass(r) == elem.negated {
+				return "", false
+			}
+			s = s[n:]
+		}
+	}
+	return s, true
+}
+
+// inClass reports whether r is within one of the ranges of a class
+func (e globElem) inClass(r rune) bool {
+	for _, rr := range e.ranges {
+		if rr.lo <= r && r <= rr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePatterns prepares patterns for matching, failing on malformed ones
+func compilePatterns(globs []string) ([]pattern, error) {
+	patterns := make([]pattern, len(globs))
+	for i, glob := range globs {
+		var err error
+		if patterns[i], err = compilePattern(glob); err != nil {
+			return nil, fmt.Errorf("%q: %w", glob, err)
+		}
+	}
+	return patterns, nil
+}
+
+// matchAny reports whether name matches any of globs, using their compiled
+// form if there is one
+func matchAny(compiled []pattern, globs []string, name string) bool {
+	for i, glob := range globs {
+		p := pattern{}
+		if len(compiled) == len(globs) {
+			p = compiled[i]
+		} else {
+			p, _ = compilePattern(glob)
+		}
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMode parses octal permissions such as "0644"
+func parseMode(mode string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0o777 {
+		return 0, fmt.Errorf("%q is not octal permissions such as 0644", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// compiledWatchDir holds the modes and patterns of a watch directory in the
+// form enforcement uses for every path
+type compiledWatchDir struct {
+	fileMode, dirMode os.FileMode
+	exclude, include  []pattern
+}
+
+// Compile parses the modes and patterns of the watch directory once, so
+// enforcement does not for every path. Loading a configuration compiles its
+// watch directories; call it again after changing these fields.
+func (w *WatchDir) Compile() error {
+	compiled := &compiledWatchDir{}
+	var err error
+	if w.FileMode != "" {
+		if compiled.fileMode, err = parseMode(w.FileMode); err != nil {
+			return fmt.Errorf("file_mode: %w", err)
+		}
+	}
+	if w.DirMode != "" {
+		if compiled.dirMode, err = parseMode(w.DirMode); err != nil {
+			return fmt.Errorf("dir_mode: %w", err)
+		}
+	}
+	if compiled.exclude, err = compilePatterns(w.Exclude); err != nil {
+		return fmt.Errorf("exclude: %w", err)
+	}
+	if compiled.include, err = compilePatterns(w.Include); err != nil {
+		return fmt.Errorf("include: %w", err)
+	}
+	w.compiled = compiled
+	return nil
+}
+
+// Mode returns the mode of files or directories in the watch directory, or
+// false if it is left unchanged
+func (w WatchDir) Mode(isDir bool) (os.FileMode, bool, error) {
+	mode := w.FileMode
+	if isDir {
+		mode = w.DirMode
+	}
+	if mode == "" {
+		return 0, false, nil
+	}
+
+	if w.compiled != nil {
+		if isDir {
+			return w.compiled.dirMode, true, nil
+		}
+		return w.compiled.fileMode, true, nil
+	}
+	parsed, err := parseMode(mode)
+	return parsed, true, err
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePattern(t *testing.T) {
+	kinds := map[string]patternKind{
+		".DS_Store": patternLiteral,
+		"*.part":    patternSuffix,
+		"*":         patternSuffix,
+		"sample*":   patternPrefix,
+		"*.mk?":     patternGlob,
+		"S[0-9]*":   patternGlob,
+		`\*.txt`:    patternGlob,
+		"a*b":       patternGlob,
+		"*sample*":  patternContains,
+		"**":        patternContains,
+		"*.S??E??*": patternGlob,
+		"[^a-c]*.é": patternGlob,
+		`[\]x-]?`:   patternGlob,
+		"*a*b*a":    patternGlob,
+		"?*?":       patternGlob,
+		"[a-":       patternGlob,
+		"?abc":      patternGlob,
+		"[ab]*":     patternGlob,
+	}
+	names := []string{
+		".DS_Store", "movie.part", "movie.mkv", "sample.mkv", "S01", "*.txt", "ab", "axxb", "", "part",
+		"Show.S01E02.mkv", "my sample clip", "d.é", "a.é", "dé", "]é", "-", "x", "abba", "abab", "aXbYa", "é", "éé", "abc", "xabc", "bcd",
+	}
+
+	for glob, kind := range kinds {
+		p, _ := compilePattern(glob)
+		assert.Equal(t, kind, p.kind, glob)
+
+		// Every kind matches exactly like filepath.Match
+		for _, name := range names {
+			want, _ := filepath.Match(glob, name)
+			assert.Equal(t, want, p.match(name), "%s against %q", glob, name)
+		}
+	}
+}
+
+func TestWatchDirCompile(t *testing.T) {
+	watchDir := WatchDir{FileMode: "0640", Exclude: []string{"*.part"}, Include: []string{"*.mkv"}}
+	require.NoError(t, watchDir.Compile())
+
+	mode, ok, err := watchDir.Mode(false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, os.FileMode(0640), mode)
+
+	_, ok, err = watchDir.Mode(true)
+	require.NoError(t, err)
+	assert.False(t, ok, "no directory mode")
+
+	assert.True(t, watchDir.ShouldProcess("/data/movie.mkv"))
+	assert.False(t, watchDir.ShouldProcess("/data/movie.mkv.part"))
+	assert.False(t, watchDir.ShouldProcess("/data/movie.srt"))
+
+	for name, invalid := range map[string]WatchDir{
+		"file mode":       {FileMode: "0999"},
+		"setuid":          {FileMode: "4755"},
+		"dir mode":        {DirMode: "rwx"},
+		"exclude pattern": {Exclude: []string{"[a-"}},
+		"include pattern": {Include: []string{"[a-"}},
+	} {
+		assert.Error(t, invalid.Compile(), name)
+	}
+}
+
+func TestWatchDirUncompiled(t *testing.T) {
+	// Watch directories built in code work without Compile
+	watchDir := WatchDir{FileMode: "0644", Exclude: []string{"*.part"}}
+
+	mode, ok, err := watchDir.Mode(false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, os.FileMode(0644), mode)
+	assert.False(t, watchDir.ShouldProcess("/data/movie.part"))
+	assert.True(t, watchDir.ShouldExclude("/data/new.part"))
+
+	_, _, err = WatchDir{DirMode: "0999"}.Mode(true)
+	assert.Error(t, err)
+}
+
+func BenchmarkPatternMatch(b *testing.B) {
+	names := []string{"Show.S01E01.1080p.mkv", "Show.S01E01.1080p.mkv.part", "sample.mkv", ".DS_Store", "Season 01"}
+	for _, glob := range []string{".DS_Store", "*.part", "sample*", "*sample*", "*.mk?", "S[0-9][0-9]E*", "*.S??E??.*"} {
+		p, err := compilePattern(glob)
+		require.NoError(b, err)
+		b.Run(glob, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				p.match(names[i%len(names)])
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Snapshot holds the configuration in effect, replaced as a whole on reload
+// so readers never see one half changed. The configuration it holds is
+// shared by every reader and must not be modified; change a Clone and
+// Store that instead. The zero value holds no configuration.
+type Snapshot struct {
+	current atomic.Pointer[Config]
+}
+
+// Load returns the configuration in effect, nil if none was stored
+func (s *Snapshot) Load() *Config {
+	return s.current.Load()
+}
+
+// Store makes cfg the configuration in effect. Neither the caller nor
+// anyone it passed cfg to may modify it afterwards.
+func (s *Snapshot) Store(cfg *Config) {
+	s.current.Store(cfg)
+}
+
+// Clone returns a deep copy of the configuration, sharing nothing that
+// could be modified with it
+func (c *Config) Clone() *Config {
+	clone := deepCopy(reflect.ValueOf(*c)).Interface().(Config)
+	return &clone
+}
+
+// deepCopy returns a copy of v with its slices, maps and the exported
+// fields of its structs copied in turn. Unexported fields, which only cache
+// what is derived from the exported ones, are shared.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			copied.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				copied.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WatchDirs = []WatchDir{{Name: "tv", Path: "/data/tv", Include: []string{"*.mkv"}}}
+	cfg.Tracing.Headers = map[string]string{"Authorization": "secret"}
+	cfg.Notifications.Pushover = []Pushover{{Name: "phone", Priorities: map[string]int{"error": 1}}}
+
+	clone := cfg.Clone()
+	assert.Equal(t, cfg, clone)
+
+	clone.WatchDirs[0].Include[0] = "*.nfo"
+	clone.Tracing.Headers["Authorization"] = "other"
+	clone.Notifications.Pushover[0].Priorities["error"] = 2
+	assert.Equal(t, "*.mkv", cfg.WatchDirs[0].Include[0])
+	assert.Equal(t, "secret", cfg.Tracing.Headers["Authorization"])
+	assert.Equal(t, 1, cfg.Notifications.Pushover[0].Priorities["error"])
+}
+
+func TestSnapshot(t *testing.T) {
+	var snapshot Snapshot
+	assert.Nil(t, snapshot.Load())
+
+	cfg := DefaultConfig()
+	snapshot.Store(cfg)
+	require.Same(t, cfg, snapshot.Load())
+
+	changed := snapshot.Load().Clone()
+	changed.LogLevel = "debug"
+	snapshot.Store(changed)
+	assert.Equal(t, "debug", snapshot.Load().LogLevel)
+	assert.Equal(t, "info", cfg.LogLevel)
+}
@@ -0,0 +1,59 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// A Tuesday
+	now := time.Date(2024, 5, 14, 12, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 14, 12, 31, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2024, 5, 15, 8, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, 5, 14, 12, 40, 0, 0, time.UTC)},
+		{"0 9 * * mon", time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 5, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 1,15 jan,jul *", time.Date(2024, 7, 1, 6, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * fri", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(now))
+		})
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	schedule, err := Parse("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestNextLocation(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+30*60)
+	schedule, err := Parse("0 * * * *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 14, 13, 0, 0, 0, loc), schedule.Next(time.Date(2024, 5, 14, 12, 10, 0, 0, loc)))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
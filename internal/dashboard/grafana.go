@@ -0,0 +1,233 @@
+// Package dashboard renders monitoring dashboards for the exported metrics
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/metrics"
+)
+
+// FormatGrafana is the Grafana dashboard JSON model
+const FormatGrafana = "grafana"
+
+// Panel layout on Grafana's 24 column grid
+const (
+	panelWidth  = 12
+	panelHeight = 8
+	gridWidth   = 24
+)
+
+// Names shared by the exported metrics
+const (
+	metricPrefix = "ownarr_"
+	folderLabel  = "folder" // Label filtered by the folder variable
+)
+
+// grafanaDashboard is the subset of the Grafana dashboard model that is rendered
+type grafanaDashboard struct {
+	UID           string           `json:"uid"`
+	Title         string           `json:"title"`
+	Tags          []string         `json:"tags"`
+	Timezone      string           `json:"timezone"`
+	Refresh       string           `json:"refresh"`
+	SchemaVersion int              `json:"schemaVersion"`
+	Time          grafanaTimeRange `json:"time"`
+	Templating    grafanaVariables `json:"templating"`
+	Panels        []grafanaPanel   `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaVariables struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Query      string             `json:"query"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Refresh    int                `json:"refresh,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+	Targets     []grafanaTarget    `json:"targets"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults grafanaFieldDefaults `json:"defaults"`
+}
+
+type grafanaFieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+// datasource refers to the data source chosen with the datasource variable
+var datasource = grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+
+// Grafana renders a Grafana dashboard with a panel for every metric in the
+// registry. Metrics with a folder label are filtered by a folder variable.
+func Grafana(registry *metrics.Registry) ([]byte, error) {
+	dashboard := grafanaDashboard{
+		UID:           "ownarr",
+		Title:         "ownarr",
+		Tags:          []string{"ownarr"},
+		Timezone:      "browser",
+		Refresh:       "30s",
+		SchemaVersion: 39,
+		Time:          grafanaTimeRange{From: "now-6h", To: "now"},
+		Templating: grafanaVariables{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+	}
+
+	var hasFolders bool
+	registry.Each(func(desc metrics.Desc) {
+		hasFolders = hasFolders || slices.Contains(desc.Labels, folderLabel)
+
+		panel := newPanel(desc)
+		panel.ID = len(dashboard.Panels) + 1
+		panel.GridPos = grafanaGridPos{
+			X: (len(dashboard.Panels) * panelWidth) % gridWidth,
+			Y: (len(dashboard.Panels) * panelWidth) / gridWidth * panelHeight,
+			W: panelWidth,
+			H: panelHeight,
+		}
+		dashboard.Panels = append(dashboard.Panels, panel)
+	})
+
+	if hasFolders {
+		dashboard.Templating.List = append(dashboard.Templating.List, grafanaVariable{
+			Name:       folderLabel,
+			Label:      "Folder",
+			Type:       "query",
+			Query:      fmt.Sprintf(`label_values({__name__=~"%s.+"}, %s)`, metricPrefix, folderLabel),
+			Datasource: &datasource,
+			Refresh:    2, // On time range change
+			Multi:      true,
+			IncludeAll: true,
+		})
+	}
+
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// newPanel creates a time series panel for a metric
+func newPanel(desc metrics.Desc) grafanaPanel {
+	selector := desc.Name + "_bucket"
+	if desc.Kind != "histogram" {
+		selector = desc.Name
+	}
+	if slices.Contains(desc.Labels, folderLabel) {
+		selector += fmt.Sprintf(`{%s=~"$%s"}`, folderLabel, folderLabel)
+	}
+
+	by := strings.Join(desc.Labels, ", ")
+	legend := legendFormat(desc.Labels)
+
+	var expr, unit string
+	switch {
+	case desc.Kind == "histogram":
+		expr = fmt.Sprintf("histogram_quantile(0.95, sum by (%s) (rate(%s[$__rate_interval])))",
+			strings.Join(append([]string{"le"}, desc.Labels...), ", "), selector)
+		unit = "s"
+	case desc.Kind == "counter":
+		expr = fmt.Sprintf("%s (rate(%s[$__rate_interval]))", aggregate("sum", by), selector)
+		unit = "ops"
+	case strings.HasSuffix(desc.Name, "_timestamp_seconds"):
+		// Show how long ago the timestamp was rather than the timestamp itself
+		expr = fmt.Sprintf("time() - %s (%s)", aggregate("max", by), selector)
+		unit = "s"
+	default:
+		expr = fmt.Sprintf("%s (%s)", aggregate("sum", by), selector)
+		unit = "short"
+	}
+
+	return grafanaPanel{
+		Type:        "timeseries",
+		Title:       panelTitle(desc),
+		Description: desc.Help,
+		Datasource:  datasource,
+		FieldConfig: grafanaFieldConfig{Defaults: grafanaFieldDefaults{Unit: unit}},
+		Targets:     []grafanaTarget{{RefID: "A", Expr: expr, LegendFormat: legend}},
+	}
+}
+
+// panelTitle derives a readable title from the metric name, e.g. "Scan
+// duration (p95)" for ownarr_scan_duration_seconds
+func panelTitle(desc metrics.Desc) string {
+	name := strings.TrimPrefix(desc.Name, metricPrefix)
+	switch {
+	case strings.HasSuffix(name, "_timestamp_seconds"):
+		name = "time since " + strings.TrimSuffix(name, "_timestamp_seconds")
+	case desc.Kind == "counter":
+		name = strings.TrimSuffix(name, "_total") + " per second"
+	default:
+		name = strings.TrimSuffix(name, "_seconds")
+	}
+
+	title := strings.ReplaceAll(name, "_", " ")
+	title = strings.ToUpper(title[:1]) + title[1:]
+	if desc.Kind == "histogram" {
+		title += " (p95)"
+	}
+	return title
+}
+
+// legendFormat shows the label values of a series, e.g. "{{folder}} {{trigger}}"
+func legendFormat(labels []string) string {
+	if len(labels) == 0 {
+		return "__auto"
+	}
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = "{{" + label + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+// aggregate returns an aggregation over the given labels, if any
+func aggregate(op, by string) string {
+	if by == "" {
+		return op
+	}
+	return op + " by (" + by + ")"
+}
@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrafanaCoversMetrics(t *testing.T) {
+	data, err := Grafana(metrics.Default)
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+
+	exprs := make(map[string]string)
+	for _, panel := range dashboard.Panels {
+		require.Len(t, panel.Targets, 1)
+		exprs[panel.Title] = panel.Targets[0].Expr
+	}
+
+	metrics.Default.Each(func(desc metrics.Desc) {
+		var found bool
+		for _, expr := range exprs {
+			if strings.Contains(expr, desc.Name) {
+				found = true
+				if strings.Contains(strings.Join(desc.Labels, ","), "folder") {
+					assert.Contains(t, expr, `folder=~"$folder"`, desc.Name)
+				}
+			}
+		}
+		assert.True(t, found, "no panel for %s", desc.Name)
+	})
+
+	names := make([]string, 0, len(dashboard.Templating.List))
+	for _, variable := range dashboard.Templating.List {
+		names = append(names, variable.Name)
+	}
+	assert.Equal(t, []string{"datasource", "folder"}, names)
+}
+
+func TestGrafanaPanels(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewHistogram("ownarr_scan_duration_seconds", "Scans.", []float64{1}, "folder", "trigger")
+	r.NewCounter("ownarr_watcher_errors_total", "Errors.")
+	r.NewGauge("ownarr_last_successful_scan_timestamp_seconds", "Last scan.", "folder")
+
+	data, err := Grafana(r)
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+	require.Len(t, dashboard.Panels, 3)
+
+	last, scans, errors := dashboard.Panels[0], dashboard.Panels[1], dashboard.Panels[2]
+
+	assert.Equal(t, "Time since last successful scan", last.Title)
+	assert.Equal(t, `time() - max by (folder) (ownarr_last_successful_scan_timestamp_seconds{folder=~"$folder"})`, last.Targets[0].Expr)
+
+	assert.Equal(t, "Scan duration (p95)", scans.Title)
+	assert.Equal(t, `histogram_quantile(0.95, sum by (le, folder, trigger) (rate(ownarr_scan_duration_seconds_bucket{folder=~"$folder"}[$__rate_interval])))`, scans.Targets[0].Expr)
+	assert.Equal(t, "{{folder}} {{trigger}}", scans.Targets[0].LegendFormat)
+
+	assert.Equal(t, "Watcher errors per second", errors.Title)
+	assert.Equal(t, "sum (rate(ownarr_watcher_errors_total[$__rate_interval]))", errors.Targets[0].Expr)
+	assert.Equal(t, grafanaGridPos{X: 0, Y: 8, W: 12, H: 8}, errors.GridPos)
+}
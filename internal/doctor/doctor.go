@@ -0,0 +1,391 @@
+// Package doctor diagnoses the environment ownarr runs in: inotify limits,
+// process capabilities, filesystems of the watch directories, owner and
+// group IDs, and settings that are valid but likely wrong
+package doctor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+)
+
+// Severity grades a finding
+type Severity string
+
+const (
+	SeverityOK      Severity = "ok"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error" // Keeps enforcement from working
+)
+
+// Finding is the result of a single check, with the remedy for problems
+type Finding struct {
+	Check    string   `json:"check" yaml:"check"`
+	Severity Severity `json:"severity" yaml:"severity"`
+	Message  string   `json:"message" yaml:"message"`
+	Remedy   string   `json:"remedy,omitempty" yaml:"remedy,omitempty"`
+}
+
+// Capabilities used by enforcement, by bit in the capability sets
+const (
+	capChown         = 0
+	capDACOverride   = 1
+	capDACReadSearch = 2
+	capFowner        = 3
+)
+
+// Filesystem magic numbers reported by statfs for filesystems on which
+// inotify misses changes
+var remoteFilesystems = map[int64]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+}
+
+// Run runs every check against the configuration and the current process
+func Run(cfg *config.Config) []Finding {
+	var findings []Finding
+	findings = append(findings, checkWatchDirs(cfg)...)
+	findings = append(findings, checkInotify(cfg, "/proc")...)
+
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		findings = append(findings, Finding{
+			Check:    "capabilities",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Failed to read the process capabilities: %v", err),
+		})
+	} else {
+		findings = append(findings, checkCapabilities(cfg, string(status), os.Geteuid())...)
+	}
+
+	findings = append(findings, checkFilesystems(cfg, statfsType)...)
+	findings = append(findings, CheckIDs(cfg)...)
+	return findings
+}
+
+// Failed reports whether any finding keeps enforcement from working
+func Failed(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWatchDirs checks that the watch directories exist and that their
+// modes make sense
+func checkWatchDirs(cfg *config.Config) []Finding {
+	var findings []Finding
+	for _, watchDir := range cfg.WatchDirs {
+		name := fmt.Sprintf("%s (%s)", watchDir.Name, watchDir.Path)
+
+		info, err := statWatchDir(watchDir)
+		switch {
+		case err != nil:
+			remedy := "Create the directory, fix the path, or mount the volume before starting ownarr"
+			if watchDir.Remote.Enabled() {
+				name = fmt.Sprintf("%s (%s on %s)", watchDir.Name, watchDir.Path, watchDir.Remote.Host)
+				remedy = "Check the host, user, credentials and known_hosts of remote, and that the path exists on the host"
+			}
+			findings = append(findings, Finding{
+				Check:    "config",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s cannot be accessed: %v", name, err),
+				Remedy:   remedy,
+			})
+			continue
+		case !info.IsDir():
+			findings = append(findings, Finding{
+				Check:    "config",
+				Severity: SeverityError,
+				Message:  name + " is not a directory",
+				Remedy:   "Point the path at the directory containing the files",
+			})
+			continue
+		}
+
+		if mode, err := strconv.ParseUint(watchDir.FileMode, 8, 32); err == nil && mode&0o111 != 0 {
+			findings = append(findings, Finding{
+				Check:    "config",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s makes every file executable with file_mode %s", name, watchDir.FileMode),
+				Remedy:   "Use a mode without execute bits for files, e.g. 0644 or 0664",
+			})
+		}
+		if mode, err := strconv.ParseUint(watchDir.DirMode, 8, 32); err == nil && mode&0o100 == 0 {
+			findings = append(findings, Finding{
+				Check:    "config",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s makes directories unsearchable for their owner with dir_mode %s", name, watchDir.DirMode),
+				Remedy:   "Keep the execute bit on directories, e.g. 0755 or 0775",
+			})
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, Finding{
+			Check:    "config",
+			Severity: SeverityOK,
+			Message:  "All watch directories are accessible",
+		})
+	}
+	return findings
+}
+
+// statWatchDir reads the metadata of the path of watchDir, logging in to its
+// remote host if it has one
+func statWatchDir(watchDir config.WatchDir) (os.FileInfo, error) {
+	if !watchDir.Remote.Enabled() {
+		return os.Stat(watchDir.Path)
+	}
+	conn, err := enforcer.DialRemote(watchDir.Remote)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.Lstat(watchDir.Path)
+}
+
+// checkInotify compares the inotify watch limit under procRoot with the
+// watches the configuration needs
+func checkInotify(cfg *config.Config, procRoot string) []Finding {
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys/fs/inotify/max_user_watches"))
+	if err != nil {
+		return []Finding{{
+			Check:    "inotify",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Failed to read the inotify watch limit: %v", err),
+		}}
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return []Finding{{
+			Check:    "inotify",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Invalid inotify watch limit %q", strings.TrimSpace(string(data))),
+		}}
+	}
+
+	needed := 0
+	for _, watchDir := range cfg.WatchDirs {
+		needed += countWatches(watchDir)
+	}
+
+	finding := Finding{
+		Check:    "inotify",
+		Severity: SeverityOK,
+		Message:  fmt.Sprintf("%d of %d inotify watches needed", needed, limit),
+	}
+	remedy := fmt.Sprintf("Raise the limit with sysctl fs.inotify.max_user_watches=%d and persist it in /etc/sysctl.d/ (on the host for containers)", needed*2)
+	switch {
+	case needed > limit:
+		finding.Severity = SeverityError
+		finding.Message += "; changes in the directories beyond the limit are only found by polling"
+		finding.Remedy = remedy
+	case needed > limit*3/4:
+		// The limit is shared by all processes of the user
+		finding.Severity = SeverityWarning
+		finding.Message += "; little room is left for new directories and other programs of the same user"
+		finding.Remedy = remedy
+	}
+	return []Finding{finding}
+}
+
+// countWatches returns the number of directories the watcher registers for
+// watchDir, following its rules for recursion and excludes. Remote
+// directories are polled and need none.
+func countWatches(watchDir config.WatchDir) int {
+	if watchDir.Remote.Enabled() {
+		return 0
+	}
+	if _, err := os.Stat(watchDir.Path); err != nil {
+		return 0
+	}
+	if !watchDir.Recursive {
+		return 1
+	}
+
+	count := 0
+	_ = filepath.WalkDir(watchDir.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != watchDir.Path && watchDir.ShouldExclude(path) {
+			return filepath.SkipDir
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// checkCapabilities checks the effective capabilities listed in status, the
+// contents of /proc/self/status, against what the configuration needs
+func checkCapabilities(cfg *config.Config, status string, euid int) []Finding {
+	var effective uint64
+	found := false
+	for line := range strings.Lines(status) {
+		if value, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			var err error
+			effective, err = strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+			found = err == nil
+			break
+		}
+	}
+	if !found {
+		return []Finding{{
+			Check:    "capabilities",
+			Severity: SeverityWarning,
+			Message:  "The process capabilities are unknown",
+		}}
+	}
+	has := func(capability uint) bool { return effective&(1<<capability) != 0 }
+
+	var findings []Finding
+	changesOwners := false
+	for _, watchDir := range cfg.WatchDirs {
+		// Owners on remote hosts are changed by the user logged in there
+		changesOwners = changesOwners || !watchDir.Remote.Enabled() && (watchDir.Owner != "" || watchDir.Group != "")
+	}
+	if changesOwners && !has(capChown) {
+		findings = append(findings, Finding{
+			Check:    "capabilities",
+			Severity: SeverityError,
+			Message:  "Owners and groups are configured, but the process lacks CAP_CHOWN to change them",
+			Remedy:   "Run as root, or grant CAP_CHOWN, e.g. AmbientCapabilities=CAP_CHOWN in the systemd unit or cap_add: [CHOWN] with Docker",
+		})
+	}
+	if !has(capFowner) {
+		findings = append(findings, Finding{
+			Check:    "capabilities",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Without CAP_FOWNER, only the permissions of paths owned by uid %d can be changed", euid),
+			Remedy:   "Run as root or as the owner of the files, or grant CAP_FOWNER, e.g. AmbientCapabilities=CAP_FOWNER in the systemd unit or cap_add: [FOWNER] with Docker",
+		})
+	}
+	if !has(capDACReadSearch) && !has(capDACOverride) {
+		findings = append(findings, Finding{
+			Check:    "capabilities",
+			Severity: SeverityWarning,
+			Message:  "Without CAP_DAC_READ_SEARCH, directories the process cannot read are skipped",
+			Remedy:   "Run as root, or grant CAP_DAC_READ_SEARCH, e.g. AmbientCapabilities=CAP_DAC_READ_SEARCH in the systemd unit or cap_add: [DAC_READ_SEARCH] with Docker",
+		})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, Finding{
+			Check:    "capabilities",
+			Severity: SeverityOK,
+			Message:  "The process may change owners and permissions of all paths",
+		})
+	}
+	return findings
+}
+
+// checkFilesystems flags watch directories on network and FUSE filesystems,
+// using statfs to get the filesystem magic of a path. Remote directories
+// are polled anyway and skipped.
+func checkFilesystems(cfg *config.Config, statfs func(path string) (int64, error)) []Finding {
+	var findings []Finding
+	for _, watchDir := range cfg.WatchDirs {
+		if watchDir.Remote.Enabled() {
+			continue
+		}
+		magic, err := statfs(watchDir.Path)
+		if err != nil {
+			continue // Reported by the config check
+		}
+		fsType, ok := remoteFilesystems[magic]
+		if !ok {
+			continue
+		}
+
+		finding := Finding{
+			Check:    "filesystem",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("%s (%s) is on %s, where inotify does not see changes made by other hosts", watchDir.Name, watchDir.Path, fsType),
+			Remedy:   fmt.Sprintf("Such changes are found by polling every %d seconds; lower poll_interval if that is too slow, or run ownarr on the file server", cfg.PollInterval),
+		}
+		if fsType == "fuse" {
+			finding.Message = fmt.Sprintf("%s (%s) is on a FUSE filesystem, whose inotify events and ownership support depend on its implementation", watchDir.Name, watchDir.Path)
+			finding.Remedy = fmt.Sprintf("Watch the underlying directories instead when possible, e.g. the branches of mergerfs; otherwise changes are found by polling every %d seconds", cfg.PollInterval)
+		}
+		findings = append(findings, finding)
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, Finding{
+			Check:    "filesystem",
+			Severity: SeverityOK,
+			Message:  "All watch directories are on local filesystems",
+		})
+	}
+	return findings
+}
+
+// CheckIDs checks that the configured owners and groups exist in the user
+// and group databases of this host, or container. Remote directories use
+// numeric IDs of their host and are skipped.
+func CheckIDs(cfg *config.Config) []Finding {
+	var findings []Finding
+	for _, watchDir := range cfg.WatchDirs {
+		if watchDir.Remote.Enabled() {
+			continue
+		}
+		if watchDir.Owner != "" {
+			if uid, err := config.ParseOwner(watchDir.Owner); err != nil {
+				findings = append(findings, unresolved(watchDir, "owner", watchDir.Owner, err))
+			} else if _, err := user.LookupId(strconv.Itoa(uid)); err != nil {
+				findings = append(findings, unnamed(watchDir, "uid", uid))
+			}
+		}
+		if watchDir.Group != "" {
+			if gid, err := config.ParseGroup(watchDir.Group); err != nil {
+				findings = append(findings, unresolved(watchDir, "group", watchDir.Group, err))
+			} else if _, err := user.LookupGroupId(strconv.Itoa(gid)); err != nil {
+				findings = append(findings, unnamed(watchDir, "gid", gid))
+			}
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, Finding{
+			Check:    "ids",
+			Severity: SeverityOK,
+			Message:  "All owners and groups exist on this host",
+		})
+	}
+	return findings
+}
+
+// unresolved reports an owner or group name that does not resolve
+func unresolved(watchDir config.WatchDir, setting, value string, err error) Finding {
+	return Finding{
+		Check:    "ids",
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("%s of %s cannot be resolved: %v", setting, watchDir.Name, err),
+		Remedy:   fmt.Sprintf("Create %s %q on this host, or use its numeric ID", setting, value),
+	}
+}
+
+// unnamed reports a numeric ID without a user or group on this host
+func unnamed(watchDir config.WatchDir, kind string, id int) Finding {
+	return Finding{
+		Check:    "ids",
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("%s %d of %s has no name on this host", kind, id, watchDir.Name),
+		Remedy:   "Files given it show as nobody in Samba and ls; nothing to do if it matches the IDs used by other hosts or containers, otherwise check the setting",
+	}
+}
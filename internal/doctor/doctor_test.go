@@ -0,0 +1,164 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// severities returns the severity of each finding
+func severities(findings []Finding) []Severity {
+	result := make([]Severity, len(findings))
+	for i, finding := range findings {
+		result[i] = finding.Severity
+	}
+	return result
+}
+
+func TestCheckWatchDirs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "ok", Path: dir, FileMode: "0644", DirMode: "0755"}}
+	assert.Equal(t, []Severity{SeverityOK}, severities(checkWatchDirs(cfg)))
+
+	cfg.WatchDirs = []config.WatchDir{
+		{Name: "missing", Path: filepath.Join(dir, "missing")},
+		{Name: "file", Path: file},
+		{Name: "modes", Path: dir, FileMode: "0755", DirMode: "0644"},
+	}
+	findings := checkWatchDirs(cfg)
+	assert.Equal(t, []Severity{SeverityError, SeverityError, SeverityWarning, SeverityWarning}, severities(findings))
+	assert.Contains(t, findings[2].Message, "executable")
+	assert.Contains(t, findings[3].Message, "unsearchable")
+	assert.True(t, Failed(findings))
+
+	// Remote directories are looked up on their host
+	knownHosts := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0o600))
+	remote := config.Remote{Host: "127.0.0.1", Port: 1, User: "ownarr", Password: "secret", KnownHosts: knownHosts}
+	cfg.WatchDirs = []config.WatchDir{{Name: "nas", Path: "/volume1/tv", Remote: remote}}
+	findings = checkWatchDirs(cfg)
+	assert.Equal(t, []Severity{SeverityError}, severities(findings))
+	assert.Contains(t, findings[0].Message, "nas (/volume1/tv on 127.0.0.1) cannot be accessed")
+	assert.Contains(t, findings[0].Remedy, "known_hosts")
+	assert.Zero(t, countWatches(cfg.WatchDirs[0]))
+}
+
+func TestCheckInotify(t *testing.T) {
+	data := t.TempDir()
+	for _, sub := range []string{"a/b", "c", "skip/d"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(data, sub), 0o755))
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "data", Path: data, Recursive: true, Exclude: []string{"skip"}}}
+
+	tests := []struct {
+		name  string
+		limit string
+		want  Severity
+	}{
+		{name: "plenty", limit: "8192\n", want: SeverityOK},
+		{name: "tight", limit: "5", want: SeverityWarning},
+		{name: "exceeded", limit: "3", want: SeverityError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			limits := filepath.Join(proc, "sys/fs/inotify")
+			require.NoError(t, os.MkdirAll(limits, 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(limits, "max_user_watches"), []byte(tt.limit), 0o644))
+
+			findings := checkInotify(cfg, proc)
+			require.Len(t, findings, 1)
+			assert.Equal(t, tt.want, findings[0].Severity)
+			// The root, a, a/b and c are watched
+			assert.Contains(t, findings[0].Message, "4 of ")
+		})
+	}
+
+	findings := checkInotify(cfg, t.TempDir())
+	assert.Equal(t, []Severity{SeverityWarning}, severities(findings), "unknown limit")
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: "/data/media", Owner: "1000"}}
+
+	tests := []struct {
+		name   string
+		status string
+		want   []Severity
+	}{
+		{name: "root", status: "Name:\town\nCapEff:\t000001ffffffffff\n", want: []Severity{SeverityOK}},
+		{name: "unprivileged", status: "CapEff:\t0000000000000000\n", want: []Severity{SeverityError, SeverityWarning, SeverityWarning}},
+		{name: "chown and fowner", status: "CapEff:\t0000000000000009\n", want: []Severity{SeverityWarning}},
+		{name: "unknown", status: "Name:\town\n", want: []Severity{SeverityWarning}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, severities(checkCapabilities(cfg, tt.status, 1000)))
+		})
+	}
+
+	// Owners on remote hosts are changed there, so CAP_CHOWN is not needed
+	cfg.WatchDirs[0].Remote = config.Remote{Host: "nas"}
+	assert.Equal(t, []Severity{SeverityOK}, severities(checkCapabilities(cfg, "CapEff:\t000000000000000e\n", 0)))
+
+	// Nor without owners or groups
+	cfg.WatchDirs[0].Remote = config.Remote{}
+	cfg.WatchDirs[0].Owner = ""
+	assert.Equal(t, []Severity{SeverityOK}, severities(checkCapabilities(cfg, "CapEff:\t000000000000000e\n", 0)))
+}
+
+func TestCheckFilesystems(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{
+		{Name: "local", Path: "/data/local"},
+		{Name: "nas", Path: "/data/nas"},
+		{Name: "pool", Path: "/data/pool"},
+		{Name: "missing", Path: "/data/missing"},
+		{Name: "remote", Path: "/data/nas", Remote: config.Remote{Host: "nas"}},
+	}
+	magic := map[string]int64{"/data/local": 0xef53, "/data/nas": 0x6969, "/data/pool": 0x65735546}
+	statfs := func(path string) (int64, error) {
+		if m, ok := magic[path]; ok {
+			return m, nil
+		}
+		return 0, errors.New("no such file or directory")
+	}
+
+	findings := checkFilesystems(cfg, statfs)
+	require.Len(t, findings, 2)
+	assert.Contains(t, findings[0].Message, "nas (/data/nas) is on nfs")
+	assert.Contains(t, findings[1].Message, "FUSE")
+	assert.Equal(t, []Severity{SeverityWarning, SeverityWarning}, severities(findings))
+
+	cfg.WatchDirs = cfg.WatchDirs[:1]
+	assert.Equal(t, []Severity{SeverityOK}, severities(checkFilesystems(cfg, statfs)))
+}
+
+func TestCheckIDs(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: "/data/media", Owner: "root", Group: "0"}}
+	assert.Equal(t, []Severity{SeverityOK}, severities(CheckIDs(cfg)))
+
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: "/data/media", Owner: "ownarr-missing-user", Group: "4000123"}}
+	findings := CheckIDs(cfg)
+	assert.Equal(t, []Severity{SeverityError, SeverityWarning}, severities(findings))
+	assert.Contains(t, findings[1].Message, "gid 4000123")
+
+	// IDs of remote directories belong to their host
+	cfg.WatchDirs[0].Remote = config.Remote{Host: "nas"}
+	assert.Equal(t, []Severity{SeverityOK}, severities(CheckIDs(cfg)))
+}
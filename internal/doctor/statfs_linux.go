@@ -0,0 +1,14 @@
+//go:build linux
+
+package doctor
+
+import "syscall"
+
+// statfsType returns the Linux filesystem magic number of path
+func statfsType(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Type), nil //nolint:unconvert // The field type differs by architecture
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package doctor
+
+import "errors"
+
+// statfsType fails, as the filesystem magic numbers checked are those of
+// Linux; filesystems are not checked elsewhere
+func statfsType(string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
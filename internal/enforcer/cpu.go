@@ -0,0 +1,57 @@
+package enforcer
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/limits"
+)
+
+const (
+	// cpuCheckInterval is the number of paths between checks of the CPU
+	// time used against the CPU limit of scans
+	cpuCheckInterval = 256
+
+	// cpuThrottleMax is how long a walk pauses at most per check, so a
+	// burst of CPU use elsewhere in the process does not stall it
+	cpuThrottleMax = time.Second
+)
+
+// SetCPULimit sets the cores tree runs may keep busy, 0 for no limit. Walks
+// pause whenever the process used more CPU time since their last check than
+// the limit allows, so scans inside a container stay clear of its quota.
+func (e *Enforcer) SetCPULimit(cores float64) {
+	e.cpuLimit.Store(math.Float64bits(cores))
+}
+
+// cpuThrottle returns a function walks call for each path, which pauses the
+// walk while the process uses more CPU than the limit allows, until ctx is
+// done
+func (e *Enforcer) cpuThrottle(ctx context.Context) func() {
+	limit := math.Float64frombits(e.cpuLimit.Load())
+	if limit <= 0 {
+		return func() {}
+	}
+
+	paths := 0
+	checked, used := time.Now(), limits.CPUTime()
+	return func() {
+		paths++
+		if paths%cpuCheckInterval != 0 {
+			return
+		}
+
+		// The time the CPU used since the last check takes at the limit
+		now, cpu := time.Now(), limits.CPUTime()
+		allowed := time.Duration(float64(cpu-used) / limit)
+		if wait := min(allowed-now.Sub(checked), cpuThrottleMax); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+			now = time.Now()
+		}
+		checked, used = now, cpu
+	}
+}
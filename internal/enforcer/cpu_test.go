@@ -0,0 +1,42 @@
+package enforcer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/limits"
+	"github.com/stretchr/testify/assert"
+)
+
+// throttleBusy keeps the CPU busy for d, then calls throttle for enough
+// paths to check the CPU time once, returning how long the calls took
+func throttleBusy(throttle func(), d time.Duration) time.Duration {
+	for started := limits.CPUTime(); limits.CPUTime()-started < d; {
+	}
+	started := time.Now()
+	for range cpuCheckInterval {
+		throttle()
+	}
+	return time.Since(started)
+}
+
+func TestCPUThrottle(t *testing.T) {
+	if limits.CPUTime() == 0 {
+		t.Skip("CPU time cannot be read here")
+	}
+
+	enf := newTestEnforcer()
+	assert.Less(t, throttleBusy(enf.cpuThrottle(context.Background()), 20*time.Millisecond), 50*time.Millisecond, "no limit")
+
+	// 20ms of CPU time take 200ms at a tenth of a core
+	enf.SetCPULimit(0.1)
+	waited := throttleBusy(enf.cpuThrottle(context.Background()), 20*time.Millisecond)
+	assert.Greater(t, waited, 100*time.Millisecond)
+	assert.LessOrEqual(t, waited, cpuThrottleMax+100*time.Millisecond)
+
+	// Cancelled walks do not wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Less(t, throttleBusy(enf.cpuThrottle(ctx), 20*time.Millisecond), 50*time.Millisecond)
+}
@@ -0,0 +1,644 @@
+package enforcer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/recovery"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/tracing"
+)
+
+// Outcome describes the result of enforcing permissions on a single path
+type Outcome int
+
+const (
+	Skipped Outcome = iota // Permissions were already correct or the path is gone
+	Fixed                  // Permissions were changed
+	Failed                 // Permissions could not be checked or changed
+)
+
+// AddTo counts the outcome towards a run result
+func (o Outcome) AddTo(result *status.RunResult) {
+	switch o {
+	case Fixed:
+		result.Fixed++
+	case Failed:
+		result.Failed++
+	default:
+		result.Skipped++
+	}
+}
+
+// Enforcer applies configured permissions to files and directories
+type Enforcer struct {
+	logger   *log.Logger
+	activity *activity.Hub
+	paused   atomic.Bool // Checks continue but nothing is changed
+	dryRun   atomic.Bool // Changes are reported as if made, but not made
+	sandbox  atomic.Bool // Changes are confined to the watch directories
+
+	allowProtected atomic.Pointer[[]string] // Protected paths enforcement may change nonetheless
+
+	foldersMu sync.Mutex
+	folders   map[string]bool // Pause state of watch directories set at runtime, by name
+
+	idsMu sync.Mutex
+	ids   map[string]int // Resolved user and group names
+
+	scanObserversMu sync.Mutex
+	scanObservers   []status.ScanObserver
+
+	workersMu sync.Mutex
+	workers   chan struct{} // Slots of the paths fixed at once across all tree runs
+
+	memoryLimit atomic.Uint64 // Soft limit of the heap in bytes, 0 for none
+	cpuLimit    atomic.Uint64 // Cores tree runs may keep busy as float64 bits, 0 for no limit
+
+	priority       atomic.Pointer[scanPriority] // Priority of the threads walking scans, nil to leave it
+	priorityWarned atomic.Bool                  // Failing to set the priority was logged
+	ownersWarned   atomic.Bool                  // Owners left alone for want of Unix owners was logged
+
+	verified *verifiedCache // Paths recently seen with the correct permissions
+	fs       FSOps          // File system paths are read and changed through
+	remotes  remotes        // Connections to the hosts of remote watch directories
+}
+
+// New creates a new enforcer fixing one path at a time
+func New(hub *activity.Hub, logger *log.Logger) *Enforcer {
+	return &Enforcer{
+		logger:   logger,
+		activity: hub,
+		workers:  make(chan struct{}, 1),
+		verified: newVerifiedCache(verifiedSize),
+		fs:       OS,
+	}
+}
+
+// SetWorkers sets how many paths tree runs fix at once, shared by all runs
+// so concurrent runs of several folders stay within the budget. Runs in
+// progress keep the previous budget.
+func (e *Enforcer) SetWorkers(n int) {
+	e.workersMu.Lock()
+	defer e.workersMu.Unlock()
+
+	e.workers = make(chan struct{}, max(n, 1))
+}
+
+// workerSlots returns the slots of the current worker budget
+func (e *Enforcer) workerSlots() chan struct{} {
+	e.workersMu.Lock()
+	defer e.workersMu.Unlock()
+
+	return e.workers
+}
+
+// SetDryRun makes enforcement report the changes it would make as fixed,
+// without making them or running pre_check commands
+func (e *Enforcer) SetDryRun(on bool) {
+	e.dryRun.Store(on)
+}
+
+// DryRun reports whether enforcement only reports changes
+func (e *Enforcer) DryRun() bool {
+	return e.dryRun.Load()
+}
+
+// Pause stops enforcement from changing permissions until Resume is called.
+// Paths are still checked, and those needing a change count as skipped.
+func (e *Enforcer) Pause() {
+	e.paused.Store(true)
+	metrics.Paused.Set(1)
+}
+
+// Resume lets enforcement change permissions again
+func (e *Enforcer) Resume() {
+	e.paused.Store(false)
+	metrics.Paused.Set(0)
+}
+
+// Paused reports whether enforcement is paused
+func (e *Enforcer) Paused() bool {
+	return e.paused.Load()
+}
+
+// PauseFolder pauses enforcement for the named watch directory only
+func (e *Enforcer) PauseFolder(name string) {
+	e.setFolderPaused(name, true)
+}
+
+// ResumeFolder resumes enforcement for the named watch directory, even if
+// it is paused by the configuration
+func (e *Enforcer) ResumeFolder(name string) {
+	e.setFolderPaused(name, false)
+}
+
+// FolderPaused reports whether enforcement is paused for a watch directory,
+// either as a whole, at runtime, or by its configuration
+func (e *Enforcer) FolderPaused(watchDir config.WatchDir) bool {
+	if e.Paused() {
+		return true
+	}
+
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	if paused, ok := e.folders[watchDir.Name]; ok {
+		return paused
+	}
+	return watchDir.Paused
+}
+
+// setFolderPaused overrides the configured pause state of a watch directory
+func (e *Enforcer) setFolderPaused(name string, paused bool) {
+	e.foldersMu.Lock()
+	defer e.foldersMu.Unlock()
+
+	if e.folders == nil {
+		e.folders = make(map[string]bool)
+	}
+	e.folders[name] = paused
+}
+
+// Change is a permission or ownership change that enforcement would make
+type Change struct {
+	Path     string
+	Kind     string // "file" or "directory"
+	OldMode  os.FileMode
+	NewMode  os.FileMode
+	OldOwner Owner
+	NewOwner Owner
+}
+
+// PathResult is the outcome of enforcement on a single path. Its change
+// holds the state found and the state wanted, which are equal for paths that
+// are already correct.
+type PathResult struct {
+	Change
+	Outcome Outcome
+	Err     error // Why the path failed or was skipped as busy, matching ErrPermissionDenied or another kind with errors.Is where it has one
+}
+
+// Tree sets the correct permissions on root and everything below it that
+// matches the watch directory's patterns, returning a summary of the run
+func (e *Enforcer) Tree(ctx context.Context, root string, watchDir config.WatchDir) status.RunResult {
+	return e.TreeEach(ctx, root, watchDir, nil)
+}
+
+// TreeEach is Tree, also passing the result of each path to visit unless it
+// is nil. Files are fixed by up to the worker budget at once, so visit may
+// see them out of order, but never concurrently.
+func (e *Enforcer) TreeEach(ctx context.Context, root string, watchDir config.WatchDir, visit func(PathResult)) status.RunResult {
+	result := status.RunResult{Started: time.Now()}
+
+	_, span := tracing.Start(ctx, "enforce.tree",
+		tracing.String("folder", watchDir.Name),
+		tracing.String("path", escape.Text(root)),
+	)
+	defer func() {
+		span.SetAttributes(resultAttrs(result)...)
+		span.Finish()
+	}()
+
+	metrics.ActiveScans.Add(1, watchDir.Name)
+	defer metrics.ActiveScans.Add(-1, watchDir.Name)
+
+	progress, stop := e.startScan(ctx, root, watchDir)
+	defer stop()
+
+	var (
+		mu      sync.Mutex // Guards result and calls of visit
+		running sync.WaitGroup
+	)
+	record := func(fixed PathResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fixed.Outcome.AddTo(&result)
+		progress.Update(result)
+		if visit != nil {
+			visit(fixed)
+		}
+	}
+
+	slots := e.workerSlots()
+	wants := e.walkTargetsIn(watchDir)
+	e.walk(ctx, root, watchDir, func(path string, info os.FileInfo) {
+		slots <- struct{}{}
+
+		// Directories are fixed before the walk enters them, so ones that
+		// could not be read before can be walked
+		if info.IsDir() {
+			record(e.fixWalked(watchDir, path, info, wants))
+			<-slots
+			return
+		}
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer func() { <-slots }()
+			if recovery.Run(e.logger, "enforcer", func() { record(e.fixWalked(watchDir, path, info, wants)) }) {
+				record(PathResult{Change: Change{Path: path}, Outcome: Failed, Err: errPanicked})
+			}
+		}()
+	}, func(path string, err error) {
+		e.publishError(path, err)
+		record(PathResult{Change: Change{Path: path}, Outcome: Failed, Err: err})
+	})
+	running.Wait()
+
+	result.Duration = time.Since(result.Started)
+	progress.Finish(result)
+	return result
+}
+
+// PlanEach checks root and everything below it without changing anything,
+// passing the result of each path to visit in walk order: fixed for paths
+// that Tree would change, skipped for those that are already correct, and
+// failed for those that could not be checked.
+func (e *Enforcer) PlanEach(ctx context.Context, root string, watchDir config.WatchDir, visit func(PathResult)) status.RunResult {
+	result := status.RunResult{Started: time.Now()}
+
+	_, span := tracing.Start(ctx, "enforce.plan",
+		tracing.String("folder", watchDir.Name),
+		tracing.String("path", escape.Text(root)),
+	)
+	defer func() {
+		span.SetAttributes(resultAttrs(result)...)
+		span.Finish()
+	}()
+
+	progress, stop := e.startScan(ctx, root, watchDir)
+	defer stop()
+	defer func() { progress.Finish(result) }()
+
+	wants := e.walkTargetsIn(watchDir)
+	e.walk(ctx, root, watchDir, func(path string, info os.FileInfo) {
+		defer func() { progress.Update(result) }()
+
+		planned := PathResult{Change: Change{Path: path, Kind: kindOf(info.IsDir())}, Outcome: Failed}
+		defer func() {
+			planned.Outcome.AddTo(&result)
+			visit(planned)
+		}()
+
+		want, err := wants.of(info.IsDir())
+		if err != nil {
+			planned.Err = err
+			return
+		}
+
+		planned.OldMode, planned.NewMode, planned.OldOwner, planned.NewOwner = want.applyTo(info)
+		planned.Outcome = Skipped
+		if planned.NewMode != planned.OldMode || planned.NewOwner != planned.OldOwner {
+			planned.Outcome = Fixed
+		}
+	}, func(path string, err error) {
+		result.Failed++
+		progress.Update(result)
+		visit(PathResult{Change: Change{Path: path}, Outcome: Failed, Err: err})
+	})
+
+	result.Duration = time.Since(result.Started)
+	return result
+}
+
+// walk calls visit for root and every path below it that matches the watch
+// directory's patterns, directories before their contents. Excluded and
+// protected directories are skipped with everything below them. Paths that
+// cannot be accessed are passed to onError; paths removed mid-walk are
+// ignored. The walk slows down near the memory limit and the CPU limit and
+// stops early once ctx is cancelled. The walk runs at the scan priority, if
+// one is set.
+func (e *Enforcer) walk(ctx context.Context, root string, watchDir config.WatchDir, visit func(string, os.FileInfo), onError func(string, error)) {
+	var err error
+	e.lowPriority(func() { err = e.walkDir(ctx, root, watchDir, visit, onError) })
+
+	if ctx.Err() != nil {
+		e.logger.Info("Enforcement cancelled", "path", escape.Text(root))
+	} else if err != nil {
+		e.logger.Error("Error during enforcement", "path", escape.Text(root), "error", err)
+	}
+}
+
+// walkDir walks root for walk, returning the error that stopped it
+func (e *Enforcer) walkDir(ctx context.Context, root string, watchDir config.WatchDir, visit func(string, os.FileInfo), onError func(string, error)) error {
+	throttleMemory, throttleCPU := e.memoryThrottle(ctx), e.cpuThrottle(ctx)
+	ops, err := e.opsFor(&watchDir)
+	if err != nil {
+		e.logger.Error("Failed to reach remote folder", "folder", watchDir.Name, "host", watchDir.Remote.Address(), "error", err)
+		metrics.RecordFailure("remote", err)
+		onError(root, err)
+		return nil
+	}
+
+	protected := e.protectedDirs(ops, root)
+	return ops.Walk(ctx, root, func(path string, d fs.DirEntry, err error) error {
+		throttleMemory()
+		throttleCPU()
+
+		// Throttling may have waited out the cancellation. Stop between
+		// paths, leaving no change half done.
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+
+		// Excluded directories are skipped with everything below them, and
+		// other paths that are not processed are never read
+		if err == nil && d.IsDir() && path != watchDir.Path && watchDir.ShouldExclude(path) {
+			return filepath.SkipDir
+		}
+		if err == nil && d.IsDir() {
+			if protected, ok := protected(path); ok {
+				e.logger.Warn("Not entering protected system path", "path", escape.Text(path), "protected", protected)
+				return filepath.SkipDir
+			}
+		}
+		if err == nil && !watchDir.ShouldProcess(path) {
+			return nil
+		}
+
+		var info os.FileInfo
+		if err == nil {
+			info, err = d.Info()
+		}
+
+		if err != nil {
+			// Paths removed mid-walk are not failures
+			if errors.Is(err, fs.ErrNotExist) {
+				e.logger.Debug("Path disappeared during enforcement", "path", escape.Text(path))
+				return nil
+			}
+			err = classify(err)
+			e.logger.Warn("Error accessing path during enforcement", "path", escape.Text(path), "error", err)
+			metrics.RecordFailure("walk", err)
+			onError(path, err)
+			return nil // Continue walking
+		}
+
+		visit(path, info)
+		return nil
+	})
+}
+
+// FixIn sets the permissions and owner configured for a watch directory on a
+// file or directory within it, unless the watch directory is paused or its
+// pre_check command vetoes the change. The result holds the state found and
+// the state wanted.
+func (e *Enforcer) FixIn(watchDir config.WatchDir, path string, isDir bool) PathResult {
+	want, err := e.targetIn(watchDir, isDir)
+	if err != nil {
+		return e.invalidTarget(watchDir, path, isDir, err)
+	}
+	return e.fix(path, nil, isDir, e.FolderPaused(watchDir), want, &watchDir)
+}
+
+// FixInfo is FixIn for a path whose metadata the caller has just read with
+// os.Lstat, sparing a second read
+func (e *Enforcer) FixInfo(watchDir config.WatchDir, path string, info os.FileInfo) PathResult {
+	want, err := e.targetIn(watchDir, info.IsDir())
+	if err != nil {
+		return e.invalidTarget(watchDir, path, info.IsDir(), err)
+	}
+	return e.fix(path, info, info.IsDir(), e.FolderPaused(watchDir), want, &watchDir)
+}
+
+// fixWalked is FixIn for a path found by a walk, reusing the metadata the
+// walk read instead of reading it again
+func (e *Enforcer) fixWalked(watchDir config.WatchDir, path string, info os.FileInfo, wants walkTargets) PathResult {
+	isDir := info.IsDir()
+	want, err := wants.of(isDir)
+	if err != nil {
+		return e.invalidTarget(watchDir, path, isDir, err)
+	}
+	return e.fix(path, info, isDir, e.FolderPaused(watchDir), want, &watchDir)
+}
+
+// invalidTarget reports a path that cannot be fixed because the permissions
+// configured for its watch directory are invalid
+func (e *Enforcer) invalidTarget(watchDir config.WatchDir, path string, isDir bool, err error) PathResult {
+	e.logger.Error("Invalid permissions configured", "folder", watchDir.Name, "path", escape.Text(path), "error", err)
+	e.publishError(path, err)
+	return PathResult{Change: Change{Path: path, Kind: kindOf(isDir)}, Outcome: Failed, Err: err}
+}
+
+// Fix sets the correct permissions on a file or directory
+func (e *Enforcer) Fix(path string, modeStr string, isDir bool) Outcome {
+	want, err := modeTarget(modeStr)
+	if err != nil {
+		e.logger.Error("Invalid file mode format", "mode", modeStr, "path", escape.Text(path), "error", err)
+		e.publishError(path, err)
+		return Failed
+	}
+	return e.fix(path, nil, isDir, e.Paused(), want, nil).Outcome
+}
+
+// fix checks the permissions and owner of a path and corrects them unless
+// paused or vetoed by the pre_check command of watchDir, if any. The path's
+// metadata is read unless stat already holds it. A symlink at path is fixed
+// itself, never the file it points to.
+func (e *Enforcer) fix(path string, stat os.FileInfo, isDir, paused bool, want target, watchDir *config.WatchDir) PathResult {
+	entityType := kindOf(isDir)
+	result := PathResult{Change: Change{Path: path, Kind: entityType}, Outcome: Failed}
+
+	ops, err := e.opsFor(watchDir)
+	if err != nil {
+		e.logger.Error("Failed to reach remote folder", "folder", watchDir.Name, "host", watchDir.Remote.Address(), "error", err)
+		metrics.RecordFailure("remote", err)
+		e.publishError(path, err)
+		result.Err = err
+		return result
+	}
+
+	if stat == nil {
+		if stat, err = ops.Lstat(path); err != nil {
+			err = classify(err)
+			e.verified.remove(path)
+			e.logger.Error("Failed to stat file for permission fix", "path", escape.Text(path), "error", err)
+			metrics.RecordFailure("stat", err)
+			e.publishError(path, err)
+			result.Err = err
+			return result
+		}
+	}
+
+	currentMode, newMode, currentOwner, newOwner := want.applyTo(stat)
+	result.OldMode, result.NewMode, result.OldOwner, result.NewOwner = currentMode, newMode, currentOwner, newOwner
+
+	// Only change what differs
+	if newMode == currentMode && newOwner == currentOwner {
+		e.verified.add(verifiedPath{path: path, isDir: isDir, mode: currentMode, owner: currentOwner})
+		result.Outcome = Skipped
+		return result
+	}
+
+	if paused {
+		e.logger.Debug("Enforcement paused, not fixing permissions",
+			"path", escape.Text(path),
+			"old_mode", currentMode,
+			"new_mode", newMode,
+			"old_owner", currentOwner,
+			"new_owner", newOwner,
+		)
+		result.Outcome = Skipped
+		return result
+	}
+
+	if err := e.checkProtected(ops, path); err != nil {
+		e.logger.Error("Refusing to fix permissions", "path", escape.Text(path), "error", err)
+		metrics.RecordFailure("protected", err)
+		e.publishError(path, err)
+		result.Err = err
+		return result
+	}
+
+	attrs := []any{"path", escape.Text(path), "type", entityType, "old_mode", currentMode, "new_mode", newMode}
+	if newOwner != currentOwner {
+		attrs = append(attrs, "old_owner", currentOwner, "new_owner", newOwner)
+	}
+	if e.DryRun() {
+		e.logger.Info("Would fix permissions", append(attrs, "dry_run", true)...)
+		e.activity.Publish(newFixedEntry(path, entityType, currentMode, newMode, currentOwner, newOwner, true))
+		result.Outcome = Fixed
+		return result
+	}
+
+	if watchDir != nil && watchDir.PreCheck != "" {
+		allowed, err := e.preCheck(*watchDir, path, entityType, currentMode, newMode)
+		if err != nil {
+			e.logger.Error("Pre-check failed, leaving permissions unchanged", "folder", watchDir.Name, "path", escape.Text(path), "error", err)
+			metrics.RecordFailure("pre_check", err)
+			e.publishError(path, err)
+			result.Err = err
+			return result
+		}
+		if !allowed {
+			result.Outcome = Skipped
+			return result
+		}
+	}
+
+	root := ""
+	if watchDir != nil {
+		root = watchDir.Path
+	}
+	change, release, err := e.resolve(ops, root, path)
+	if err != nil {
+		e.logger.Error("Refusing to fix permissions", "path", escape.Text(path), "error", err)
+		metrics.RecordFailure("resolve", err)
+		e.publishError(path, err)
+		result.Err = err
+		return result
+	}
+	defer release()
+
+	// Change the owner first, as chown may clear setuid and setgid bits
+	if newOwner != currentOwner {
+		if err := change.chown(change.name, newOwner.UID, newOwner.GID); err != nil {
+			err = classify(withPath(err, path))
+			if errors.Is(err, ErrBusy) {
+				return e.busy(result, "chown", err)
+			}
+			e.logger.Error("Failed to fix owner", "path", escape.Text(path), "owner", newOwner, "error", err)
+			metrics.RecordFailure("chown", err)
+			e.publishError(path, err)
+			result.Err = err
+			return result
+		}
+	}
+	if newMode != currentMode {
+		if err := change.chmod(change.name, newMode); err != nil {
+			err = classify(withPath(err, path))
+			if errors.Is(err, ErrBusy) {
+				return e.busy(result, "chmod", err)
+			}
+			e.logger.Error("Failed to fix permissions", "path", escape.Text(path), "mode", newMode, "error", err)
+			metrics.RecordFailure("chmod", err)
+			e.publishError(path, err)
+			result.Err = err
+			return result
+		}
+	}
+
+	e.verified.add(verifiedPath{path: path, isDir: isDir, mode: newMode, owner: newOwner})
+	e.logger.Info("Fixed permissions", attrs...)
+	metrics.Fixed.Inc(entityType)
+	e.activity.Publish(newFixedEntry(path, entityType, currentMode, newMode, currentOwner, newOwner, false))
+	result.Outcome = Fixed
+	return result
+}
+
+// busy skips a path the system refused to change while it is in use,
+// leaving it to the next poll or scan rather than failing it
+func (e *Enforcer) busy(result PathResult, op string, err error) PathResult {
+	e.logger.Info("Path busy, retrying on the next cycle", "path", escape.Text(result.Path), "op", op, "error", err)
+	metrics.Busy.Inc(op)
+	result.Outcome = Skipped
+	result.Err = err
+	return result
+}
+
+// kindOf names the kind of a path for logs and activity entries
+func kindOf(isDir bool) string {
+	if isDir {
+		return "directory"
+	}
+	return "file"
+}
+
+// newFixedEntry describes a change for activity subscribers. The owner is
+// only included when it changed.
+func newFixedEntry(path, kind string, oldMode, newMode os.FileMode, oldOwner, newOwner Owner, dryRun bool) activity.Entry {
+	entry := activity.Entry{
+		Type:    activity.TypeFixed,
+		Path:    path,
+		Kind:    kind,
+		OldMode: oldMode.String(),
+		NewMode: newMode.String(),
+		DryRun:  dryRun,
+	}
+	if newOwner != oldOwner {
+		entry.OldOwner, entry.NewOwner = oldOwner.String(), newOwner.String()
+	}
+	return entry
+}
+
+// resultAttrs describes a run result as span attributes
+func resultAttrs(result status.RunResult) []tracing.Attr {
+	return []tracing.Attr{
+		tracing.Int("paths.fixed", result.Fixed),
+		tracing.Int("paths.skipped", result.Skipped),
+		tracing.Int("paths.failed", result.Failed),
+	}
+}
+
+// publishError reports a failed enforcement to activity subscribers
+func (e *Enforcer) publishError(path string, err error) {
+	e.activity.Publish(activity.Entry{
+		Type:  activity.TypeError,
+		Path:  path,
+		Error: err.Error(),
+	})
+}
+
+// parseMode parses an octal mode string such as "0644"
+func parseMode(modeStr string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return os.FileMode(mode), nil
+}
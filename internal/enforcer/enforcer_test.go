@@ -0,0 +1,468 @@
+package enforcer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEnforcer creates an enforcer with quiet logging
+func newTestEnforcer() *Enforcer {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	return New(activity.NewHub(), logger)
+}
+
+// plan collects the changes PlanEach reports below root, sorted by path
+func plan(enf *Enforcer, root string, watchDir config.WatchDir) ([]Change, status.RunResult) {
+	var changes []Change
+	result := enf.PlanEach(context.Background(), root, watchDir, func(planned PathResult) {
+		if planned.Outcome == Fixed {
+			changes = append(changes, planned.Change)
+		}
+	})
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return changes, result
+}
+
+func TestFix(t *testing.T) {
+	enf := newTestEnforcer()
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	assert.Equal(t, Fixed, enf.Fix(file, "0644", false))
+	assert.Equal(t, Skipped, enf.Fix(file, "0644", false))
+	assert.Equal(t, Failed, enf.Fix(file, "", false))
+	assert.Equal(t, Failed, enf.Fix(file, "0999", false))
+	assert.Equal(t, Failed, enf.Fix(filepath.Join(dir, "missing"), "0644", false))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestFixInfo(t *testing.T) {
+	enf := newTestEnforcer()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	watchDir := config.WatchDir{Path: dir, FileMode: "0644", DirMode: "0755"}
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	fixed := enf.FixInfo(watchDir, file, info)
+	assert.Equal(t, Fixed, fixed.Outcome)
+	assert.Equal(t, os.FileMode(0600), fixed.OldMode)
+
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	assert.Equal(t, Skipped, enf.FixInfo(watchDir, file, info).Outcome)
+}
+
+func TestPause(t *testing.T) {
+	enf := newTestEnforcer()
+	file := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	enf.Pause()
+	assert.True(t, enf.Paused())
+	assert.Equal(t, Skipped, enf.Fix(file, "0644", false))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "unchanged while paused")
+
+	enf.Resume()
+	assert.False(t, enf.Paused())
+	assert.Equal(t, Fixed, enf.Fix(file, "0644", false))
+}
+
+func TestDryRun(t *testing.T) {
+	hub := activity.NewHub()
+	entries, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	enf := New(hub, logger)
+	enf.SetDryRun(true)
+	assert.True(t, enf.DryRun())
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	watchDir := config.WatchDir{Path: dir, FileMode: "0644", PreCheck: "touch " + filepath.Join(dir, "ran")}
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, file, false).Outcome, "reported as fixed")
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "unchanged in a dry run")
+	assert.NoFileExists(t, filepath.Join(dir, "ran"), "pre_check is not run")
+
+	entry := <-entries
+	assert.True(t, entry.DryRun)
+	assert.Equal(t, "-rw-r--r--", entry.NewMode)
+}
+
+func TestPauseFolder(t *testing.T) {
+	enf := newTestEnforcer()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	media := config.WatchDir{Name: "media", Path: dir, FileMode: "0644", DirMode: "0755", Paused: true}
+	assert.True(t, enf.FolderPaused(media), "paused by configuration")
+	assert.Equal(t, Skipped, enf.FixIn(media, file, false).Outcome)
+
+	enf.ResumeFolder("media")
+	assert.False(t, enf.FolderPaused(media), "resumed at runtime")
+
+	enf.PauseFolder("media")
+	assert.True(t, enf.FolderPaused(media))
+	assert.False(t, enf.FolderPaused(config.WatchDir{Name: "other"}))
+	assert.Equal(t, Fixed, enf.Fix(file, "0644", false), "only enforcement within the folder is paused")
+}
+
+func TestTree(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.Mkdir(sub, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "movie.mkv"), []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "movie.tmp"), []byte("x"), 0600))
+	backup := filepath.Join(root, "backup.tmp")
+	require.NoError(t, os.Mkdir(backup, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(backup, "movie.mkv"), []byte("x"), 0600))
+
+	watchDir := config.WatchDir{
+		Path:     root,
+		Exclude:  []string{"*.tmp"},
+		FileMode: "0644",
+		DirMode:  "0755",
+	}
+
+	result := enf.Tree(context.Background(), root, watchDir)
+	assert.Equal(t, 2, result.Fixed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Zero(t, result.Failed)
+
+	info, err := os.Stat(filepath.Join(sub, "movie.tmp"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "excluded file must not change")
+	info, err = os.Stat(filepath.Join(backup, "movie.mkv"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "files in excluded directories must not change")
+
+	// A second run finds nothing to do
+	result = enf.Tree(context.Background(), root, watchDir)
+	assert.Zero(t, result.Fixed)
+	assert.Equal(t, 3, result.Skipped)
+}
+
+func TestTreeEach(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+	file := filepath.Join(root, "movie.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	outcomes := map[string]PathResult{}
+	result := enf.TreeEach(context.Background(), root, config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}, func(fixed PathResult) {
+		outcomes[fixed.Path] = fixed
+	})
+	assert.Equal(t, 1, result.Fixed)
+	require.Len(t, outcomes, 2)
+
+	assert.Equal(t, Skipped, outcomes[root].Outcome)
+	assert.Equal(t, "directory", outcomes[root].Kind)
+	assert.Equal(t, outcomes[root].OldMode, outcomes[root].NewMode)
+
+	assert.Equal(t, Fixed, outcomes[file].Outcome)
+	assert.Equal(t, os.FileMode(0600), outcomes[file].OldMode)
+	assert.Equal(t, os.FileMode(0644), outcomes[file].NewMode)
+	assert.NoError(t, outcomes[file].Err)
+}
+
+func TestTreeWorkers(t *testing.T) {
+	enf := newTestEnforcer()
+	enf.SetWorkers(4)
+
+	// Two folders at once share the budget
+	roots := []string{t.TempDir(), t.TempDir()}
+	for _, root := range roots {
+		require.NoError(t, os.Chmod(root, 0755))
+		require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0700))
+		for i := range 50 {
+			require.NoError(t, os.WriteFile(filepath.Join(root, "sub", fmt.Sprintf("ep%02d.mkv", i)), nil, 0600))
+		}
+	}
+
+	results := make([]status.RunResult, len(roots))
+	visited := make([]int, len(roots))
+	var running sync.WaitGroup
+	for i, root := range roots {
+		running.Go(func() {
+			watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+			results[i] = enf.TreeEach(context.Background(), root, watchDir, func(PathResult) { visited[i]++ })
+		})
+	}
+	running.Wait()
+
+	for i, root := range roots {
+		assert.Equal(t, 51, results[i].Fixed, "files and the subdirectory")
+		assert.Equal(t, 52, visited[i])
+
+		info, err := os.Stat(filepath.Join(root, "sub", "ep49.mkv"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	}
+}
+
+func TestTreeCancelled(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "movie.mkv"), []byte("x"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := enf.Tree(ctx, root, config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"})
+	assert.Zero(t, result.Fixed+result.Skipped+result.Failed)
+
+	info, err := os.Stat(filepath.Join(root, "movie.mkv"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestPlan(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+
+	file := filepath.Join(root, "movie.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "done.mkv"), []byte("x"), 0644))
+
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	changes, result := plan(enf, root, watchDir)
+	require.Len(t, changes, 1)
+	assert.Equal(t, Change{Path: file, Kind: "file", OldMode: 0600, NewMode: 0644}, changes[0])
+	assert.Equal(t, 1, result.Fixed)
+	assert.Equal(t, 2, result.Skipped)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "plan must not change anything")
+}
+
+func TestPlanEach(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+	file := filepath.Join(root, "movie.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "broken")))
+
+	outcomes := map[string]Outcome{}
+	result := enf.PlanEach(context.Background(), root, config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}, func(planned PathResult) {
+		outcomes[planned.Path] = planned.Outcome
+		if planned.Outcome == Failed {
+			assert.Error(t, planned.Err)
+		}
+	})
+
+	// Symlinks are planned themselves, so a broken one is no failure
+	assert.Equal(t, map[string]Outcome{
+		root:                          Skipped,
+		file:                          Fixed,
+		filepath.Join(root, "broken"): Skipped,
+	}, outcomes)
+	assert.Equal(t, 1, result.Fixed)
+	assert.Equal(t, 2, result.Skipped)
+	assert.Zero(t, result.Failed)
+}
+
+func TestTreeSymlinks(t *testing.T) {
+	enf := newTestEnforcer()
+	root, outside := t.TempDir(), t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+	require.NoError(t, os.Chmod(outside, 0700))
+	secret := filepath.Join(outside, "secret.mkv")
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0600))
+	require.NoError(t, os.Symlink(secret, filepath.Join(root, "file")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "dir")))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	// Neither symlinks to files nor to directories outside are followed
+	result := enf.Tree(context.Background(), root, watchDir)
+	assert.Equal(t, 3, result.Skipped)
+	assert.Zero(t, result.Fixed+result.Failed)
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, filepath.Join(root, "file"), false).Outcome)
+
+	for path, mode := range map[string]os.FileMode{secret: 0600, outside: 0700} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), path)
+	}
+
+	// Symlinks have no mode to change
+	assert.Error(t, OS.Lchmod(filepath.Join(root, "file"), 0644))
+	assert.NoError(t, OS.Lchmod(secret, 0640))
+	info, err := os.Stat(secret)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+// benchFilesEnv lists the tree sizes benchmarks run against, as a comma
+// separated list of file counts, e.g. 100000,1000000,5000000
+const benchFilesEnv = "OWNARR_BENCH_FILES"
+
+// benchSizes returns the tree sizes to benchmark, 10k and 100k files unless
+// set by benchFilesEnv
+func benchSizes(b *testing.B) []int {
+	value := os.Getenv(benchFilesEnv)
+	if value == "" {
+		return []int{10_000, 100_000}
+	}
+
+	var sizes []int
+	for _, field := range strings.Split(value, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		require.NoError(b, err, "invalid %s", benchFilesEnv)
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// makeBenchTree fills root with files spread over directories of 100 files,
+// two levels deep like a library of shows and seasons. Every tenth file is a
+// .part file for the exclude pattern of benchWatchDir. All paths already
+// have the right mode, as in the steady state of a periodic scan.
+func makeBenchTree(b *testing.B, root string, files int) {
+	require.NoError(b, os.Chmod(root, 0755))
+	for i := 0; i < files; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("show%03d", i/10_000), fmt.Sprintf("season%03d", i/100%100))
+		if i%100 == 0 {
+			require.NoError(b, os.MkdirAll(dir, 0755))
+		}
+
+		name := fmt.Sprintf("episode%05d.mkv", i)
+		if i%10 == 0 {
+			name += ".part"
+		}
+		require.NoError(b, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+}
+
+// benchWatchDir is the configuration benchmarks enforce on their trees,
+// compiled like a loaded configuration
+func benchWatchDir(b *testing.B, root string) config.WatchDir {
+	watchDir := config.WatchDir{
+		Name:     "bench",
+		Path:     root,
+		Exclude:  []string{"*.part", "*.tmp"},
+		FileMode: "0644",
+		DirMode:  "0755",
+	}
+	require.NoError(b, watchDir.Compile())
+	return watchDir
+}
+
+// benchTrees runs bench against a tree of each size
+func benchTrees(b *testing.B, bench func(b *testing.B, root string, files int)) {
+	for _, files := range benchSizes(b) {
+		b.Run("files="+strconv.Itoa(files), func(b *testing.B) {
+			root := b.TempDir()
+			makeBenchTree(b, root, files)
+			bench(b, root, files)
+		})
+	}
+}
+
+func BenchmarkTree(b *testing.B) {
+	enf := newTestEnforcer()
+	benchTrees(b, func(b *testing.B, root string, files int) {
+		watchDir := benchWatchDir(b, root)
+		b.ReportAllocs()
+		for b.Loop() {
+			result := enf.Tree(context.Background(), root, watchDir)
+			require.Zero(b, result.Fixed+result.Failed)
+		}
+		b.ReportMetric(float64(files)*float64(b.N)/b.Elapsed().Seconds(), "files/s")
+	})
+}
+
+func BenchmarkPlan(b *testing.B) {
+	enf := newTestEnforcer()
+	benchTrees(b, func(b *testing.B, root string, files int) {
+		watchDir := benchWatchDir(b, root)
+		b.ReportAllocs()
+		for b.Loop() {
+			_, result := plan(enf, root, watchDir)
+			require.Zero(b, result.Fixed+result.Failed)
+		}
+		b.ReportMetric(float64(files)*float64(b.N)/b.Elapsed().Seconds(), "files/s")
+	})
+}
+
+func BenchmarkFixIn(b *testing.B) {
+	enf := newTestEnforcer()
+	root := b.TempDir()
+	file := filepath.Join(root, "movie.mkv")
+	require.NoError(b, os.WriteFile(file, nil, 0644))
+	watchDir := benchWatchDir(b, root)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		enf.FixIn(watchDir, file, false)
+	}
+}
+
+func TestTreeHostileNames(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	require.NoError(t, os.Chmod(root, 0755))
+
+	// Names torrents bring along: newlines, escape sequences, Latin-1 bytes
+	names := []string{"line\nbreak.mkv", "\x1b[31mred.mkv", "caf\xe9.mkv", "tab\tand\rcr.srt", "sub\n\xff"}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("x"), 0600))
+	}
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	var changed []string
+	result := enf.TreeEach(context.Background(), root, watchDir, func(fixed PathResult) {
+		if fixed.Outcome == Fixed {
+			changed = append(changed, filepath.Base(fixed.Path))
+		}
+	})
+	assert.Equal(t, len(names), result.Fixed)
+	assert.Zero(t, result.Failed)
+	assert.ElementsMatch(t, names, changed)
+
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(root, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), "%q", name)
+	}
+}
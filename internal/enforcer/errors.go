@@ -0,0 +1,66 @@
+package enforcer
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Kinds of enforcement errors, matched with errors.Is
+var (
+	// ErrPermissionDenied marks paths ownarr lacks the rights to change,
+	// usually as it does not run as root or the owner of the path
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrUnsupportedFilesystem marks paths on file systems that cannot take
+	// the change, such as read-only mounts or FAT and SMB shares without
+	// Unix owners and modes
+	ErrUnsupportedFilesystem = errors.New("unsupported file system")
+
+	// ErrOutsideRoot marks paths the sandbox refused to change, as they
+	// resolve to a file outside their watch directory
+	ErrOutsideRoot = errors.New("resolves outside the watch directory")
+
+	// ErrProtected marks paths refused as they are or resolve to a
+	// protected system path
+	ErrProtected = errors.New("protected system path")
+
+	// ErrBusy marks paths the system refused to change while they are in
+	// use, such as running executables on some file systems. They count as
+	// skipped, not failed, and the next poll or scan tries them again.
+	ErrBusy = errors.New("busy")
+)
+
+// errPanicked marks paths whose fix panicked, logged with its stack
+var errPanicked = errors.New("fix panicked, see the log")
+
+// kindError gives an error of a file operation the kind of one of the
+// enforcement errors, keeping its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Error returns the message of the underlying error
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the kind and the underlying error
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classify returns err with its kind, if it has one of the kinds that
+// callers branch on
+func classify(err error) error {
+	switch {
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		return &kindError{kind: ErrPermissionDenied, err: err}
+	case errors.Is(err, syscall.EROFS), errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOTSUP):
+		return &kindError{kind: ErrUnsupportedFilesystem, err: err}
+	case errors.Is(err, syscall.EBUSY), errors.Is(err, syscall.ETXTBSY):
+		return &kindError{kind: ErrBusy, err: err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,27 @@
+package enforcer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	err := classify(&os.PathError{Op: "chown", Path: "/data/a", Err: syscall.EPERM})
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	assert.ErrorIs(t, err, syscall.EPERM)
+	assert.Equal(t, "chown /data/a: operation not permitted", err.Error())
+
+	err = classify(&os.PathError{Op: "chmod", Path: "/data/a", Err: syscall.EROFS})
+	assert.ErrorIs(t, err, ErrUnsupportedFilesystem)
+	assert.NotErrorIs(t, err, ErrPermissionDenied)
+
+	err = classify(&os.PathError{Op: "chmod", Path: "/data/a", Err: syscall.ETXTBSY})
+	assert.ErrorIs(t, err, ErrBusy)
+
+	other := errors.New("boom")
+	assert.Equal(t, other, classify(other))
+}
@@ -0,0 +1,45 @@
+package enforcer
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/keksiqc/ownarr/internal/walk"
+)
+
+// FSOps are the file system operations enforcement reads and changes paths
+// through. OS is the real file system; MemFS holds one in memory, so changes
+// of owners and their failures can be tested without root. None of them
+// follow a symlink at the end of a name: a symlink is read and changed
+// itself, and the file it points to is never touched.
+type FSOps interface {
+	Lstat(name string) (os.FileInfo, error)                         // Reads the metadata of name
+	Lchown(name string, uid, gid int) error                         // Changes the owner of name
+	Lchmod(name string, mode os.FileMode) error                     // Changes the mode of name, failing for symlinks, which have none
+	EvalSymlinks(path string) (string, error)                       // Returns path with every symlink in it resolved
+	Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error // Walks root like walk.Dir
+}
+
+// OS is the real file system
+var OS FSOps = osFS{}
+
+// osFS passes file system operations to the system
+type osFS struct{}
+
+func (osFS) Lstat(name string) (os.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) Lchown(name string, uid, gid int) error     { return os.Lchown(name, uid, gid) }
+func (osFS) Lchmod(name string, mode os.FileMode) error { return lchmod(name, mode) }
+func (osFS) EvalSymlinks(path string) (string, error)   { return filepath.EvalSymlinks(path) }
+
+func (osFS) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	return walk.Dir(ctx, root, fn)
+}
+
+// SetFSOps sets the file system enforcement reads and changes paths
+// through, OS unless set. It must be set before enforcement starts. The
+// sandbox only confines changes to OS.
+func (e *Enforcer) SetFSOps(ops FSOps) {
+	e.fs = ops
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package enforcer
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// lchmod changes the mode of name without following a symlink there. Linux
+// takes no AT_SYMLINK_NOFOLLOW for fchmodat before fchmodat2, so name is
+// opened without following it and changed through /proc, which leaves no
+// moment for a symlink to be swapped in.
+func lchmod(name string, mode os.FileMode) error {
+	fd, err := unix.Open(name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	defer func() { _ = unix.Close(fd) }()
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return &os.PathError{Op: "chmod", Path: name, Err: unix.EOPNOTSUPP}
+	}
+
+	err = unix.Chmod(procFD+strconv.Itoa(fd), uint32(mode.Perm()))
+	if errors.Is(err, unix.ENOENT) {
+		// Without /proc, change name itself, which was no symlink just now
+		err = unix.Fchmodat(unix.AT_FDCWD, name, uint32(mode.Perm()), 0)
+	}
+	if err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package enforcer
+
+import (
+	"errors"
+	"os"
+)
+
+// lchmod changes the mode of name unless it is a symlink. Only Linux can
+// change a file opened without following it, so elsewhere name is checked
+// before it is changed.
+func lchmod(name string, mode os.FileMode) error {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return &os.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+	}
+	return os.Chmod(name, mode)
+}
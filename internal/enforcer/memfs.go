@@ -0,0 +1,290 @@
+package enforcer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinks is how many symlinks MemFS follows resolving a path before it
+// gives up, as Linux does
+const maxSymlinks = 40
+
+// MemFS is a file system held in memory, for testing enforcement without
+// root: any owner can be set, failures can be injected per operation and
+// path, and every change made is recorded. Symlinks are only followed as the
+// last element of a path, and only by EvalSymlinks. It is safe for
+// concurrent use.
+type MemFS struct {
+	mu      sync.Mutex
+	files   map[string]*memFile
+	fails   map[string]error // Injected errors by operation and path
+	changes []string         // Changes made, in order
+}
+
+// memFile is a file, directory or symlink of a MemFS
+type memFile struct {
+	mode   os.FileMode // Type and permission bits
+	owner  Owner
+	target string // Target of a symlink
+}
+
+// NewMemFS returns an empty MemFS
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*memFile), fails: make(map[string]error)}
+}
+
+// AddDir adds a directory, and any missing parents with its owner and mode
+// 0755
+func (m *MemFS) AddDir(path string, perm os.FileMode, owner Owner) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.add(path, &memFile{mode: os.ModeDir | perm.Perm(), owner: owner})
+}
+
+// AddFile adds a regular file, and any missing parents with its owner and
+// mode 0755
+func (m *MemFS) AddFile(path string, perm os.FileMode, owner Owner) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.add(path, &memFile{mode: perm.Perm(), owner: owner})
+}
+
+// AddSymlink adds a symlink to target, relative to the symlink's directory
+// unless absolute
+func (m *MemFS) AddSymlink(path, target string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.add(path, &memFile{mode: os.ModeSymlink | 0777, target: target})
+}
+
+// add stores file at path, adding the missing parents
+func (m *MemFS) add(path string, file *memFile) {
+	path = filepath.Clean(path)
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; !ok {
+			m.files[dir] = &memFile{mode: os.ModeDir | 0755, owner: file.owner}
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	m.files[path] = file
+}
+
+// Fail makes the operation op on path fail with err from now on, or succeed
+// again if err is nil. The operations are those of FSOps in lower case, with
+// "readdir" failing the listing of a directory during walks.
+func (m *MemFS) Fail(op, path string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := op + " " + filepath.Clean(path)
+	if err == nil {
+		delete(m.fails, key)
+		return
+	}
+	m.fails[key] = err
+}
+
+// Changes returns the changes made so far as "chown path uid:gid" and
+// "chmod path mode", in order
+func (m *MemFS) Changes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.changes)
+}
+
+// Lstat reads the metadata of name, not following symlinks
+func (m *MemFS) Lstat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{name: filepath.Base(name), file: *file}, nil
+}
+
+// Lchown changes the owner of name, not following symlinks. An ID of -1
+// leaves that part unchanged.
+func (m *MemFS) Lchown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path := filepath.Clean(name)
+	file, err := m.lookup("lchown", path)
+	if err != nil {
+		return err
+	}
+	if uid >= 0 {
+		file.owner.UID = uid
+	}
+	if gid >= 0 {
+		file.owner.GID = gid
+	}
+	m.changes = append(m.changes, fmt.Sprintf("chown %s %d:%d", path, uid, gid))
+	return nil
+}
+
+// Lchmod changes the permission bits of name, failing for symlinks as Linux
+// does
+func (m *MemFS) Lchmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path := filepath.Clean(name)
+	file, err := m.lookup("lchmod", path)
+	if err != nil {
+		return err
+	}
+	if file.mode&os.ModeSymlink != 0 {
+		return &fs.PathError{Op: "lchmod", Path: name, Err: syscall.EOPNOTSUPP}
+	}
+	file.mode = file.mode.Type() | mode.Perm()
+	m.changes = append(m.changes, fmt.Sprintf("chmod %s %04o", path, mode.Perm()))
+	return nil
+}
+
+// EvalSymlinks returns path with a symlink at its end resolved
+func (m *MemFS) EvalSymlinks(path string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, _, err := m.follow("evalsymlinks", path)
+	return resolved, err
+}
+
+// Walk walks root like walk.Dir, visiting the entries of each directory in
+// lexical order. The tree is not locked between paths, so fn may change it.
+func (m *MemFS) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	info, err := m.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walk(ctx, filepath.Clean(root), fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walk visits path and, if it is a directory, the paths below it
+func (m *MemFS) walk(ctx context.Context, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	children, err := m.readDir(path)
+	if err != nil {
+		// Report the directory a second time with the error, as walk.Dir does
+		if err := fn(path, d, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			return err
+		}
+		return nil
+	}
+	for _, child := range children {
+		info, err := m.Lstat(child)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue // Removed since the listing
+		case err != nil:
+			err = fn(child, nil, err)
+		default:
+			err = m.walk(ctx, child, fs.FileInfoToDirEntry(info), fn)
+		}
+		if err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				// Skipping a file skips the rest of its directory
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDir returns the paths in directory dir, sorted
+func (m *MemFS) readDir(dir string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.fails["readdir "+dir]; err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: err}
+	}
+	var children []string
+	for path := range m.files {
+		if path != dir && filepath.Dir(path) == dir {
+			children = append(children, path)
+		}
+	}
+	slices.Sort(children)
+	return children, nil
+}
+
+// lookup returns the file at name without following a symlink there,
+// failing as op would
+func (m *MemFS) lookup(op, name string) (*memFile, error) {
+	path := filepath.Clean(name)
+	if err := m.fails[op+" "+path]; err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	file, ok := m.files[path]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOENT}
+	}
+	return file, nil
+}
+
+// follow returns the path and file that name resolves to through symlinks,
+// failing as op would
+func (m *MemFS) follow(op, name string) (string, *memFile, error) {
+	path := filepath.Clean(name)
+	for range maxSymlinks {
+		file, err := m.lookup(op, path)
+		if err != nil {
+			return "", nil, err
+		}
+		if file.mode&os.ModeSymlink == 0 {
+			return path, file, nil
+		}
+		if filepath.IsAbs(file.target) {
+			path = filepath.Clean(file.target)
+		} else {
+			path = filepath.Join(filepath.Dir(path), file.target)
+		}
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+}
+
+// memInfo is the metadata of a MemFS file, whose Sys is its Owner
+type memInfo struct {
+	name string
+	file memFile
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.file.target)) }
+func (i memInfo) Mode() os.FileMode  { return i.file.mode }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i memInfo) Sys() any           { return i.file.owner }
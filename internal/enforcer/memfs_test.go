@@ -0,0 +1,136 @@
+package enforcer
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMemFSEnforcer creates an enforcer with quiet logging working on a MemFS
+// holding a folder of root's files
+func newMemFSEnforcer() (*Enforcer, *MemFS) {
+	mem := NewMemFS()
+	mem.AddDir("/data/tv", 0700, Owner{})
+	mem.AddFile("/data/tv/a.mkv", 0600, Owner{})
+	mem.AddFile("/data/tv/b.mkv", 0644, Owner{UID: 1000, GID: 1000})
+
+	enf := newTestEnforcer()
+	enf.SetFSOps(mem)
+	return enf, mem
+}
+
+// memWatchDir wants the folder of newMemFSEnforcer owned by 1000:1000
+var memWatchDir = config.WatchDir{Name: "tv", Path: "/data/tv", FileMode: "0644", DirMode: "0755", Owner: "1000", Group: "1000"}
+
+func TestMemFSTree(t *testing.T) {
+	enf, mem := newMemFSEnforcer()
+
+	result := enf.Tree(context.Background(), "/data/tv", memWatchDir)
+	assert.Equal(t, 2, result.Fixed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Zero(t, result.Failed)
+	assert.ElementsMatch(t, []string{
+		"chown /data/tv 1000:1000",
+		"chmod /data/tv 0755",
+		"chown /data/tv/a.mkv 1000:1000",
+		"chmod /data/tv/a.mkv 0644",
+	}, mem.Changes())
+
+	info, err := mem.Lstat("/data/tv/a.mkv")
+	require.NoError(t, err)
+	assert.Equal(t, Owner{UID: 1000, GID: 1000}, fileOwner(info))
+
+	result = enf.Tree(context.Background(), "/data/tv", memWatchDir)
+	assert.Equal(t, 3, result.Skipped)
+	assert.Len(t, mem.Changes(), 4)
+}
+
+func TestMemFSFailures(t *testing.T) {
+	enf, mem := newMemFSEnforcer()
+	mem.Fail("lchown", "/data/tv/a.mkv", syscall.EPERM)
+	mem.Fail("readdir", "/data/tv", syscall.EIO)
+
+	// The owner is changed before the mode, so a refused chown leaves both
+	result := enf.FixIn(memWatchDir, "/data/tv/a.mkv", false)
+	assert.Equal(t, Failed, result.Outcome)
+	assert.ErrorIs(t, result.Err, ErrPermissionDenied)
+	assert.ErrorContains(t, result.Err, "/data/tv/a.mkv")
+	assert.Empty(t, mem.Changes())
+
+	// Directories that cannot be listed fail, but are fixed themselves
+	run := enf.Tree(context.Background(), "/data/tv", memWatchDir)
+	assert.Equal(t, 1, run.Fixed)
+	assert.Equal(t, 1, run.Failed)
+}
+
+func TestMemFSSymlinks(t *testing.T) {
+	enf, mem := newMemFSEnforcer()
+	mem.AddFile("/etc/passwd", 0600, Owner{})
+	mem.AddSymlink("/data/tv/passwd", "/etc/passwd")
+	mem.AddSymlink("/data/tv/c.mkv", "a.mkv")
+
+	// Symlinks get the owner, and only they do: their targets and modes are
+	// left alone, also for targets outside the tree
+	result := enf.Tree(context.Background(), "/data/tv", memWatchDir)
+	assert.Equal(t, 4, result.Fixed)
+	assert.Zero(t, result.Failed)
+	assert.ElementsMatch(t, []string{
+		"chown /data/tv 1000:1000",
+		"chmod /data/tv 0755",
+		"chown /data/tv/a.mkv 1000:1000",
+		"chmod /data/tv/a.mkv 0644",
+		"chown /data/tv/c.mkv 1000:1000",
+		"chown /data/tv/passwd 1000:1000",
+	}, mem.Changes())
+
+	info, err := mem.Lstat("/etc/passwd")
+	require.NoError(t, err)
+	assert.Equal(t, Owner{}, fileOwner(info))
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Symlinks fixed on their own are handled alike
+	mem.AddSymlink("/data/tv/d.mkv", "/etc/passwd")
+	assert.Equal(t, Fixed, enf.FixIn(memWatchDir, "/data/tv/d.mkv", false).Outcome)
+	assert.Equal(t, "chown /data/tv/d.mkv 1000:1000", mem.Changes()[6])
+	assert.Equal(t, Skipped, enf.FixIn(memWatchDir, "/data/tv/d.mkv", false).Outcome)
+}
+
+func TestMemFSDryRun(t *testing.T) {
+	enf, mem := newMemFSEnforcer()
+	enf.SetDryRun(true)
+
+	result := enf.Tree(context.Background(), "/data/tv", memWatchDir)
+	assert.Equal(t, 2, result.Fixed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Empty(t, mem.Changes())
+
+	// Plans agree with the dry run
+	changes, _ := plan(enf, "/data/tv", memWatchDir)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "/data/tv/a.mkv", changes[1].Path)
+	assert.Equal(t, Owner{}, changes[1].OldOwner)
+	assert.Equal(t, Owner{UID: 1000, GID: 1000}, changes[1].NewOwner)
+}
+
+func TestMemFSBusy(t *testing.T) {
+	enf, mem := newMemFSEnforcer()
+	mem.Fail("lchmod", "/data/tv/a.mkv", syscall.ETXTBSY)
+
+	// Busy paths are skipped rather than failed, and tried again
+	result := enf.FixIn(memWatchDir, "/data/tv/a.mkv", false)
+	assert.Equal(t, Skipped, result.Outcome)
+	assert.ErrorIs(t, result.Err, ErrBusy)
+	assert.False(t, enf.Verified(memWatchDir, "/data/tv/a.mkv"))
+
+	mem.Fail("lchmod", "/data/tv/a.mkv", nil)
+	assert.Equal(t, Fixed, enf.FixIn(memWatchDir, "/data/tv/a.mkv", false).Outcome)
+	assert.Equal(t, []string{
+		"chown /data/tv/a.mkv 1000:1000",
+		"chmod /data/tv/a.mkv 0644",
+	}, mem.Changes())
+}
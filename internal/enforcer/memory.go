@@ -0,0 +1,86 @@
+package enforcer
+
+import (
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	// memoryCheckInterval is the number of paths between checks of the heap
+	// against the memory limit
+	memoryCheckInterval = 1024
+
+	// memoryThrottleRatio is the share of the memory limit above which tree
+	// runs stop taking on new paths
+	memoryThrottleRatio = 0.9
+
+	// memoryThrottleWait is how long a throttled run waits before checking
+	// the heap again
+	memoryThrottleWait = 100 * time.Millisecond
+
+	// memoryThrottleMax is how long a run waits at most, in case the heap is
+	// held by something other than enforcement
+	memoryThrottleMax = 5 * time.Second
+)
+
+// heapMetric is the runtime metric of the memory held by heap objects,
+// including ones not yet collected
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// SetMemoryLimit sets a soft limit on the memory of the heap in bytes, 0 for
+// none. Tree runs approaching it stop walking until the garbage collector
+// brings the heap back below, so memory stays bounded however many paths
+// are in flight.
+func (e *Enforcer) SetMemoryLimit(limit uint64) {
+	e.memoryLimit.Store(limit)
+}
+
+// memoryThrottle returns a function walks call for each path, which blocks
+// while the heap is near the memory limit, for up to memoryThrottleMax or
+// until ctx is done
+func (e *Enforcer) memoryThrottle(ctx context.Context) func() {
+	limit := e.memoryLimit.Load()
+	if limit == 0 {
+		return func() {}
+	}
+
+	threshold := uint64(float64(limit) * memoryThrottleRatio)
+	sample := []metrics.Sample{{Name: heapMetric}}
+	paths := 0
+	return func() {
+		paths++
+		if paths%memoryCheckInterval != 0 {
+			return
+		}
+
+		var started time.Time
+		for {
+			metrics.Read(sample)
+			heap := sample[0].Value.Uint64()
+			switch {
+			case heap < threshold:
+				if !started.IsZero() {
+					e.logger.Debug("Memory below the limit again, resuming", "heap_bytes", heap, "waited", time.Since(started))
+				}
+				return
+			case started.IsZero():
+				// Collect first, the heap may be mostly garbage
+				e.logger.Debug("Memory near the limit, throttling enforcement", "heap_bytes", heap, "limit_bytes", limit)
+				started = time.Now()
+				runtime.GC()
+				continue
+			case time.Since(started) >= memoryThrottleMax:
+				e.logger.Warn("Memory still above the limit, continuing anyway", "heap_bytes", heap, "limit_bytes", limit)
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(memoryThrottleWait):
+			}
+		}
+	}
+}
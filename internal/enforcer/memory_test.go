@@ -0,0 +1,35 @@
+package enforcer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// throttlePaths calls throttle for enough paths to check the heap once,
+// returning how long it took
+func throttlePaths(throttle func()) time.Duration {
+	started := time.Now()
+	for range memoryCheckInterval {
+		throttle()
+	}
+	return time.Since(started)
+}
+
+func TestMemoryThrottle(t *testing.T) {
+	enf := newTestEnforcer()
+	assert.Less(t, throttlePaths(enf.memoryThrottle(context.Background())), memoryThrottleWait, "no limit")
+
+	enf.SetMemoryLimit(1 << 40)
+	assert.Less(t, throttlePaths(enf.memoryThrottle(context.Background())), memoryThrottleWait, "far below the limit")
+
+	// Above the limit, runs wait until cancelled
+	enf.SetMemoryLimit(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*memoryThrottleWait)
+	defer cancel()
+	waited := throttlePaths(enf.memoryThrottle(ctx))
+	assert.GreaterOrEqual(t, waited, memoryThrottleWait)
+	assert.Less(t, waited, memoryThrottleMax)
+}
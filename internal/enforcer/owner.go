@@ -0,0 +1,169 @@
+package enforcer
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Owner is the user and group owning a path. An ID of -1 leaves that part
+// unchanged.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// anyOwner accepts every owner
+var anyOwner = Owner{UID: -1, GID: -1}
+
+// String formats the owner as uid:gid
+func (o Owner) String() string {
+	return strconv.Itoa(o.UID) + ":" + strconv.Itoa(o.GID)
+}
+
+// fileOwner returns the owner of a stat result, or anyOwner on platforms
+// that do not report one. Results of MemFS carry their Owner.
+func fileOwner(info os.FileInfo) Owner {
+	if owner, ok := info.Sys().(Owner); ok {
+		return owner
+	}
+	return statOwner(info)
+}
+
+// target is the mode and owner a path should have
+type target struct {
+	mode    os.FileMode
+	hasMode bool // Without a mode, only the owner is enforced
+	owner   Owner
+}
+
+// apply returns the mode and owner a path with the current ones should have
+func (t target) apply(mode os.FileMode, owner Owner) (os.FileMode, Owner) {
+	if t.hasMode {
+		mode = t.mode
+	}
+	if t.owner.UID >= 0 {
+		owner.UID = t.owner.UID
+	}
+	if t.owner.GID >= 0 {
+		owner.GID = t.owner.GID
+	}
+	return mode, owner
+}
+
+// applyTo returns the mode and owner found in info and those its path
+// should have. Symlinks have no mode of their own, so only their owner is
+// changed.
+func (t target) applyTo(info os.FileInfo) (oldMode, newMode os.FileMode, oldOwner, newOwner Owner) {
+	oldMode, oldOwner = info.Mode().Perm(), fileOwner(info)
+	if info.Mode()&os.ModeSymlink != 0 {
+		t.hasMode = false
+	}
+	newMode, newOwner = t.apply(oldMode, oldOwner)
+	return oldMode, newMode, oldOwner, newOwner
+}
+
+// modeTarget returns the target of an octal mode string such as "0644"
+func modeTarget(modeStr string) (target, error) {
+	if modeStr == "" {
+		return target{}, errors.New("empty mode")
+	}
+	mode, err := parseMode(modeStr)
+	if err != nil {
+		return target{}, err
+	}
+	return target{mode: mode, hasMode: true, owner: anyOwner}, nil
+}
+
+// targetIn returns the target of a file or directory in a watch directory.
+// An empty mode leaves the mode unchanged.
+func (e *Enforcer) targetIn(watchDir config.WatchDir, isDir bool) (target, error) {
+	owner, err := e.ownerOf(watchDir)
+	if err != nil {
+		return target{}, err
+	}
+	mode, hasMode, err := watchDir.Mode(isDir)
+	if err != nil {
+		return target{}, err
+	}
+	return target{mode: mode, hasMode: hasMode, owner: owner}, nil
+}
+
+// walkTargets are the targets of the files and directories of a watch
+// directory, resolved once for a whole walk
+type walkTargets struct {
+	file, dir       target
+	fileErr, dirErr error
+}
+
+// walkTargetsIn resolves the targets of a watch directory for a walk
+func (e *Enforcer) walkTargetsIn(watchDir config.WatchDir) walkTargets {
+	var wants walkTargets
+	wants.file, wants.fileErr = e.targetIn(watchDir, false)
+	wants.dir, wants.dirErr = e.targetIn(watchDir, true)
+	return wants
+}
+
+// of returns the target of a file or directory
+func (w walkTargets) of(isDir bool) (target, error) {
+	if isDir {
+		return w.dir, w.dirErr
+	}
+	return w.file, w.fileErr
+}
+
+// ownerOf resolves the owner and group of a watch directory. Names are
+// looked up once until ResetIDs, since enforcement resolves them for every
+// path.
+func (e *Enforcer) ownerOf(watchDir config.WatchDir) (Owner, error) {
+	owner := anyOwner
+	if !ownership {
+		if (watchDir.Owner != "" || watchDir.Group != "") && e.ownersWarned.CompareAndSwap(false, true) {
+			e.logger.Warn("Owners and groups are not enforced on this system, only modes", "folder", watchDir.Name)
+		}
+		return owner, nil
+	}
+
+	var err error
+	if watchDir.Owner != "" {
+		if owner.UID, err = e.lookupID("user:"+watchDir.Owner, watchDir.Owner, config.ParseOwner); err != nil {
+			return anyOwner, err
+		}
+	}
+	if watchDir.Group != "" {
+		if owner.GID, err = e.lookupID("group:"+watchDir.Group, watchDir.Group, config.ParseGroup); err != nil {
+			return anyOwner, err
+		}
+	}
+	return owner, nil
+}
+
+// lookupID returns the cached ID under key, or parses name and caches it
+func (e *Enforcer) lookupID(key, name string, parse func(string) (int, error)) (int, error) {
+	e.idsMu.Lock()
+	defer e.idsMu.Unlock()
+
+	if id, ok := e.ids[key]; ok {
+		return id, nil
+	}
+	id, err := parse(name)
+	if err != nil {
+		return 0, err
+	}
+	if e.ids == nil {
+		e.ids = make(map[string]int)
+	}
+	e.ids[key] = id
+	return id, nil
+}
+
+// ResetIDs forgets the resolved user and group names, so that accounts
+// changed since they were looked up are resolved again
+func (e *Enforcer) ResetIDs() {
+	e.idsMu.Lock()
+	defer e.idsMu.Unlock()
+
+	e.ids = nil
+}
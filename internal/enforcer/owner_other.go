@@ -0,0 +1,21 @@
+//go:build !windows
+
+package enforcer
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownership reports whether the system has Unix owners for enforcement to
+// change
+const ownership = true
+
+// statOwner returns the owner in a stat result of the system
+func statOwner(info os.FileInfo) Owner {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return anyOwner
+	}
+	return Owner{UID: int(st.Uid), GID: int(st.Gid)}
+}
@@ -0,0 +1,144 @@
+package enforcer
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetApply(t *testing.T) {
+	current := Owner{UID: 1000, GID: 1000}
+
+	mode, owner := target{mode: 0644, hasMode: true, owner: anyOwner}.apply(0600, current)
+	assert.Equal(t, os.FileMode(0644), mode)
+	assert.Equal(t, current, owner)
+
+	mode, owner = target{owner: Owner{UID: -1, GID: 100}}.apply(0600, current)
+	assert.Equal(t, os.FileMode(0600), mode, "mode unchanged without one")
+	assert.Equal(t, Owner{UID: 1000, GID: 100}, owner)
+}
+
+func TestTargetIn(t *testing.T) {
+	enf := newTestEnforcer()
+
+	want, err := enf.targetIn(config.WatchDir{DirMode: "0755"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, target{owner: anyOwner}, want, "nothing to enforce")
+
+	want, err = enf.targetIn(config.WatchDir{Owner: "1000", Group: "0"}, false)
+	require.NoError(t, err)
+	assert.False(t, want.hasMode)
+	assert.Equal(t, Owner{UID: 1000, GID: 0}, want.owner)
+
+	_, err = enf.targetIn(config.WatchDir{Owner: "no-such-user-ownarr", FileMode: "0644"}, false)
+	assert.Error(t, err)
+}
+
+func TestResetIDs(t *testing.T) {
+	enf := newTestEnforcer()
+
+	lookups := 0
+	parse := func(name string) (int, error) {
+		lookups++
+		return strconv.Atoi(name)
+	}
+
+	// Names are looked up once until the cache is reset
+	for range 2 {
+		id, err := enf.lookupID("user:1000", "1000", parse)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, id)
+	}
+	assert.Equal(t, 1, lookups)
+
+	enf.ResetIDs()
+	_, err := enf.lookupID("user:1000", "1000", parse)
+	require.NoError(t, err)
+	assert.Equal(t, 2, lookups)
+}
+
+func TestPlanOwner(t *testing.T) {
+	enf := newTestEnforcer()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	current := fileOwner(info)
+
+	watchDir := config.WatchDir{
+		Path:     dir,
+		Include:  []string{"*.txt"},
+		FileMode: "0644",
+		Owner:    strconv.Itoa(current.UID + 1),
+	}
+
+	changes, result := plan(enf, dir, watchDir)
+	require.Len(t, changes, 1)
+	assert.Equal(t, file, changes[0].Path)
+	assert.Equal(t, current, changes[0].OldOwner)
+	assert.Equal(t, Owner{UID: current.UID + 1, GID: current.GID}, changes[0].NewOwner)
+	assert.Equal(t, changes[0].OldMode, changes[0].NewMode)
+	assert.Equal(t, 1, result.Fixed)
+
+	watchDir.Owner = strconv.Itoa(current.UID)
+	changes, _ = plan(enf, dir, watchDir)
+	assert.Empty(t, changes)
+}
+
+func TestFixInOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+
+	enf := newTestEnforcer()
+	file := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	watchDir := config.WatchDir{Owner: "1234", Group: "5678"}
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, file, false).Outcome)
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, Owner{UID: 1234, GID: 5678}, fileOwner(info))
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "mode unchanged")
+}
+
+func TestFixInOwnerSymlink(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+
+	enf := newTestEnforcer()
+	root, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret.mkv")
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0600))
+	link := filepath.Join(root, "link.mkv")
+	require.NoError(t, os.Symlink(secret, link))
+
+	// The symlink gets the owner, the file outside it points to does not
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", Owner: "1234", Group: "5678"}
+	for _, sandbox := range []bool{false, true} {
+		if sandbox && enf.SetSandbox(true) != nil {
+			continue
+		}
+		require.NoError(t, os.Lchown(link, 0, 0))
+		assert.Equal(t, Fixed, enf.FixIn(watchDir, link, false).Outcome)
+		assert.Equal(t, Skipped, enf.FixIn(watchDir, link, false).Outcome)
+
+		info, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.Equal(t, Owner{UID: 1234, GID: 5678}, fileOwner(info))
+		info, err = os.Stat(secret)
+		require.NoError(t, err)
+		assert.Equal(t, Owner{}, fileOwner(info))
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package enforcer
+
+import "os"
+
+// ownership reports whether the system has Unix owners for enforcement to
+// change. Windows has access control lists instead, so owners and groups
+// are left alone.
+const ownership = false
+
+// statOwner returns anyOwner, as stat results on Windows hold no owner
+func statOwner(os.FileInfo) Owner {
+	return anyOwner
+}
@@ -0,0 +1,71 @@
+package enforcer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// preCheckTimeout bounds how long a pre_check command may hold up
+// enforcement
+const preCheckTimeout = 10 * time.Second
+
+// maxPreCheckOutput is how much of a pre_check command's output is logged
+const maxPreCheckOutput = 512
+
+// preCheck runs the watch directory's pre_check command for a change and
+// reports whether it allows the change. A command that exits non-zero vetoes
+// it; one that cannot run returns an error.
+func (e *Enforcer) preCheck(watchDir config.WatchDir, path, kind string, oldMode, newMode os.FileMode) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), preCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", watchDir.PreCheck)
+	cmd.Env = append(os.Environ(),
+		"OWNARR_PATH="+path,
+		"OWNARR_KIND="+kind,
+		"OWNARR_OLD_MODE="+oldMode.String(),
+		"OWNARR_NEW_MODE="+newMode.String(),
+		"OWNARR_FOLDER="+watchDir.Name,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Children that keep the output open must not hold up enforcement
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	reason := strings.TrimSpace(output.String())
+	if len(reason) > maxPreCheckOutput {
+		reason = "..." + reason[len(reason)-maxPreCheckOutput:]
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return false, fmt.Errorf("pre_check timed out after %s", preCheckTimeout)
+	case errors.As(err, &exitErr):
+		e.logger.Info("Change vetoed by pre_check",
+			"folder", watchDir.Name,
+			"path", escape.Text(path),
+			"old_mode", oldMode,
+			"new_mode", newMode,
+			"exit_code", exitErr.ExitCode(),
+			"output", reason,
+		)
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to run pre_check: %w", err)
+	}
+
+	e.logger.Debug("Change allowed by pre_check", "folder", watchDir.Name, "path", escape.Text(path))
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package enforcer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixInPreCheck(t *testing.T) {
+	enf := newTestEnforcer()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	watchDir := config.WatchDir{Name: "media", Path: dir, FileMode: "0644", DirMode: "0755"}
+
+	mode := func() os.FileMode {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	watchDir.PreCheck = "exit 1"
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome, "vetoed")
+	assert.Equal(t, os.FileMode(0600), mode())
+
+	watchDir.PreCheck = "exec nonexistent-ownarr-command"
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome, "a missing command exits non-zero")
+
+	watchDir.PreCheck = `test "$OWNARR_PATH" = "` + file + `" && test "$OWNARR_KIND" = file && ` +
+		`test "$OWNARR_OLD_MODE" = -rw------- && test "$OWNARR_NEW_MODE" = -rw-r--r-- && test "$OWNARR_FOLDER" = media`
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, file, false).Outcome, "allowed")
+	assert.Equal(t, os.FileMode(0644), mode())
+
+	watchDir.PreCheck = "touch " + filepath.Join(dir, "ran")
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome, "already correct")
+	assert.NoFileExists(t, filepath.Join(dir, "ran"), "not run without a change")
+}
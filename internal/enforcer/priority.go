@@ -0,0 +1,40 @@
+package enforcer
+
+import "runtime"
+
+// scanPriority is the CPU and I/O priority of the threads walking scans
+type scanPriority struct {
+	nice    int    // Nice value, 0 to leave it
+	ioClass string // config.IOClassBestEffort or config.IOClassIdle, empty to leave it
+}
+
+// SetScanPriority lowers the priority of the threads walking scans to a nice
+// value and an I/O class, so full scans yield the CPU and disk to media
+// servers. 0 and an empty class leave the priorities alone. Only Linux sets
+// priorities per thread; elsewhere scans run at the process's priority.
+func (e *Enforcer) SetScanPriority(nice int, ioClass string) {
+	e.priority.Store(&scanPriority{nice: nice, ioClass: ioClass})
+}
+
+// lowPriority calls fn on a thread of its own running at the scan priority,
+// or right away if none is set. The thread ends with fn, as its priority
+// cannot be raised again without privileges.
+func (e *Enforcer) lowPriority(fn func()) {
+	priority := e.priority.Load()
+	if priority == nil || (priority.nice == 0 && priority.ioClass == "") {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Never unlocked, so the thread exits with the goroutine
+		runtime.LockOSThread()
+		if err := lowerThreadPriority(*priority); err != nil && e.priorityWarned.CompareAndSwap(false, true) {
+			e.logger.Warn("Failed to lower the priority of scans, running them at normal priority", "error", err)
+		}
+		fn()
+	}()
+	<-done
+}
@@ -0,0 +1,53 @@
+//go:build linux
+
+package enforcer
+
+import (
+	"fmt"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// Arguments of ioprio_set, from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1 // A single thread, by its id
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioLowestBE   = 7 // Lowest priority level within the best-effort class
+)
+
+// lowerThreadPriority lowers the CPU and I/O priority of the calling thread,
+// which must be locked to its goroutine. A nice value below the thread's
+// current one is left alone, as raising the priority needs privileges.
+func lowerThreadPriority(priority scanPriority) error {
+	tid := unix.Gettid()
+
+	if priority.nice > 0 {
+		// The system call returns 20 minus the nice value
+		current, err := unix.Getpriority(unix.PRIO_PROCESS, tid)
+		if err != nil {
+			return fmt.Errorf("failed to read nice value: %w", err)
+		}
+		if priority.nice > 20-current {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, priority.nice); err != nil {
+				return fmt.Errorf("failed to set nice value: %w", err)
+			}
+		}
+	}
+
+	var ioprio int
+	switch priority.ioClass {
+	case config.IOClassBestEffort:
+		ioprio = ioprioClassBE<<ioprioClassShift | ioprioLowestBE
+	case config.IOClassIdle:
+		ioprio = ioprioClassIdle << ioprioClassShift
+	default:
+		return nil
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+		return fmt.Errorf("failed to set I/O class %s: %w", priority.ioClass, errno)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package enforcer
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// threadPriority returns the nice value and I/O priority of the calling
+// thread
+func threadPriority(t *testing.T) (int, int) {
+	tid := unix.Gettid()
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, tid)
+	require.NoError(t, err)
+	ioprio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+	require.Zero(t, errno)
+	return 20 - prio, int(ioprio)
+}
+
+func TestLowPriority(t *testing.T) {
+	enf := newTestEnforcer()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	nice, ioprio := threadPriority(t)
+
+	enf.SetScanPriority(max(nice, 10), config.IOClassIdle)
+	enf.lowPriority(func() {
+		scanNice, scanIOPrio := threadPriority(t)
+		assert.Equal(t, max(nice, 10), scanNice)
+		assert.Equal(t, ioprioClassIdle<<ioprioClassShift, scanIOPrio)
+	})
+
+	enf.SetScanPriority(0, config.IOClassBestEffort)
+	enf.lowPriority(func() {
+		scanNice, scanIOPrio := threadPriority(t)
+		assert.Equal(t, nice, scanNice, "left alone")
+		assert.Equal(t, ioprioClassBE<<ioprioClassShift|ioprioLowestBE, scanIOPrio)
+	})
+
+	// The caller's thread keeps its priority
+	afterNice, afterIOPrio := threadPriority(t)
+	assert.Equal(t, nice, afterNice)
+	assert.Equal(t, ioprio, afterIOPrio)
+	assert.False(t, enf.priorityWarned.Load())
+}
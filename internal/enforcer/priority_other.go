@@ -0,0 +1,10 @@
+//go:build !linux
+
+package enforcer
+
+import "errors"
+
+// lowerThreadPriority fails, as only Linux sets priorities per thread
+func lowerThreadPriority(scanPriority) error {
+	return errors.New("scan priorities are only supported on Linux")
+}
@@ -0,0 +1,71 @@
+package enforcer
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// countDelay is how long a scan runs before the paths to expect are counted,
+// so short scans never pay for a second walk
+const countDelay = time.Second
+
+// AddScanObserver registers an observer that is told about the progress of
+// Tree and PlanEach runs
+func (e *Enforcer) AddScanObserver(observer status.ScanObserver) {
+	e.scanObserversMu.Lock()
+	defer e.scanObserversMu.Unlock()
+
+	e.scanObservers = append(e.scanObservers, observer)
+}
+
+// startScan starts following a scan of root, counting the paths it will
+// visit in the background once it has run for countDelay. The returned
+// function stops the count.
+func (e *Enforcer) startScan(ctx context.Context, root string, watchDir config.WatchDir) (*status.ScanProgress, context.CancelFunc) {
+	e.scanObserversMu.Lock()
+	observers := e.scanObservers
+	e.scanObserversMu.Unlock()
+
+	progress := status.NewScanProgress(watchDir.Path, root, e.logger, observers)
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(countDelay):
+		}
+		// Counting a remote tree would walk it twice over the network
+		ops, err := e.opsFor(&watchDir)
+		if err != nil || ops != e.fs {
+			return
+		}
+		e.lowPriority(func() {
+			if total, ok := countPaths(ctx, ops, root, watchDir); ok {
+				progress.SetTotal(total)
+			}
+		})
+	}()
+	return progress, cancel
+}
+
+// countPaths counts the paths a walk of root visits, without reading their
+// metadata. It reports false if ctx was cancelled first.
+func countPaths(ctx context.Context, ops FSOps, root string, watchDir config.WatchDir) (int, bool) {
+	count := 0
+	_ = ops.Walk(ctx, root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != watchDir.Path && watchDir.ShouldExclude(path) {
+			return filepath.SkipDir
+		}
+		if err == nil && watchDir.ShouldProcess(path) {
+			count++
+		}
+		return nil
+	})
+	return count, ctx.Err() == nil
+}
@@ -0,0 +1,70 @@
+package enforcer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanRecorder records the scans reported to it
+type scanRecorder struct {
+	mu    sync.Mutex
+	scans []status.Scan
+	done  []bool
+}
+
+func (r *scanRecorder) ObserveScan(_ string, scan status.Scan, done bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scans = append(r.scans, scan)
+	r.done = append(r.done, done)
+}
+
+func TestTreeReportsProgress(t *testing.T) {
+	enf := newTestEnforcer()
+	recorder := &scanRecorder{}
+	enf.AddScanObserver(recorder)
+
+	root := t.TempDir()
+	for _, name := range []string{"a.mkv", "b.mkv", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), nil, 0o600))
+	}
+	watchDir := config.WatchDir{Name: "media", Path: root, FileMode: "0644", DirMode: "0755"}
+
+	result := enf.Tree(context.Background(), root, watchDir)
+	require.Equal(t, 4, result.Fixed+result.Skipped)
+
+	// The start and end are always reported
+	require.GreaterOrEqual(t, len(recorder.scans), 2)
+	assert.False(t, recorder.done[0])
+	final := recorder.scans[len(recorder.scans)-1]
+	assert.True(t, recorder.done[len(recorder.done)-1])
+	assert.Equal(t, root, final.Root)
+	assert.Equal(t, 4, final.Processed)
+	assert.Equal(t, result.Fixed, final.Fixed)
+}
+
+func TestCountPaths(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	for _, name := range []string{"a.mkv", "sub/b.mkv", "sub/c.nfo"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), nil, 0o644))
+	}
+
+	// Counts match the paths a walk visits, following the patterns
+	count, ok := countPaths(context.Background(), OS, root, config.WatchDir{Path: root, Exclude: []string{"*.nfo"}})
+	assert.True(t, ok)
+	assert.Equal(t, 4, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = countPaths(ctx, OS, root, config.WatchDir{Path: root})
+	assert.False(t, ok)
+}
@@ -0,0 +1,126 @@
+package enforcer
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// protectedPaths are the system directories enforcement never changes or
+// enters, whether a watch directory or a symlink leads there. The root
+// directory is protected itself, but not the paths below it outside these.
+var protectedPaths = []string{
+	"/bin",
+	"/boot",
+	"/dev",
+	"/etc",
+	"/lib",
+	"/lib32",
+	"/lib64",
+	"/proc",
+	"/run",
+	"/sbin",
+	"/sys",
+	"/usr",
+	"/var/lib/containerd",
+	"/var/lib/docker",
+	"/var/lib/kubelet",
+	"/var/run",
+}
+
+// homePaths hold the home directories of users, whose owners differ by
+// design
+var homePaths = []string{"/home", "/root"}
+
+// SetAllowProtected lets enforcement change and enter the given paths and
+// everything below them, even where they are protected
+func (e *Enforcer) SetAllowProtected(paths []string) {
+	allowed := make([]string, len(paths))
+	for i, path := range paths {
+		allowed[i] = filepath.Clean(path)
+	}
+	e.allowProtected.Store(&allowed)
+}
+
+// protected returns the protected path that path is or lies below, unless
+// it is allowed
+func (e *Enforcer) protected(path string) (string, bool) {
+	if allowed := e.allowProtected.Load(); allowed != nil {
+		for _, root := range *allowed {
+			if config.Within(path, root) {
+				return "", false
+			}
+		}
+	}
+
+	if path == "/" {
+		return path, true
+	}
+	for _, root := range protectedPaths {
+		if config.Within(path, root) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// checkProtected returns an error if path, or the file it resolves to
+// through symlinks in its directories, is protected. A symlink at path
+// itself is changed rather than followed, so its target does not matter.
+func (e *Enforcer) checkProtected(ops FSOps, path string) error {
+	if root, ok := e.protected(path); ok {
+		return fmt.Errorf("%s: %w %s", path, ErrProtected, root)
+	}
+
+	dir, err := ops.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(dir, filepath.Base(path))
+	if root, ok := e.protected(resolved); ok {
+		return fmt.Errorf("%s: resolves to %s, below the %w %s", path, resolved, ErrProtected, root)
+	}
+	return nil
+}
+
+// protectedDirs returns a function reporting whether a directory found by a
+// walk of root is protected. Walks do not follow symlinks below root, so
+// only root itself needs resolving.
+func (e *Enforcer) protectedDirs(ops FSOps, root string) func(string) (string, bool) {
+	resolved, err := ops.EvalSymlinks(root)
+	if err != nil {
+		resolved = root
+	}
+	return func(path string) (string, bool) {
+		if protected, ok := e.protected(path); ok {
+			return protected, true
+		}
+		return e.protected(filepath.Join(resolved, strings.TrimPrefix(path, root)))
+	}
+}
+
+// Dangerous reports whether enforcing a watch directory at path would reach
+// into the system: path is the root directory, a protected path or one
+// below it, a directory holding a protected path, or a directory of home
+// directories. Symlinks in path are resolved where it exists.
+func Dangerous(path string) bool {
+	paths := []string{filepath.Clean(path)}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		paths = append(paths, resolved)
+	}
+
+	for _, path := range paths {
+		if path == "/" || slices.Contains(homePaths, path) {
+			return true
+		}
+		for _, root := range protectedPaths {
+			if config.Within(path, root) || config.Within(root, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package enforcer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtected(t *testing.T) {
+	enf := newTestEnforcer()
+	enf.SetAllowProtected([]string{"/var/lib/docker/volumes/media/"})
+
+	tests := []struct {
+		path      string
+		protected string
+	}{
+		{"/", "/"},
+		{"/etc", "/etc"},
+		{"/etc/ssl/certs", "/etc"},
+		{"/var/lib/docker", "/var/lib/docker"},
+		{"/var/lib/docker/volumes/other/_data", "/var/lib/docker"},
+		{"/var/lib/docker/volumes/media/_data", ""},
+		{"/etcetera", ""},
+		{"/var", ""},
+		{"/data/etc", ""},
+	}
+	for _, tt := range tests {
+		protected, ok := enf.protected(tt.path)
+		assert.Equal(t, tt.protected != "", ok, tt.path)
+		assert.Equal(t, tt.protected, protected, tt.path)
+	}
+}
+
+func TestDangerous(t *testing.T) {
+	for _, path := range []string{"/", "/etc", "/etc/", "/usr/local", "/var", "/var/lib", "/var/lib/docker/volumes", "/home", "/root"} {
+		assert.True(t, Dangerous(path), path)
+	}
+	for _, path := range []string{"/data", "/mnt/media", "/home/alice/downloads", "/var/log", "/srv/tv", t.TempDir()} {
+		assert.False(t, Dangerous(path), path)
+	}
+
+	// Symlinks are resolved
+	link := filepath.Join(t.TempDir(), "media")
+	require.NoError(t, os.Symlink("/", link))
+	assert.True(t, Dangerous(link))
+}
+
+func TestCheckProtected(t *testing.T) {
+	if _, err := os.Stat("/etc/passwd"); err != nil {
+		t.Skip("no /etc/passwd here")
+	}
+
+	enf := newTestEnforcer()
+	enf.SetDryRun(true)
+	root := t.TempDir()
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(root, "passwd")))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(root, "etc")))
+	watchDir := config.WatchDir{Path: root, FileMode: "0600", DirMode: "0700"}
+
+	// Paths through symlinks into protected paths are refused, even in dry
+	// runs
+	result := enf.FixIn(watchDir, filepath.Join(root, "etc", "passwd"), false)
+	assert.Equal(t, Failed, result.Outcome)
+	assert.ErrorIs(t, result.Err, ErrProtected)
+
+	// Symlinks to protected paths are changed themselves, so they are not
+	assert.NoError(t, enf.checkProtected(OS, filepath.Join(root, "passwd")))
+
+	// Walks of a symlink into a protected path do not enter it
+	protected := enf.protectedDirs(OS, filepath.Join(root, "etc"))
+	_, ok := protected(filepath.Join(root, "etc", "ssl"))
+	assert.True(t, ok)
+	_, ok = enf.protectedDirs(OS, root)(filepath.Join(root, "media"))
+	assert.False(t, ok)
+
+	// Allowed paths are changed
+	enf.SetAllowProtected([]string{"/etc/passwd"})
+	assert.NoError(t, enf.checkProtected(OS, filepath.Join(root, "etc", "passwd")))
+}
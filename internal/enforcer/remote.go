@@ -0,0 +1,332 @@
+package enforcer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// remoteTimeout bounds connecting to a remote host and logging in
+const remoteTimeout = 30 * time.Second
+
+// RemoteFS is the file system of a remote host, reached over SSH, for NAS
+// boxes ownarr cannot run on. Metadata is read and changed over SFTP, which
+// needs nothing installed on the host. SFTP cannot change the owner of a
+// symlink itself, so those run chown -h on the host instead.
+//
+// SFTP changes follow symlinks, so each change checks first that the path
+// is none. Unlike the sandbox, this leaves a moment in which a symlink
+// swapped in by someone on the host is followed.
+type RemoteFS struct {
+	client *ssh.Client
+	sftp   *sftp.Client
+	done   chan struct{} // Closed once the connection ends
+}
+
+// DialRemote connects to the SSH server of remote and starts SFTP on it.
+// The server's host key must be listed in the known_hosts file of remote.
+func DialRemote(remote config.Remote) (*RemoteFS, error) {
+	auth, err := remoteAuth(remote)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(remote.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	client, err := ssh.Dial("tcp", remote.Address(), &ssh.ClientConfig{
+		User:            remote.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         remoteTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", remote.Address(), err)
+	}
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to start SFTP on %s: %w", remote.Address(), err)
+	}
+
+	r := &RemoteFS{client: client, sftp: sftpClient, done: make(chan struct{})}
+	go func() {
+		_ = client.Wait()
+		close(r.done)
+	}()
+	return r, nil
+}
+
+// remoteAuth returns how to log in to remote: with its private key,
+// decrypted with the password if one is set, or else with the password
+func remoteAuth(remote config.Remote) ([]ssh.AuthMethod, error) {
+	if remote.IdentityFile == "" {
+		return []ssh.AuthMethod{ssh.Password(remote.Password)}, nil
+	}
+
+	key, err := os.ReadFile(remote.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	var signer ssh.Signer
+	if remote.Password != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(remote.Password))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", remote.IdentityFile, err)
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+}
+
+// Close ends the connection
+func (r *RemoteFS) Close() error {
+	_ = r.sftp.Close()
+	return r.client.Close()
+}
+
+// closed reports whether the connection has ended
+func (r *RemoteFS) closed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Lstat reads the metadata of name, not following symlinks
+func (r *RemoteFS) Lstat(name string) (os.FileInfo, error) {
+	info, err := r.sftp.Lstat(name)
+	if err != nil {
+		return nil, remoteError("lstat", name, err)
+	}
+	return remoteInfo{info}, nil
+}
+
+// Lchown changes the owner of name, not following symlinks
+func (r *RemoteFS) Lchown(name string, uid, gid int) error {
+	info, err := r.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return r.run("lchown", name, "chown -h "+ownerSpec(uid, gid)+" -- "+shellQuote(name))
+	}
+	if err := r.sftp.Chown(name, uid, gid); err != nil {
+		return remoteError("lchown", name, err)
+	}
+	return nil
+}
+
+// Lchmod changes the permission bits of name, failing for symlinks
+func (r *RemoteFS) Lchmod(name string, mode os.FileMode) error {
+	info, err := r.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return &fs.PathError{Op: "lchmod", Path: name, Err: syscall.EOPNOTSUPP}
+	}
+	if err := r.sftp.Chmod(name, mode.Perm()); err != nil {
+		return remoteError("lchmod", name, err)
+	}
+	return nil
+}
+
+// EvalSymlinks returns path with every symlink in it resolved by the host
+func (r *RemoteFS) EvalSymlinks(name string) (string, error) {
+	resolved, err := r.sftp.RealPath(name)
+	if err != nil {
+		return "", remoteError("evalsymlinks", name, err)
+	}
+	return resolved, nil
+}
+
+// Walk walks root like walk.Dir, visiting the entries of each directory in
+// the order the host lists them
+func (r *RemoteFS) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	info, err := r.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = r.walk(ctx, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walk visits name and, if it is a directory, the paths below it
+func (r *RemoteFS) walk(ctx context.Context, name string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, fs.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := r.sftp.ReadDirContext(ctx, name)
+	if err != nil {
+		// Report the directory a second time with the error, as walk.Dir does
+		if err := fn(name, d, remoteError("readdir", name, err)); err != nil && !errors.Is(err, fs.SkipDir) {
+			return err
+		}
+		return nil
+	}
+	for _, entry := range entries {
+		if err := r.walk(ctx, path.Join(name, entry.Name()), fs.FileInfoToDirEntry(remoteInfo{entry}), fn); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				// Skipping a file skips the rest of its directory
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs command on the host, failing as op on name would
+func (r *RemoteFS) run(op, name, command string) error {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	defer func() { _ = session.Close() }()
+
+	output, err := session.CombinedOutput(command)
+	if err == nil {
+		return nil
+	}
+	message := strings.TrimSpace(string(output))
+	if strings.Contains(message, "not permitted") {
+		err = syscall.EPERM
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("%s: %w", message, err)}
+}
+
+// remoteError returns err of op on name with the SFTP status codes mapped
+// to the errors the system would return, so they are classified alike
+func remoteError(op, name string, err error) error {
+	var status *sftp.StatusError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = syscall.ENOENT
+	case errors.Is(err, fs.ErrPermission):
+		err = syscall.EACCES
+	case errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported:
+		err = syscall.EOPNOTSUPP
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// ownerSpec returns the owner argument of chown for IDs, -1 leaving that
+// part unchanged
+func ownerSpec(uid, gid int) string {
+	spec := ""
+	if uid >= 0 {
+		spec = strconv.Itoa(uid)
+	}
+	if gid >= 0 {
+		spec += ":" + strconv.Itoa(gid)
+	}
+	return spec
+}
+
+// shellQuote quotes s as a single word of a POSIX shell command
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteInfo is the metadata of a remote file, whose Sys is its Owner
+type remoteInfo struct {
+	os.FileInfo
+}
+
+func (i remoteInfo) Sys() any {
+	if stat, ok := i.FileInfo.Sys().(*sftp.FileStat); ok {
+		return Owner{UID: int(stat.UID), GID: int(stat.GID)}
+	}
+	return anyOwner
+}
+
+// remotes holds a connection to each remote host in use, dialed on first
+// use and again once it ends. The zero value holds none.
+type remotes struct {
+	mu    sync.Mutex
+	conns map[config.Remote]*RemoteFS
+}
+
+// get returns the connection to remote, dialing it if needed
+func (r *remotes) get(remote config.Remote) (*RemoteFS, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conn, ok := r.conns[remote]; ok && !conn.closed() {
+		return conn, nil
+	}
+	conn, err := DialRemote(remote)
+	if err != nil {
+		return nil, err
+	}
+	if r.conns == nil {
+		r.conns = make(map[config.Remote]*RemoteFS)
+	}
+	r.conns[remote] = conn
+	return conn, nil
+}
+
+// retain closes the connections to the hosts none of watchDirs is on
+func (r *remotes) retain(watchDirs []config.WatchDir) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used := make(map[config.Remote]bool, len(watchDirs))
+	for _, watchDir := range watchDirs {
+		used[watchDir.Remote] = true
+	}
+	for remote, conn := range r.conns {
+		if !used[remote] {
+			_ = conn.Close()
+			delete(r.conns, remote)
+		}
+	}
+}
+
+// opsFor returns the file system of watchDir: OS or its remote host
+func (e *Enforcer) opsFor(watchDir *config.WatchDir) (FSOps, error) {
+	if watchDir == nil || !watchDir.Remote.Enabled() {
+		return e.fs, nil
+	}
+	return e.remotes.get(watchDir.Remote)
+}
+
+// RetainRemotes closes the connections to remote hosts that none of
+// watchDirs is on any longer
+func (e *Enforcer) RetainRemotes(watchDirs []config.WatchDir) {
+	e.remotes.retain(watchDirs)
+}
+
+// CloseRemotes closes every connection to a remote host
+func (e *Enforcer) CloseRemotes() {
+	e.remotes.retain(nil)
+}
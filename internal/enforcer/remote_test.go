@@ -0,0 +1,221 @@
+package enforcer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSSHServer serves SFTP and shell commands on the local file system
+// over SSH, as a NAS would, returning the remote to reach it with
+func startSSHServer(t *testing.T) config.Remote {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	_, clientKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	require.NoError(t, err)
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientSigner.PublicKey().Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, serverConfig)
+		}
+	}()
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	require.NoError(t, err)
+	identity := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(identity, pem.EncodeToMemory(block), 0600))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(listener.Addr().String())}, hostSigner.PublicKey())
+	require.NoError(t, os.WriteFile(knownHosts, []byte(line+"\n"), 0600))
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return config.Remote{Host: host, Port: portNumber, User: "ownarr", IdentityFile: identity, KnownHosts: knownHosts}
+}
+
+// serveSSH serves the sessions of an SSH connection
+func serveSSH(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(channel, requests)
+	}
+}
+
+// serveSession runs the SFTP subsystem or a command in a session
+func serveSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() { _ = channel.Close() }()
+	for request := range requests {
+		var payload struct{ Value string }
+		if ssh.Unmarshal(request.Payload, &payload) != nil {
+			_ = request.Reply(false, nil)
+			continue
+		}
+
+		switch {
+		case request.Type == "subsystem" && payload.Value == "sftp":
+			_ = request.Reply(true, nil)
+			if server, err := sftp.NewServer(channel); err == nil {
+				_ = server.Serve()
+			}
+			return
+		case request.Type == "exec":
+			_ = request.Reply(true, nil)
+			cmd := exec.Command("sh", "-c", payload.Value)
+			cmd.Stdout, cmd.Stderr = channel, channel.Stderr()
+			status := 0
+			if err := cmd.Run(); err != nil {
+				status = 1
+			}
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+			return
+		default:
+			_ = request.Reply(false, nil)
+		}
+	}
+}
+
+func TestRemoteTree(t *testing.T) {
+	remote := startSSHServer(t)
+	enf := newTestEnforcer()
+	defer enf.CloseRemotes()
+
+	root, outside := t.TempDir(), t.TempDir()
+	require.NoError(t, os.Chmod(root, 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "Season 01"), 0700))
+	file := filepath.Join(root, "Season 01", "ep1.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	secret := filepath.Join(outside, "secret.mkv")
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0600))
+	require.NoError(t, os.Symlink(secret, filepath.Join(root, "link.mkv")))
+	watchDir := config.WatchDir{Name: "nas", Path: root, Recursive: true, FileMode: "0644", DirMode: "0755", Remote: remote}
+
+	// Changes are made on the host, and symlinks are not followed there either
+	result := enf.Tree(context.Background(), root, watchDir)
+	assert.Equal(t, 3, result.Fixed)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Zero(t, result.Failed)
+	for path, want := range map[string]os.FileMode{root: 0755, filepath.Join(root, "Season 01"): 0755, file: 0644, secret: 0600} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+
+	// Plans read the host alike
+	require.NoError(t, os.Chmod(file, 0600))
+	changes, _ := plan(enf, root, watchDir)
+	require.Len(t, changes, 1)
+	assert.Equal(t, Change{Path: file, Kind: "file", OldMode: 0600, NewMode: 0644, OldOwner: changes[0].OldOwner, NewOwner: changes[0].OldOwner}, changes[0])
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, file, false).Outcome)
+
+	// Connections that ended are dialed again
+	enf.CloseRemotes()
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome)
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+	link := filepath.Join(root, "it's.mkv")
+	require.NoError(t, os.Symlink(secret, link))
+	watchDir.Owner, watchDir.Group = "1234", "5678"
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, link, false).Outcome)
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, Owner{UID: 1234, GID: 5678}, fileOwner(info))
+	info, err = os.Stat(secret)
+	require.NoError(t, err)
+	assert.Equal(t, Owner{}, fileOwner(info))
+}
+
+func TestRemoteFailures(t *testing.T) {
+	remote := startSSHServer(t)
+	enf := newTestEnforcer()
+	defer enf.CloseRemotes()
+	root := t.TempDir()
+
+	// Hosts whose key is not known are refused
+	other := startSSHServer(t)
+	unknown := remote
+	unknown.KnownHosts = other.KnownHosts
+	result := enf.Tree(context.Background(), root, config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755", Remote: unknown})
+	assert.Equal(t, 1, result.Failed)
+
+	// Unreachable hosts fail the run rather than ending it silently
+	unreachable := remote
+	unreachable.Port = 1
+	fixed := enf.FixIn(config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755", Remote: unreachable}, root, true)
+	assert.Equal(t, Failed, fixed.Outcome)
+	assert.ErrorContains(t, fixed.Err, "failed to connect")
+
+	// Missing paths fail as they would locally
+	ops, err := enf.opsFor(&config.WatchDir{Remote: remote})
+	require.NoError(t, err)
+	_, err = ops.Lstat(filepath.Join(root, "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Connections to hosts no longer configured are closed
+	conn := ops.(*RemoteFS)
+	enf.RetainRemotes([]config.WatchDir{{Remote: remote}})
+	assert.False(t, conn.closed())
+	enf.RetainRemotes(nil)
+	<-conn.done
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/data/it'\''s here'`, shellQuote("/data/it's here"))
+	assert.Equal(t, "'a\nb'", shellQuote("a\nb"))
+	assert.Equal(t, "1000:100", ownerSpec(1000, 100))
+	assert.Equal(t, ":100", ownerSpec(-1, 100))
+}
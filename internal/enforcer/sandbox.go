@@ -0,0 +1,57 @@
+package enforcer
+
+import (
+	"errors"
+	"os"
+)
+
+// SetSandbox confines changes to the watch directories of the paths fixed:
+// each path is opened beneath its watch directory before it is changed, so
+// neither a symlink nor ".." swapped in after it was checked can lead a
+// change outside. It returns an error, leaving changes unconfined, where
+// the system does not support it.
+func (e *Enforcer) SetSandbox(on bool) error {
+	if on {
+		if err := sandboxSupported(); err != nil {
+			e.sandbox.Store(false)
+			return err
+		}
+	}
+	e.sandbox.Store(on)
+	return nil
+}
+
+// changer changes the owner and mode of a path through a name of it
+type changer struct {
+	name  string
+	chown func(name string, uid, gid int) error
+	chmod func(name string, mode os.FileMode) error
+}
+
+// resolve returns how to change path without following a symlink there:
+// with the sandbox on, through a name of path opened beneath root, else
+// through ops. The returned function releases the name. Only paths of OS
+// can be opened beneath their root.
+func (e *Enforcer) resolve(ops FSOps, root, path string) (changer, func(), error) {
+	if !e.sandbox.Load() || root == "" || ops != OS {
+		return changer{name: path, chown: ops.Lchown, chmod: ops.Lchmod}, func() {}, nil
+	}
+
+	name, release, err := openBeneath(root, path)
+	if err != nil {
+		return changer{}, nil, err
+	}
+	// The name leads straight to the file opened, a symlink or not, so
+	// following it changes that file and nothing it points to
+	return changer{name: name, chown: os.Chown, chmod: os.Chmod}, release, nil
+}
+
+// withPath names path in the error of a file operation made through another
+// name of it, such as one from resolve
+func withPath(err error, path string) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && pathErr.Path != path {
+		return &os.PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+	}
+	return err
+}
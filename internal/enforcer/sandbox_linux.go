@@ -0,0 +1,61 @@
+//go:build linux
+
+package enforcer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// procFD is where the open files of the process can be reached by name
+const procFD = "/proc/self/fd/"
+
+// sandboxSupported checks for openat2, added in Linux 5.6, and a mounted
+// /proc to change the files it opens through
+func sandboxSupported() error {
+	fd, err := unix.Openat2(unix.AT_FDCWD, ".", &unix.OpenHow{Flags: unix.O_PATH | unix.O_CLOEXEC, Resolve: unix.RESOLVE_BENEATH})
+	if err != nil {
+		return fmt.Errorf("openat2 is not available: %w", err)
+	}
+	defer func() { _ = unix.Close(fd) }()
+
+	if _, err := os.Stat(procFD + strconv.Itoa(fd)); err != nil {
+		return fmt.Errorf("/proc is not mounted: %w", err)
+	}
+	return nil
+}
+
+// openBeneath opens path with openat2 and RESOLVE_BENEATH relative to root,
+// so that the kernel refuses symlinks and ".." leading outside root, and
+// returns the name of the opened file under /proc/self/fd. A symlink at
+// path is opened itself, not followed. Changing the file through that name
+// changes the file opened, whatever happens to path meanwhile.
+func openBeneath(root, path string) (string, func(), error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return "", nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer func() { _ = unix.Close(dir) }()
+
+	fd, err := unix.Openat2(dir, rel, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_NOFOLLOW | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if errors.Is(err, unix.EXDEV) {
+		return "", nil, fmt.Errorf("%s %w %s", path, ErrOutsideRoot, root)
+	}
+	if err != nil {
+		return "", nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return procFD + strconv.Itoa(fd), func() { _ = unix.Close(fd) }, nil
+}
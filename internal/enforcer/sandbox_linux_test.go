@@ -0,0 +1,55 @@
+//go:build linux
+
+package enforcer
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandbox(t *testing.T) {
+	enf := newTestEnforcer()
+	if err := enf.SetSandbox(true); err != nil {
+		t.Skipf("sandbox not supported here: %v", err)
+	}
+
+	root, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "passwd")
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0600))
+	inside := filepath.Join(root, "ep1.mkv")
+	require.NoError(t, os.WriteFile(inside, []byte("x"), 0600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink("ep1.mkv", filepath.Join(root, "link")))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	// Paths through symlinks leading outside are refused
+	result := enf.FixIn(watchDir, filepath.Join(root, "escape", "passwd"), false)
+	assert.Equal(t, Failed, result.Outcome)
+	assert.ErrorIs(t, result.Err, ErrOutsideRoot)
+	info, err := os.Stat(secret)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Symlinks within are the path changed, not what they point to
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, filepath.Join(root, "link"), false).Outcome)
+	info, err = os.Stat(inside)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, inside, false).Outcome)
+	info, err = os.Stat(inside)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// Errors name the path fixed, not the name it was changed through
+	change, release, err := enf.resolve(OS, root, inside)
+	require.NoError(t, err)
+	defer release()
+	assert.Equal(t, &os.PathError{Op: "chmod", Path: inside, Err: syscall.EPERM},
+		withPath(&os.PathError{Op: "chmod", Path: change.name, Err: syscall.EPERM}, inside))
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package enforcer
+
+import "errors"
+
+// sandboxSupported fails, as only Linux resolves paths beneath a directory
+func sandboxSupported() error {
+	return errors.New("the sandbox is only supported on Linux")
+}
+
+// openBeneath is never called without sandbox support
+func openBeneath(root, path string) (string, func(), error) {
+	return "", nil, errors.New("the sandbox is only supported on Linux")
+}
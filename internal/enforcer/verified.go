@@ -0,0 +1,105 @@
+package enforcer
+
+import (
+	"container/list"
+	"os"
+	"sync"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// verifiedSize is the number of paths remembered as correct
+const verifiedSize = 4096
+
+// verifiedPath is a path last seen with the mode and owner in it
+type verifiedPath struct {
+	path  string
+	isDir bool
+	mode  os.FileMode
+	owner Owner
+}
+
+// verifiedCache remembers the paths most recently seen with the correct mode
+// and owner, dropping the least recently seen beyond its size. Writes change
+// a file's contents and modification time but not its mode or owner, so
+// entries stay valid until a permission change, rename or removal of the
+// path is reported with Forget. Entries hold no modification or change time
+// to compare, as that would read the metadata the cache saves, so a
+// permission change whose event is lost goes unnoticed until the next poll.
+type verifiedCache struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List               // Entries, most recently seen first
+	paths map[string]*list.Element // Entries by path
+}
+
+// newVerifiedCache returns an empty cache of up to size paths
+func newVerifiedCache(size int) *verifiedCache {
+	return &verifiedCache{size: size, order: list.New(), paths: make(map[string]*list.Element)}
+}
+
+// add remembers a path as seen with a mode and owner
+func (c *verifiedCache) add(entry verifiedPath) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.paths[entry.path]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.paths[entry.path] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.paths, oldest.Value.(verifiedPath).path)
+	}
+}
+
+// get returns the entry of a path, marking it as recently seen
+func (c *verifiedCache) get(path string) (verifiedPath, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.paths[path]
+	if !ok {
+		return verifiedPath{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(verifiedPath), true
+}
+
+// remove forgets a path
+func (c *verifiedCache) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.paths[path]; ok {
+		c.order.Remove(elem)
+		delete(c.paths, path)
+	}
+}
+
+// Verified reports whether a path was recently seen with the mode and owner
+// the watch directory wants, so a write to it needs neither a read of its
+// metadata nor a fix. It compares against the rules in force, so entries
+// survive configuration reloads.
+func (e *Enforcer) Verified(watchDir config.WatchDir, path string) bool {
+	entry, ok := e.verified.get(path)
+	if !ok {
+		return false
+	}
+	want, err := e.targetIn(watchDir, entry.isDir)
+	if err != nil {
+		return false
+	}
+	mode, owner := want.apply(entry.mode, entry.owner)
+	return mode == entry.mode && owner == entry.owner
+}
+
+// Forget drops what is remembered about a path whose permissions may have
+// changed, or that was renamed or removed
+func (e *Enforcer) Forget(path string) {
+	e.verified.remove(path)
+}
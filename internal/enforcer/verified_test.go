@@ -0,0 +1,62 @@
+package enforcer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifiedCache(t *testing.T) {
+	cache := newVerifiedCache(2)
+	cache.add(verifiedPath{path: "/a", mode: 0644})
+	cache.add(verifiedPath{path: "/b", mode: 0644})
+
+	// Seeing /a again keeps it over /b
+	_, ok := cache.get("/a")
+	require.True(t, ok)
+	cache.add(verifiedPath{path: "/c", mode: 0644})
+	_, ok = cache.get("/b")
+	assert.False(t, ok, "least recently seen")
+
+	cache.add(verifiedPath{path: "/a", mode: 0600})
+	entry, ok := cache.get("/a")
+	require.True(t, ok)
+	assert.Equal(t, os.FileMode(0600), entry.mode)
+
+	cache.remove("/a")
+	_, ok = cache.get("/a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.order.Len())
+}
+
+func TestVerified(t *testing.T) {
+	enf := newTestEnforcer()
+	root := t.TempDir()
+	file := filepath.Join(root, "ep1.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	assert.False(t, enf.Verified(watchDir, file), "never seen")
+	assert.Equal(t, Fixed, enf.FixIn(watchDir, file, false).Outcome)
+	assert.True(t, enf.Verified(watchDir, file))
+
+	// Rules changed by a reload apply to what was seen
+	stricter := watchDir
+	stricter.FileMode = "0640"
+	assert.False(t, enf.Verified(stricter, file))
+
+	enf.Forget(file)
+	assert.False(t, enf.Verified(watchDir, file))
+	assert.Equal(t, Skipped, enf.FixIn(watchDir, file, false).Outcome)
+	assert.True(t, enf.Verified(watchDir, file), "already correct")
+
+	// Changes only reported, not made, leave the path unverified
+	enf.Forget(file)
+	enf.SetDryRun(true)
+	assert.Equal(t, Fixed, enf.FixIn(stricter, file, false).Outcome)
+	assert.False(t, enf.Verified(stricter, file))
+}
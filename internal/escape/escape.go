@@ -0,0 +1,46 @@
+// Package escape makes file names safe to show in logs, terminals, messages
+// and JSON. Torrents and other downloads regularly carry names with control
+// characters, newlines or bytes that are not UTF-8, which must neither forge
+// lines of output nor be lost in encoding.
+package escape
+
+import (
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Text returns s as is if it is valid UTF-8 of printable characters and
+// spaces, or with Go escapes such as \n, \x1b and \xff otherwise, without
+// surrounding quotes
+func Text(s string) string {
+	if printable(s) {
+		return s
+	}
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+// JSON returns s as is if it is valid UTF-8, which JSON encodes without
+// loss, or escaped like Text otherwise, where encoding would replace the
+// invalid bytes
+func JSON(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return Text(s)
+}
+
+// printable reports whether s is valid UTF-8 without control or other
+// unprintable characters
+func printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package escape
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/data/tv/Show (2024)/S01E01.mkv", "/data/tv/Show (2024)/S01E01.mkv"},
+		{"/data/Ünïcödé 日本語.mkv", "/data/Ünïcödé 日本語.mkv"},
+		{"/data/a\nb.mkv", `/data/a\nb.mkv`},
+		{"/data/a\tb\rc", `/data/a\tb\rc`},
+		{"/data/\x1b[31mred", `/data/\x1b[31mred`},
+		{"/data/latin1-\xe9t\xe9.mkv", `/data/latin1-\xe9t\xe9.mkv`},
+		{"/data/quote\"back\\slash\n", `/data/quote\"back\\slash\n`},
+		{"/data/zero\u200bwidth", `/data/zero\u200bwidth`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Text(tt.in))
+	}
+}
+
+func TestJSON(t *testing.T) {
+	// Valid UTF-8 survives encoding, control characters included
+	name := "/data/a\nb\x1b.mkv"
+	assert.Equal(t, name, JSON(name))
+	data, err := json.Marshal(JSON(name))
+	require.NoError(t, err)
+	var decoded string
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, name, decoded)
+
+	// Invalid bytes are escaped rather than replaced
+	assert.Equal(t, `/data/\xff\nname`, JSON("/data/\xff\nname"))
+}
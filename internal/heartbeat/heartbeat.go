@@ -0,0 +1,127 @@
+// Package heartbeat pings a healthchecks.io compatible URL after each poll
+// cycle, so that a stopped or stuck instance is noticed
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// Heartbeat observes enforcement runs and pings once every watch directory
+// has completed a run since the previous ping. The ping goes to /fail once
+// fail_after consecutive cycles had failed paths.
+type Heartbeat struct {
+	logger *log.Logger
+	client *http.Client
+
+	mu      sync.Mutex
+	cfg     config.Heartbeat
+	folders map[string]bool // Watch directory paths, true once they ran this cycle
+	failed  int             // Failed paths in this cycle
+	failing int             // Consecutive cycles with failed paths
+}
+
+// New creates a heartbeat for the settings in cfg
+func New(cfg *config.Config, logger *log.Logger) *Heartbeat {
+	h := &Heartbeat{
+		logger: logger,
+		client: &http.Client{},
+	}
+	h.SetConfig(cfg)
+	return h
+}
+
+// SetConfig replaces the settings after a reload and starts a new cycle
+func (h *Heartbeat) SetConfig(cfg *config.Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cfg = cfg.Heartbeat
+	h.folders = make(map[string]bool, len(cfg.WatchDirs))
+	for _, watchDir := range cfg.WatchDirs {
+		h.folders[watchDir.Path] = false
+	}
+	h.failed = 0
+}
+
+// ObserveRun records a run and pings in the background once it completes
+// the cycle
+func (h *Heartbeat) ObserveRun(folder string, result status.RunResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.folders[folder]; !ok || h.cfg.URL == "" {
+		return
+	}
+	h.folders[folder] = true
+	h.failed += result.Failed
+	for _, ran := range h.folders {
+		if !ran {
+			return
+		}
+	}
+
+	url, body := strings.TrimSuffix(h.cfg.URL, "/"), "Poll cycle complete"
+	if h.failed > 0 {
+		h.failing++
+		body = fmt.Sprintf("%d paths failed, %d consecutive cycles with errors", h.failed, h.failing)
+	} else {
+		h.failing = 0
+	}
+	if h.failing >= h.cfg.FailAfter {
+		url += "/fail"
+	}
+	timeout := time.Duration(h.cfg.Timeout) * time.Second
+
+	for path := range h.folders {
+		h.folders[path] = false
+	}
+	h.failed = 0
+
+	go h.ping(url, body, timeout)
+}
+
+// ping posts body to url. Failures are only logged, since a missed ping is
+// what the check is there to notice.
+func (h *Heartbeat) ping(url, body string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		h.logger.Error("Failed to create heartbeat request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("User-Agent", "ownarr")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// The URL identifies the check, so leave it out of the logs
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		h.logger.Warn("Failed to send heartbeat", "error", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		h.logger.Warn("Heartbeat rejected", "status", resp.Status)
+		return
+	}
+	h.logger.Debug("Sent heartbeat", "failed", strings.HasSuffix(url, "/fail"))
+}
@@ -0,0 +1,100 @@
+package heartbeat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ping is a request received by the test server
+type ping struct {
+	path string
+	body string
+}
+
+func newTestHeartbeat(t *testing.T) (*Heartbeat, <-chan ping) {
+	t.Helper()
+
+	pings := make(chan ping, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pings <- ping{path: r.URL.Path, body: string(body)}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Heartbeat.URL = server.URL + "/check/"
+	cfg.Heartbeat.FailAfter = 2
+	cfg.WatchDirs = []config.WatchDir{{Path: "/data/tv"}, {Path: "/data/movies"}}
+
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	return New(cfg, logger), pings
+}
+
+func receive(t *testing.T, pings <-chan ping) ping {
+	t.Helper()
+	select {
+	case p := <-pings:
+		return p
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no heartbeat received")
+		return ping{}
+	}
+}
+
+func TestHeartbeatPingsEachCycle(t *testing.T) {
+	h, pings := newTestHeartbeat(t)
+
+	// A cycle is complete once every watch directory has run
+	h.ObserveRun("/data/tv", status.RunResult{Fixed: 1})
+	h.ObserveRun("/data/tv", status.RunResult{})
+	h.ObserveRun("/data/other", status.RunResult{})
+	assert.Empty(t, pings)
+
+	h.ObserveRun("/data/movies", status.RunResult{})
+	p := receive(t, pings)
+	assert.Equal(t, "/check", p.path)
+	assert.Equal(t, "Poll cycle complete", p.body)
+}
+
+func TestHeartbeatFailsOnPersistentErrors(t *testing.T) {
+	h, pings := newTestHeartbeat(t)
+
+	cycle := func(failed int) ping {
+		h.ObserveRun("/data/tv", status.RunResult{Failed: failed})
+		h.ObserveRun("/data/movies", status.RunResult{})
+		return receive(t, pings)
+	}
+
+	// A single cycle with errors is still a success
+	assert.Equal(t, "/check", cycle(1).path)
+
+	p := cycle(3)
+	assert.Equal(t, "/check/fail", p.path)
+	assert.Equal(t, "3 paths failed, 2 consecutive cycles with errors", p.body)
+
+	// A clean cycle recovers
+	assert.Equal(t, "/check", cycle(0).path)
+}
+
+func TestHeartbeatDisabled(t *testing.T) {
+	h, pings := newTestHeartbeat(t)
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Path: "/data/tv"}}
+	h.SetConfig(cfg)
+
+	h.ObserveRun("/data/tv", status.RunResult{})
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, pings)
+}
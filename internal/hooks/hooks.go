@@ -0,0 +1,215 @@
+// Package hooks runs the commands of watch directories after permissions
+// were fixed in them
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/recovery"
+)
+
+// queueSize is the number of commands waiting to run before further ones
+// are dropped
+const queueSize = 1000
+
+// maxOutput is how much of a failed command's output is logged
+const maxOutput = 512
+
+// shell runs the commands, so that they may use pipes and redirects
+var shell = []string{"/bin/sh", "-c"}
+
+// job is a command to run for a fixed path
+type job struct {
+	command string
+	folder  string
+	entry   activity.Entry
+}
+
+// Runner runs the on_fixed commands of watch directories in the background,
+// with a limit on the commands running at once
+type Runner struct {
+	logger *log.Logger
+
+	queue  chan job
+	done   chan struct{}      // Closed once the queue has been drained
+	ctx    context.Context    // Cancelled to kill running commands
+	cancel context.CancelFunc // Cancels ctx
+
+	mu      sync.Mutex
+	cfg     *config.Config
+	slots   chan struct{} // Held by running commands, sized by hooks.concurrency
+	timeout time.Duration
+	closed  bool
+	wg      sync.WaitGroup // Commands running
+}
+
+// New creates a runner for the hooks configured in cfg and starts running
+// commands
+func New(cfg *config.Config, logger *log.Logger) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{
+		logger: logger,
+		queue:  make(chan job, queueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	r.SetConfig(cfg)
+
+	go r.run()
+	return r
+}
+
+// SetConfig replaces the hooks and limits after a reload. Running commands
+// keep their previous limits.
+func (r *Runner) SetConfig(cfg *config.Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cfg = cfg
+	r.slots = make(chan struct{}, cfg.Hooks.Concurrency)
+	r.timeout = time.Duration(cfg.Hooks.Timeout) * time.Second
+}
+
+// Enabled reports whether any watch directory has hooks
+func (r *Runner) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, watchDir := range r.cfg.WatchDirs {
+		if len(watchDir.OnFixed) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Record queues the on_fixed commands of the watch directory holding a fixed
+// path. Commands are dropped if the queue is full or the runner is closed,
+// and never run for dry runs.
+func (r *Runner) Record(entry activity.Entry) {
+	if entry.Type != activity.TypeFixed || entry.DryRun {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	watchDir, err := r.cfg.WatchDirFor(entry.Path)
+	if err != nil {
+		return
+	}
+	for _, command := range watchDir.OnFixed {
+		select {
+		case r.queue <- job{command: command, folder: watchDir.Name, entry: entry}:
+		default:
+			r.logger.Warn("Hook queue full, dropping command", "folder", watchDir.Name, "path", escape.Text(entry.Path))
+		}
+	}
+}
+
+// Close stops accepting commands and waits for queued and running ones.
+// Commands still running when ctx ends are killed.
+func (r *Runner) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-r.done
+		return fmt.Errorf("failed to finish hooks: %w", ctx.Err())
+	}
+}
+
+// run starts queued commands as slots become free until the queue is closed,
+// restarting after a panic
+func (r *Runner) run() {
+	defer close(r.done)
+	defer r.wg.Wait()
+
+	recovery.Loop(r.logger, "hooks", nil, r.start)
+}
+
+// start starts queued commands for run
+func (r *Runner) start() {
+	for j := range r.queue {
+		r.mu.Lock()
+		slots := r.slots
+		timeout := r.timeout
+		r.mu.Unlock()
+
+		select {
+		case slots <- struct{}{}:
+		case <-r.ctx.Done():
+			continue
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-slots }()
+			recovery.Run(r.logger, "hooks", func() { r.exec(j, timeout) })
+		}()
+	}
+}
+
+// exec runs a command with the fixed path in its environment
+func (r *Runner) exec(j job, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell[0], append(shell[1:], j.command)...)
+	cmd.Env = append(os.Environ(),
+		"OWNARR_PATH="+j.entry.Path,
+		"OWNARR_KIND="+j.entry.Kind,
+		"OWNARR_OLD_MODE="+j.entry.OldMode,
+		"OWNARR_NEW_MODE="+j.entry.NewMode,
+		"OWNARR_FOLDER="+j.folder,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Children that keep the output open must not hold up the runner
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		r.logger.Error("Hook failed", "folder", j.folder, "path", escape.Text(j.entry.Path), "command", j.command,
+			"error", err, "output", tail(output.String(), maxOutput))
+		return
+	}
+	r.logger.Debug("Hook finished", "folder", j.folder, "path", escape.Text(j.entry.Path), "command", j.command, "duration", time.Since(start))
+}
+
+// tail returns the last limit bytes of s, trimmed
+func tail(s string, limit int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > limit {
+		s = "..." + s[len(s)-limit:]
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRunner creates a runner for watch directories with quiet logging
+func newTestRunner(watchDirs []config.WatchDir, concurrency, timeout int) *Runner {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = watchDirs
+	cfg.Hooks = config.Hooks{Concurrency: concurrency, Timeout: timeout}
+	return New(cfg, logger)
+}
+
+func TestRunnerRunsHooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	r := newTestRunner([]config.WatchDir{
+		{Name: "media", Path: "/data/media"},
+		{Name: "tv", Path: "/data/media/tv", OnFixed: []string{`echo "$OWNARR_FOLDER $OWNARR_PATH $OWNARR_OLD_MODE $OWNARR_NEW_MODE" >> ` + out}},
+	}, 1, 10)
+	assert.True(t, r.Enabled())
+
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/tv/a b.mkv", OldMode: "-rw-------", NewMode: "-rw-r--r--"})
+	r.Record(activity.Entry{Type: activity.TypeError, Path: "/data/media/tv/c.mkv"})
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/tv/dry.mkv", DryRun: true})
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/movie.mkv"})
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/tvshows/d.mkv"})
+	require.NoError(t, r.Close(context.Background()))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "tv /data/media/tv/a b.mkv -rw------- -rw-r--r--\n", string(data),
+		"only paths fixed for real in the folder with hooks run them")
+
+	// Paths fixed after closing are dropped
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/tv/e.mkv"})
+}
+
+func TestRunnerLimitsConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	// Each command records how many commands were running when it started
+	command := `n=$(ls ` + dir + ` | grep -c running); touch ` + dir + `/running.$$; echo $n > ` + dir + `/seen.$$; sleep 0.2; rm ` + dir + `/running.$$`
+	r := newTestRunner([]config.WatchDir{{Name: "media", Path: "/data/media", OnFixed: []string{command}}}, 2, 10)
+
+	for i := range 6 {
+		r.Record(activity.Entry{Type: activity.TypeFixed, Path: filepath.Join("/data/media", string(rune('a'+i)))})
+	}
+	require.NoError(t, r.Close(context.Background()))
+
+	seen, err := filepath.Glob(filepath.Join(dir, "seen.*"))
+	require.NoError(t, err)
+	require.Len(t, seen, 6)
+	var counts []string
+	for _, path := range seen {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		counts = append(counts, strings.TrimSpace(string(data)))
+	}
+	sort.Strings(counts)
+	assert.LessOrEqual(t, counts[len(counts)-1], "1", "at most one other command runs at the same time")
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	r := newTestRunner([]config.WatchDir{{Name: "media", Path: "/data/media", OnFixed: []string{"sleep 10"}}}, 1, 1)
+
+	start := time.Now()
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/a.mkv"})
+	require.NoError(t, r.Close(context.Background()))
+	assert.Less(t, time.Since(start), 5*time.Second, "commands are killed after the timeout")
+}
+
+func TestRunnerCloseKillsCommands(t *testing.T) {
+	r := newTestRunner([]config.WatchDir{{Name: "media", Path: "/data/media", OnFixed: []string{"sleep 10"}}}, 1, 60)
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/a.mkv"})
+	r.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/media/b.mkv"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, r.Close(ctx))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,112 @@
+package limits
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup file systems are mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// CPUQuota returns the CPU time the process's cgroup may use, in cores, or 0
+// if it is unlimited or unknown. Quotas of parent cgroups count too, so the
+// quota of a container is found from within a nested cgroup.
+func CPUQuota() float64 {
+	return cpuQuota(procRoot, cgroupRoot)
+}
+
+// cpuQuota reads the CPU quota of the process's cgroups listed under
+// procRoot from the cgroup file systems under cgroupRoot, both cgroup v2 and
+// v1
+func cpuQuota(procRoot, cgroupRoot string) float64 {
+	f, err := os.Open(filepath.Join(procRoot, "self/cgroup"))
+	if err != nil {
+		return 0
+	}
+	defer func() { _ = f.Close() }()
+
+	quota := 0.0
+	lower := func(cores float64) {
+		if cores > 0 && (quota == 0 || cores < quota) {
+			quota = cores
+		}
+	}
+
+	// Lines are hierarchy-ID:controllers:path, with an empty list of
+	// controllers for cgroup v2
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch controllers := strings.Split(fields[1], ","); {
+		case fields[0] == "0" && fields[1] == "":
+			eachCgroup(cgroupRoot, fields[2], func(dir string) { lower(readCPUMax(dir)) })
+		case slices.Contains(controllers, "cpu"):
+			mount := filepath.Join(cgroupRoot, fields[1])
+			if _, err := os.Stat(mount); err != nil {
+				mount = filepath.Join(cgroupRoot, "cpu")
+			}
+			eachCgroup(mount, fields[2], func(dir string) { lower(readCFSQuota(dir)) })
+		}
+	}
+	return quota
+}
+
+// eachCgroup calls fn for the directory of a cgroup below mount and those
+// of its parents. Within a cgroup namespace the path is / while the mount
+// shows the container's own cgroup, so the mount itself is always visited.
+func eachCgroup(mount, cgroup string, fn func(dir string)) {
+	for cgroup = path.Clean("/" + cgroup); cgroup != "/"; cgroup = path.Dir(cgroup) {
+		fn(filepath.Join(mount, filepath.FromSlash(cgroup)))
+	}
+	fn(mount)
+}
+
+// readCPUMax reads the quota of a cgroup v2 directory from cpu.max, which
+// holds the quota and period in microseconds, or "max" for none
+func readCPUMax(dir string) float64 {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0
+	}
+	return cores(fields[0], fields[1])
+}
+
+// readCFSQuota reads the quota of a cgroup v1 directory from
+// cpu.cfs_quota_us, -1 for none, and cpu.cfs_period_us
+func readCFSQuota(dir string) float64 {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0
+	}
+	return cores(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cores divides a quota by its period, returning 0 if either is not a
+// positive number
+func cores(quota, period string) float64 {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return float64(q) / float64(p)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package limits
+
+import (
+	"syscall"
+	"time"
+)
+
+// CPUTime returns the CPU time the process has used so far, in user and
+// system mode, or 0 if it cannot be read
+func CPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package limits
+
+import "time"
+
+// CPUTime returns 0, as the CPU time of the process is not read on Windows
+func CPUTime() time.Duration {
+	return 0
+}
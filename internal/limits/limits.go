@@ -0,0 +1,49 @@
+// Package limits reads the operating system limits ownarr runs within: the
+// files it may have open, the directories it may watch for changes and the
+// CPU time its container may use
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is where the proc file system is mounted
+const procRoot = "/proc"
+
+// WatchLimit returns the most directories a user may watch with inotify, or
+// 0 if the system has no such limit or it cannot be read
+func WatchLimit() int {
+	return watchLimit(procRoot)
+}
+
+// watchLimit reads the inotify watch limit under procRoot
+func watchLimit(procRoot string) int {
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys/fs/inotify/max_user_watches"))
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// countOpen counts the entries of a directory listing the process's open
+// files, such as /proc/self/fd, leaving out the one used to read it
+func countOpen(dir string) (int, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	return max(len(names)-1, 0), nil
+}
@@ -0,0 +1,67 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchLimit(t *testing.T) {
+	procRoot := t.TempDir()
+	assert.Zero(t, watchLimit(procRoot), "no inotify")
+
+	dir := filepath.Join(procRoot, "sys/fs/inotify")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_user_watches"), []byte("8192\n"), 0644))
+	assert.Equal(t, 8192, watchLimit(procRoot))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_user_watches"), []byte("lots"), 0644))
+	assert.Zero(t, watchLimit(procRoot))
+}
+
+func TestOpenFiles(t *testing.T) {
+	before, limit := OpenFiles()
+	if before < 0 {
+		t.Skip("open files cannot be counted here")
+	}
+	assert.Positive(t, limit)
+
+	f, err := os.Open(t.TempDir())
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	after, _ := OpenFiles()
+	assert.Equal(t, before+1, after)
+}
+
+func TestCPUQuota(t *testing.T) {
+	write := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	procRoot, cgroupRoot := t.TempDir(), t.TempDir()
+	assert.Zero(t, cpuQuota(procRoot, cgroupRoot), "no cgroups")
+
+	// cgroup v2, limited by the parent of the process's cgroup
+	write(filepath.Join(procRoot, "self/cgroup"), "0::/compose/ownarr\n")
+	write(filepath.Join(cgroupRoot, "cpu.max"), "max 100000\n")
+	write(filepath.Join(cgroupRoot, "compose/ownarr/cpu.max"), "max 100000\n")
+	assert.Zero(t, cpuQuota(procRoot, cgroupRoot), "unlimited")
+	write(filepath.Join(cgroupRoot, "compose/cpu.max"), "150000 100000\n")
+	assert.Equal(t, 1.5, cpuQuota(procRoot, cgroupRoot))
+	write(filepath.Join(cgroupRoot, "compose/ownarr/cpu.max"), "50000 100000\n")
+	assert.Equal(t, 0.5, cpuQuota(procRoot, cgroupRoot), "the lowest quota applies")
+
+	// cgroup v1 within a cgroup namespace
+	procRoot, cgroupRoot = t.TempDir(), t.TempDir()
+	write(filepath.Join(procRoot, "self/cgroup"), "4:memory:/\n2:cpu,cpuacct:/\n0::/\n")
+	write(filepath.Join(cgroupRoot, "cpu,cpuacct/cpu.cfs_quota_us"), "-1\n")
+	write(filepath.Join(cgroupRoot, "cpu,cpuacct/cpu.cfs_period_us"), "100000\n")
+	assert.Zero(t, cpuQuota(procRoot, cgroupRoot), "unlimited")
+	write(filepath.Join(cgroupRoot, "cpu,cpuacct/cpu.cfs_quota_us"), "200000\n")
+	assert.Equal(t, 2.0, cpuQuota(procRoot, cgroupRoot))
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package limits
+
+import (
+	"math"
+	"syscall"
+)
+
+// OpenFiles returns the number of files the process has open, -1 if it
+// cannot be counted, and the most it may have open, 0 if unlimited or
+// unknown
+func OpenFiles() (open, limit int) {
+	open = -1
+	for _, dir := range []string{procRoot + "/self/fd", "/dev/fd"} {
+		if n, err := countOpen(dir); err == nil {
+			open = n
+			break
+		}
+	}
+
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err == nil && uint64(rlimit.Cur) <= math.MaxInt32 {
+		limit = int(rlimit.Cur)
+	}
+	return open, limit
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package limits
+
+// OpenFiles reports the open files as unknown, since Windows limits handles
+// per process only by memory
+func OpenFiles() (open, limit int) {
+	return -1, 0
+}
@@ -0,0 +1,85 @@
+package mediaserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Jellyfin tells a Jellyfin or Emby server about changed folders, which it
+// then rescans within whichever libraries hold them
+type Jellyfin struct {
+	url        string
+	apiKey     string
+	serverType string
+	libraries  []config.JellyfinLibrary
+	client     *http.Client
+}
+
+type jellyfinUpdates struct {
+	Updates []jellyfinUpdate `json:"Updates"`
+}
+
+type jellyfinUpdate struct {
+	Path       string `json:"Path"`
+	UpdateType string `json:"UpdateType"`
+}
+
+// NewJellyfin creates a library for a Jellyfin or Emby server
+func NewJellyfin(cfg config.Jellyfin, client *http.Client) *Jellyfin {
+	return &Jellyfin{
+		url:        strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		serverType: cfg.Type,
+		libraries:  cfg.Libraries,
+		client:     client,
+	}
+}
+
+// Covers reports whether dir is within a mapped library
+func (j *Jellyfin) Covers(dir string) bool {
+	_, ok := j.library(dir)
+	return ok
+}
+
+// Refresh reports the folders as modified in a single request
+func (j *Jellyfin) Refresh(ctx context.Context, dirs []string) error {
+	var updates jellyfinUpdates
+	for _, dir := range dirs {
+		if library, ok := j.library(dir); ok {
+			updates.Updates = append(updates.Updates, jellyfinUpdate{
+				Path:       mapPath(dir, library.Path, library.ServerPath),
+				UpdateType: "Modified",
+			})
+		}
+	}
+	if len(updates.Updates) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(updates)
+	if err != nil {
+		return fmt.Errorf("failed to encode updates: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url+"/Library/Media/Updated", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if j.serverType == config.EmbyServer {
+		req.Header.Set("X-Emby-Token", j.apiKey)
+	} else {
+		req.Header.Set("Authorization", fmt.Sprintf("MediaBrowser Token=%q", j.apiKey))
+	}
+	return send(j.client, req)
+}
+
+// library returns the most specific library directory containing dir
+func (j *Jellyfin) library(dir string) (config.JellyfinLibrary, bool) {
+	return config.Innermost(j.libraries, dir, func(library config.JellyfinLibrary) string { return library.Path })
+}
@@ -0,0 +1,48 @@
+package mediaserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJellyfinRefresh(t *testing.T) {
+	var (
+		header   http.Header
+		received jellyfinUpdates
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/Library/Media/Updated", r.URL.Path)
+		header = r.Header.Clone()
+		received = jellyfinUpdates{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	libraries := []config.JellyfinLibrary{{Path: "/data/media/tv", ServerPath: "/tv"}, {Path: "/data/media/movies", ServerPath: "/data/media/movies"}}
+	jellyfin := NewJellyfin(config.Jellyfin{Type: config.JellyfinServer, URL: srv.URL, APIKey: "key", Libraries: libraries}, srv.Client())
+
+	assert.True(t, jellyfin.Covers("/data/media/tv/Show"))
+	assert.False(t, jellyfin.Covers("/data/media/music"))
+
+	require.NoError(t, jellyfin.Refresh(context.Background(), []string{"/data/media/movies/Film", "/data/media/tv/Show/Season 1"}))
+	assert.Equal(t, `MediaBrowser Token="key"`, header.Get("Authorization"))
+	assert.Equal(t, []jellyfinUpdate{
+		{Path: "/data/media/movies/Film", UpdateType: "Modified"},
+		{Path: "/tv/Show/Season 1", UpdateType: "Modified"},
+	}, received.Updates)
+
+	// Emby takes the key in its own header
+	emby := NewJellyfin(config.Jellyfin{Type: config.EmbyServer, URL: srv.URL, APIKey: "key", Libraries: libraries}, srv.Client())
+	require.NoError(t, emby.Refresh(context.Background(), []string{"/data/media/tv"}))
+	assert.Equal(t, "key", header.Get("X-Emby-Token"))
+	assert.Empty(t, header.Get("Authorization"))
+	assert.Equal(t, []jellyfinUpdate{{Path: "/tv", UpdateType: "Modified"}}, received.Updates)
+}
@@ -0,0 +1,228 @@
+// Package mediaserver asks media servers to rescan folders after their
+// permissions were fixed, so new files show up without waiting for the
+// server's next scheduled scan
+package mediaserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// requestTimeout limits each request to a media server
+const requestTimeout = 30 * time.Second
+
+// maxErrorBody is how much of an error response is included in the error
+const maxErrorBody = 512
+
+// Library is a media server holding some of the watched directories
+type Library interface {
+	// Covers reports whether a local directory is within the libraries
+	Covers(dir string) bool
+	// Refresh rescans local directories, each of them covered
+	Refresh(ctx context.Context, dirs []string) error
+}
+
+// library is a configured media server and the directories waiting for its
+// refresh
+type library struct {
+	name    string
+	library Library
+	delay   time.Duration       // Time without further fixes before refreshing
+	pending map[string]struct{} // Directories to refresh
+	timer   *time.Timer         // Runs the refresh, nil when nothing is pending
+}
+
+// Trigger records fixed paths and refreshes the libraries holding them once
+// no further fixes arrived for the library's delay, so that a batch of fixes
+// causes a single refresh
+type Trigger struct {
+	logger *log.Logger
+	client *http.Client
+
+	mu        sync.Mutex
+	libraries []*library
+	closed    bool
+	wg        sync.WaitGroup // Refreshes in progress
+}
+
+// New creates a trigger for the media servers configured in cfg
+func New(cfg *config.Config, logger *log.Logger) *Trigger {
+	t := &Trigger{
+		logger: logger,
+		client: &http.Client{},
+	}
+	t.SetConfig(cfg)
+	return t
+}
+
+// SetConfig replaces the media servers after a reload. Refreshes pending
+// with the previous settings are started right away.
+func (t *Trigger) SetConfig(cfg *config.Config) {
+	var libraries []*library
+	if cfg.Plex.URL != "" {
+		libraries = append(libraries, newLibrary("plex", NewPlex(cfg.Plex, t.client), cfg.Plex.Delay))
+	}
+	for _, jellyfin := range cfg.Jellyfin {
+		libraries = append(libraries, newLibrary(jellyfin.Name, NewJellyfin(jellyfin, t.client), jellyfin.Delay))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, l := range t.libraries {
+		if l.timer != nil && l.timer.Stop() && !t.closed {
+			t.wg.Add(1)
+			go t.flush(l)
+		}
+	}
+	t.libraries = libraries
+}
+
+// newLibrary wraps a media server with a delay in seconds
+func newLibrary(name string, l Library, delay int) *library {
+	return &library{
+		name:    name,
+		library: l,
+		delay:   time.Duration(delay) * time.Second,
+		pending: make(map[string]struct{}),
+	}
+}
+
+// Enabled reports whether any media server is configured
+func (t *Trigger) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.libraries) > 0
+}
+
+// Record queues the directory of a fixed path for a refresh by the
+// libraries holding it. Directories are refreshed themselves, files through
+// the directory containing them.
+func (t *Trigger) Record(entry activity.Entry) {
+	if entry.Type != activity.TypeFixed || entry.DryRun {
+		return
+	}
+	dir := entry.Path
+	if entry.Kind != "directory" {
+		dir = filepath.Dir(entry.Path)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	for _, l := range t.libraries {
+		if !l.library.Covers(dir) {
+			continue
+		}
+		l.pending[dir] = struct{}{}
+
+		// Every fix postpones the refresh until things have settled
+		if l.timer == nil {
+			l.timer = time.AfterFunc(l.delay, func() {
+				t.mu.Lock()
+				if t.closed {
+					t.mu.Unlock()
+					return
+				}
+				t.wg.Add(1)
+				t.mu.Unlock()
+				t.flush(l)
+			})
+		} else {
+			l.timer.Reset(l.delay)
+		}
+	}
+}
+
+// flush refreshes the pending directories of a library. The caller adds to
+// wg while holding mu and not closed.
+func (t *Trigger) flush(l *library) {
+	defer t.wg.Done()
+
+	t.mu.Lock()
+	dirs := make([]string, 0, len(l.pending))
+	for dir := range l.pending {
+		dirs = append(dirs, dir)
+	}
+	l.pending = make(map[string]struct{})
+	l.timer = nil
+	t.mu.Unlock()
+
+	if len(dirs) == 0 {
+		return
+	}
+	slices.Sort(dirs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := l.library.Refresh(ctx, dirs); err != nil {
+		t.logger.Error("Failed to refresh media library", "server", l.name, "error", err)
+		return
+	}
+	t.logger.Info("Refreshed media library", "server", l.name, "folders", len(dirs))
+}
+
+// Close drops pending refreshes and waits for running ones
+func (t *Trigger) Close() {
+	t.mu.Lock()
+	t.closed = true
+	for _, l := range t.libraries {
+		if l.timer != nil {
+			l.timer.Stop()
+		}
+	}
+	t.mu.Unlock()
+
+	t.wg.Wait()
+}
+
+// mapPath translates a local path below root to the same path below the
+// media server's remote root
+func mapPath(path, root, remote string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(path, root), string(filepath.Separator))
+	if rel == "" {
+		return remote
+	}
+	return strings.TrimSuffix(remote, "/") + "/" + filepath.ToSlash(rel)
+}
+
+// send performs a request, failing on a non-2xx response
+func send(client *http.Client, req *http.Request) error {
+	req.Header.Set("User-Agent", "ownarr")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Leave out the URL, which may carry a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s request failed: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
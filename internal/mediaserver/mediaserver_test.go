@@ -0,0 +1,82 @@
+package mediaserver
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLibrary covers a single root and records its refreshes
+type fakeLibrary struct {
+	root      string
+	mu        sync.Mutex
+	refreshes [][]string
+}
+
+func (f *fakeLibrary) Covers(dir string) bool {
+	return config.Within(dir, f.root)
+}
+
+func (f *fakeLibrary) Refresh(_ context.Context, dirs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshes = append(f.refreshes, dirs)
+	return nil
+}
+
+func (f *fakeLibrary) calls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refreshes
+}
+
+func newTestTrigger(libraries ...*library) *Trigger {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	t := New(config.DefaultConfig(), logger)
+	t.libraries = libraries
+	return t
+}
+
+func TestTriggerBatchesFixes(t *testing.T) {
+	fake := &fakeLibrary{root: "/data/tv"}
+	trigger := newTestTrigger(newLibrary("fake", fake, 0))
+	trigger.libraries[0].delay = 50 * time.Millisecond
+
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/tv/Show/Season 1/a.mkv", Kind: "file"})
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/tv/Show/Season 1/b.mkv", Kind: "file"})
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/tv/Show/Season 2", Kind: "directory"})
+	trigger.Record(activity.Entry{Type: activity.TypeError, Path: "/data/tv/Show/Season 3/c.mkv", Kind: "file"})
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/movies/Film/film.mkv", Kind: "file"})
+
+	require.Eventually(t, func() bool { return len(fake.calls()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	trigger.Close()
+
+	assert.Equal(t, [][]string{{"/data/tv/Show/Season 1", "/data/tv/Show/Season 2"}}, fake.calls())
+}
+
+func TestTriggerCloseDropsPending(t *testing.T) {
+	fake := &fakeLibrary{root: "/data/tv"}
+	trigger := newTestTrigger(newLibrary("fake", fake, 60))
+
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/tv/a.mkv", Kind: "file"})
+	trigger.Close()
+	trigger.Record(activity.Entry{Type: activity.TypeFixed, Path: "/data/tv/b.mkv", Kind: "file"})
+
+	assert.Empty(t, fake.calls())
+}
+
+func TestMapPath(t *testing.T) {
+	assert.Equal(t, "/tv/Show/Season 1", mapPath("/data/media/tv/Show/Season 1", "/data/media/tv", "/tv"))
+	assert.Equal(t, "/tv", mapPath("/data/media/tv", "/data/media/tv", "/tv"))
+	assert.Equal(t, "/tv/Show", mapPath("/data/media/tv/Show", "/data/media/tv", "/tv/"))
+}
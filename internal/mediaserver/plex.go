@@ -0,0 +1,94 @@
+package mediaserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// maxPartialScans is the number of folders of a section that are scanned
+// one by one. Beyond it the whole section is scanned, which is cheaper than
+// many partial scans.
+const maxPartialScans = 10
+
+// Plex refreshes Plex library sections
+type Plex struct {
+	url      string
+	token    string
+	sections []config.PlexSection
+	client   *http.Client
+}
+
+// NewPlex creates a library for a Plex server
+func NewPlex(cfg config.Plex, client *http.Client) *Plex {
+	return &Plex{
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		token:    cfg.Token,
+		sections: cfg.Sections,
+		client:   client,
+	}
+}
+
+// Covers reports whether dir is within a mapped section
+func (p *Plex) Covers(dir string) bool {
+	_, ok := p.section(dir)
+	return ok
+}
+
+// Refresh scans the folders of each section, or the whole section when many
+// of its folders changed
+func (p *Plex) Refresh(ctx context.Context, dirs []string) error {
+	bySection := make(map[int][]string)
+	var ids []int
+	for _, dir := range dirs {
+		section, ok := p.section(dir)
+		if !ok {
+			continue
+		}
+		if _, seen := bySection[section.ID]; !seen {
+			ids = append(ids, section.ID)
+		}
+		bySection[section.ID] = append(bySection[section.ID], mapPath(dir, section.Path, section.PlexPath))
+	}
+
+	var errs []error
+	for _, id := range ids {
+		paths := bySection[id]
+		if len(paths) > maxPartialScans {
+			paths = []string{""}
+		}
+		for _, path := range paths {
+			if err := p.refresh(ctx, id, path); err != nil {
+				errs = append(errs, fmt.Errorf("section %d: %w", id, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refresh scans a section, or only path within it if set
+func (p *Plex) refresh(ctx context.Context, id int, path string) error {
+	endpoint := p.url + "/library/sections/" + strconv.Itoa(id) + "/refresh"
+	if path != "" {
+		endpoint += "?" + url.Values{"path": {path}}.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Plex-Token", p.token)
+	req.Header.Set("Accept", "application/json")
+	return send(p.client, req)
+}
+
+// section returns the most specific section containing dir
+func (p *Plex) section(dir string) (config.PlexSection, bool) {
+	return config.Innermost(p.sections, dir, func(section config.PlexSection) string { return section.Path })
+}
@@ -0,0 +1,66 @@
+package mediaserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlexRefresh(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "plex-token", r.Header.Get("X-Plex-Token"))
+		mu.Lock()
+		requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("path"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	plex := NewPlex(config.Plex{
+		URL:   srv.URL + "/",
+		Token: "plex-token",
+		Sections: []config.PlexSection{
+			{ID: 1, Path: "/data/media", PlexPath: "/media"},
+			{ID: 2, Path: "/data/media/tv", PlexPath: "/tv"},
+		},
+	}, srv.Client())
+
+	assert.True(t, plex.Covers("/data/media/tv/Show"))
+	assert.False(t, plex.Covers("/data/downloads"))
+
+	require.NoError(t, plex.Refresh(context.Background(), []string{"/data/media/tv/Show/Season 1", "/data/media/Film"}))
+	assert.Equal(t, []string{
+		"/library/sections/2/refresh /tv/Show/Season 1",
+		"/library/sections/1/refresh /media/Film",
+	}, requests)
+
+	// Many folders in a section scan the whole section
+	requests = nil
+	dirs := make([]string, maxPartialScans+1)
+	for i := range dirs {
+		dirs[i] = fmt.Sprintf("/data/media/tv/Show %d", i)
+	}
+	require.NoError(t, plex.Refresh(context.Background(), dirs))
+	assert.Equal(t, []string{"/library/sections/2/refresh "}, requests)
+}
+
+func TestPlexRefreshFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	plex := NewPlex(config.Plex{URL: srv.URL, Token: "wrong", Sections: []config.PlexSection{{ID: 1, Path: "/data", PlexPath: "/data"}}}, srv.Client())
+	err := plex.Refresh(context.Background(), []string{"/data/a"})
+	assert.ErrorContains(t, err, "section 1: unexpected response 401 Unauthorized")
+}
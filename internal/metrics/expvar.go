@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"expvar"
+	"strings"
+)
+
+func init() {
+	expvar.Publish("ownarr", expvar.Func(func() any {
+		return Default.Vars()
+	}))
+}
+
+// Vars returns the current value of every series keyed by its name and
+// labels in the Prometheus notation, e.g. `ownarr_fixed_total{kind="file"}`
+func (r *Registry) Vars() map[string]float64 {
+	vars := make(map[string]float64)
+	for _, sample := range r.Gather() {
+		var key strings.Builder
+		key.WriteString(sample.Name)
+		for i, label := range sample.Labels {
+			if i == 0 {
+				key.WriteByte('{')
+			} else {
+				key.WriteByte(',')
+			}
+			key.WriteString(label.Name + `="` + escapeLabel(label.Value) + `"`)
+		}
+		if len(sample.Labels) > 0 {
+			key.WriteByte('}')
+		}
+		vars[key.String()] = sample.Value
+	}
+	return vars
+}
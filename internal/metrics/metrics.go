@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"errors"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// Scan triggers
+const (
+	TriggerPoll    = "poll"    // Periodic check by the watcher
+	TriggerAPI     = "api"     // Enforcement requested through the HTTP API
+	TriggerStartup = "startup" // Scan of every folder when the daemon starts, with scan_on_start
+)
+
+// Default is the registry served at /metrics
+var Default = NewRegistry()
+
+// Bucket bounds in seconds
+var (
+	scanBuckets  = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800}
+	eventBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// Application metrics
+var (
+	ScanDuration = Default.NewHistogram("ownarr_scan_duration_seconds",
+		"Duration of full enforcement scans of a folder.", scanBuckets, "folder", "trigger")
+	ScanPaths = Default.NewCounter("ownarr_scan_paths_total",
+		"Paths checked by full scans, by outcome.", "folder", "outcome")
+	LastSuccessfulScan = Default.NewGauge("ownarr_last_successful_scan_timestamp_seconds",
+		"Unix time of the last full scan of a folder that completed without failures.", "folder")
+	ActiveScans = Default.NewGauge("ownarr_active_scans",
+		"Enforcement walks currently running over a folder.", "folder")
+
+	EventDuration = Default.NewHistogram("ownarr_event_handling_seconds",
+		"Time from a file system event to the end of its handling.", eventBuckets, "folder", "operation")
+
+	Fixed = Default.NewCounter("ownarr_fixed_total",
+		"Permission changes made.", "kind")
+	Failures = Default.NewCounter("ownarr_failures_total",
+		"Failed file operations, by operation and errno class.", "op", "errno")
+	Busy = Default.NewCounter("ownarr_busy_total",
+		"File operations refused as the path was in use, retried on the next cycle.", "op")
+	WatcherErrors = Default.NewCounter("ownarr_watcher_errors_total",
+		"Errors reported by the file system watcher, by kind.", "kind")
+	EventBursts = Default.NewCounter("ownarr_event_bursts_total",
+		"Bursts of events in one directory handled by a scan of it instead.", "folder")
+	EventQueue = Default.NewGauge("ownarr_event_queue_length",
+		"Events of a folder waiting to be handled.", "folder")
+	EventsDropped = Default.NewCounter("ownarr_events_dropped_total",
+		"Events of a folder dropped because its queue was full.", "folder")
+	Panics = Default.NewCounter("ownarr_panics_total",
+		"Panics recovered, by the component that restarted after them.", "component")
+	Paused = Default.NewGauge("ownarr_paused",
+		"Whether enforcement is paused (1) or changing permissions (0).")
+
+	Watches = Default.NewGaugeFunc("ownarr_watches",
+		"Directories watched with inotify or the platform equivalent.", "folder")
+	Goroutines = Default.NewGaugeFunc("ownarr_goroutines",
+		"Goroutines currently running.", "")
+)
+
+func init() {
+	Goroutines.SetFunc(func() map[string]float64 {
+		return map[string]float64{"": float64(runtime.NumGoroutine())}
+	})
+}
+
+// ObserveScan records the result of a full scan of a folder
+func ObserveScan(folder, trigger string, result status.RunResult) {
+	ScanDuration.Observe(result.Duration.Seconds(), folder, trigger)
+	ScanPaths.Add(float64(result.Fixed), folder, "fixed")
+	ScanPaths.Add(float64(result.Skipped), folder, "skipped")
+	ScanPaths.Add(float64(result.Failed), folder, "failed")
+
+	if result.Failed == 0 {
+		LastSuccessfulScan.Set(float64(result.Started.Add(result.Duration).Unix()), folder)
+	}
+}
+
+// ObserveEvent records the handling latency of a file system event
+func ObserveEvent(folder, operation string, occurred time.Time) {
+	EventDuration.Observe(time.Since(occurred).Seconds(), folder, operation)
+}
+
+// RecordFailure counts a failed file operation such as "stat", "chmod" or "chown"
+func RecordFailure(op string, err error) {
+	Failures.Inc(op, ErrnoClass(err))
+}
+
+// ErrnoClass returns a short, low-cardinality name for the cause of a
+// file operation error
+func ErrnoClass(err error) string {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return "other"
+	}
+
+	switch errno {
+	case syscall.EPERM:
+		return "EPERM"
+	case syscall.EACCES:
+		return "EACCES"
+	case syscall.ENOENT:
+		return "ENOENT"
+	case syscall.EROFS:
+		return "EROFS"
+	case syscall.EBUSY:
+		return "EBUSY"
+	case syscall.ETXTBSY:
+		return "ETXTBSY"
+	case syscall.EINVAL:
+		return "EINVAL"
+	case syscall.EIO:
+		return "EIO"
+	case syscall.ENOSPC:
+		return "ENOSPC"
+	default:
+		return "other"
+	}
+}
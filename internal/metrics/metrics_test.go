@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrnoClass(t *testing.T) {
+	assert.Equal(t, "EPERM", ErrnoClass(&fs.PathError{Op: "chmod", Path: "/x", Err: syscall.EPERM}))
+	assert.Equal(t, "EROFS", ErrnoClass(fmt.Errorf("wrapped: %w", syscall.EROFS)))
+	assert.Equal(t, "ENOENT", ErrnoClass(os.NewSyscallError("stat", syscall.ENOENT)))
+	assert.Equal(t, "other", ErrnoClass(syscall.ELOOP))
+	assert.Equal(t, "other", ErrnoClass(fmt.Errorf("not a syscall error")))
+}
+
+func TestObserveScan(t *testing.T) {
+	started := time.Unix(1700000000, 0)
+
+	ObserveScan("observe-test", TriggerPoll, status.RunResult{Started: started, Duration: 2 * time.Second, Fixed: 3})
+	assert.Equal(t, uint64(1), ScanDuration.Count("observe-test", TriggerPoll))
+	assert.Equal(t, 3.0, ScanPaths.Value("observe-test", "fixed"))
+	assert.Equal(t, 1700000002.0, LastSuccessfulScan.Value("observe-test"))
+
+	// Scans with failures do not count as successful
+	ObserveScan("observe-test", TriggerPoll, status.RunResult{Started: started.Add(time.Hour), Failed: 1})
+	assert.Equal(t, 1700000002.0, LastSuccessfulScan.Value("observe-test"))
+}
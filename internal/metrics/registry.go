@@ -0,0 +1,539 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and writes them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric is a named family of series
+type metric interface {
+	desc() Desc
+	write(w *bufio.Writer)
+	gather(fn func(Sample))
+}
+
+// Sample is the current value of a single series. Histograms are gathered
+// as their _sum and _count counters.
+type Sample struct {
+	Name   string
+	Kind   string // "counter" or "gauge"
+	Labels []Label
+	Value  float64
+}
+
+// Desc describes a metric
+type Desc struct {
+	Name   string
+	Help   string
+	Kind   string // "counter", "gauge" or "histogram"
+	Labels []string
+}
+
+// Label is a label name and value
+type Label struct {
+	Name  string
+	Value string
+}
+
+// labelPairs combines label names and values
+func labelPairs(names, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+	return labels
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric to the registry, panicking on duplicate names as
+// that is a programming error
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := m.desc().Name
+	for _, existing := range r.metrics {
+		if existing.desc().Name == name {
+			panic("metrics: duplicate metric " + name)
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// Each calls fn with the description of every registered metric in name order
+func (r *Registry) Each(fn func(Desc)) {
+	for _, m := range r.sorted() {
+		fn(m.desc())
+	}
+}
+
+// Gather returns the current samples of all metrics in name order
+func (r *Registry) Gather() []Sample {
+	var samples []Sample
+	for _, m := range r.sorted() {
+		m.gather(func(sample Sample) {
+			samples = append(samples, sample)
+		})
+	}
+	return samples
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, m := range r.sorted() {
+		desc := m.desc()
+		fmt.Fprintf(bw, "# HELP %s %s\n", desc.Name, escapeHelp(desc.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", desc.Name, desc.Kind)
+		m.write(bw)
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// sorted returns the registered metrics in name order
+func (r *Registry) sorted() []metric {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].desc().Name < metrics[j].desc().Name
+	})
+	return metrics
+}
+
+// family holds the series of a metric keyed by label values
+type family[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string // Label values by series key
+}
+
+// newFamily creates an empty family
+func newFamily[T any](name, help string, labels []string) family[T] {
+	return family[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+	}
+}
+
+// get returns the series for the label values, creating it with create if
+// needed. Callers must hold mu.
+func (f *family[T]) get(labelValues []string, create func() *T) *T {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// each calls fn for every series in label order. Callers must hold mu.
+func (f *family[T]) each(fn func(labelValues []string, s *T)) {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fn(f.values[key], f.series[key])
+	}
+}
+
+// Counter is a metric that only increases
+type Counter struct {
+	family[float64]
+}
+
+// NewCounter creates and registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily[float64](name, help, labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	*c.get(labelValues, newFloat) += v
+}
+
+// Value returns the current value of a series
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return *c.get(labelValues, newFloat)
+}
+
+// desc describes the metric
+func (c *Counter) desc() Desc {
+	return Desc{Name: c.name, Help: c.help, Kind: "counter", Labels: c.labels}
+}
+
+// write writes the samples of every series
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(func(labelValues []string, v *float64) {
+		writeSample(w, c.name, c.labels, labelValues, "", "", *v)
+	})
+}
+
+// gather reports the value of every series
+func (c *Counter) gather(fn func(Sample)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(func(labelValues []string, v *float64) {
+		fn(Sample{Name: c.name, Kind: "counter", Labels: labelPairs(c.labels, labelValues), Value: *v})
+	})
+}
+
+// Gauge is a metric that can go up and down
+type Gauge struct {
+	family[float64]
+}
+
+// NewGauge creates and registers a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newFamily[float64](name, help, labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the series with the given label values to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	*g.get(labelValues, newFloat) = v
+}
+
+// Add adds v to the series with the given label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	*g.get(labelValues, newFloat) += v
+}
+
+// Value returns the current value of a series
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return *g.get(labelValues, newFloat)
+}
+
+// Delete removes the series with the given label values, for labels that
+// no longer exist such as removed folders
+func (g *Gauge) Delete(labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	delete(g.series, key)
+	delete(g.values, key)
+}
+
+// desc describes the metric
+func (g *Gauge) desc() Desc {
+	return Desc{Name: g.name, Help: g.help, Kind: "gauge", Labels: g.labels}
+}
+
+// write writes the samples of every series
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.each(func(labelValues []string, v *float64) {
+		writeSample(w, g.name, g.labels, labelValues, "", "", *v)
+	})
+}
+
+// gather reports the value of every series
+func (g *Gauge) gather(fn func(Sample)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.each(func(labelValues []string, v *float64) {
+		fn(Sample{Name: g.name, Kind: "gauge", Labels: labelPairs(g.labels, labelValues), Value: *v})
+	})
+}
+
+// GaugeFunc is a gauge whose series are read from a callback when collected
+type GaugeFunc struct {
+	name  string
+	help  string
+	label string
+
+	mu sync.Mutex
+	fn func() map[string]float64
+}
+
+// NewGaugeFunc creates and registers a gauge read from a callback. With an
+// empty label the callback's value under the empty key is reported alone.
+func (r *Registry) NewGaugeFunc(name, help, label string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, label: label}
+	r.register(g)
+	return g
+}
+
+// SetFunc sets the callback that provides the gauge's values
+func (g *GaugeFunc) SetFunc(fn func() map[string]float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.fn = fn
+}
+
+// desc describes the metric
+func (g *GaugeFunc) desc() Desc {
+	desc := Desc{Name: g.name, Help: g.help, Kind: "gauge"}
+	if g.label != "" {
+		desc.Labels = []string{g.label}
+	}
+	return desc
+}
+
+// write writes the samples of every series
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+	if fn == nil {
+		return
+	}
+
+	values := fn()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if g.label == "" {
+			writeSample(w, g.name, nil, nil, "", "", values[key])
+			continue
+		}
+		writeSample(w, g.name, []string{g.label}, []string{key}, "", "", values[key])
+	}
+}
+
+// gather reports the value of every series
+func (g *GaugeFunc) gather(fn func(Sample)) {
+	g.mu.Lock()
+	values := g.fn
+	g.mu.Unlock()
+	if values == nil {
+		return
+	}
+
+	current := values()
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sample := Sample{Name: g.name, Kind: "gauge", Value: current[key]}
+		if g.label != "" {
+			sample.Labels = []Label{{Name: g.label, Value: key}}
+		}
+		fn(sample)
+	}
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	family[histogramSeries]
+	buckets []float64
+}
+
+// histogramSeries holds the observations of a single series
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a histogram with the given upper
+// bucket bounds, in increasing order, and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily[histogramSeries](name, help, labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// Observe records a value in the series with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.get(labelValues, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	})
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations in a series
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[strings.Join(labelValues, "\xff")]
+	if !ok {
+		return 0
+	}
+	return s.count
+}
+
+// desc describes the metric
+func (h *Histogram) desc() Desc {
+	return Desc{Name: h.name, Help: h.help, Kind: "histogram", Labels: h.labels}
+}
+
+// write writes the samples of every series
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.each(func(labelValues []string, s *histogramSeries) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, labelValues, "", "", float64(s.count))
+	})
+}
+
+// gather reports the sum and count of every series
+func (h *Histogram) gather(fn func(Sample)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.each(func(labelValues []string, s *histogramSeries) {
+		labels := labelPairs(h.labels, labelValues)
+		fn(Sample{Name: h.name + "_sum", Kind: "counter", Labels: labels, Value: s.sum})
+		fn(Sample{Name: h.name + "_count", Kind: "counter", Labels: labels, Value: float64(s.count)})
+	})
+}
+
+// writeSample writes a single sample line, with an optional extra label
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(labelValues[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// formatFloat formats a sample value
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp escapes help text for the text format
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// newFloat allocates a zero series value
+func newFloat() *float64 {
+	return new(float64)
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
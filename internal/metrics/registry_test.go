@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	counter := r.NewCounter("test_events_total", "Events seen.", "folder")
+	counter.Inc("movies")
+	counter.Add(2, `say "hi"`)
+
+	gauge := r.NewGauge("test_temperature", "Current temperature.")
+	gauge.Set(21.5)
+
+	histogram := r.NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+	histogram.Observe(0.05, "scan")
+	histogram.Observe(0.5, "scan")
+	histogram.Observe(5, "scan")
+
+	watches := r.NewGaugeFunc("test_watches", "Watches.", "folder")
+	watches.SetFunc(func() map[string]float64 { return map[string]float64{"tv": 4} })
+
+	var b strings.Builder
+	_, err := r.WriteTo(&b)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="scan",le="0.1"} 1
+test_duration_seconds_bucket{op="scan",le="1"} 2
+test_duration_seconds_bucket{op="scan",le="+Inf"} 3
+test_duration_seconds_sum{op="scan"} 5.55
+test_duration_seconds_count{op="scan"} 3
+# HELP test_events_total Events seen.
+# TYPE test_events_total counter
+test_events_total{folder="movies"} 1
+test_events_total{folder="say \"hi\""} 2
+# HELP test_temperature Current temperature.
+# TYPE test_temperature gauge
+test_temperature 21.5
+# HELP test_watches Watches.
+# TYPE test_watches gauge
+test_watches{folder="tv"} 4
+`, b.String())
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.")
+
+	assert.Panics(t, func() { r.NewGauge("test_total", "Test.") })
+}
+
+func TestGaugeDelete(t *testing.T) {
+	r := NewRegistry()
+	gauge := r.NewGauge("test_gauge", "Test.", "folder")
+	gauge.Set(1, "old")
+	gauge.Delete("old")
+
+	var b strings.Builder
+	_, err := r.WriteTo(&b)
+	require.NoError(t, err)
+	assert.NotContains(t, b.String(), "old")
+}
+
+func TestVars(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("ownarr_fixed_total", "Fixed.", "kind").Add(2, "file")
+	r.NewGauge("ownarr_paused", "Paused.").Set(1)
+
+	assert.Equal(t, map[string]float64{
+		`ownarr_fixed_total{kind="file"}`: 2,
+		"ownarr_paused":                   1,
+	}, r.Vars())
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// StatsD line formats
+const (
+	FormatGraphite  = "graphite"  // Label values appended to the dotted metric name
+	FormatDogStatsD = "dogstatsd" // Labels sent as DogStatsD tags
+)
+
+// maxPacketSize keeps UDP packets below common MTUs
+const maxPacketSize = 1432
+
+// StatsDOptions configures a StatsD sink
+type StatsDOptions struct {
+	Address  string        // host:port of the StatsD server
+	Prefix   string        // Prepended to every metric name
+	Format   string        // FormatGraphite or FormatDogStatsD
+	Interval time.Duration // Time between flushes
+}
+
+// StatsD periodically sends the metrics of a registry to a StatsD server.
+// Counters are sent as the increase since the previous flush, gauges as
+// their current value.
+type StatsD struct {
+	logger   *log.Logger
+	registry *Registry
+	options  StatsDOptions
+	previous map[string]float64 // Counter values at the previous flush
+}
+
+// NewStatsD creates a StatsD sink for registry
+func NewStatsD(registry *Registry, options StatsDOptions, logger *log.Logger) *StatsD {
+	return &StatsD{
+		logger:   logger,
+		registry: registry,
+		options:  options,
+		previous: make(map[string]float64),
+	}
+}
+
+// Run flushes metrics every interval until ctx is cancelled, then flushes
+// one last time
+func (s *StatsD) Run(ctx context.Context) error {
+	conn, err := net.Dial("udp", s.options.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd at %s: %w", s.options.Address, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(conn)
+			return nil
+		case <-ticker.C:
+			s.flush(conn)
+		}
+	}
+}
+
+// flush sends the current samples in as few packets as possible
+func (s *StatsD) flush(conn net.Conn) {
+	var packet strings.Builder
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, err := conn.Write([]byte(packet.String())); err != nil {
+			s.logger.Debug("Failed to send statsd metrics", "error", err)
+		}
+		packet.Reset()
+	}
+
+	for _, line := range s.lines() {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+			send()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	send()
+}
+
+// lines formats the registry's samples as StatsD lines
+func (s *StatsD) lines() []string {
+	var lines []string
+	for _, sample := range s.registry.Gather() {
+		name, tags := s.name(sample)
+
+		value := sample.Value
+		kind := "g"
+		if sample.Kind == "counter" {
+			key := name + tags
+			value -= s.previous[key]
+			s.previous[key] = sample.Value
+			if value == 0 {
+				continue
+			}
+			kind = "c"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s:%s|%s%s", name, formatFloat(value), kind, tags))
+	}
+	return lines
+}
+
+// name returns the StatsD name and tag suffix of a sample
+func (s *StatsD) name(sample Sample) (string, string) {
+	name := sanitize(strings.TrimPrefix(sample.Name, "ownarr_"))
+	if s.options.Prefix != "" {
+		name = s.options.Prefix + "." + name
+	}
+
+	if s.options.Format == FormatDogStatsD {
+		if len(sample.Labels) == 0 {
+			return name, ""
+		}
+		tags := make([]string, len(sample.Labels))
+		for i, label := range sample.Labels {
+			tags[i] = label.Name + ":" + sanitize(label.Value)
+		}
+		return name, "|#" + strings.Join(tags, ",")
+	}
+
+	for _, label := range sample.Labels {
+		value := sanitize(label.Value)
+		if value == "" {
+			value = "none"
+		}
+		name += "." + value
+	}
+	return name, ""
+}
+
+// sanitize replaces characters with special meaning in StatsD and Graphite
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', '#', ',', ' ', '\n', '/':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsDLines(t *testing.T) {
+	r := NewRegistry()
+	fixed := r.NewCounter("ownarr_fixed_total", "Fixed.", "kind")
+	active := r.NewGauge("ownarr_active_scans", "Active.", "folder")
+	fixed.Add(3, "file")
+	active.Set(1, "tv.shows")
+
+	graphite := NewStatsD(r, StatsDOptions{Prefix: "ownarr", Format: FormatGraphite}, log.New(os.Stderr))
+	assert.Equal(t, []string{
+		"ownarr.active_scans.tv_shows:1|g",
+		"ownarr.fixed_total.file:3|c",
+	}, graphite.lines())
+
+	// Counters are sent as deltas and skipped while unchanged
+	fixed.Add(2, "file")
+	assert.Equal(t, []string{
+		"ownarr.active_scans.tv_shows:1|g",
+		"ownarr.fixed_total.file:2|c",
+	}, graphite.lines())
+	assert.Equal(t, []string{"ownarr.active_scans.tv_shows:1|g"}, graphite.lines())
+
+	dogstatsd := NewStatsD(r, StatsDOptions{Format: FormatDogStatsD}, log.New(os.Stderr))
+	assert.Equal(t, []string{
+		"active_scans:1|g|#folder:tv_shows",
+		"fixed_total:5|c|#kind:file",
+	}, dogstatsd.lines())
+}
+
+func TestStatsDRun(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	r := NewRegistry()
+	r.NewGauge("ownarr_up", "Up.").Set(1)
+
+	sink := NewStatsD(r, StatsDOptions{
+		Address:  conn.LocalAddr().String(),
+		Prefix:   "ownarr",
+		Format:   FormatGraphite,
+		Interval: 10 * time.Millisecond,
+	}, log.New(os.Stderr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sink.Run(ctx) }()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	buf := make([]byte, maxPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ownarr.up:1|g", strings.TrimSpace(string(buf[:n])))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Apprise notification types by severity
+var appriseTypes = map[string]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "failure",
+}
+
+// Apprise relays events through an Apprise API server to any of the
+// services it supports
+type Apprise struct {
+	url    string
+	key    string
+	urls   []string
+	tags   []string
+	client *http.Client
+}
+
+type appriseMessage struct {
+	URLs   string `json:"urls,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Type   string `json:"type"`
+	Format string `json:"format"`
+}
+
+// NewApprise creates a notifier for an Apprise API server
+func NewApprise(cfg config.Apprise, client *http.Client) *Apprise {
+	return &Apprise{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		key:    cfg.Key,
+		urls:   cfg.URLs,
+		tags:   cfg.Tags,
+		client: client,
+	}
+}
+
+// Send asks the server to notify the services of the stored configuration,
+// or the configured Apprise URLs
+func (a *Apprise) Send(ctx context.Context, event Event) error {
+	message := appriseMessage{
+		Title:  event.Title,
+		Body:   event.Text(),
+		Type:   appriseTypes[event.Severity],
+		Format: "text",
+	}
+
+	endpoint := a.url + "/notify/"
+	if a.key != "" {
+		endpoint += url.PathEscape(a.key)
+		message.Tag = strings.Join(a.tags, ",")
+	} else {
+		message.URLs = strings.Join(a.urls, ",")
+	}
+
+	return postJSON(ctx, a.client, endpoint, nil, message)
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppriseSend(t *testing.T) {
+	var (
+		path     string
+		received appriseMessage
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		received = appriseMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	event := Event{Type: EventErrors, Severity: SeverityError, Title: "Enforcement errors", Message: "10 errors within 1m0s"}
+
+	// A stored configuration, limited to tagged services
+	stored := NewApprise(config.Apprise{URL: srv.URL, Key: "ownarr", Tags: []string{"admin", "mobile"}}, srv.Client())
+	require.NoError(t, stored.Send(context.Background(), event))
+	assert.Equal(t, "/notify/ownarr", path)
+	assert.Equal(t, appriseMessage{Tag: "admin,mobile", Title: "Enforcement errors", Body: "10 errors within 1m0s", Type: "failure", Format: "text"}, received)
+
+	// Apprise URLs sent with each request
+	stateless := NewApprise(config.Apprise{URL: srv.URL + "/", URLs: []string{"pover://user@token", "mailto://a:b@example.com"}}, srv.Client())
+	require.NoError(t, stateless.Send(context.Background(), event))
+	assert.Equal(t, "/notify/", path)
+	assert.Equal(t, "pover://user@token,mailto://a:b@example.com", received.URLs)
+	assert.Empty(t, received.Tag)
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Embed colors by severity
+var discordColors = map[string]int{
+	SeverityInfo:    0x2ecc71, // Green
+	SeverityWarning: 0xf1c40f, // Yellow
+	SeverityError:   0xe74c3c, // Red
+}
+
+// Discord posts events as embeds to a Discord channel webhook
+type Discord struct {
+	url      string
+	username string
+	client   *http.Client
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      discordFooter  `json:"footer"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// NewDiscord creates a notifier for a Discord webhook
+func NewDiscord(cfg config.Discord, client *http.Client) *Discord {
+	return &Discord{
+		url:      cfg.URL,
+		username: cfg.Username,
+		client:   client,
+	}
+}
+
+// Send posts the event as an embed. Summaries list the folder and run
+// counts as fields; error alerts list the failed paths.
+func (d *Discord) Send(ctx context.Context, event Event) error {
+	embed := discordEmbed{
+		Title:       event.Title,
+		Description: event.Message,
+		Color:       discordColors[event.Severity],
+		Timestamp:   event.Time.Format(time.RFC3339),
+		Footer:      discordFooter{Text: "ownarr"},
+	}
+
+	if event.Folder != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "Folder", Value: event.Folder, Inline: true})
+	}
+	if event.Run != nil {
+		embed.Fields = append(embed.Fields,
+			discordField{Name: "Fixed", Value: strconv.Itoa(event.Run.Fixed), Inline: true},
+			discordField{Name: "Failed", Value: strconv.Itoa(event.Run.Failed), Inline: true},
+			discordField{Name: "Duration", Value: (time.Duration(event.Run.DurationMS) * time.Millisecond).String(), Inline: true},
+		)
+	}
+	if len(event.Errors) > 0 {
+		lines := make([]string, len(event.Errors))
+		for i, failure := range event.Errors {
+			lines[i] = fmt.Sprintf("%s: %s", failure.Path, failure.Error)
+		}
+		embed.Description += "\n```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+
+	return postJSON(ctx, d.client, d.url, nil, discordMessage{
+		Username: d.username,
+		Embeds:   []discordEmbed{embed},
+	})
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscordSend(t *testing.T) {
+	var received discordMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	discord := NewDiscord(config.Discord{URL: srv.URL, Username: "ownarr"}, srv.Client())
+	require.NoError(t, discord.Send(context.Background(), Event{
+		Type:     EventSummary,
+		Severity: SeverityWarning,
+		Time:     time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC),
+		Title:    "Permission errors in tv",
+		Message:  "3 fixed, 120 unchanged, 1 failed in 1.2s",
+		Folder:   "tv",
+		Run:      &Run{Fixed: 3, Skipped: 120, Failed: 1, DurationMS: 1200},
+	}))
+
+	assert.Equal(t, "ownarr", received.Username)
+	require.Len(t, received.Embeds, 1)
+	embed := received.Embeds[0]
+	assert.Equal(t, "Permission errors in tv", embed.Title)
+	assert.Equal(t, 0xf1c40f, embed.Color)
+	assert.Equal(t, "2024-05-14T12:00:00Z", embed.Timestamp)
+	assert.Equal(t, []discordField{
+		{Name: "Folder", Value: "tv", Inline: true},
+		{Name: "Fixed", Value: "3", Inline: true},
+		{Name: "Failed", Value: "1", Inline: true},
+		{Name: "Duration", Value: "1.2s", Inline: true},
+	}, embed.Fields)
+}
+
+func TestDiscordSendErrors(t *testing.T) {
+	var received discordMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	discord := NewDiscord(config.Discord{URL: srv.URL}, srv.Client())
+	require.NoError(t, discord.Send(context.Background(), Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Title:    "Enforcement errors",
+		Message:  "10 errors within 1m0s",
+		Errors:   []Failure{{Path: "/data/a", Error: "permission denied"}},
+	}))
+
+	require.Len(t, received.Embeds, 1)
+	assert.Equal(t, 0xe74c3c, received.Embeds[0].Color)
+	assert.Equal(t, "10 errors within 1m0s\n```\n/data/a: permission denied\n```", received.Embeds[0].Description)
+	assert.Empty(t, received.Embeds[0].Fields)
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Heading colors by severity
+var emailColors = map[string]string{
+	SeverityInfo:    "#2e7d32",
+	SeverityWarning: "#ef6c00",
+	SeverityError:   "#c62828",
+}
+
+// emailTemplate renders the HTML body of an email
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"color":    func(severity string) string { return emailColors[severity] },
+	"duration": func(ms int64) time.Duration { return time.Duration(ms) * time.Millisecond },
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222">
+<h2 style="color: {{color .Severity}}">{{.Title}}</h2>
+<p>{{.Message}}</p>
+{{- if .Folder}}
+<p><b>Folder:</b> {{.Folder}}</p>
+{{- end}}
+{{- with .Run}}
+<table cellpadding="4">
+<tr><td>Fixed</td><td>{{.Fixed}}</td></tr>
+<tr><td>Unchanged</td><td>{{.Skipped}}</td></tr>
+<tr><td>Failed</td><td>{{.Failed}}</td></tr>
+<tr><td>Duration</td><td>{{duration .DurationMS}}</td></tr>
+</table>
+{{- end}}
+{{- with .Report}}{{if .Folders}}
+<table cellpadding="4" border="1" style="border-collapse: collapse">
+<tr><th>Folder</th><th>Runs</th><th>Fixed</th><th>Trend</th><th>Unchanged</th><th>Failed</th></tr>
+{{- range .Folders}}
+<tr><td>{{.Folder}}</td><td>{{.Runs}}</td><td>{{.Fixed}}</td><td>{{.Trend}}</td><td>{{.Skipped}}</td><td>{{.Failed}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .TopErrors}}
+<h3>Top errors</h3>
+<ul>
+{{- range .}}
+<li><code>{{.Path}}</code>: {{.Count}} times, {{.Error}}</li>
+{{- end}}
+</ul>
+{{- end}}{{end}}
+{{- with .Errors}}
+<h3>Errors</h3>
+<ul>
+{{- range .}}
+<li><code>{{.Path}}</code>: {{.Error}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<p style="color: #888; font-size: small">Sent by ownarr at {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+</body>
+</html>
+`))
+
+// Email sends events as multipart plain text and HTML emails over SMTP
+type Email struct {
+	host      string
+	addr      string
+	mode      string // One of the config.Email TLS modes
+	username  string
+	password  string
+	from      *mail.Address
+	to        []*mail.Address
+	tlsConfig *tls.Config
+}
+
+// NewEmail creates a notifier for an SMTP server
+func NewEmail(cfg config.Email) *Email {
+	e := &Email{
+		host:      cfg.Host,
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		mode:      cfg.TLS,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		from:      parseAddress(cfg.From),
+		tlsConfig: &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12},
+	}
+	for _, to := range cfg.To {
+		e.to = append(e.to, parseAddress(to))
+	}
+	return e
+}
+
+// parseAddress parses an address checked by the config validation, keeping
+// it as is should it fail anyway
+func parseAddress(address string) *mail.Address {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return &mail.Address{Address: address}
+	}
+	return parsed
+}
+
+// Send delivers the event to all recipients. The connection is closed once
+// ctx expires.
+func (e *Email) Send(ctx context.Context, event Event) error {
+	msg, err := e.message(event)
+	if err != nil {
+		return err
+	}
+
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", e.addr, err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer func() { _ = conn.Close() }()
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	if e.mode == config.EmailSTARTTLS {
+		if err := client.StartTLS(e.tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.from.Address); err != nil {
+		return fmt.Errorf("sender rejected: %w", err)
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", to.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the server, over TLS unless STARTTLS or no TLS is used
+func (e *Email) dial(ctx context.Context) (net.Conn, error) {
+	if e.mode == config.EmailTLS {
+		dialer := &tls.Dialer{Config: e.tlsConfig}
+		return dialer.DialContext(ctx, "tcp", e.addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", e.addr)
+}
+
+// message formats the event as a multipart/alternative email with a plain
+// text and an HTML body
+func (e *Email) message(event Event) ([]byte, error) {
+	var html bytes.Buffer
+	if err := emailTemplate.Execute(&html, event); err != nil {
+		return nil, fmt.Errorf("failed to render email: %w", err)
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", event.Text() + "\n"},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create email part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("failed to encode email part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode email part: %w", err)
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish email: %w", err)
+	}
+
+	to := make([]string, len(e.to))
+	for i, address := range e.to {
+		to[i] = address.String()
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[ownarr] "+event.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
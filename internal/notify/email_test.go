@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpMessage is a message received by the fake SMTP server
+type smtpMessage struct {
+	from string
+	to   []string
+	data []byte
+}
+
+// serveSMTP accepts a single SMTP session without TLS or authentication and
+// returns the message it receives
+func serveSMTP(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		var msg smtpMessage
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(line); {
+			case strings.HasPrefix(command, "EHLO"):
+				_ = tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				_ = tp.PrintfLine("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				_ = tp.PrintfLine("250 OK")
+			case command == "DATA":
+				_ = tp.PrintfLine("354 Go ahead")
+				msg.data, _ = tp.ReadDotBytes()
+				_ = tp.PrintfLine("250 OK")
+				received <- msg
+			case command == "QUIT":
+				_ = tp.PrintfLine("221 Bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestEmailSend(t *testing.T) {
+	addr, received := serveSMTP(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	email := NewEmail(config.Email{
+		Host: host,
+		Port: portNumber,
+		TLS:  config.EmailNoTLS,
+		From: "ownarr <ownarr@example.com>",
+		To:   []string{"admin@example.com", "Ops <ops@example.com>"},
+	})
+	event := Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Time:     time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC),
+		Title:    "Enforcement errors",
+		Message:  "2 errors within 1m0s",
+		Errors:   []Failure{{Path: "/data/<b>.mkv", Error: "permission denied"}},
+	}
+	require.NoError(t, email.Send(context.Background(), event))
+
+	msg := <-received
+	assert.Equal(t, "ownarr@example.com", msg.from)
+	assert.Equal(t, []string{"admin@example.com", "ops@example.com"}, msg.to)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg.data))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "[ownarr] Enforcement errors", subject)
+	assert.Equal(t, `"Ops" <ops@example.com>`, strings.Split(parsed.Header.Get("To"), ", ")[1])
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	bodies := map[string]string{}
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(t, err)
+		bodies[part.Header.Get("Content-Type")] = string(content)
+	}
+
+	assert.Contains(t, bodies["text/plain; charset=utf-8"], "/data/<b>.mkv: permission denied")
+	html := bodies["text/html; charset=utf-8"]
+	assert.Contains(t, html, "<h2 style=\"color: #c62828\">Enforcement errors</h2>")
+	assert.Contains(t, html, "<code>/data/&lt;b&gt;.mkv</code>: permission denied")
+}
+
+func TestEmailSendFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().(*net.TCPAddr)
+	require.NoError(t, listener.Close())
+
+	email := NewEmail(config.Email{
+		Host: addr.IP.String(),
+		Port: addr.Port,
+		TLS:  config.EmailNoTLS,
+		From: "ownarr@example.com",
+		To:   []string{"admin@example.com"},
+	})
+	err = email.Send(context.Background(), Startup("1.0.0", 1))
+	assert.ErrorContains(t, err, "failed to connect")
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Gotify priorities by severity. The Android app notifies silently below 4
+// and pops up from 8.
+var gotifyPriorities = map[string]int{
+	SeverityInfo:    2,
+	SeverityWarning: 5,
+	SeverityError:   8,
+}
+
+// Gotify sends events as messages of a Gotify application
+type Gotify struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras"`
+}
+
+// NewGotify creates a notifier for a Gotify application
+func NewGotify(cfg config.Gotify, client *http.Client) *Gotify {
+	return &Gotify{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		token:  cfg.Token,
+		client: client,
+	}
+}
+
+// Send posts the event as a plain text message with a priority for its
+// severity
+func (g *Gotify) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, g.client, g.url+"/message", map[string]string{"X-Gotify-Key": g.token}, gotifyMessage{
+		Title:    event.Title,
+		Message:  event.Text(),
+		Priority: gotifyPriorities[event.Severity],
+		Extras: map[string]any{
+			"client::display": map[string]string{"contentType": "text/plain"},
+		},
+	})
+}
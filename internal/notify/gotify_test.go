@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGotifySend(t *testing.T) {
+	var (
+		key      string
+		received gotifyMessage
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("X-Gotify-Key")
+		assert.Equal(t, "/gotify/message", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	gotify := NewGotify(config.Gotify{URL: srv.URL + "/gotify/", Token: "AbCdEf"}, srv.Client())
+	require.NoError(t, gotify.Send(context.Background(), Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Title:    "Enforcement errors",
+		Message:  "10 errors within 1m0s",
+	}))
+
+	assert.Equal(t, "AbCdEf", key)
+	assert.Equal(t, "Enforcement errors", received.Title)
+	assert.Equal(t, "10 errors within 1m0s", received.Message)
+	assert.Equal(t, 8, received.Priority)
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// notifiarrAPI is the passthrough endpoint relaying notifications to Discord
+const notifiarrAPI = "https://notifiarr.com/api/v1/notification/passthrough"
+
+// Notifiarr relays events through Notifiarr's passthrough API to a Discord
+// channel, next to the notifications of the *arr apps
+type Notifiarr struct {
+	apiURL    string
+	apiKey    string
+	channelID string
+	client    *http.Client
+}
+
+type notifiarrMessage struct {
+	Notification notifiarrNotification `json:"notification"`
+	Discord      notifiarrDiscord      `json:"discord"`
+}
+
+type notifiarrNotification struct {
+	Update bool   `json:"update"`
+	Name   string `json:"name"`
+	Event  string `json:"event"`
+}
+
+type notifiarrDiscord struct {
+	Color string        `json:"color"`
+	Text  notifiarrText `json:"text"`
+	IDs   notifiarrIDs  `json:"ids"`
+}
+
+type notifiarrText struct {
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Fields      []notifiarrField `json:"fields,omitempty"`
+	Footer      string           `json:"footer"`
+}
+
+type notifiarrField struct {
+	Title  string `json:"title"`
+	Text   string `json:"text"`
+	Inline bool   `json:"inline"`
+}
+
+type notifiarrIDs struct {
+	Channel json.Number `json:"channel"` // Snowflake IDs exceed the precision of a float
+}
+
+// NewNotifiarr creates a notifier for a Discord channel reached through
+// Notifiarr
+func NewNotifiarr(cfg config.Notifiarr, client *http.Client) *Notifiarr {
+	return &Notifiarr{
+		apiURL:    notifiarrAPI,
+		apiKey:    cfg.APIKey,
+		channelID: cfg.ChannelID,
+		client:    client,
+	}
+}
+
+// Send passes the event through as a Discord embed colored by severity,
+// with the same fields as a Discord webhook
+func (n *Notifiarr) Send(ctx context.Context, event Event) error {
+	text := notifiarrText{
+		Title:       event.Title,
+		Description: event.Message,
+		Footer:      "ownarr · " + event.Time.Format(time.DateTime),
+	}
+	if event.Folder != "" {
+		text.Fields = append(text.Fields, notifiarrField{Title: "Folder", Text: event.Folder, Inline: true})
+	}
+	if event.Run != nil {
+		text.Fields = append(text.Fields,
+			notifiarrField{Title: "Fixed", Text: strconv.Itoa(event.Run.Fixed), Inline: true},
+			notifiarrField{Title: "Failed", Text: strconv.Itoa(event.Run.Failed), Inline: true},
+			notifiarrField{Title: "Duration", Text: (time.Duration(event.Run.DurationMS) * time.Millisecond).String(), Inline: true},
+		)
+	}
+	if len(event.Errors) > 0 {
+		lines := make([]string, len(event.Errors))
+		for i, failure := range event.Errors {
+			lines[i] = fmt.Sprintf("%s: %s", failure.Path, failure.Error)
+		}
+		text.Description += "\n```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+
+	return postJSON(ctx, n.client, n.apiURL, map[string]string{"X-API-Key": n.apiKey}, notifiarrMessage{
+		Notification: notifiarrNotification{Name: "ownarr", Event: event.Type},
+		Discord: notifiarrDiscord{
+			Color: fmt.Sprintf("%06X", discordColors[event.Severity]),
+			Text:  text,
+			IDs:   notifiarrIDs{Channel: json.Number(n.channelID)},
+		},
+	})
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifiarrSend(t *testing.T) {
+	var (
+		apiKey string
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-API-Key")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	notifiarr := NewNotifiarr(config.Notifiarr{APIKey: "notifiarr-key", ChannelID: "735481457153277994"}, srv.Client())
+	notifiarr.apiURL = srv.URL
+
+	at := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, notifiarr.Send(context.Background(), Event{
+		Type:     EventSummary,
+		Severity: SeverityWarning,
+		Time:     at,
+		Folder:   "tv",
+		Title:    "Fixed permissions in tv",
+		Message:  "3 fixed, 1 failed",
+		Run:      &Run{Fixed: 3, Failed: 1, DurationMS: 1500},
+		Errors:   []Failure{{Path: "/data/tv/a.mkv", Error: "permission denied"}},
+	}))
+	assert.Equal(t, "notifiarr-key", apiKey)
+
+	// The channel is sent as a number without losing precision
+	assert.Contains(t, string(body), `"channel":735481457153277994`)
+
+	var received notifiarrMessage
+	require.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, notifiarrNotification{Name: "ownarr", Event: EventSummary}, received.Notification)
+	assert.Equal(t, "F1C40F", received.Discord.Color)
+	assert.Equal(t, "Fixed permissions in tv", received.Discord.Text.Title)
+	assert.Equal(t, "3 fixed, 1 failed\n```\n/data/tv/a.mkv: permission denied\n```", received.Discord.Text.Description)
+	assert.Equal(t, []notifiarrField{
+		{Title: "Folder", Text: "tv", Inline: true},
+		{Title: "Fixed", Text: "3", Inline: true},
+		{Title: "Failed", Text: "1", Inline: true},
+		{Title: "Duration", Text: "1.5s", Inline: true},
+	}, received.Discord.Text.Fields)
+}
+
+func TestNotifiarrSendFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"result":"error","details":{"response":"invalid API key"}}`))
+	}))
+	defer srv.Close()
+
+	notifiarr := NewNotifiarr(config.Notifiarr{APIKey: "wrong", ChannelID: "1"}, srv.Client())
+	notifiarr.apiURL = srv.URL
+
+	err := notifiarr.Send(context.Background(), Startup("1.0.0", 1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
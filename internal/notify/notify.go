@@ -0,0 +1,543 @@
+// Package notify sends enforcement events to external services
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// Event types, as selected in the events list of a target
+const (
+	EventSummary  = "summary"  // A run fixed or failed to fix permissions
+	EventErrors   = "errors"   // Enforcement errors piled up
+	EventDegraded = "degraded" // A condition impairing enforcement started
+	EventReport   = "report"   // The scheduled report of all runs
+	EventStartup  = "startup"  // The application started
+	EventShutdown = "shutdown" // The application is stopping
+)
+
+// Event severities
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// queueSize is the number of events waiting for delivery before further
+// events are dropped
+const queueSize = 100
+
+// maxFailures is the number of failed paths listed in an error alert
+const maxFailures = 10
+
+// Event is a notification about the application or an enforcement run
+type Event struct {
+	Type     string    `json:"type"`
+	Severity string    `json:"severity"`
+	Time     time.Time `json:"time"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Folder   string    `json:"folder,omitempty"`
+	Run      *Run      `json:"run,omitempty"`    // Set for summaries
+	Errors   []Failure `json:"errors,omitempty"` // Set for error alerts
+	Report   *Report   `json:"report,omitempty"` // Set for reports
+}
+
+// Run is the result of an enforcement run
+type Run struct {
+	Fixed      int   `json:"fixed"`
+	Skipped    int   `json:"skipped"`
+	Failed     int   `json:"failed"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Failure is a path that could not be enforced
+type Failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Notifier delivers events to a single service
+type Notifier interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// severities orders the event severities, lowest first
+var severities = []string{SeverityInfo, SeverityWarning, SeverityError}
+
+// target is a configured notifier and the events it receives
+type target struct {
+	name        string
+	events      []string // Event types, all when empty
+	folders     []string // Folders of folder events, all when empty
+	minSeverity string   // Lowest severity, all when empty
+	notifier    Notifier
+}
+
+// wants reports whether the target receives an event. Events that are not
+// about a folder pass the folder filter.
+func (t target) wants(event Event) bool {
+	if len(t.events) > 0 && !slices.Contains(t.events, event.Type) {
+		return false
+	}
+	if len(t.folders) > 0 && event.Folder != "" && !slices.Contains(t.folders, event.Folder) {
+		return false
+	}
+	return t.minSeverity == "" || slices.Index(severities, event.Severity) >= slices.Index(severities, t.minSeverity)
+}
+
+// burst counts the errors within the current error window
+type burst struct {
+	start    time.Time
+	count    int
+	failures []Failure
+}
+
+// Dispatcher routes events to the configured notifiers in the background,
+// retrying failed deliveries. It records activity entries and observes runs
+// to raise error alerts and run summaries.
+type Dispatcher struct {
+	logger  *log.Logger
+	client  *http.Client
+	backoff time.Duration // Delay before the first retry, doubled for each one after
+
+	queue      chan Event
+	done       chan struct{}      // Closed once the queue has been drained
+	reschedule chan struct{}      // Signalled when the report schedule may have changed
+	ctx        context.Context    // Cancelled to abandon deliveries
+	cancel     context.CancelFunc // Cancels ctx
+
+	mu            sync.Mutex
+	cfg           *config.Config
+	targets       []target
+	templates     map[string]eventTemplate // Custom titles and messages by event type
+	burst         burst
+	report        map[string]FolderReport // Run totals by folder since reportSince
+	reportErrors  map[string]PathErrors   // Failures by path since reportSince
+	reportSince   time.Time
+	previousFixed map[string]int // Fixed paths by folder in the previous report
+	closed        bool
+}
+
+// New creates a dispatcher for the notification targets in cfg and starts
+// delivering events
+func New(cfg *config.Config, logger *log.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		logger:       logger,
+		client:       &http.Client{},
+		backoff:      time.Second,
+		queue:        make(chan Event, queueSize),
+		done:         make(chan struct{}),
+		reschedule:   make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+		report:       make(map[string]FolderReport),
+		reportErrors: make(map[string]PathErrors),
+		reportSince:  time.Now(),
+	}
+	d.SetConfig(cfg)
+
+	go d.run()
+	go d.schedule()
+	return d
+}
+
+// SetConfig replaces the notification targets and templates after a reload
+func (d *Dispatcher) SetConfig(cfg *config.Config) {
+	targets := newTargets(cfg.Notifications, d.client)
+	templates, err := parseTemplates(cfg.Notifications)
+	if err != nil {
+		d.logger.Error("Failed to parse notification templates", "error", err)
+	}
+
+	d.mu.Lock()
+	d.cfg = cfg
+	d.targets = targets
+	d.templates = templates
+	d.mu.Unlock()
+
+	select {
+	case d.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+// newTargets creates the notifiers configured in cfg
+func newTargets(cfg config.Notifications, client *http.Client) []target {
+	var targets []target
+	for _, webhook := range cfg.Webhooks {
+		targets = append(targets, target{
+			name:        webhook.Name,
+			events:      webhook.Events,
+			folders:     webhook.Folders,
+			minSeverity: webhook.MinSeverity,
+			notifier:    NewWebhook(webhook, client),
+		})
+	}
+	for _, discord := range cfg.Discord {
+		targets = append(targets, target{
+			name:        discord.Name,
+			events:      discord.Events,
+			folders:     discord.Folders,
+			minSeverity: discord.MinSeverity,
+			notifier:    NewDiscord(discord, client),
+		})
+	}
+	for _, slack := range cfg.Slack {
+		targets = append(targets, target{
+			name:        slack.Name,
+			events:      slack.Events,
+			folders:     slack.Folders,
+			minSeverity: slack.MinSeverity,
+			notifier:    NewSlack(slack, client),
+		})
+	}
+	for _, telegram := range cfg.Telegram {
+		targets = append(targets, target{
+			name:        telegram.Name,
+			events:      telegram.Events,
+			folders:     telegram.Folders,
+			minSeverity: telegram.MinSeverity,
+			notifier:    NewTelegram(telegram, client),
+		})
+	}
+	for _, ntfy := range cfg.Ntfy {
+		targets = append(targets, target{
+			name:        ntfy.Name,
+			events:      ntfy.Events,
+			folders:     ntfy.Folders,
+			minSeverity: ntfy.MinSeverity,
+			notifier:    NewNtfy(ntfy, client),
+		})
+	}
+	for _, gotify := range cfg.Gotify {
+		targets = append(targets, target{
+			name:        gotify.Name,
+			events:      gotify.Events,
+			folders:     gotify.Folders,
+			minSeverity: gotify.MinSeverity,
+			notifier:    NewGotify(gotify, client),
+		})
+	}
+	for _, apprise := range cfg.Apprise {
+		targets = append(targets, target{
+			name:        apprise.Name,
+			events:      apprise.Events,
+			folders:     apprise.Folders,
+			minSeverity: apprise.MinSeverity,
+			notifier:    NewApprise(apprise, client),
+		})
+	}
+	for _, email := range cfg.Email {
+		targets = append(targets, target{
+			name:        email.Name,
+			events:      email.Events,
+			folders:     email.Folders,
+			minSeverity: email.MinSeverity,
+			notifier:    NewEmail(email),
+		})
+	}
+	for _, pushover := range cfg.Pushover {
+		targets = append(targets, target{
+			name:        pushover.Name,
+			events:      pushover.Events,
+			folders:     pushover.Folders,
+			minSeverity: pushover.MinSeverity,
+			notifier:    NewPushover(pushover, client),
+		})
+	}
+	for _, notifiarr := range cfg.Notifiarr {
+		targets = append(targets, target{
+			name:        notifiarr.Name,
+			events:      notifiarr.Events,
+			folders:     notifiarr.Folders,
+			minSeverity: notifiarr.MinSeverity,
+			notifier:    NewNotifiarr(notifiarr, client),
+		})
+	}
+	return targets
+}
+
+// Notify queues an event for delivery. Events are dropped if the queue is
+// full or the dispatcher is closed.
+func (d *Dispatcher) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || len(d.targets) == 0 {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		d.logger.Warn("Notification queue full, dropping event", "type", event.Type)
+	}
+}
+
+// Record counts enforcement errors for the report and raises an error alert
+// once error_threshold errors occur within error_window. Further errors in
+// the same window are not alerted again.
+func (d *Dispatcher) Record(entry activity.Entry) {
+	if entry.Type != activity.TypeError {
+		return
+	}
+
+	// Failures end up in messages, where names must not forge lines
+	path, message := escape.Text(entry.Path), escape.Text(entry.Error)
+
+	d.mu.Lock()
+	d.addError(path, message)
+	settings := d.cfg.Notifications
+	window := time.Duration(settings.ErrorWindow) * time.Second
+	if entry.Time.Sub(d.burst.start) >= window {
+		d.burst = burst{start: entry.Time}
+	}
+	d.burst.count++
+	if len(d.burst.failures) < maxFailures {
+		d.burst.failures = append(d.burst.failures, Failure{Path: path, Error: message})
+	}
+	if d.burst.count != settings.ErrorThreshold {
+		d.mu.Unlock()
+		return
+	}
+	failures := slices.Clone(d.burst.failures)
+	d.mu.Unlock()
+
+	d.Notify(Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Time:     entry.Time,
+		Title:    "Enforcement errors",
+		Message:  fmt.Sprintf("%d errors within %s", settings.ErrorThreshold, window),
+		Errors:   failures,
+	})
+}
+
+// ObserveRun adds a run to the daily report and sends a summary of runs that
+// fixed or failed to fix anything, or that meet the folder's thresholds
+func (d *Dispatcher) ObserveRun(folder string, result status.RunResult) {
+	watchDir := d.watchDir(folder)
+	name := watchDir.Name
+	d.addRun(name, result)
+	if !watchDir.Notify.Summarizes(result.Fixed, result.Failed) {
+		return
+	}
+
+	event := Event{
+		Type:     EventSummary,
+		Severity: SeverityInfo,
+		Time:     result.Started.Add(result.Duration),
+		Title:    "Permissions fixed in " + name,
+		Message: fmt.Sprintf("%d fixed, %d unchanged, %d failed in %s",
+			result.Fixed, result.Skipped, result.Failed, result.Duration.Round(time.Millisecond)),
+		Folder: name,
+		Run: &Run{
+			Fixed:      result.Fixed,
+			Skipped:    result.Skipped,
+			Failed:     result.Failed,
+			DurationMS: result.Duration.Milliseconds(),
+		},
+	}
+	if result.Failed > 0 {
+		event.Severity = SeverityWarning
+		event.Title = "Permission errors in " + name
+	}
+	d.Notify(event)
+}
+
+// ObserveDegraded sends an alert when a degraded condition starts, such as
+// watcher errors
+func (d *Dispatcher) ObserveDegraded(flag, reason string) {
+	d.Notify(Event{
+		Type:     EventDegraded,
+		Severity: SeverityError,
+		Title:    "ownarr degraded",
+		Message:  fmt.Sprintf("%s: %s", flag, reason),
+	})
+}
+
+// Text formats the message of an event with its folder, run counts, report
+// and failed paths as plain text, for services without rich formatting
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+
+	if e.Folder != "" {
+		fmt.Fprintf(&b, "\n\nFolder: %s", e.Folder)
+	}
+	if e.Run != nil {
+		fmt.Fprintf(&b, "\nFixed: %d\nFailed: %d\nDuration: %s",
+			e.Run.Fixed, e.Run.Failed, time.Duration(e.Run.DurationMS)*time.Millisecond)
+	}
+	if e.Report != nil && len(e.Report.Folders) > 0 {
+		b.WriteString("\n")
+		for _, total := range e.Report.Folders {
+			fmt.Fprintf(&b, "\n%s: %d runs, %d fixed (%s), %d failed", total.Folder, total.Runs, total.Fixed, total.Trend(), total.Failed)
+		}
+	}
+	if e.Report != nil && len(e.Report.TopErrors) > 0 {
+		b.WriteString("\n\nTop errors:")
+		for _, failures := range e.Report.TopErrors {
+			fmt.Fprintf(&b, "\n%s: %d times, %s", failures.Path, failures.Count, failures.Error)
+		}
+	}
+	if len(e.Errors) > 0 {
+		b.WriteString("\n")
+		for _, failure := range e.Errors {
+			fmt.Fprintf(&b, "\n%s: %s", failure.Path, failure.Error)
+		}
+	}
+	return b.String()
+}
+
+// Startup returns the event sent when the application starts
+func Startup(version string, folders int) Event {
+	return Event{
+		Type:     EventStartup,
+		Severity: SeverityInfo,
+		Title:    "ownarr started",
+		Message:  fmt.Sprintf("Version %s watching %d folders", version, folders),
+	}
+}
+
+// Shutdown returns the event sent when the application stops
+func Shutdown(version string) Event {
+	return Event{
+		Type:     EventShutdown,
+		Severity: SeverityInfo,
+		Title:    "ownarr stopping",
+		Message:  fmt.Sprintf("Version %s is shutting down", version),
+	}
+}
+
+// Close delivers the queued events and stops the dispatcher. Deliveries
+// still running when ctx expires are abandoned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return fmt.Errorf("failed to deliver notifications: %w", ctx.Err())
+	}
+}
+
+// watchDir returns the watch directory at path. Unknown directories are
+// named by their path.
+func (d *Dispatcher) watchDir(path string) config.WatchDir {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, watchDir := range d.cfg.WatchDirs {
+		if watchDir.Path == path {
+			return watchDir
+		}
+	}
+	return config.WatchDir{Name: path, Path: path}
+}
+
+// folderTargets returns the targets a folder restricts its events to, all
+// when nil
+func folderTargets(cfg *config.Config, folder string) []string {
+	if folder == "" {
+		return nil
+	}
+	for _, watchDir := range cfg.WatchDirs {
+		if watchDir.Name == folder {
+			return watchDir.Notify.Targets
+		}
+	}
+	return nil
+}
+
+// run delivers queued events until the queue is closed
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for event := range d.queue {
+		d.mu.Lock()
+		targets := d.targets
+		settings := d.cfg.Notifications
+		only := folderTargets(d.cfg, event.Folder)
+		tmpl, custom := d.templates[event.Type]
+		dryRun := d.cfg.DryRun
+		d.mu.Unlock()
+
+		if custom {
+			rendered, err := tmpl.apply(event)
+			if err != nil {
+				d.logger.Error("Failed to render notification template, sending the default", "event", event.Type, "error", err)
+			}
+			event = rendered
+		}
+		if dryRun {
+			event.Title = "[DRY-RUN] " + event.Title
+		}
+
+		var wg sync.WaitGroup
+		for _, t := range targets {
+			if !t.wants(event) || (len(only) > 0 && !slices.Contains(only, t.name)) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(t, event, time.Duration(settings.Timeout)*time.Second, settings.Retries)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// deliver sends an event to a target, retrying with exponential backoff
+func (d *Dispatcher) deliver(t target, event Event, timeout time.Duration, retries int) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(d.ctx, timeout)
+		err := t.notifier.Send(ctx, event)
+		cancel()
+		if err == nil {
+			d.logger.Debug("Sent notification", "target", t.name, "event", event.Type)
+			return
+		}
+
+		if attempt > retries || d.ctx.Err() != nil {
+			d.logger.Error("Failed to send notification", "target", t.name, "event", event.Type, "attempts", attempt, "error", err)
+			return
+		}
+		d.logger.Warn("Failed to send notification, retrying", "target", t.name, "event", event.Type, "attempt", attempt, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			d.logger.Error("Abandoned notification", "target", t.name, "event", event.Type)
+			return
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifier records the events it receives, failing the first few sends
+type fakeNotifier struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	events   []Event
+}
+
+func (f *fakeNotifier) Send(_ context.Context, event Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("unavailable")
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+// newTestDispatcher creates a dispatcher sending to the given targets
+func newTestDispatcher(cfg *config.Config, targets ...target) *Dispatcher {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	d := New(cfg, logger)
+	d.backoff = time.Millisecond
+	d.targets = targets
+	return d
+}
+
+func TestDispatcherRoutesEvents(t *testing.T) {
+	all, errorsOnly := &fakeNotifier{}, &fakeNotifier{}
+	d := newTestDispatcher(config.DefaultConfig(),
+		target{name: "all", notifier: all},
+		target{name: "errors", events: []string{EventErrors}, notifier: errorsOnly},
+	)
+
+	d.Notify(Startup("1.0.0", 2))
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, all.events, 1)
+	assert.Equal(t, EventStartup, all.events[0].Type)
+	assert.False(t, all.events[0].Time.IsZero())
+	assert.Empty(t, errorsOnly.events)
+
+	// Events after closing are dropped
+	d.Notify(Shutdown("1.0.0"))
+	assert.Len(t, all.events, 1)
+}
+
+func TestDispatcherDryRun(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DryRun = true
+	all := &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "all", notifier: all})
+
+	d.Notify(Startup("1.0.0", 2))
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, all.events, 1)
+	assert.Equal(t, "[DRY-RUN] ownarr started", all.events[0].Title)
+}
+
+func TestTargetWants(t *testing.T) {
+	summary := Event{Type: EventSummary, Severity: SeverityWarning, Folder: "tv"}
+	startup := Startup("1.0.0", 1)
+
+	assert.True(t, target{}.wants(summary))
+	assert.False(t, target{events: []string{EventErrors}}.wants(summary))
+	assert.True(t, target{folders: []string{"tv"}}.wants(summary))
+	assert.False(t, target{folders: []string{"movies"}}.wants(summary))
+	assert.True(t, target{folders: []string{"movies"}}.wants(startup), "events without a folder pass")
+	assert.True(t, target{minSeverity: SeverityWarning}.wants(summary))
+	assert.False(t, target{minSeverity: SeverityError}.wants(summary))
+	assert.False(t, target{minSeverity: SeverityWarning}.wants(startup))
+}
+
+func TestEventText(t *testing.T) {
+	event := Event{
+		Message: "2 errors within 1m0s",
+		Errors:  []Failure{{Path: "/data/a", Error: "denied"}, {Path: "/data/b", Error: "busy"}},
+	}
+	assert.Equal(t, "2 errors within 1m0s\n\n/data/a: denied\n/data/b: busy", event.Text())
+	assert.Equal(t, "Version 1.0.0 watching 2 folders", Startup("1.0.0", 2).Text())
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Notifications.Retries = 2
+
+	flaky, broken := &fakeNotifier{failures: 2}, &fakeNotifier{failures: 10}
+	d := newTestDispatcher(cfg,
+		target{name: "flaky", notifier: flaky},
+		target{name: "broken", notifier: broken},
+	)
+
+	d.Notify(Startup("1.0.0", 1))
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Equal(t, 3, flaky.attempts)
+	assert.Len(t, flaky.events, 1)
+	assert.Equal(t, 3, broken.attempts, "gives up after the retries")
+	assert.Empty(t, broken.events)
+}
+
+func TestDispatcherErrorBurst(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Notifications.ErrorThreshold = 3
+	cfg.Notifications.ErrorWindow = 60
+
+	notifier := &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "fake", notifier: notifier})
+
+	start := time.Now()
+	for i := range 5 {
+		d.Record(activity.Entry{Time: start.Add(time.Duration(i) * time.Second), Type: activity.TypeError, Path: "/data/a", Error: "denied"})
+	}
+	d.Record(activity.Entry{Time: start, Type: activity.TypeFixed, Path: "/data/b"})
+
+	// A new window alerts again
+	for i := range 3 {
+		d.Record(activity.Entry{Time: start.Add(time.Minute + time.Duration(i)*time.Second), Type: activity.TypeError, Path: "/data/c"})
+	}
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, notifier.events, 2)
+	assert.Equal(t, EventErrors, notifier.events[0].Type)
+	assert.Equal(t, SeverityError, notifier.events[0].Severity)
+	assert.Len(t, notifier.events[0].Errors, 3)
+	assert.Equal(t, Failure{Path: "/data/a", Error: "denied"}, notifier.events[0].Errors[0])
+	assert.Equal(t, "/data/c", notifier.events[1].Errors[0].Path)
+}
+
+func TestDispatcherObserveRun(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: "/data/tv"}}
+
+	notifier := &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "fake", notifier: notifier})
+
+	d.ObserveRun("/data/tv", status.RunResult{Skipped: 10})
+	d.ObserveRun("/data/tv", status.RunResult{Fixed: 2, Skipped: 8, Duration: time.Second})
+	d.ObserveRun("/data/movies", status.RunResult{Failed: 1})
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, notifier.events, 2, "runs without changes are not reported")
+	assert.Equal(t, "tv", notifier.events[0].Folder)
+	assert.Equal(t, SeverityInfo, notifier.events[0].Severity)
+	assert.Equal(t, &Run{Fixed: 2, Skipped: 8, DurationMS: 1000}, notifier.events[0].Run)
+	assert.Equal(t, "/data/movies", notifier.events[1].Folder, "unknown folders are named by path")
+	assert.Equal(t, SeverityWarning, notifier.events[1].Severity)
+}
+
+func TestDispatcherFolderRules(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{
+		{Name: "downloads", Path: "/data/downloads", Notify: config.Notify{Targets: []string{"discord"}, MinFailed: 1}},
+		{Name: "tv", Path: "/data/tv"},
+	}
+
+	discord, email := &fakeNotifier{}, &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "discord", notifier: discord}, target{name: "email", notifier: email})
+
+	d.ObserveRun("/data/downloads", status.RunResult{Fixed: 20})
+	d.ObserveRun("/data/downloads", status.RunResult{Fixed: 20, Failed: 1})
+	d.ObserveRun("/data/tv", status.RunResult{Fixed: 1})
+	d.Notify(Startup("1.0.0", 2))
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, discord.events, 3, "runs below the thresholds are not reported")
+	assert.Equal(t, "downloads", discord.events[0].Folder)
+	assert.Equal(t, 1, discord.events[0].Run.Failed)
+	assert.Equal(t, "tv", discord.events[1].Folder)
+	assert.Equal(t, EventStartup, discord.events[2].Type)
+
+	require.Len(t, email.events, 2, "folder events only go to the folder's targets")
+	assert.Equal(t, "tv", email.events[0].Folder)
+	assert.Equal(t, EventStartup, email.events[1].Type)
+}
+
+func TestDispatcherObserveDegraded(t *testing.T) {
+	quiet, urgent := &fakeNotifier{}, &fakeNotifier{}
+	d := newTestDispatcher(config.DefaultConfig(),
+		target{name: "quiet", events: []string{EventSummary}, notifier: quiet},
+		target{name: "urgent", minSeverity: SeverityWarning, notifier: urgent},
+	)
+
+	d.ObserveDegraded("watcher_errors", "queue overflow")
+	d.Notify(Startup("1.0.0", 1))
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Empty(t, quiet.events)
+	require.Len(t, urgent.events, 1, "routine events stay below the escalation threshold")
+	assert.Equal(t, EventDegraded, urgent.events[0].Type)
+	assert.Equal(t, SeverityError, urgent.events[0].Severity)
+	assert.Equal(t, "watcher_errors: queue overflow", urgent.events[0].Message)
+}
+
+func TestDispatcherCloseAbandonsDeliveries(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Notifications.Retries = 100
+
+	d := newTestDispatcher(cfg, target{name: "broken", notifier: &fakeNotifier{failures: 1000}})
+	d.backoff = time.Hour
+
+	d.Notify(Startup("1.0.0", 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Close(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// ntfy priorities and tags by severity. Tags that match an emoji short code
+// are shown as that emoji.
+var (
+	ntfyPriorities = map[string]int{
+		SeverityInfo:    3, // Default
+		SeverityWarning: 4, // High
+		SeverityError:   5, // Max
+	}
+	ntfyTags = map[string]string{
+		SeverityInfo:    "white_check_mark",
+		SeverityWarning: "warning",
+		SeverityError:   "rotating_light",
+	}
+)
+
+// Ntfy publishes events to an ntfy topic
+type Ntfy struct {
+	url    string
+	topic  string
+	token  string
+	client *http.Client
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags"`
+}
+
+// NewNtfy creates a notifier for an ntfy topic
+func NewNtfy(cfg config.Ntfy, client *http.Client) *Ntfy {
+	return &Ntfy{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		topic:  cfg.Topic,
+		token:  cfg.Token,
+		client: client,
+	}
+}
+
+// Send publishes the event with a priority and emoji tag for its severity
+func (n *Ntfy) Send(ctx context.Context, event Event) error {
+	var headers map[string]string
+	if n.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+
+	tags := []string{ntfyTags[event.Severity], "ownarr"}
+	if event.Folder != "" {
+		tags = append(tags, event.Folder)
+	}
+
+	return postJSON(ctx, n.client, n.url+"/", headers, ntfyMessage{
+		Topic:    n.topic,
+		Title:    event.Title,
+		Message:  event.Text(),
+		Priority: ntfyPriorities[event.Severity],
+		Tags:     tags,
+	})
+}
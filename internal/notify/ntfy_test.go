@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfySend(t *testing.T) {
+	var (
+		auth     string
+		received ntfyMessage
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "/", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	ntfy := NewNtfy(config.Ntfy{URL: srv.URL + "/", Topic: "media", Token: "tk_abc"}, srv.Client())
+	require.NoError(t, ntfy.Send(context.Background(), Event{
+		Type:     EventSummary,
+		Severity: SeverityWarning,
+		Title:    "Permission errors in tv",
+		Message:  "3 fixed, 120 unchanged, 1 failed in 1.2s",
+		Folder:   "tv",
+		Run:      &Run{Fixed: 3, Skipped: 120, Failed: 1, DurationMS: 1200},
+	}))
+
+	assert.Equal(t, "Bearer tk_abc", auth)
+	assert.Equal(t, ntfyMessage{
+		Topic:    "media",
+		Title:    "Permission errors in tv",
+		Message:  "3 fixed, 120 unchanged, 1 failed in 1.2s\n\nFolder: tv\nFixed: 3\nFailed: 1\nDuration: 1.2s",
+		Priority: 4,
+		Tags:     []string{"warning", "ownarr", "tv"},
+	}, received)
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// pushoverAPI is the Pushover message endpoint
+const pushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// Pushover message limits, in characters
+const (
+	pushoverMaxTitle   = 250
+	pushoverMaxMessage = 1024
+)
+
+// Emergency priority messages repeat every pushoverRetry seconds until
+// acknowledged or pushoverExpire seconds have passed
+const (
+	pushoverEmergency = 2
+	pushoverRetry     = 60
+	pushoverExpire    = 3600
+)
+
+// Default Pushover priorities by severity: quiet for info, normal for
+// warnings and high, bypassing quiet hours, for errors
+var pushoverPriorities = map[string]int{
+	SeverityInfo:    -1,
+	SeverityWarning: 0,
+	SeverityError:   1,
+}
+
+// Pushover sends events as push notifications of a Pushover application
+type Pushover struct {
+	apiURL     string
+	token      string
+	user       string
+	device     string
+	sound      string
+	priorities map[string]int
+	client     *http.Client
+}
+
+type pushoverMessage struct {
+	Token     string `json:"token"`
+	User      string `json:"user"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Priority  int    `json:"priority"`
+	Timestamp int64  `json:"timestamp"`
+	Device    string `json:"device,omitempty"`
+	Sound     string `json:"sound,omitempty"`
+	Retry     int    `json:"retry,omitempty"`
+	Expire    int    `json:"expire,omitempty"`
+}
+
+// NewPushover creates a notifier for a Pushover user or group
+func NewPushover(cfg config.Pushover, client *http.Client) *Pushover {
+	priorities := make(map[string]int, len(pushoverPriorities))
+	for severity, priority := range pushoverPriorities {
+		priorities[severity] = priority
+	}
+	for severity, priority := range cfg.Priorities {
+		priorities[severity] = priority
+	}
+
+	return &Pushover{
+		apiURL:     pushoverAPI,
+		token:      cfg.Token,
+		user:       cfg.User,
+		device:     cfg.Device,
+		sound:      cfg.Sound,
+		priorities: priorities,
+		client:     client,
+	}
+}
+
+// Send posts the event with the priority of its severity
+func (p *Pushover) Send(ctx context.Context, event Event) error {
+	msg := pushoverMessage{
+		Token:     p.token,
+		User:      p.user,
+		Title:     truncate(event.Title, pushoverMaxTitle),
+		Message:   truncate(event.Text(), pushoverMaxMessage),
+		Priority:  p.priorities[event.Severity],
+		Timestamp: event.Time.Unix(),
+		Device:    p.device,
+		Sound:     p.sound,
+	}
+	if msg.Priority == pushoverEmergency {
+		msg.Retry = pushoverRetry
+		msg.Expire = pushoverExpire
+	}
+	return postJSON(ctx, p.client, p.apiURL, nil, msg)
+}
+
+// truncate shortens s to at most limit characters, marking the cut with an
+// ellipsis
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushoverSend(t *testing.T) {
+	var received pushoverMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = pushoverMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	pushover := NewPushover(config.Pushover{
+		Token:      "app-token",
+		User:       "user-key",
+		Device:     "phone",
+		Priorities: map[string]int{SeverityError: 2},
+	}, srv.Client())
+	pushover.apiURL = srv.URL
+
+	at := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, pushover.Send(context.Background(), Event{Severity: SeverityWarning, Time: at, Title: "Permission errors in tv", Message: "1 failed"}))
+	assert.Equal(t, pushoverMessage{
+		Token:     "app-token",
+		User:      "user-key",
+		Title:     "Permission errors in tv",
+		Message:   "1 failed",
+		Priority:  0,
+		Timestamp: at.Unix(),
+		Device:    "phone",
+	}, received)
+
+	// Info is sent quietly by default
+	require.NoError(t, pushover.Send(context.Background(), Startup("1.0.0", 1)))
+	assert.Equal(t, -1, received.Priority)
+
+	// Emergency priority repeats until acknowledged
+	require.NoError(t, pushover.Send(context.Background(), Event{Severity: SeverityError, Message: strings.Repeat("x", 2000)}))
+	assert.Equal(t, 2, received.Priority)
+	assert.Equal(t, pushoverRetry, received.Retry)
+	assert.Equal(t, pushoverExpire, received.Expire)
+	assert.Len(t, []rune(received.Message), pushoverMaxMessage)
+}
+
+func TestPushoverSendFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"user":"invalid","errors":["user identifier is invalid"],"status":0}`))
+	}))
+	defer srv.Close()
+
+	pushover := NewPushover(config.Pushover{Token: "app-token", User: "bad"}, srv.Client())
+	pushover.apiURL = srv.URL
+
+	err := pushover.Send(context.Background(), Startup("1.0.0", 1))
+	assert.ErrorContains(t, err, "user identifier is invalid")
+}
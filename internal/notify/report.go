@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/cron"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// maxTopErrors is the number of most failing paths listed in a report
+const maxTopErrors = 5
+
+// maxErrorPaths bounds the failing paths counted between reports
+const maxErrorPaths = 1000
+
+// Report sums up the runs of each folder since the previous report
+type Report struct {
+	Since     time.Time      `json:"since"`
+	Folders   []FolderReport `json:"folders"`
+	TopErrors []PathErrors   `json:"top_errors,omitempty"` // Paths that failed most often
+}
+
+// FolderReport is the total of the runs in a folder
+type FolderReport struct {
+	Folder        string `json:"folder"`
+	Runs          int    `json:"runs"`
+	Fixed         int    `json:"fixed"`
+	Skipped       int    `json:"skipped"`
+	Failed        int    `json:"failed"`
+	PreviousFixed int    `json:"previous_fixed"` // Fixed in the previous report, to show the drift trend
+}
+
+// PathErrors counts the failures of a path
+type PathErrors struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+	Error string `json:"error"` // The latest error
+}
+
+// Trend returns the change of fixed paths since the previous report, e.g.
+// "+4" or "-2"
+func (f FolderReport) Trend() string {
+	return fmt.Sprintf("%+d", f.Fixed-f.PreviousFixed)
+}
+
+// addRun adds a run to the folder totals of the next report
+func (d *Dispatcher) addRun(folder string, result status.RunResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	total := d.report[folder]
+	total.Folder = folder
+	total.Runs++
+	total.Fixed += result.Fixed
+	total.Skipped += result.Skipped
+	total.Failed += result.Failed
+	d.report[folder] = total
+}
+
+// addError counts a failed path for the next report
+func (d *Dispatcher) addError(path, err string) {
+	failures, ok := d.reportErrors[path]
+	if !ok && len(d.reportErrors) >= maxErrorPaths {
+		return
+	}
+	failures.Path = path
+	failures.Count++
+	failures.Error = err
+	d.reportErrors[path] = failures
+}
+
+// takeReport returns the report of the runs since the previous one and starts
+// the next report at now
+func (d *Dispatcher) takeReport(now time.Time) Event {
+	d.mu.Lock()
+	report := Report{Since: d.reportSince}
+	previous := make(map[string]int, len(d.report))
+	for _, total := range d.report {
+		total.PreviousFixed = d.previousFixed[total.Folder]
+		report.Folders = append(report.Folders, total)
+		previous[total.Folder] = total.Fixed
+	}
+	for _, failures := range d.reportErrors {
+		report.TopErrors = append(report.TopErrors, failures)
+	}
+	title := "Daily report"
+	if d.cfg.Notifications.ReportSchedule != "" {
+		title = "Scheduled report"
+	}
+	d.previousFixed = previous
+	d.report = make(map[string]FolderReport)
+	d.reportErrors = make(map[string]PathErrors)
+	d.reportSince = now
+	d.mu.Unlock()
+
+	slices.SortFunc(report.Folders, func(a, b FolderReport) int {
+		return strings.Compare(a.Folder, b.Folder)
+	})
+	slices.SortFunc(report.TopErrors, func(a, b PathErrors) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	if len(report.TopErrors) > maxTopErrors {
+		report.TopErrors = report.TopErrors[:maxTopErrors]
+	}
+
+	var fixed, failed int
+	for _, total := range report.Folders {
+		fixed += total.Fixed
+		failed += total.Failed
+	}
+	event := Event{
+		Type:     EventReport,
+		Severity: SeverityInfo,
+		Time:     now,
+		Title:    title,
+		Message: fmt.Sprintf("%d fixed and %d failed in %d folders since %s",
+			fixed, failed, len(report.Folders), report.Since.Format(time.DateTime)),
+		Report: &report,
+	}
+	if failed > 0 {
+		event.Severity = SeverityWarning
+	}
+	return event
+}
+
+// nextReport returns the first time after now that a report is due, at the
+// local time of day report_time or by the cron expression report_schedule.
+// It reports false if neither is set or valid.
+func nextReport(now time.Time, settings config.Notifications) (time.Time, bool) {
+	if settings.ReportSchedule != "" {
+		schedule, err := cron.Parse(settings.ReportSchedule)
+		if err != nil {
+			return time.Time{}, false
+		}
+		next := schedule.Next(now)
+		return next, !next.IsZero()
+	}
+
+	clock, err := time.Parse("15:04", settings.ReportTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, true
+}
+
+// schedule sends reports at report_time or by report_schedule until the
+// dispatcher is closed. A reload signals reschedule to pick up a changed
+// schedule.
+func (d *Dispatcher) schedule() {
+	for {
+		d.mu.Lock()
+		settings := d.cfg.Notifications
+		d.mu.Unlock()
+
+		var (
+			timer *time.Timer
+			fire  <-chan time.Time
+		)
+		if next, ok := nextReport(time.Now(), settings); ok {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+
+		select {
+		case now := <-fire:
+			d.Notify(d.takeReport(now))
+		case <-d.reschedule:
+		case <-d.ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if d.ctx.Err() != nil {
+			return
+		}
+	}
+}
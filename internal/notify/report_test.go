@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextReport(t *testing.T) {
+	now := time.Date(2024, 5, 14, 12, 30, 0, 0, time.UTC)
+
+	next, ok := nextReport(now, config.Notifications{ReportTime: "18:00"})
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 5, 14, 18, 0, 0, 0, time.UTC), next)
+
+	// A time that has passed today is sent tomorrow
+	next, ok = nextReport(now, config.Notifications{ReportTime: "12:30"})
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 5, 15, 12, 30, 0, 0, time.UTC), next)
+
+	// A Tuesday, so weekly reports on Monday are sent next week
+	next, ok = nextReport(now, config.Notifications{ReportSchedule: "0 8 * * mon"})
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 5, 20, 8, 0, 0, 0, time.UTC), next)
+
+	_, ok = nextReport(now, config.Notifications{})
+	assert.False(t, ok)
+}
+
+func TestDispatcherReport(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: "/data/tv"}, {Name: "movies", Path: "/data/movies"}}
+
+	notifier := &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "reports", events: []string{EventReport}, notifier: notifier})
+
+	d.ObserveRun("/data/tv", status.RunResult{Skipped: 10})
+	d.ObserveRun("/data/tv", status.RunResult{Fixed: 2, Skipped: 8})
+	d.ObserveRun("/data/movies", status.RunResult{Failed: 1})
+	for _, path := range []string{"/data/movies/a.mkv", "/data/movies/b.mkv", "/data/movies/a.mkv"} {
+		d.Record(activity.Entry{Type: activity.TypeError, Time: time.Now(), Path: path, Error: "permission denied"})
+	}
+
+	now := time.Now()
+	d.Notify(d.takeReport(now))
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, notifier.events, 1)
+	event := notifier.events[0]
+	assert.Equal(t, SeverityWarning, event.Severity)
+	assert.Contains(t, event.Message, "2 fixed and 1 failed in 2 folders")
+	require.NotNil(t, event.Report)
+	assert.Equal(t, []FolderReport{
+		{Folder: "movies", Runs: 1, Failed: 1},
+		{Folder: "tv", Runs: 2, Fixed: 2, Skipped: 18},
+	}, event.Report.Folders)
+	assert.Equal(t, []PathErrors{
+		{Path: "/data/movies/a.mkv", Count: 2, Error: "permission denied"},
+		{Path: "/data/movies/b.mkv", Count: 1, Error: "permission denied"},
+	}, event.Report.TopErrors)
+	assert.Contains(t, event.Text(), "tv: 2 runs, 2 fixed (+2), 0 failed")
+	assert.Contains(t, event.Text(), "/data/movies/a.mkv: 2 times, permission denied")
+
+	// The next report starts empty and compares with the previous one
+	d.addRun("tv", status.RunResult{Fixed: 1})
+	next := d.takeReport(now.Add(time.Hour))
+	assert.Equal(t, now, next.Report.Since)
+	assert.Equal(t, []FolderReport{{Folder: "tv", Runs: 1, Fixed: 1, PreviousFixed: 2}}, next.Report.Folders)
+	assert.Equal(t, "-1", next.Report.Folders[0].Trend())
+	assert.Empty(t, next.Report.TopErrors)
+	assert.Equal(t, SeverityInfo, next.Severity)
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// Header emoji by severity
+var slackEmoji = map[string]string{
+	SeverityInfo:    ":white_check_mark:",
+	SeverityWarning: ":warning:",
+	SeverityError:   ":rotating_light:",
+}
+
+// Slack posts events as Block Kit messages to a Slack incoming webhook
+type Slack struct {
+	url    string
+	client *http.Client
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"` // Shown in notifications
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// NewSlack creates a notifier for a Slack incoming webhook
+func NewSlack(cfg config.Slack, client *http.Client) *Slack {
+	return &Slack{
+		url:    cfg.URL,
+		client: client,
+	}
+}
+
+// Send posts the event with a header, the run counts as fields and the
+// failed paths of error alerts
+func (s *Slack) Send(ctx context.Context, event Event) error {
+	title := strings.TrimSpace(slackEmoji[event.Severity] + " " + event.Title)
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: title, Emoji: true}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: slackEscape(event.Message)}},
+	}
+
+	var fields []slackText
+	if event.Folder != "" {
+		fields = append(fields, slackField("Folder", event.Folder))
+	}
+	if event.Run != nil {
+		fields = append(fields,
+			slackField("Fixed", strconv.Itoa(event.Run.Fixed)),
+			slackField("Failed", strconv.Itoa(event.Run.Failed)),
+			slackField("Duration", (time.Duration(event.Run.DurationMS)*time.Millisecond).String()),
+		)
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	}
+
+	if len(event.Errors) > 0 {
+		lines := make([]string, len(event.Errors))
+		for i, failure := range event.Errors {
+			lines[i] = slackEscape(fmt.Sprintf("%s: %s", failure.Path, failure.Error))
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "```" + strings.Join(lines, "\n") + "```"}})
+	}
+
+	blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{
+		{Type: "mrkdwn", Text: fmt.Sprintf("ownarr · <!date^%d^{date_short_pretty} {time_secs}|%s>", event.Time.Unix(), event.Time.Format(time.RFC3339))},
+	}})
+
+	return postJSON(ctx, s.client, s.url, nil, slackMessage{Text: event.Title, Blocks: blocks})
+}
+
+// slackField formats a labelled value for a section's fields
+func slackField(name, value string) slackText {
+	return slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", name, slackEscape(value))}
+}
+
+// slackEscape escapes the characters that Slack treats as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackSend(t *testing.T) {
+	var received slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	slack := NewSlack(config.Slack{URL: srv.URL}, srv.Client())
+	require.NoError(t, slack.Send(context.Background(), Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Time:     time.Unix(1715688000, 0),
+		Title:    "Enforcement errors",
+		Message:  "10 errors within 1m0s",
+		Errors:   []Failure{{Path: "/data/<a>", Error: "permission denied"}},
+	}))
+
+	assert.Equal(t, "Enforcement errors", received.Text)
+	require.Len(t, received.Blocks, 4)
+	assert.Equal(t, ":rotating_light: Enforcement errors", received.Blocks[0].Text.Text)
+	assert.Equal(t, "```/data/&lt;a&gt;: permission denied```", received.Blocks[2].Text.Text)
+	assert.Contains(t, received.Blocks[3].Elements[0].Text, "<!date^1715688000^")
+}
+
+func TestSlackSendSummary(t *testing.T) {
+	var received slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	slack := NewSlack(config.Slack{URL: srv.URL}, srv.Client())
+	require.NoError(t, slack.Send(context.Background(), Event{
+		Type:     EventSummary,
+		Severity: SeverityInfo,
+		Title:    "Permissions fixed in tv",
+		Folder:   "tv",
+		Run:      &Run{Fixed: 3, DurationMS: 1200},
+	}))
+
+	require.Len(t, received.Blocks, 4)
+	assert.Equal(t, []slackText{
+		{Type: "mrkdwn", Text: "*Folder*\ntv"},
+		{Type: "mrkdwn", Text: "*Fixed*\n3"},
+		{Type: "mrkdwn", Text: "*Failed*\n0"},
+		{Type: "mrkdwn", Text: "*Duration*\n1.2s"},
+	}, received.Blocks[2].Fields)
+}
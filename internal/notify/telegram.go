@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// telegramAPI is the base URL of the Telegram Bot API
+const telegramAPI = "https://api.telegram.org"
+
+// telegramInterval spaces messages to stay within Telegram's limit of 20
+// messages per minute to a group
+const telegramInterval = 3 * time.Second
+
+// Telegram sends events as messages from a Telegram bot. Messages are
+// spaced out to respect Telegram's rate limits.
+type Telegram struct {
+	apiURL   string
+	token    string
+	chatID   string
+	client   *http.Client
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // Earliest time for the next message
+}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// NewTelegram creates a notifier for a Telegram chat
+func NewTelegram(cfg config.Telegram, client *http.Client) *Telegram {
+	return &Telegram{
+		apiURL:   telegramAPI,
+		token:    cfg.Token,
+		chatID:   cfg.ChatID,
+		client:   client,
+		interval: telegramInterval,
+	}
+}
+
+// Send sends the event as an HTML formatted message, waiting for its turn
+// if messages were sent recently
+func (t *Telegram) Send(ctx context.Context, event Event) error {
+	if err := t.wait(ctx); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(telegramMessage{
+		ChatID:                t.chatID,
+		Text:                  telegramText(event),
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	endpoint := t.apiURL + "/bot" + url.PathEscape(t.token) + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ownarr")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return requestError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	if result.OK {
+		return nil
+	}
+
+	// Hold back further messages for as long as Telegram asks
+	if resp.StatusCode == http.StatusTooManyRequests && result.Parameters.RetryAfter > 0 {
+		t.mu.Lock()
+		t.next = time.Now().Add(time.Duration(result.Parameters.RetryAfter) * time.Second)
+		t.mu.Unlock()
+	}
+	return fmt.Errorf("unexpected response %s: %s", resp.Status, result.Description)
+}
+
+// wait reserves the next message slot and sleeps until it arrives
+func (t *Telegram) wait(ctx context.Context) error {
+	t.mu.Lock()
+	at := time.Now()
+	if t.next.After(at) {
+		at = t.next
+	}
+	t.next = at.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("rate limited: %w", ctx.Err())
+	}
+}
+
+// telegramText formats an event as a Telegram HTML message
+func telegramText(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b>\n%s", html.EscapeString(event.Title), html.EscapeString(event.Message))
+
+	if event.Folder != "" {
+		fmt.Fprintf(&b, "\n\nFolder: %s", html.EscapeString(event.Folder))
+	}
+	if event.Run != nil {
+		fmt.Fprintf(&b, "\nFixed: %d\nFailed: %d\nDuration: %s",
+			event.Run.Fixed, event.Run.Failed, time.Duration(event.Run.DurationMS)*time.Millisecond)
+	}
+	if len(event.Errors) > 0 {
+		b.WriteString("\n\n<pre>")
+		for i, failure := range event.Errors {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(html.EscapeString(failure.Path + ": " + failure.Error))
+		}
+		b.WriteString("</pre>")
+	}
+	return b.String()
+}
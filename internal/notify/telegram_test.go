@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTelegram creates a Telegram notifier sending to srv
+func newTestTelegram(srv *httptest.Server) *Telegram {
+	telegram := NewTelegram(config.Telegram{Token: "123:abc", ChatID: "-100"}, srv.Client())
+	telegram.apiURL = srv.URL
+	return telegram
+}
+
+func TestTelegramSend(t *testing.T) {
+	var (
+		path     string
+		received telegramMessage
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	require.NoError(t, newTestTelegram(srv).Send(context.Background(), Event{
+		Type:     EventErrors,
+		Severity: SeverityError,
+		Title:    "Enforcement errors",
+		Message:  "10 errors within 1m0s",
+		Errors:   []Failure{{Path: "/data/a&b", Error: "permission denied"}},
+	}))
+
+	assert.Equal(t, "/bot123:abc/sendMessage", path)
+	assert.Equal(t, "-100", received.ChatID)
+	assert.Equal(t, "HTML", received.ParseMode)
+	assert.Equal(t, "<b>Enforcement errors</b>\n10 errors within 1m0s\n\n<pre>/data/a&amp;b: permission denied</pre>", received.Text)
+}
+
+func TestTelegramRateLimit(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"ok": false, "description": "Too Many Requests: retry after 30", "parameters": {"retry_after": 30}}`))
+	}))
+	defer srv.Close()
+
+	telegram := newTestTelegram(srv)
+	telegram.interval = 0
+
+	err := telegram.Send(context.Background(), Startup("1.0.0", 1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Too Many Requests")
+
+	// The next message waits for retry_after rather than hitting the API
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, telegram.Send(ctx, Startup("1.0.0", 1)), context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTelegramSpacesMessages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	telegram := newTestTelegram(srv)
+	telegram.interval = 50 * time.Millisecond
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, telegram.Send(context.Background(), Startup("1.0.0", 1)))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// eventTemplate replaces the title and message of events. Nil templates keep
+// the default.
+type eventTemplate struct {
+	title   *template.Template
+	message *template.Template
+}
+
+// parseTemplates parses the custom templates by event type
+func parseTemplates(cfg config.Notifications) (map[string]eventTemplate, error) {
+	templates := make(map[string]eventTemplate, len(cfg.Templates))
+	for event, tmpl := range cfg.Templates {
+		var (
+			parsed eventTemplate
+			err    error
+		)
+		if tmpl.Title != "" {
+			if parsed.title, err = template.New(event + ".title").Parse(tmpl.Title); err != nil {
+				return nil, fmt.Errorf("invalid %s title template: %w", event, err)
+			}
+		}
+		if tmpl.Message != "" {
+			if parsed.message, err = template.New(event + ".message").Parse(tmpl.Message); err != nil {
+				return nil, fmt.Errorf("invalid %s message template: %w", event, err)
+			}
+		}
+		templates[event] = parsed
+	}
+	return templates, nil
+}
+
+// apply renders the templates with the event, whose default title and
+// message remain available to them
+func (t eventTemplate) apply(event Event) (Event, error) {
+	rendered := event
+	if t.title != nil {
+		var b strings.Builder
+		if err := t.title.Execute(&b, event); err != nil {
+			return event, err
+		}
+		rendered.Title = b.String()
+	}
+	if t.message != nil {
+		var b strings.Builder
+		if err := t.message.Execute(&b, event); err != nil {
+			return event, err
+		}
+		rendered.Message = b.String()
+	}
+	return rendered, nil
+}
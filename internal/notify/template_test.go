@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTemplateApply(t *testing.T) {
+	templates, err := parseTemplates(config.Notifications{Templates: map[string]config.Template{
+		EventSummary: {
+			Title:   "[ownarr] {{.Folder}}",
+			Message: "{{.Run.Fixed}} fixed{{if .Run.Failed}}, {{.Run.Failed}} FAILED{{end}} ({{.Message}})",
+		},
+		EventStartup: {Message: "up since {{.Time.Format \"15:04\"}}"},
+	}})
+	require.NoError(t, err)
+
+	event := Event{Type: EventSummary, Title: "Permission errors in tv", Message: "2 fixed, 0 unchanged, 1 failed in 1s", Folder: "tv", Run: &Run{Fixed: 2, Failed: 1}}
+	rendered, err := templates[EventSummary].apply(event)
+	require.NoError(t, err)
+	assert.Equal(t, "[ownarr] tv", rendered.Title)
+	assert.Equal(t, "2 fixed, 1 FAILED (2 fixed, 0 unchanged, 1 failed in 1s)", rendered.Message)
+
+	// A template without a title keeps the default one
+	startup := Startup("1.0.0", 1)
+	startup.Time = time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	rendered, err = templates[EventStartup].apply(startup)
+	require.NoError(t, err)
+	assert.Equal(t, "ownarr started", rendered.Title)
+	assert.Equal(t, "up since 12:00", rendered.Message)
+
+	// Events that fail to render are sent unchanged
+	_, err = templates[EventSummary].apply(Event{Type: EventSummary, Title: "t", Message: "m"})
+	assert.Error(t, err)
+}
+
+func TestDispatcherTemplates(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: "/data/tv"}}
+	cfg.Notifications.Templates = map[string]config.Template{
+		EventSummary: {Title: "{{.Folder}}: {{.Run.Fixed}} fixed"},
+		EventErrors:  {Title: "{{.Run.Fixed}}"},
+	}
+
+	notifier := &fakeNotifier{}
+	d := newTestDispatcher(cfg, target{name: "fake", notifier: notifier})
+
+	d.ObserveRun("/data/tv", status.RunResult{Fixed: 3})
+	d.Notify(Event{Type: EventErrors, Title: "Enforcement errors"})
+	require.NoError(t, d.Close(context.Background()))
+
+	require.Len(t, notifier.events, 2)
+	assert.Equal(t, "tv: 3 fixed", notifier.events[0].Title)
+	assert.Equal(t, "Enforcement errors", notifier.events[1].Title, "the default is sent when rendering fails")
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// maxErrorBody is how much of an error response is included in the error
+const maxErrorBody = 512
+
+// Webhook posts events as JSON to an HTTP endpoint
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhook creates a notifier for a generic webhook
+func NewWebhook(cfg config.Webhook, client *http.Client) *Webhook {
+	return &Webhook{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  client,
+	}
+}
+
+// Send posts the event as the request body
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, w.client, w.url, w.headers, event)
+}
+
+// postJSON posts v encoded as JSON and checks for a successful response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return do(client, req)
+}
+
+// do sends a request, failing on a non-2xx response
+func do(client *http.Client, req *http.Request) error {
+	req.Header.Set("User-Agent", "ownarr")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return requestError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// requestError describes a failed request without its URL, which often
+// contains a token
+func requestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Errorf("failed to send request: %w", err)
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var (
+		received Event
+		header   http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhook(config.Webhook{URL: srv.URL, Headers: map[string]string{"X-Token": "abc"}}, srv.Client())
+	require.NoError(t, webhook.Send(context.Background(), Startup("1.0.0", 2)))
+
+	assert.Equal(t, EventStartup, received.Type)
+	assert.Equal(t, "Version 1.0.0 watching 2 folders", received.Message)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "abc", header.Get("X-Token"))
+}
+
+func TestWebhookSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhook(config.Webhook{URL: srv.URL}, srv.Client())
+	err := webhook.Send(context.Background(), Startup("1.0.0", 2))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized: bad token")
+}
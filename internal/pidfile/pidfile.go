@@ -0,0 +1,67 @@
+// Package pidfile maintains the PID file of a running instance for init
+// scripts, replacing files left behind by instances that did not exit cleanly
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Write creates the PID file at path with the PID of the current process. It
+// fails if the file names another running process, and replaces it if that
+// process is gone.
+func Write(path string) error {
+	// A second attempt follows removing a stale file
+	for range 2 {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(path)
+				return fmt.Errorf("failed to write PID file: %w", err)
+			}
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to create PID file: %w", err)
+		}
+
+		pid, err := Read(path)
+		if err == nil && pid != os.Getpid() && running(pid) {
+			return fmt.Errorf("already running as process %d according to %s", pid, path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale PID file: %w", err)
+		}
+	}
+	return fmt.Errorf("failed to create PID file: %s was recreated concurrently", path)
+}
+
+// Read returns the PID stored in the file at path
+func Read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID file %s", path)
+	}
+	return pid, nil
+}
+
+// Remove deletes the PID file at path if it still holds the PID of the
+// current process
+func Remove(path string) error {
+	pid, err := Read(path)
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,73 @@
+package pidfile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exitedPID returns the PID of a process that has exited
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ownarr.pid")
+
+	require.NoError(t, Write(path))
+	pid, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+
+	require.NoError(t, Remove(path))
+	assert.NoFileExists(t, path)
+}
+
+func TestWriteRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ownarr.pid")
+
+	// The parent of the test process is still running
+	require.NoError(t, os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0o644))
+
+	err := Write(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already running")
+
+	// The file of the other instance is kept
+	require.NoError(t, Remove(path))
+	pid, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getppid(), pid)
+}
+
+func TestWriteStale(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "exited process", content: strconv.Itoa(exitedPID(t)) + "\n"},
+		{name: "own pid", content: strconv.Itoa(os.Getpid())},
+		{name: "invalid", content: "garbage"},
+		{name: "empty", content: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ownarr.pid")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			require.NoError(t, Write(path))
+			pid, err := Read(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.Getpid(), pid)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package pidfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// running reports whether a process with the given PID exists. A process of
+// another user cannot be signalled but still exists.
+func running(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package pidfile
+
+import "os"
+
+// running reports whether a process with the given PID exists, which on
+// Windows is whether it can be opened
+func running(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/recovery"
+	"github.com/keksiqc/ownarr/internal/watcher"
+)
+
+// queueSize is the number of events a folder's queue holds before further
+// events of the folder are dropped
+const queueSize = 1000
+
+// pipeline queues the events of one watch directory for a worker of its own,
+// so that a flood of events in one folder does not delay the others
+type pipeline struct {
+	ready   chan struct{} // Signals the worker that events were queued
+	retired chan struct{} // Closed once the watch directory is removed
+
+	mu       sync.Mutex
+	folder   string // Name of the watch directory of the last queued event, labelling the queue length
+	events   []watcher.Event
+	overflow bool // Events were dropped since the queue last drained
+}
+
+// newPipeline returns an empty pipeline for a watch directory
+func newPipeline() *pipeline {
+	return &pipeline{ready: make(chan struct{}, 1), retired: make(chan struct{})}
+}
+
+// push queues an event, returning false if the queue is full. Scans and the
+// ends of poll runs stand for many events, so they are queued regardless.
+// The first event dropped since the queue last drained reports overflow.
+func (q *pipeline) push(event watcher.Event) (queued, overflow bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// A renamed folder keeps its pipeline, so its series moves to the new name
+	if name := event.WatchDir.Name; name != q.folder {
+		if q.folder != "" {
+			metrics.EventQueue.Delete(q.folder)
+		}
+		q.folder = name
+	}
+
+	if len(q.events) >= queueSize && !standsForMany(event.Operation) {
+		overflow = !q.overflow
+		q.overflow = true
+		return false, overflow
+	}
+
+	q.events = append(q.events, event)
+	metrics.EventQueue.Set(float64(len(q.events)), q.folder)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true, false
+}
+
+// standsForMany reports whether an operation covers many paths: scans after
+// bursts and overflows, the ends of poll runs and polls of remote folders
+func standsForMany(operation string) bool {
+	return operation == "SCAN" || operation == "CATCH_UP" || operation == "POLL_COMPLETE" || operation == "POLL_REMOTE"
+}
+
+// pop takes the next queued event, returning false if there is none
+func (q *pipeline) pop() (watcher.Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) == 0 {
+		q.overflow = false
+		return watcher.Event{}, false
+	}
+
+	event := q.events[0]
+	q.events[0] = watcher.Event{}
+	q.events = q.events[1:]
+	if len(q.events) == 0 {
+		// Let the backing array of a flood go
+		q.events = nil
+	}
+	metrics.EventQueue.Set(float64(len(q.events)), q.folder)
+	return event, true
+}
+
+// name returns the folder name labelling the queue length
+func (q *pipeline) name() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.folder
+}
+
+// retire lets the worker return once the queue is empty. No events may be
+// queued afterwards.
+func (q *pipeline) retire() {
+	close(q.retired)
+}
+
+// release marks the events still queued as done without handling them,
+// returning their number
+func (q *pipeline) release() int {
+	count := 0
+	for {
+		event, ok := q.pop()
+		if !ok {
+			return count
+		}
+		event.Done()
+		count++
+	}
+}
+
+// dispatch queues an event in the pipeline of its watch directory, starting
+// the pipeline's worker on the folder's first event. Events the worker
+// abandons are added to abandoned. The pipeline of a folder that is no
+// longer configured is retired once the event is queued, so events sent
+// before a reload are still handled.
+func (p *Processor) dispatch(ctx context.Context, event watcher.Event, pipelines map[string]*pipeline, closed <-chan struct{}, wg *sync.WaitGroup, abandoned *atomic.Int64) {
+	path := event.WatchDir.Path
+	q, ok := pipelines[path]
+	if !ok {
+		q = newPipeline()
+		pipelines[path] = q
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			abandoned.Add(int64(p.work(ctx, q, closed)))
+			metrics.EventQueue.Delete(q.name())
+		}()
+	}
+
+	queued, overflow := q.push(event)
+	if !p.watched(path) {
+		q.retire()
+		delete(pipelines, path)
+	}
+	if queued {
+		return
+	}
+	// Poll checks release their path, so the next poll covers it again
+	event.Done()
+	folder := event.WatchDir.Name
+	metrics.EventsDropped.Inc(folder)
+	if overflow {
+		p.logger.Warn("Event queue of folder full, dropping events until it drains", "folder", folder, "size", queueSize)
+	} else {
+		p.logger.Debug("Event queue of folder full, dropping event", "folder", folder, "path", escape.Text(event.Path))
+	}
+}
+
+// prune retires the pipelines of folders that are no longer configured
+func (p *Processor) prune(pipelines map[string]*pipeline) {
+	for path, q := range pipelines {
+		if !p.watched(path) {
+			q.retire()
+			delete(pipelines, path)
+		}
+	}
+}
+
+// handle handles an event, recovering a panic so that the folder's worker
+// goes on with the next event
+func (p *Processor) handle(ctx context.Context, event watcher.Event) {
+	recovery.Run(p.logger, "processor", func() { p.handleEvent(ctx, event) })
+}
+
+// work handles the events of a pipeline in order until ctx is done, or until
+// the queue is empty once closed is closed or the pipeline retired. It
+// returns the number of events left unhandled.
+func (p *Processor) work(ctx context.Context, q *pipeline, closed <-chan struct{}) int {
+	for {
+		if ctx.Err() != nil {
+			return q.release()
+		}
+		if event, ok := q.pop(); ok {
+			p.handle(ctx, event)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return q.release()
+		case <-q.ready:
+			continue
+		case <-closed:
+		case <-q.retired:
+		}
+
+		// Events are only queued before closed is closed or the pipeline
+		// retired
+		if event, ok := q.pop(); ok {
+			p.handle(ctx, event)
+			continue
+		}
+		return 0
+	}
+}
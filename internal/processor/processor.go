@@ -2,192 +2,457 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"os"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/recovery"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/tracing"
 	"github.com/keksiqc/ownarr/internal/watcher"
 )
 
 // Processor handles file system events
 type Processor struct {
-	logger *log.Logger
+	logger   *log.Logger
+	enforcer *enforcer.Enforcer
+	tracker  *status.Tracker
+
+	mu       sync.Mutex                      // Guards folders, runs and progress, shared by the folders' workers
+	folders  map[string]bool                 // Paths of the configured watch directories, nil until Retain is called
+	runs     map[string]*status.RunResult    // In-progress poll runs by watch directory
+	progress map[string]*status.ScanProgress // Progress of the poll runs
+	retained chan struct{}                   // Signals Process that folders changed
 }
 
 // New creates a new event processor
-func New(logger *log.Logger) *Processor {
+func New(enf *enforcer.Enforcer, tracker *status.Tracker, logger *log.Logger) *Processor {
 	return &Processor{
-		logger: logger,
+		logger:   logger,
+		enforcer: enf,
+		tracker:  tracker,
+		runs:     make(map[string]*status.RunResult),
+		progress: make(map[string]*status.ScanProgress),
+		retained: make(chan struct{}, 1),
+	}
+}
+
+// Retain stops the workers of folders that none of watchDirs is any longer,
+// once they have handled their queued events
+func (p *Processor) Retain(watchDirs []config.WatchDir) {
+	folders := make(map[string]bool, len(watchDirs))
+	for _, watchDir := range watchDirs {
+		folders[watchDir.Path] = true
+	}
+
+	p.mu.Lock()
+	p.folders = folders
+	p.mu.Unlock()
+
+	select {
+	case p.retained <- struct{}{}:
+	default:
 	}
 }
 
-// Process processes file system events
-func (p *Processor) Process(ctx context.Context, events <-chan watcher.Event, errors <-chan error) {
+// watched reports whether path is the path of a configured watch directory
+func (p *Processor) watched(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.folders == nil || p.folders[path]
+}
+
+// Process processes file system events. Each watch directory's events are
+// handled in order by a worker of its own, so folders do not wait for each
+// other. Once events is closed, Process returns after the queued events are
+// handled. Once ctx is done, it returns as soon as the events being handled
+// are, abandoning the rest. It returns the number of events abandoned.
+func (p *Processor) Process(ctx context.Context, events <-chan watcher.Event, errs <-chan error) int {
+	pipelines := make(map[string]*pipeline)
+	closed := make(chan struct{})
+	var (
+		workers   sync.WaitGroup
+		abandoned atomic.Int64
+	)
+	wait := func() int {
+		close(closed)
+		workers.Wait()
+		return int(abandoned.Load())
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return wait() + release(events)
 
 		case event, ok := <-events:
 			if !ok {
-				return
+				return wait()
 			}
-			p.handleEvent(event)
+			p.dispatch(ctx, event, pipelines, closed, &workers, &abandoned)
+
+		case <-p.retained:
+			p.prune(pipelines)
 
-		case err, ok := <-errors:
+		case err, ok := <-errs:
+			if !ok {
+				// The events sent before the errors closed are still due
+				errs = nil
+				continue
+			}
+			recovery.Run(p.logger, "processor", func() { p.handleError(err) })
+		}
+	}
+}
+
+// release marks the events waiting in events as done without handling
+// them, returning their number
+func release(events <-chan watcher.Event) int {
+	count := 0
+	for {
+		select {
+		case event, ok := <-events:
 			if !ok {
-				return
+				return count
 			}
-			p.logger.Error("Watcher error", "error", err)
+			event.Done()
+			count++
+		default:
+			return count
 		}
 	}
 }
 
+// handleError reports an error of the watcher, raising the degraded
+// condition of its kind along with what is done about it
+func (p *Processor) handleError(err error) {
+	kind, hint := watcher.ErrorWatcher, ""
+	var watchErr *watcher.Error
+	if errors.As(err, &watchErr) {
+		kind, hint = watchErr.Kind, watchErr.Hint()
+	}
+
+	reason := err.Error()
+	if hint != "" {
+		reason += "; " + hint
+		p.logger.Error("Watcher error", "kind", kind, "error", err, "hint", hint)
+	} else {
+		p.logger.Error("Watcher error", "kind", kind, "error", err)
+	}
+	p.tracker.SetDegraded(kind, reason)
+	metrics.WatcherErrors.Inc(kind)
+}
+
 // handleEvent processes a single file system event
-func (p *Processor) handleEvent(event watcher.Event) {
+func (p *Processor) handleEvent(ctx context.Context, event watcher.Event) {
+	defer event.Done()
+
+	// Poll checks are traced as a whole by the scan span
+	if !isPollOperation(event.Operation) {
+		var span *tracing.Span
+		ctx, span = tracing.StartAt(ctx, "event.handle", event.Timestamp,
+			tracing.String("folder", event.WatchDir.Name),
+			tracing.String("path", escape.Text(event.Path)),
+			tracing.String("operation", event.Operation),
+		)
+		defer span.Finish()
+	}
+
 	p.logger.Info("Processing file event",
-		"path", event.Path,
+		"path", escape.Text(event.Path),
 		"operation", event.Operation,
 		"timestamp", event.Timestamp.Format(time.RFC3339),
 	)
 
 	switch event.Operation {
 	case "CREATE":
-		p.handleCreate(event)
+		p.handleCreate(ctx, event)
 	case "WRITE":
 		p.handleWrite(event)
 	case "REMOVE":
 		p.handleRemove(event)
 	case "RENAME":
-		p.handleRename(event)
+		p.handleRename(ctx, event)
 	case "CHMOD":
 		p.handleChmod(event)
+	case "SCAN":
+		p.handleScan(ctx, event)
+	case "CATCH_UP":
+		p.handleCatchUp(ctx, event)
 	case "POLL_CHECK":
-		p.handlePollCheck(event)
+		p.recordPoll(event, p.handlePollCheck(event))
 	case "POLL_CHECK_DIR":
-		p.handlePollCheckDir(event)
+		p.recordPoll(event, p.handlePollCheckDir(event))
+	case "POLL_COMPLETE":
+		p.handlePollComplete(ctx, event)
+	case "POLL_REMOTE":
+		p.handlePollRemote(ctx, event)
 	default:
-		p.logger.Warn("Unknown operation", "operation", event.Operation, "path", event.Path)
+		p.logger.Warn("Unknown operation", "operation", event.Operation, "path", escape.Text(event.Path))
+		return
+	}
+
+	if !isPollOperation(event.Operation) {
+		p.tracker.RecordEvent(event.WatchDir.Path, event.Timestamp)
+		metrics.ObserveEvent(event.WatchDir.Name, event.Operation, event.Timestamp)
 	}
 }
 
 // handleCreate handles file/directory creation events
-func (p *Processor) handleCreate(event watcher.Event) {
-	stat, err := os.Stat(event.Path)
+func (p *Processor) handleCreate(ctx context.Context, event watcher.Event) {
+	stat, err := os.Lstat(event.Path)
 	if err != nil {
-		p.logger.Error("Failed to stat created file", "path", event.Path, "error", err)
+		p.logger.Error("Failed to stat created file", "path", escape.Text(event.Path), "error", err)
 		return
 	}
 
 	if stat.IsDir() {
-		p.logger.Info("Directory created", "path", event.Path)
-		p.fixPermissions(event.Path, event.WatchDir.DirMode, true)
+		p.logger.Info("Directory created", "path", escape.Text(event.Path))
+		p.fixTree(ctx, event.Path, event.WatchDir)
 	} else {
-		p.logger.Info("File created", "path", event.Path, "size", stat.Size())
-		p.fixPermissions(event.Path, event.WatchDir.FileMode, false)
+		p.logger.Info("File created", "path", escape.Text(event.Path), "size", stat.Size())
+		p.fixPermissions(event.Path, event.WatchDir, stat)
 	}
 }
 
-// handleWrite handles file modification events
+// handleWrite handles file modification events. Writes leave the mode and
+// owner alone, so files recently seen with the correct ones are not read
+// again, sparing a stat per write during long downloads, unless a
+// permission change was merged into the event.
 func (p *Processor) handleWrite(event watcher.Event) {
-	stat, err := os.Stat(event.Path)
+	if event.Chmodded {
+		p.enforcer.Forget(event.Path)
+	} else if p.enforcer.Verified(event.WatchDir, event.Path) {
+		p.logger.Debug("File modified, permissions recently verified", "path", escape.Text(event.Path))
+		return
+	}
+
+	stat, err := os.Lstat(event.Path)
 	if err != nil {
-		p.logger.Error("Failed to stat modified file", "path", event.Path, "error", err)
+		p.logger.Error("Failed to stat modified file", "path", escape.Text(event.Path), "error", err)
 		return
 	}
 
-	p.logger.Info("File modified", "path", event.Path, "size", stat.Size())
-	p.fixPermissions(event.Path, event.WatchDir.FileMode, false)
+	p.logger.Info("File modified", "path", escape.Text(event.Path), "size", stat.Size())
+	p.fixPermissions(event.Path, event.WatchDir, stat)
 }
 
 // handleRemove handles file/directory removal events
 func (p *Processor) handleRemove(event watcher.Event) {
-	p.logger.Info("File or directory removed", "path", event.Path)
+	p.enforcer.Forget(event.Path)
+	p.logger.Info("File or directory removed", "path", escape.Text(event.Path))
 }
 
 // handleRename handles file/directory rename events
-func (p *Processor) handleRename(event watcher.Event) {
-	p.logger.Info("File or directory renamed", "path", event.Path)
+func (p *Processor) handleRename(ctx context.Context, event watcher.Event) {
+	p.enforcer.Forget(event.Path)
+	stat, err := os.Lstat(event.Path)
+	if err != nil {
+		// The old name is gone; the new name arrives as its own CREATE event
+		p.logger.Info("File or directory renamed", "path", escape.Text(event.Path))
+		return
+	}
+
+	// The path was replaced in place (e.g. rsync renaming its temp file over
+	// the final name), so enforce what is there now
+	p.logger.Info("File or directory renamed into place", "path", escape.Text(event.Path))
+	if stat.IsDir() {
+		p.fixTree(ctx, event.Path, event.WatchDir)
+	} else {
+		p.fixPermissions(event.Path, event.WatchDir, stat)
+	}
 }
 
 // handleChmod handles permission change events
 func (p *Processor) handleChmod(event watcher.Event) {
-	p.logger.Debug("File permissions changed", "path", event.Path)
+	p.enforcer.Forget(event.Path)
+	p.logger.Debug("File permissions changed", "path", escape.Text(event.Path))
+}
+
+// handleScan handles a burst of events in a directory, which the watcher
+// replaced by a single scan of the directory once the burst settled
+func (p *Processor) handleScan(ctx context.Context, event watcher.Event) {
+	if _, err := os.Stat(event.Path); err != nil {
+		p.logger.Debug("Directory gone before its scan", "path", escape.Text(event.Path), "error", err)
+		return
+	}
+
+	p.logger.Info("Scanning directory after a burst of events", "path", escape.Text(event.Path))
+	metrics.EventBursts.Inc(event.WatchDir.Name)
+	p.fixTree(ctx, event.Path, event.WatchDir)
+}
+
+// handleCatchUp enforces a whole folder after the system dropped events
+func (p *Processor) handleCatchUp(ctx context.Context, event watcher.Event) {
+	if _, err := os.Stat(event.Path); err != nil {
+		p.logger.Debug("Folder gone before its catch-up scan", "path", escape.Text(event.Path), "error", err)
+		return
+	}
+
+	p.logger.Info("Scanning folder to catch up on dropped events", "folder", event.WatchDir.Name, "path", escape.Text(event.Path))
+	p.fixTree(ctx, event.Path, event.WatchDir)
 }
 
 // handlePollCheck handles periodic permission checks for files
-func (p *Processor) handlePollCheck(event watcher.Event) {
-	stat, err := os.Stat(event.Path)
+func (p *Processor) handlePollCheck(event watcher.Event) enforcer.Outcome {
+	stat, err := os.Lstat(event.Path)
 	if err != nil {
 		// File might have been deleted between poll generation and processing
-		p.logger.Debug("Failed to stat file during polling", "path", event.Path, "error", err)
-		return
+		p.logger.Debug("Failed to stat file during polling", "path", escape.Text(event.Path), "error", err)
+		return enforcer.Skipped
 	}
 
-	if !stat.IsDir() {
-		p.logger.Debug("Polling check: file", "path", event.Path, "size", stat.Size())
-		p.fixPermissions(event.Path, event.WatchDir.FileMode, false)
+	if stat.IsDir() {
+		return enforcer.Skipped
 	}
+
+	p.logger.Debug("Polling check: file", "path", escape.Text(event.Path), "size", stat.Size())
+	return p.fixPermissions(event.Path, event.WatchDir, stat)
 }
 
 // handlePollCheckDir handles periodic permission checks for directories
-func (p *Processor) handlePollCheckDir(event watcher.Event) {
-	stat, err := os.Stat(event.Path)
+func (p *Processor) handlePollCheckDir(event watcher.Event) enforcer.Outcome {
+	stat, err := os.Lstat(event.Path)
 	if err != nil {
-		p.logger.Debug("Failed to stat directory during polling", "path", event.Path, "error", err)
-		return
+		p.logger.Debug("Failed to stat directory during polling", "path", escape.Text(event.Path), "error", err)
+		return enforcer.Skipped
 	}
 
-	if stat.IsDir() {
-		p.logger.Debug("Polling check: directory", "path", event.Path)
-		p.fixPermissions(event.Path, event.WatchDir.DirMode, true)
+	if !stat.IsDir() {
+		return enforcer.Skipped
 	}
+
+	p.logger.Debug("Polling check: directory", "path", escape.Text(event.Path))
+	return p.fixPermissions(event.Path, event.WatchDir, stat)
 }
 
-// fixPermissions sets the correct permissions on a file or directory
-func (p *Processor) fixPermissions(path string, modeStr string, isDir bool) {
-	// Validate mode string is not empty
-	if modeStr == "" {
-		p.logger.Warn("Empty mode string provided", "path", path)
-		return
+// handlePollComplete finishes the poll run for a watch directory and records its result
+func (p *Processor) handlePollComplete(ctx context.Context, event watcher.Event) {
+	folder := event.WatchDir.Path
+
+	p.mu.Lock()
+	run, ok := p.runs[folder]
+	if !ok {
+		run = &status.RunResult{}
 	}
+	progress, tracked := p.progress[folder]
+	delete(p.runs, folder)
+	delete(p.progress, folder)
+	p.mu.Unlock()
 
-	// Parse the mode string (e.g., "0644" -> 0644)
-	mode, err := strconv.ParseUint(modeStr, 8, 32)
-	if err != nil {
-		p.logger.Error("Invalid file mode format", "mode", modeStr, "path", path, "error", err)
+	run.Started = event.Timestamp
+	run.Duration = time.Since(event.Timestamp)
+	run.Failed += event.Failed
+	if tracked {
+		progress.Finish(*run)
+	}
+	p.recordRun(ctx, event.WatchDir, *run)
+}
+
+// handlePollRemote polls a folder on a remote host, walking it through the
+// enforcer in one go since the watcher cannot reach it. A poll cut short by
+// ctx is not recorded as a run.
+func (p *Processor) handlePollRemote(ctx context.Context, event watcher.Event) {
+	run := p.enforcer.Tree(ctx, event.Path, event.WatchDir)
+	if ctx.Err() != nil {
 		return
 	}
+	p.recordRun(ctx, event.WatchDir, run)
+}
 
-	fileMode := os.FileMode(mode)
+// recordRun records the result of a poll run of a folder
+func (p *Processor) recordRun(ctx context.Context, watchDir config.WatchDir, run status.RunResult) {
+	p.tracker.RecordRun(watchDir.Path, run)
+	metrics.ObserveScan(watchDir.Name, metrics.TriggerPoll, run)
 
-	// Get current permissions
-	stat, err := os.Stat(path)
-	if err != nil {
-		p.logger.Error("Failed to stat file for permission fix", "path", path, "error", err)
-		return
+	_, span := tracing.StartAt(ctx, "scan.poll", run.Started,
+		tracing.String("folder", watchDir.Name),
+		tracing.Int("paths.fixed", run.Fixed),
+		tracing.Int("paths.skipped", run.Skipped),
+		tracing.Int("paths.failed", run.Failed),
+	)
+	span.Finish()
+
+	logFn := p.logger.Debug
+	if run.Fixed > 0 || run.Failed > 0 {
+		logFn = p.logger.Info
 	}
+	logFn("Periodic check complete",
+		"path", escape.Text(watchDir.Path),
+		"fixed", run.Fixed,
+		"skipped", run.Skipped,
+		"failed", run.Failed,
+		"duration", run.Duration,
+	)
+}
 
-	currentMode := stat.Mode().Perm()
+// recordPoll counts the outcome of a poll check towards the folder's current run
+func (p *Processor) recordPoll(event watcher.Event, result enforcer.Outcome) {
+	folder := event.WatchDir.Path
 
-	// Only change permissions if they're different
-	if currentMode != fileMode {
-		if err := os.Chmod(path, fileMode); err != nil {
-			p.logger.Error("Failed to fix permissions", "path", path, "mode", modeStr, "error", err)
-			return
-		}
+	p.mu.Lock()
+	run, ok := p.runs[folder]
+	if !ok {
+		run = &status.RunResult{}
+		p.runs[folder] = run
+	}
+	p.mu.Unlock()
 
-		entityType := "file"
-		if isDir {
-			entityType = "directory"
+	result.AddTo(run)
+	p.pollProgress(event.WatchDir).Update(*run)
+}
+
+// pollProgress returns the progress of the folder's current poll run,
+// starting to follow it if needed. The previous run's size is the expected
+// total.
+func (p *Processor) pollProgress(watchDir config.WatchDir) *status.ScanProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress, ok := p.progress[watchDir.Path]
+	if !ok {
+		progress = status.NewScanProgress(watchDir.Path, watchDir.Path, p.logger, []status.ScanObserver{p.tracker})
+		if last := p.tracker.Snapshot().Folders[watchDir.Path].LastRun; last != nil {
+			progress.SetTotal(last.Fixed + last.Skipped + last.Failed)
 		}
+		p.progress[watchDir.Path] = progress
+	}
+	return progress
+}
 
-		p.logger.Info("Fixed permissions",
-			"path", path,
-			"type", entityType,
-			"old_mode", currentMode,
-			"new_mode", fileMode,
-		)
+// fixTree sets the correct permissions on a directory and everything below it.
+// Directories moved into a watched tree arrive as a single CREATE event, so
+// their contents would otherwise wait for the next poll.
+func (p *Processor) fixTree(ctx context.Context, root string, watchDir config.WatchDir) {
+	result := p.enforcer.Tree(ctx, root, watchDir)
+	p.logger.Debug("Fixed directory tree",
+		"path", escape.Text(root),
+		"fixed", result.Fixed,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+	)
+}
+
+// fixPermissions sets the permissions configured for the watch directory on
+// a file or directory, given the result of its os.Lstat
+func (p *Processor) fixPermissions(path string, watchDir config.WatchDir, stat os.FileInfo) enforcer.Outcome {
+	return p.enforcer.FixInfo(watchDir, path, stat).Outcome
+}
+
+// isPollOperation reports whether an operation was generated by periodic polling
+func isPollOperation(operation string) bool {
+	switch operation {
+	case "POLL_CHECK", "POLL_CHECK_DIR", "POLL_COMPLETE", "POLL_REMOTE":
+		return true
+	default:
+		return false
 	}
 }
@@ -2,14 +2,25 @@ package processor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
 	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/status"
 	"github.com/keksiqc/ownarr/internal/watcher"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcessor(t *testing.T) {
@@ -17,7 +28,7 @@ func TestProcessor(t *testing.T) {
 	logger := log.New(os.Stderr)
 	logger.SetLevel(log.ErrorLevel) // Minimize test output
 
-	processor := New(logger)
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
 	assert.NotNil(t, processor)
 
 	// Create test channels
@@ -56,7 +67,7 @@ func TestHandleEvent(t *testing.T) {
 	logger := log.New(os.Stderr)
 	logger.SetLevel(log.ErrorLevel)
 
-	processor := New(logger)
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
 
 	testEvent := watcher.Event{
 		Path:      "/tmp/testfile.txt",
@@ -70,12 +81,401 @@ func TestHandleEvent(t *testing.T) {
 	}
 
 	// This should not panic
-	processor.handleEvent(testEvent)
+	processor.handleEvent(context.Background(), testEvent)
 
 	// Test with different operations
 	operations := []string{"CREATE", "WRITE", "REMOVE", "RENAME", "CHMOD", "UNKNOWN"}
 	for _, op := range operations {
 		testEvent.Operation = op
-		processor.handleEvent(testEvent)
+		processor.handleEvent(context.Background(), testEvent)
+	}
+}
+
+func TestHandleCreateDirectoryFixesContents(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "Season 01")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "extras"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extras", "ep1.mkv"), []byte("x"), 0600))
+
+	processor.handleEvent(context.Background(), watcher.Event{
+		Path:      dir,
+		Operation: "CREATE",
+		WatchDir: config.WatchDir{
+			Path:     root,
+			FileMode: "0644",
+			DirMode:  "0755",
+		},
+		Timestamp: time.Now(),
+	})
+
+	for path, want := range map[string]os.FileMode{
+		dir:                                     0755,
+		filepath.Join(dir, "extras"):            0755,
+		filepath.Join(dir, "extras", "ep1.mkv"): 0644,
+	} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+}
+
+func TestHandleCreateSymlink(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	root, outside := t.TempDir(), t.TempDir()
+	require.NoError(t, os.Chmod(outside, 0700))
+	secret := filepath.Join(outside, "secret.mkv")
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0600))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755", Recursive: true}
+
+	// Symlinks created in the tree leave what they point to alone
+	for name, target := range map[string]string{"file.mkv": secret, "dir": outside} {
+		link := filepath.Join(root, name)
+		require.NoError(t, os.Symlink(target, link))
+		processor.handleEvent(context.Background(), watcher.Event{Path: link, Operation: "CREATE", WatchDir: watchDir, Timestamp: time.Now()})
+	}
+
+	for path, want := range map[string]os.FileMode{secret: 0600, outside: 0700} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+}
+
+func TestHandleWriteVerified(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	root := t.TempDir()
+	file := filepath.Join(root, "download.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+	handle := func(operation string) os.FileMode {
+		processor.handleEvent(context.Background(), watcher.Event{Path: file, Operation: operation, WatchDir: watchDir, Timestamp: time.Now()})
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		return info.Mode().Perm()
+	}
+
+	assert.Equal(t, os.FileMode(0644), handle("CREATE"))
+
+	// Writes to a file just fixed are not checked again
+	require.NoError(t, os.Chmod(file, 0600))
+	assert.Equal(t, os.FileMode(0600), handle("WRITE"))
+
+	// until a permission change is reported
+	handle("CHMOD")
+	assert.Equal(t, os.FileMode(0644), handle("WRITE"))
+
+	// also when it was merged into the write
+	require.NoError(t, os.Chmod(file, 0600))
+	processor.handleEvent(context.Background(), watcher.Event{Path: file, Operation: "WRITE", WatchDir: watchDir, Timestamp: time.Now(), Chmodded: true})
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestHandleScan(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	root := t.TempDir()
+	album := filepath.Join(root, "album")
+	require.NoError(t, os.Mkdir(album, 0700))
+	tracks := make([]string, 20)
+	for i := range tracks {
+		tracks[i] = filepath.Join(album, fmt.Sprintf("track%02d.flac", i))
+		require.NoError(t, os.WriteFile(tracks[i], []byte("x"), 0600))
+	}
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	processor.handleEvent(context.Background(), watcher.Event{Path: album, Operation: "SCAN", WatchDir: watchDir, Timestamp: time.Now()})
+
+	for _, path := range append(tracks, album) {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		want := os.FileMode(0644)
+		if info.IsDir() {
+			want = 0755
+		}
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+
+	// A directory removed before its scan is left alone
+	processor.handleEvent(context.Background(), watcher.Event{Path: filepath.Join(root, "gone"), Operation: "SCAN", WatchDir: watchDir, Timestamp: time.Now()})
+
+	// Catch-up scans cover the whole folder
+	require.NoError(t, os.Chmod(tracks[0], 0600))
+	processor.handleEvent(context.Background(), watcher.Event{Path: root, Operation: "CATCH_UP", WatchDir: watchDir, Timestamp: time.Now()})
+	info, err := os.Stat(tracks[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestHandleError(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	tracker := status.NewTracker("test")
+	processor := New(enforcer.New(activity.NewHub(), logger), tracker, logger)
+
+	processor.handleError(&watcher.Error{Kind: watcher.ErrorWatchesExhausted, Path: "/data/tv", Err: syscall.ENOSPC})
+	processor.handleError(errors.New("unknown"))
+
+	snapshot := tracker.Snapshot()
+	assert.Equal(t, []string{watcher.ErrorWatcher, watcher.ErrorWatchesExhausted}, snapshot.DegradedFlags())
+	assert.Contains(t, snapshot.Degraded[watcher.ErrorWatchesExhausted].Reason, "/data/tv")
+	assert.Contains(t, snapshot.Degraded[watcher.ErrorWatchesExhausted].Reason, "fs.inotify.max_user_watches")
+	assert.Equal(t, "unknown", snapshot.Degraded[watcher.ErrorWatcher].Reason)
+}
+
+func TestProcessFolders(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	var files []string
+	events := make(chan watcher.Event, 10)
+	for _, name := range []string{"downloads", "media"} {
+		root := t.TempDir()
+		watchDir := config.WatchDir{Name: name, Path: root, FileMode: "0644", DirMode: "0755"}
+		for i := range 3 {
+			file := filepath.Join(root, fmt.Sprintf("ep%d.mkv", i))
+			require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+			files = append(files, file)
+			events <- watcher.Event{Path: file, Operation: "CREATE", WatchDir: watchDir, Timestamp: time.Now()}
+		}
+	}
+	close(events)
+
+	// Queued events are handled before Process returns, even once the
+	// errors are closed first
+	errs := make(chan error)
+	close(errs)
+	assert.Zero(t, processor.Process(context.Background(), events, errs))
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), file)
+	}
+}
+
+func TestProcessCancelled(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	root := t.TempDir()
+	watchDir := config.WatchDir{Name: "media", Path: root, FileMode: "0644", DirMode: "0755"}
+	file := filepath.Join(root, "ep.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	events := make(chan watcher.Event, 5)
+	for range 5 {
+		events <- watcher.Event{Path: file, Operation: "CREATE", WatchDir: watchDir, Timestamp: time.Now()}
+	}
+
+	// Events not handled once cancelled are abandoned and reported
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, 5, processor.Process(ctx, events, make(chan error)))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestProcessRetain(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+	events := make(chan watcher.Event)
+	processed := make(chan int, 1)
+	go func() { processed <- processor.Process(context.Background(), events, make(chan error)) }()
+
+	kept := config.WatchDir{Name: "retain-kept", Path: t.TempDir(), FileMode: "0644", DirMode: "0755"}
+	removed := config.WatchDir{Name: "retain-removed", Path: t.TempDir(), FileMode: "0644", DirMode: "0755"}
+	send := func(watchDir config.WatchDir) {
+		file := filepath.Join(watchDir.Path, "ep.mkv")
+		require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+		events <- watcher.Event{Path: file, Operation: "CREATE", WatchDir: watchDir, Timestamp: time.Now()}
+	}
+	fixed := func(watchDir config.WatchDir) bool {
+		info, err := os.Stat(filepath.Join(watchDir.Path, "ep.mkv"))
+		return err == nil && info.Mode().Perm() == 0644
+	}
+
+	send(kept)
+	send(removed)
+	require.Eventually(t, func() bool { return fixed(kept) && fixed(removed) }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, queueSeries("retain-kept"))
+	assert.True(t, queueSeries("retain-removed"))
+
+	// The worker of a removed folder stops and its series goes away
+	processor.Retain([]config.WatchDir{kept})
+	require.Eventually(t, func() bool { return !queueSeries("retain-removed") }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, queueSeries("retain-kept"))
+
+	// Events sent before the reload are still handled
+	send(removed)
+	require.Eventually(t, func() bool { return fixed(removed) && !queueSeries("retain-removed") }, 5*time.Second, 10*time.Millisecond)
+
+	// A renamed folder moves its series to the new name
+	renamed := kept
+	renamed.Name = "retain-renamed"
+	send(renamed)
+	require.Eventually(t, func() bool { return queueSeries("retain-renamed") }, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, queueSeries("retain-kept"))
+
+	close(events)
+	assert.Zero(t, <-processed)
+	assert.False(t, queueSeries("retain-renamed"))
+}
+
+// queueSeries reports whether the queue length of a folder is exported
+func queueSeries(folder string) bool {
+	for _, sample := range metrics.Default.Gather() {
+		if sample.Name == "ownarr_event_queue_length" && slices.Contains(sample.Labels, metrics.Label{Name: "folder", Value: folder}) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPipeline(t *testing.T) {
+	q := newPipeline()
+	event := watcher.Event{Path: "/downloads/file", Operation: "CREATE", WatchDir: config.WatchDir{Name: "pipeline-test", Path: "/downloads"}}
+	for range queueSize {
+		queued, overflow := q.push(event)
+		require.True(t, queued)
+		require.False(t, overflow)
+	}
+
+	// Only the first event dropped reports the overflow
+	queued, overflow := q.push(event)
+	assert.False(t, queued)
+	assert.True(t, overflow)
+	queued, overflow = q.push(event)
+	assert.False(t, queued)
+	assert.False(t, overflow)
+
+	// Events standing for many others are never dropped
+	queued, _ = q.push(watcher.Event{Path: "/downloads", Operation: "POLL_COMPLETE"})
+	assert.True(t, queued)
+
+	for range queueSize {
+		next, ok := q.pop()
+		require.True(t, ok)
+		require.Equal(t, "CREATE", next.Operation)
+	}
+	next, ok := q.pop()
+	require.True(t, ok)
+	assert.Equal(t, "POLL_COMPLETE", next.Operation)
+	_, ok = q.pop()
+	assert.False(t, ok)
+
+	// A drained queue reports the next overflow again
+	for range queueSize {
+		q.push(event)
+	}
+	_, overflow = q.push(event)
+	assert.True(t, overflow)
+}
+
+func TestPollProgress(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	tracker := status.NewTracker("test")
+	processor := New(enforcer.New(activity.NewHub(), logger), tracker, logger)
+
+	root := t.TempDir()
+	file := filepath.Join(root, "ep1.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+	watchDir := config.WatchDir{Path: root, FileMode: "0644", DirMode: "0755"}
+
+	// The scan shows in the status while the poll run is in flight
+	processor.handleEvent(context.Background(), watcher.Event{Path: file, Operation: "POLL_CHECK", WatchDir: watchDir, Timestamp: time.Now()})
+	scan := tracker.Snapshot().Folders[root].Scan
+	require.NotNil(t, scan)
+	assert.Equal(t, root, scan.Root)
+
+	processor.handleEvent(context.Background(), watcher.Event{Path: root, Operation: "POLL_COMPLETE", WatchDir: watchDir, Timestamp: time.Now()})
+	folder := tracker.Snapshot().Folders[root]
+	assert.Nil(t, folder.Scan)
+	require.NotNil(t, folder.LastRun)
+	assert.Equal(t, 1, folder.LastRun.Fixed)
+
+	// Paths the watcher could not read fail the run
+	processor.handleEvent(context.Background(), watcher.Event{Path: root, Operation: "POLL_COMPLETE", WatchDir: watchDir, Timestamp: time.Now(), Failed: 1})
+	folder = tracker.Snapshot().Folders[root]
+	require.NotNil(t, folder.LastRun)
+	assert.Equal(t, 1, folder.LastRun.Failed)
+}
+
+func TestPollRemote(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	tracker := status.NewTracker("test")
+	processor := New(enforcer.New(activity.NewHub(), logger), tracker, logger)
+
+	// Hosts that cannot be reached fail the folder's run
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0600))
+	remote := config.Remote{Host: "127.0.0.1", Port: 1, User: "admin", Password: "secret", KnownHosts: knownHosts}
+	watchDir := config.WatchDir{Name: "nas", Path: "/volume1/media", FileMode: "0644", DirMode: "0755", Remote: remote}
+	processor.handleEvent(context.Background(), watcher.Event{Path: watchDir.Path, Operation: "POLL_REMOTE", WatchDir: watchDir, Timestamp: time.Now()})
+
+	folder := tracker.Snapshot().Folders[watchDir.Path]
+	require.NotNil(t, folder.LastRun)
+	assert.Equal(t, 1, folder.LastRun.Failed)
+}
+
+func BenchmarkHandleEvent(b *testing.B) {
+	logger := log.New(io.Discard)
+	logger.SetLevel(log.ErrorLevel)
+
+	processor := New(enforcer.New(activity.NewHub(), logger), status.NewTracker("test"), logger)
+
+	// Events for files that are already correct, the common case of a busy
+	// download folder
+	root := b.TempDir()
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = filepath.Join(root, fmt.Sprintf("episode%04d.mkv", i))
+		require.NoError(b, os.WriteFile(paths[i], nil, 0644))
+	}
+	watchDir := config.WatchDir{Path: root, Exclude: []string{"*.part"}, FileMode: "0644", DirMode: "0755"}
+	require.NoError(b, watchDir.Compile())
+
+	for _, operation := range []string{"CREATE", "WRITE", "POLL_CHECK"} {
+		b.Run(operation, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				processor.handleEvent(context.Background(), watcher.Event{
+					Path:      paths[i%len(paths)],
+					Operation: operation,
+					WatchDir:  watchDir,
+					Timestamp: time.Now(),
+				})
+			}
+		})
 	}
 }
@@ -0,0 +1,66 @@
+// Package recovery keeps the goroutines of the daemon running through
+// panics, so that a bug hit by one path reports itself instead of ending the
+// process or silently stopping the event loop
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/metrics"
+)
+
+// Degraded is the degraded condition raised by a panic
+const Degraded = "panics"
+
+// restartDelay is how long a component waits to restart after a panic, so
+// that one panicking on every run does not spin
+const restartDelay = time.Second
+
+// reporter is told about every panic once it is logged and counted
+var reporter atomic.Pointer[func(reason string)]
+
+// SetReporter sets the function told about every panic recovered, with a
+// reason naming the component and the panic, such as one raising the
+// Degraded condition
+func SetReporter(report func(reason string)) {
+	reporter.Store(&report)
+}
+
+// Run calls fn, recovering a panic in it. A panic is logged with its stack,
+// counted by component and reported. Run reports whether fn panicked.
+func Run(logger *log.Logger, component string, fn func()) (panicked bool) {
+	defer func() {
+		if value := recover(); value != nil {
+			panicked = true
+			report(logger, component, value, debug.Stack())
+		}
+	}()
+	fn()
+	return false
+}
+
+// Loop calls fn until it returns without panicking, restarting it after a
+// pause each time it panics. It returns early once done is closed.
+func Loop(logger *log.Logger, component string, done <-chan struct{}, fn func()) {
+	for Run(logger, component, fn) {
+		select {
+		case <-done:
+			return
+		case <-time.After(restartDelay):
+		}
+		logger.Warn("Restarting after a panic", "component", component)
+	}
+}
+
+// report logs, counts and reports a recovered panic
+func report(logger *log.Logger, component string, value any, stack []byte) {
+	logger.Error("Recovered from a panic", "component", component, "panic", value, "stack", string(stack))
+	metrics.Panics.Inc(component)
+	if report := reporter.Load(); report != nil {
+		(*report)(fmt.Sprintf("%s panicked: %v", component, value))
+	}
+}
@@ -0,0 +1,49 @@
+package recovery
+
+import (
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	logger := log.New(io.Discard)
+	var reasons []string
+	SetReporter(func(reason string) { reasons = append(reasons, reason) })
+	defer SetReporter(func(string) {})
+
+	assert.False(t, Run(logger, "test", func() {}))
+	assert.Empty(t, reasons)
+
+	before := metrics.Panics.Value("test")
+	assert.True(t, Run(logger, "test", func() { panic("malformed path") }))
+	assert.Equal(t, []string{"test panicked: malformed path"}, reasons)
+	assert.Equal(t, before+1, metrics.Panics.Value("test"))
+}
+
+func TestLoop(t *testing.T) {
+	logger := log.New(io.Discard)
+
+	// A panicking component restarts until it returns
+	runs := 0
+	Loop(logger, "test", nil, func() {
+		runs++
+		if runs == 1 {
+			panic("first run")
+		}
+	})
+	assert.Equal(t, 2, runs)
+
+	// Closing done stops the restarts
+	done := make(chan struct{})
+	close(done)
+	runs = 0
+	Loop(logger, "test", done, func() {
+		runs++
+		panic("every run")
+	})
+	assert.Equal(t, 1, runs)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// accessLogKey is the context key of the access log entry being built
+type accessLogKey struct{}
+
+// accessEntry collects request details that are only known to handlers
+type accessEntry struct {
+	user string
+}
+
+// newAccessLogger creates the logger for JSON access logs. They are written
+// as one JSON object per line for log shippers such as Promtail or Filebeat,
+// whatever format the application log uses.
+func newAccessLogger() *log.Logger {
+	return log.NewWithOptions(os.Stderr, log.Options{
+		ReportTimestamp: true,
+		TimeFormat:      time.RFC3339Nano,
+		Formatter:       log.JSONFormatter,
+	})
+}
+
+// accessLog logs each request once it has been served, when enabled
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.currentConfig().Server.AccessLog
+		if !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		entry := &accessEntry{}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+		logger := s.logger
+		if cfg.Format == "json" {
+			logger = s.accessLogger
+		}
+		logger.Info("HTTP request",
+			"method", r.Method,
+			"path", escape.Text(r.URL.Path),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote", s.clientIP(r),
+			"user", entry.user,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// setAccessUser records the authenticated user in the request's access log
+func setAccessUser(r *http.Request, user string) {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessEntry); ok {
+		entry.user = user
+	}
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status code
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, so
+// streams can still flush and adjust deadlines
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogJSON(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.Server.AccessLog = config.AccessLog{Enabled: true, Format: "json"}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	var buf bytes.Buffer
+	s.accessLogger = log.NewWithOptions(&buf, log.Options{Formatter: log.JSONFormatter})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), buf.String())
+	assert.Equal(t, "HTTP request", entry["msg"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/api/v1/config", entry["path"])
+	assert.EqualValues(t, http.StatusOK, entry["status"])
+	assert.Equal(t, "api_key", entry["user"])
+	assert.NotZero(t, entry["bytes"])
+
+	// Disabled access logs write nothing
+	buf.Reset()
+	cfg.Server.AccessLog.Enabled = false
+	s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Empty(t, buf.String())
+}
+
+func TestStatusRecorder(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+
+	rec.WriteHeader(http.StatusNotFound)
+	rec.WriteHeader(http.StatusInternalServerError)
+	_, err := rec.Write([]byte("missing"))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, rec.status, "the first status wins")
+	assert.EqualValues(t, 7, rec.bytes)
+	assert.NoError(t, http.NewResponseController(rec).Flush(), "flushing reaches the underlying writer")
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// identityKey is the context key of the authenticated identity
+type identityKey struct{}
+
+// requireAuth rejects requests that are not authenticated by the API key,
+// basic auth, or a trusted proxy. Without any configured method the endpoint
+// is disabled.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.currentConfig().Server
+		if cfg.APIKey == "" && !cfg.BasicAuth.Enabled() && !cfg.ProxyAuth.Enabled() {
+			writeError(w, http.StatusForbidden, "no authentication is configured")
+			return
+		}
+
+		id, ok := authenticate(cfg, r)
+		if !ok {
+			if cfg.BasicAuth.Enabled() {
+				w.Header().Set("WWW-Authenticate", `Basic realm="ownarr", charset="UTF-8"`)
+			}
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		setAccessUser(r, id)
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+}
+
+// authenticate returns the identity of a request's caller, trying each
+// configured method in turn
+func authenticate(cfg config.Server, r *http.Request) (string, bool) {
+	if cfg.APIKey != "" {
+		if key := requestAPIKey(r); key != "" && secureEqual(key, cfg.APIKey) {
+			return "api_key", true
+		}
+	}
+
+	if cfg.BasicAuth.Enabled() {
+		if user, password, ok := r.BasicAuth(); ok {
+			// Compare both to avoid revealing which one was wrong
+			userOK := secureEqual(user, cfg.BasicAuth.Username)
+			passwordOK := secureEqual(password, cfg.BasicAuth.Password)
+			if userOK && passwordOK {
+				return user, true
+			}
+		}
+	}
+
+	if cfg.ProxyAuth.Enabled() {
+		if user := r.Header.Get(cfg.ProxyAuth.Header); user != "" && fromTrustedProxy(cfg.ProxyAuth, r) {
+			return user, true
+		}
+	}
+
+	return "", false
+}
+
+// identity returns the authenticated identity of a request, if any
+func identity(r *http.Request) string {
+	id, _ := r.Context().Value(identityKey{}).(string)
+	return id
+}
+
+// fromTrustedProxy reports whether the request was made by a trusted proxy
+func fromTrustedProxy(proxyAuth config.ProxyAuth, r *http.Request) bool {
+	addr, err := netip.ParseAddr(remoteHost(r))
+	return err == nil && proxyAuth.Trusts(addr)
+}
+
+// requestAPIKey extracts the API key from a request. It is accepted as a
+// bearer token, in the X-Api-Key header, or as the apikey query parameter
+// for clients that cannot set headers.
+func requestAPIKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("apikey")
+}
+
+// secureEqual compares secrets in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAuthAPIKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	handler := s.requireAuth(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{name: "no key", target: "/", wantCode: http.StatusUnauthorized},
+		{name: "bearer token", target: "/", header: "Authorization", value: "Bearer secret", wantCode: http.StatusNoContent},
+		{name: "wrong bearer token", target: "/", header: "Authorization", value: "Bearer nope", wantCode: http.StatusUnauthorized},
+		{name: "basic scheme", target: "/", header: "Authorization", value: "Basic secret", wantCode: http.StatusUnauthorized},
+		{name: "api key header", target: "/", header: "X-Api-Key", value: "secret", wantCode: http.StatusNoContent},
+		{name: "wrong api key header", target: "/", header: "X-Api-Key", value: "secre", wantCode: http.StatusUnauthorized},
+		{name: "query parameter", target: "/?apikey=secret", wantCode: http.StatusNoContent},
+		{name: "wrong query parameter", target: "/?apikey=other", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
+
+func TestRequireAuthBasicAndProxy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.BasicAuth = config.BasicAuth{Username: "admin", Password: "hunter2"}
+	cfg.Server.ProxyAuth = config.ProxyAuth{Header: "Remote-User", TrustedProxies: []string{"10.0.0.0/8"}}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	var gotIdentity string
+	handler := s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		gotIdentity = identity(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		setup        func(*http.Request)
+		wantCode     int
+		wantIdentity string
+	}{
+		{
+			name:     "no credentials",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "basic auth",
+			setup:        func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") },
+			wantCode:     http.StatusNoContent,
+			wantIdentity: "admin",
+		},
+		{
+			name:     "wrong password",
+			setup:    func(r *http.Request) { r.SetBasicAuth("admin", "wrong") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "10.1.2.3:4567",
+			setup:        func(r *http.Request) { r.Header.Set("Remote-User", "alice") },
+			wantCode:     http.StatusNoContent,
+			wantIdentity: "alice",
+		},
+		{
+			name:       "untrusted proxy",
+			remoteAddr: "192.0.2.1:4567",
+			setup:      func(r *http.Request) { r.Header.Set("Remote-User", "alice") },
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name:       "trusted proxy without user",
+			remoteAddr: "10.1.2.3:4567",
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name:     "api key not configured",
+			setup:    func(r *http.Request) { r.Header.Set("X-Api-Key", "") },
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIdentity = ""
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantIdentity, gotIdentity)
+			if tt.wantCode == http.StatusUnauthorized {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Basic")
+			}
+		})
+	}
+}
+
+func TestClientIPBehindTrustedProxy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.ProxyAuth = config.ProxyAuth{Header: "Remote-User", TrustedProxies: []string{"10.0.0.1", "10.0.0.2"}}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7, 10.0.0.2")
+	assert.Equal(t, "198.51.100.7", s.clientIP(req))
+
+	// Forwarded headers from untrusted peers are ignored
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", s.clientIP(req))
+}
+
+func TestRequireAuthNotConfigured(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.requireAuth(func(http.ResponseWriter, *http.Request) {
+		t.Fatal("handler must not run")
+	})(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// maxConfigSize limits the size of configuration uploads
+const maxConfigSize = 1 << 20
+
+// handleGetConfig returns the effective configuration with secrets redacted
+func (s *Server) handleGetConfig(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.currentConfig().Redacted())
+}
+
+// handlePutConfig validates and applies a new configuration, given as JSON
+// or YAML. Redacted secrets keep their current value, so the output of
+// GET can be edited and sent back.
+func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	if s.applyConfig == nil {
+		writeError(w, http.StatusNotImplemented, "runtime configuration is not supported")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "configuration too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read configuration")
+		return
+	}
+
+	cfg, err := config.Parse(data)
+	if err != nil {
+		writeConfigError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	restoreSecrets(cfg, s.currentConfig())
+
+	if err := s.applyConfig(cfg); err != nil {
+		writeConfigError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.logger.Info("Configuration updated via API", "user", identity(r))
+	writeJSON(w, http.StatusOK, s.currentConfig().Redacted())
+}
+
+// handleReload reloads the configuration file, like SIGHUP, and reports
+// whether the new configuration was valid and applied
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if s.reloadConfig == nil {
+		writeError(w, http.StatusNotImplemented, "reloading the configuration file is not supported")
+		return
+	}
+
+	if err := s.reloadConfig(); err != nil {
+		writeConfigError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.logger.Info("Configuration reloaded via API", "user", identity(r))
+	writeJSON(w, http.StatusOK, s.currentConfig().Redacted())
+}
+
+// restoreSecrets replaces redacted values in cfg with the current secrets.
+// Notification targets are matched by name, watch directories by path or
+// else by name.
+func restoreSecrets(cfg, current *config.Config) {
+	restoreValue(&cfg.Server.APIKey, current.Server.APIKey)
+	restoreValue(&cfg.Server.BasicAuth.Password, current.Server.BasicAuth.Password)
+	restoreValue(&cfg.Heartbeat.URL, current.Heartbeat.URL)
+	restoreValue(&cfg.Plex.Token, current.Plex.Token)
+	restoreTargets(cfg.Jellyfin, current.Jellyfin, func(t *config.Jellyfin) (string, *string) {
+		return t.Name, &t.APIKey
+	})
+	restoreHeaders(cfg.Tracing.Headers, current.Tracing.Headers)
+	restoreRemotes(cfg.WatchDirs, current.WatchDirs)
+
+	webhooks := make(map[string]config.Webhook, len(current.Notifications.Webhooks))
+	for _, webhook := range current.Notifications.Webhooks {
+		webhooks[webhook.Name] = webhook
+	}
+	for _, webhook := range cfg.Notifications.Webhooks {
+		restoreHeaders(webhook.Headers, webhooks[webhook.Name].Headers)
+	}
+
+	restoreTargets(cfg.Notifications.Discord, current.Notifications.Discord, func(t *config.Discord) (string, *string) {
+		return t.Name, &t.URL
+	})
+	restoreTargets(cfg.Notifications.Slack, current.Notifications.Slack, func(t *config.Slack) (string, *string) {
+		return t.Name, &t.URL
+	})
+	restoreTargets(cfg.Notifications.Telegram, current.Notifications.Telegram, func(t *config.Telegram) (string, *string) {
+		return t.Name, &t.Token
+	})
+	restoreTargets(cfg.Notifications.Ntfy, current.Notifications.Ntfy, func(t *config.Ntfy) (string, *string) {
+		return t.Name, &t.Token
+	})
+	restoreTargets(cfg.Notifications.Gotify, current.Notifications.Gotify, func(t *config.Gotify) (string, *string) {
+		return t.Name, &t.Token
+	})
+	restoreTargets(cfg.Notifications.Notifiarr, current.Notifications.Notifiarr, func(t *config.Notifiarr) (string, *string) {
+		return t.Name, &t.APIKey
+	})
+	restoreTargets(cfg.Notifications.Pushover, current.Notifications.Pushover, func(t *config.Pushover) (string, *string) {
+		return t.Name, &t.Token
+	})
+	restoreTargets(cfg.Notifications.Email, current.Notifications.Email, func(t *config.Email) (string, *string) {
+		return t.Name, &t.Password
+	})
+
+	// Apprise URLs are restored by position
+	apprise := make(map[string][]string, len(current.Notifications.Apprise))
+	for _, target := range current.Notifications.Apprise {
+		apprise[target.Name] = target.URLs
+	}
+	for _, target := range cfg.Notifications.Apprise {
+		urls := apprise[target.Name]
+		for i := range target.URLs {
+			if i < len(urls) {
+				restoreValue(&target.URLs[i], urls[i])
+			}
+		}
+	}
+}
+
+// restoreRemotes restores the redacted remote passwords of watchDirs from the
+// current watch directory of the same path, or else of the same name
+func restoreRemotes(watchDirs, current []config.WatchDir) {
+	for i := range watchDirs {
+		watchDir := &watchDirs[i]
+		if watchDir.Remote.Password != config.RedactedValue {
+			continue
+		}
+		watchDir.Remote.Password = ""
+		for _, old := range current {
+			if old.Path == watchDir.Path {
+				watchDir.Remote.Password = old.Remote.Password
+				break
+			}
+			if watchDir.Name != "" && old.Name == watchDir.Name {
+				watchDir.Remote.Password = old.Remote.Password
+			}
+		}
+	}
+}
+
+// restoreTargets restores a redacted secret of each notification target
+// from the current target of the same name
+func restoreTargets[T any](targets, current []T, secret func(*T) (name string, value *string)) {
+	values := make(map[string]string, len(current))
+	for i := range current {
+		name, value := secret(&current[i])
+		values[name] = *value
+	}
+	for i := range targets {
+		name, value := secret(&targets[i])
+		restoreValue(value, values[name])
+	}
+}
+
+// restoreValue replaces a redacted value with the current one
+func restoreValue(value *string, current string) {
+	if *value == config.RedactedValue {
+		*value = current
+	}
+}
+
+// restoreHeaders replaces redacted header values with the current ones
+func restoreHeaders(headers, current map[string]string) {
+	for key, value := range headers {
+		if value == config.RedactedValue {
+			headers[key] = current[key]
+		}
+	}
+}
+
+// writeConfigError writes an error response for a rejected configuration,
+// naming the setting at fault in field where it is known
+func writeConfigError(w http.ResponseWriter, code int, err error) {
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		writeJSON(w, code, map[string]string{"error": err.Error(), "field": invalid.Field})
+		return
+	}
+	writeError(w, code, err.Error())
+}
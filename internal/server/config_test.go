@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newConfigTestServer creates a server whose config updates are applied directly
+func newConfigTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: "/data/media", FileMode: "0644", DirMode: "0755"}}
+
+	s := newTestServer(cfg, status.NewTracker("test"))
+	s.applyConfig = func(newCfg *config.Config) error {
+		if newCfg.LogLevel == "verbose" {
+			return errors.New("unknown log level: verbose")
+		}
+		s.SetConfig(newCfg)
+		return nil
+	}
+	return s
+}
+
+// configRequest performs an authenticated request against the config endpoint
+func configRequest(s *Server, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/config", strings.NewReader(body))
+	req.Header.Set("X-Api-Key", "secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetConfigRedactsSecrets(t *testing.T) {
+	s := newConfigTestServer(t)
+
+	rec := configRequest(s, http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var cfg config.Config
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&cfg))
+	assert.Equal(t, config.RedactedValue, cfg.Server.APIKey)
+	assert.Equal(t, "/data/media", cfg.WatchDirs[0].Path)
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestPutConfig(t *testing.T) {
+	s := newConfigTestServer(t)
+
+	// A redacted key round-trips without changing the key
+	rec := configRequest(s, http.MethodPut, `
+log_level: debug
+poll_interval: 60
+server:
+  enabled: true
+  api_key: REDACTED
+watch_dirs:
+  - path: /data/tv
+`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	cfg := s.currentConfig()
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, 60, cfg.PollInterval)
+	assert.Equal(t, "secret", cfg.Server.APIKey)
+	require.Len(t, cfg.WatchDirs, 1)
+	assert.Equal(t, "tv", cfg.WatchDirs[0].Name)
+}
+
+func TestPutConfigRejectsInvalid(t *testing.T) {
+	s := newConfigTestServer(t)
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantField string
+	}{
+		{name: "malformed", body: "watch_dirs: [", wantCode: http.StatusBadRequest},
+		{name: "fails validation", body: `{"poll_interval": 0}`, wantCode: http.StatusBadRequest, wantField: "poll_interval"},
+		{name: "rejected on apply", body: `{"log_level": "verbose", "server": {"api_key": "REDACTED"}}`, wantCode: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := configRequest(s, http.MethodPut, tt.body)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantField, body["field"])
+			assert.Equal(t, "/data/media", s.currentConfig().WatchDirs[0].Path, "config is unchanged")
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	s := newConfigTestServer(t)
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reload", nil)
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a reload function the endpoint is unavailable
+	assert.Equal(t, http.StatusNotImplemented, request().Code)
+
+	s.reloadConfig = func() error {
+		cfg := config.DefaultConfig()
+		cfg.Server.APIKey = "secret"
+		cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: "/data/tv"}}
+		return s.applyConfig(cfg)
+	}
+	rec := request()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "/data/tv", s.currentConfig().WatchDirs[0].Path)
+	assert.NotContains(t, rec.Body.String(), "secret")
+
+	s.reloadConfig = func() error { return errors.New("invalid poll_interval") }
+	rec = request()
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid poll_interval")
+}
+
+func TestRestoreSecrets(t *testing.T) {
+	current := config.DefaultConfig()
+	current.Server.APIKey = "secret"
+	current.Heartbeat.URL = "https://hc-ping.com/uuid"
+	current.Notifications.Webhooks = []config.Webhook{
+		{Name: "a", Headers: map[string]string{"X-Token": "token-a"}},
+		{Name: "b", Headers: map[string]string{"X-Token": "token-b"}},
+	}
+	current.Notifications.Discord = []config.Discord{{Name: "discord", URL: "https://discord.com/api/webhooks/1/abc"}}
+	current.Notifications.Apprise = []config.Apprise{{Name: "apprise", URLs: []string{"pover://user@token", "tgram://bot/chat"}}}
+	current.Notifications.Email = []config.Email{{Name: "email", Username: "ownarr", Password: "hunter2"}}
+	current.Notifications.Notifiarr = []config.Notifiarr{{Name: "notifiarr", APIKey: "notifiarr-key", ChannelID: "123"}}
+	current.WatchDirs = []config.WatchDir{
+		{Name: "nas", Path: "/volume1/tv", Remote: config.Remote{Host: "nas", Password: "ssh-secret"}},
+		{Name: "nas2", Path: "/volume2/tv", Remote: config.Remote{Host: "nas2", Password: "other-secret"}},
+	}
+
+	cfg := current.Redacted()
+	cfg.Notifications.Webhooks = []config.Webhook{cfg.Notifications.Webhooks[1]}
+	restoreSecrets(cfg, current)
+
+	assert.Equal(t, "secret", cfg.Server.APIKey)
+	assert.Equal(t, "https://hc-ping.com/uuid", cfg.Heartbeat.URL)
+	assert.Equal(t, "token-b", cfg.Notifications.Webhooks[0].Headers["X-Token"], "targets are matched by name")
+	assert.Equal(t, "https://discord.com/api/webhooks/1/abc", cfg.Notifications.Discord[0].URL)
+	assert.Equal(t, "hunter2", cfg.Notifications.Email[0].Password)
+	assert.Equal(t, "notifiarr-key", cfg.Notifications.Notifiarr[0].APIKey)
+	assert.Equal(t, "ssh-secret", cfg.WatchDirs[0].Remote.Password)
+
+	// Watch directories are matched by path, or by name when it changed
+	renamed := current.Redacted()
+	renamed.WatchDirs[0].Name = "tv"
+	renamed.WatchDirs[1].Path = "/volume3/tv"
+	restoreSecrets(renamed, current)
+	assert.Equal(t, "ssh-secret", renamed.WatchDirs[0].Remote.Password)
+	assert.Equal(t, "other-secret", renamed.WatchDirs[1].Remote.Password)
+
+	// Apprise URLs keep their position, new ones are taken as given
+	cfg.Notifications.Apprise[0].URLs = append(cfg.Notifications.Apprise[0].URLs, "mailto://a:b@example.com")
+	restoreSecrets(cfg, current)
+	assert.Equal(t, []string{"pover://user@token", "tgram://bot/chat", "mailto://a:b@example.com"}, cfg.Notifications.Apprise[0].URLs)
+}
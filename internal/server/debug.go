@@ -0,0 +1,40 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// debugRoutes returns the diagnostic endpoints. They are not part of the
+// API, so openapi.json does not describe them, and they answer 404 unless
+// server.debug is set.
+func (s *Server) debugRoutes() []route {
+	return []route{
+		{http.MethodGet, "/debug/vars", s.requireDebug(expvar.Handler().ServeHTTP)},
+		{http.MethodGet, "/debug/pprof/", s.requireDebug(pprof.Index)},
+		{http.MethodGet, "/debug/pprof/cmdline", s.requireDebug(pprof.Cmdline)},
+		{http.MethodGet, "/debug/pprof/profile", s.requireDebug(pprof.Profile)},
+		{http.MethodGet, "/debug/pprof/symbol", s.requireDebug(pprof.Symbol)},
+		{http.MethodPost, "/debug/pprof/symbol", s.requireDebug(pprof.Symbol)},
+		{http.MethodGet, "/debug/pprof/trace", s.requireDebug(pprof.Trace)},
+	}
+}
+
+// requireDebug hides an endpoint unless debugging is enabled, and requires
+// authentication as the endpoints reveal internals of the process
+func (s *Server) requireDebug(next http.HandlerFunc) http.HandlerFunc {
+	authenticated := s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		// Profiles and traces take longer than the write timeout allows
+		s.clearWriteDeadline(http.NewResponseController(w))
+		next(w, r)
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.currentConfig().Server.Debug {
+			http.NotFound(w, r)
+			return
+		}
+		authenticated(w, r)
+	}
+}
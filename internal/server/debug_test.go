@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, request("/debug/vars", "secret").Code, "disabled by default")
+
+	cfg.Server.Debug = true
+	assert.Equal(t, http.StatusUnauthorized, request("/debug/vars", "").Code)
+
+	rec := request("/debug/vars", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"ownarr": {`)
+	assert.Contains(t, rec.Body.String(), `"memstats": {`)
+
+	assert.Equal(t, http.StatusOK, request("/debug/pprof/", "secret").Code)
+	assert.Equal(t, http.StatusOK, request("/debug/pprof/heap", "secret").Code)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// Page size limits for dry-run results
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// dryRunResponse is a page of the changes enforcement would make
+type dryRunResponse struct {
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Skipped int            `json:"skipped"`
+	Failed  int            `json:"failed"`
+	Changes []dryRunChange `json:"changes"`
+}
+
+// dryRunChange is a single permission change enforcement would make
+type dryRunChange struct {
+	Folder   string `json:"folder"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	OldMode  string `json:"old_mode"`
+	NewMode  string `json:"new_mode"`
+	OldOwner string `json:"old_owner,omitempty"` // uid:gid, set when the owner would change
+	NewOwner string `json:"new_owner,omitempty"`
+}
+
+// handleDryRun scans all watch directories, or the one selected with
+// ?folder=, and returns the changes enforcement would make without making
+// them. Results are paged with ?offset= and ?limit= in walk order; only the
+// page is kept, so memory stays flat however many paths would change.
+func (s *Server) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, ok := queryInt(w, query.Get("offset"), "offset", 0)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(w, query.Get("limit"), "limit", defaultPageSize)
+	if !ok {
+		return
+	}
+	if offset < 0 || limit < 1 || limit > maxPageSize {
+		writeError(w, http.StatusBadRequest, "offset must not be negative and limit must be between 1 and "+strconv.Itoa(maxPageSize))
+		return
+	}
+
+	watchDirs := s.currentConfig().WatchDirs
+	if name := query.Get("folder"); name != "" {
+		watchDir, ok := s.lookupFolder(w, name)
+		if !ok {
+			return
+		}
+		watchDirs = []config.WatchDir{watchDir}
+	}
+
+	s.clearWriteDeadline(http.NewResponseController(w))
+
+	resp := dryRunResponse{Offset: offset, Limit: limit, Changes: []dryRunChange{}}
+	for _, watchDir := range watchDirs {
+		result := s.enforcer.PlanEach(r.Context(), watchDir.Path, watchDir, func(planned enforcer.PathResult) {
+			if planned.Outcome != enforcer.Fixed {
+				return
+			}
+			if resp.Total >= offset && len(resp.Changes) < limit {
+				c := dryRunChange{
+					Folder:  watchDir.Name,
+					Path:    escape.JSON(planned.Path),
+					Kind:    planned.Kind,
+					OldMode: planned.OldMode.String(),
+					NewMode: planned.NewMode.String(),
+				}
+				if planned.NewOwner != planned.OldOwner {
+					c.OldOwner, c.NewOwner = planned.OldOwner.String(), planned.NewOwner.String()
+				}
+				resp.Changes = append(resp.Changes, c)
+			}
+			resp.Total++
+		})
+		resp.Skipped += result.Skipped
+		resp.Failed += result.Failed
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// queryInt parses an integer query parameter, writing an error response if
+// it is malformed
+func queryInt(w http.ResponseWriter, value, name string, fallback int) (int, bool) {
+	if value == "" {
+		return fallback, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+name+" parameter")
+		return 0, false
+	}
+	return n, true
+}
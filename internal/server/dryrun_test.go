@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dryRunRequest performs an authenticated dry-run request
+func dryRunRequest(s *Server, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dryrun"+query, nil)
+	req.Header.Set("X-Api-Key", "secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0755))
+	for i := range 3 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("movie%d.mkv", i)), []byte("x"), 0600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "done.mkv"), []byte("x"), 0644))
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: dir, FileMode: "0644", DirMode: "0755"}}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	page := func(query string) dryRunResponse {
+		rec := dryRunRequest(s, query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp dryRunResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	all := page("?folder=media")
+	assert.Equal(t, 3, all.Total)
+	assert.Equal(t, 2, all.Skipped)
+	require.Len(t, all.Changes, 3)
+	assert.Equal(t, "-rw-------", all.Changes[0].OldMode)
+	assert.Equal(t, "-rw-r--r--", all.Changes[0].NewMode)
+	assert.Equal(t, "file", all.Changes[0].Kind)
+
+	// Pages follow the walk order, which is the same for every request
+	resp := page("?folder=media&offset=1&limit=1")
+	assert.Equal(t, 3, resp.Total)
+	assert.Equal(t, []dryRunChange{all.Changes[1]}, resp.Changes)
+
+	info, err := os.Stat(filepath.Join(dir, "movie1.mkv"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "dry run must not change anything")
+
+	assert.Equal(t, http.StatusNotFound, dryRunRequest(s, "?folder=other").Code)
+	assert.Equal(t, http.StatusBadRequest, dryRunRequest(s, "?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, dryRunRequest(s, "?offset=x").Code)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/metrics"
+	"github.com/keksiqc/ownarr/internal/tracing"
+)
+
+// enforceTarget is a tree to enforce within a watch directory
+type enforceTarget struct {
+	watchDir config.WatchDir
+	root     string // Watch directory path, or a subpath within it
+}
+
+// handleEnforce runs enforcement across all watch directories. With
+// ?async=true it returns immediately with a job that can be polled.
+func (s *Server) handleEnforce(w http.ResponseWriter, r *http.Request) {
+	watchDirs := s.currentConfig().WatchDirs
+	targets := make([]enforceTarget, 0, len(watchDirs))
+	for _, watchDir := range watchDirs {
+		targets = append(targets, enforceTarget{watchDir: watchDir, root: watchDir.Path})
+	}
+
+	s.startJob(w, r, targets)
+}
+
+// handleEnforceFolder runs enforcement for a single named watch directory,
+// or a subpath within it
+func (s *Server) handleEnforceFolder(w http.ResponseWriter, r *http.Request) {
+	watchDir, ok := s.lookupFolder(w, r.PathValue("folder"))
+	if !ok {
+		return
+	}
+
+	root, ok := resolveSubpath(watchDir.Path, r.PathValue("path"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "path must be within the folder")
+		return
+	}
+
+	s.startJob(w, r, []enforceTarget{{watchDir: watchDir, root: root}})
+}
+
+// lookupFolder finds the watch directory with the given name, writing an
+// error response if there is no unique match
+func (s *Server) lookupFolder(w http.ResponseWriter, name string) (config.WatchDir, bool) {
+	var matches []config.WatchDir
+	for _, watchDir := range s.currentConfig().WatchDirs {
+		if watchDir.Name == name {
+			matches = append(matches, watchDir)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		writeError(w, http.StatusNotFound, "folder not found")
+		return config.WatchDir{}, false
+	case 1:
+		return matches[0], true
+	default:
+		writeError(w, http.StatusConflict, "folder name is ambiguous, set a unique name in the config")
+		return config.WatchDir{}, false
+	}
+}
+
+// handleJob returns the state of a job
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// startJob creates a job for the targets and runs it, either before
+// responding or in the background when ?async=true is set
+func (s *Server) startJob(w http.ResponseWriter, r *http.Request, targets []enforceTarget) {
+	async := false
+	if value := r.URL.Query().Get("async"); value != "" {
+		var err error
+		if async, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid async parameter")
+			return
+		}
+	}
+
+	id := s.jobs.create()
+	s.logger.Info("Enforcement requested via API", "job", id, "user", identity(r), "targets", len(targets), "async", async)
+
+	if async {
+		// The job outlives the request but not the server
+		if !s.goJob(context.WithoutCancel(r.Context()), func(ctx context.Context) { s.runJob(ctx, id, targets) }) {
+			s.jobs.finish(id, []folderRun{}, true)
+			writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+			return
+		}
+
+		j, _ := s.jobs.get(id)
+		w.Header().Set("Location", "/api/v1/jobs/"+id)
+		writeJSON(w, http.StatusAccepted, j)
+		return
+	}
+
+	s.clearWriteDeadline(http.NewResponseController(w))
+	ctx, cancel := s.jobContext(r.Context())
+	defer cancel()
+	s.runJob(ctx, id, targets)
+
+	j, _ := s.jobs.get(id)
+	writeJSON(w, http.StatusOK, j)
+}
+
+// runJob enforces each target in turn and records the results
+func (s *Server) runJob(ctx context.Context, id string, targets []enforceTarget) {
+	ctx, span := tracing.Start(ctx, "enforce.job",
+		tracing.String("job", id),
+		tracing.Int("targets", len(targets)),
+	)
+	defer span.Finish()
+
+	folders := make([]folderRun, 0, len(targets))
+	for _, target := range targets {
+		result := s.enforcer.Tree(ctx, target.root, target.watchDir)
+
+		// Only full runs describe the state of the folder
+		if target.root == target.watchDir.Path {
+			s.tracker.RecordRun(target.watchDir.Path, result)
+			metrics.ObserveScan(target.watchDir.Name, metrics.TriggerAPI, result)
+		}
+
+		folders = append(folders, folderRun{
+			Name:      target.watchDir.Name,
+			Path:      target.root,
+			runStatus: *newRunStatus(result),
+		})
+	}
+
+	s.jobs.finish(id, folders, ctx.Err() != nil)
+
+	j, _ := s.jobs.get(id)
+	s.logger.Info("Enforcement job complete",
+		"job", id,
+		"fixed", j.Fixed,
+		"skipped", j.Skipped,
+		"failed", j.Failed,
+	)
+}
+
+// resolveSubpath joins a relative subpath onto root, reporting false if the
+// result would escape root
+func resolveSubpath(root, subpath string) (string, bool) {
+	if subpath == "" {
+		return root, true
+	}
+
+	joined := filepath.Join(root, filepath.FromSlash(subpath))
+	rel, err := filepath.Rel(root, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return joined, true
+}
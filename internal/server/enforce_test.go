@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEnforceTestServer creates a server watching a temporary directory
+// containing one file with incorrect permissions
+func newEnforceTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0755))
+	file := filepath.Join(dir, "movie.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{{Path: dir, FileMode: "0644", DirMode: "0755"}}
+
+	return newTestServer(cfg, status.NewTracker("test")), file
+}
+
+func TestEnforceRequiresAPIKey(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/enforce", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/enforce", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	cfg := s.currentConfig().Clone()
+	cfg.Server.APIKey = ""
+	s.SetConfig(cfg)
+	req.Header.Set("Authorization", "Bearer ")
+	rec = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestEnforceSync(t *testing.T) {
+	s, file := newEnforceTestServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/enforce", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var j job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&j))
+	assert.Equal(t, jobCompleted, j.Status)
+	assert.Equal(t, 1, j.Fixed)
+	assert.Equal(t, 1, j.Skipped)
+	require.Len(t, j.Folders, 1)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// The run is reported as the folder's last run
+	folder := s.tracker.Snapshot().Folders[s.currentConfig().WatchDirs[0].Path]
+	require.NotNil(t, folder.LastRun)
+	assert.Equal(t, 1, folder.LastRun.Fixed)
+}
+
+func TestEnforceAsync(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/enforce?async=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var j job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&j))
+	assert.Equal(t, "/api/v1/jobs/"+j.ID, rec.Header().Get("Location"))
+
+	assert.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+j.ID, nil))
+		var polled job
+		return rec.Code == http.StatusOK &&
+			json.NewDecoder(rec.Body).Decode(&polled) == nil &&
+			polled.Status == jobCompleted && polled.Fixed == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestJobNotFound(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestEnforceFolder(t *testing.T) {
+	s, file := newEnforceTestServer(t)
+	cfg := s.currentConfig().Clone()
+	cfg.WatchDirs[0].Name = "movies"
+	s.SetConfig(cfg)
+
+	sub := filepath.Join(cfg.WatchDirs[0].Path, "sub")
+	require.NoError(t, os.Mkdir(sub, 0700))
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{name: "unknown folder", target: "/api/v1/enforce/tv", wantCode: http.StatusNotFound},
+		{name: "escaping subpath", target: "/api/v1/enforce/movies/..%2F..%2Fetc", wantCode: http.StatusBadRequest},
+		{name: "subpath", target: "/api/v1/enforce/movies/sub", wantCode: http.StatusOK},
+		{name: "whole folder", target: "/api/v1/enforce/movies", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+
+	// The subpath run fixed only the subdirectory
+	info, err := os.Stat(sub)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestEnforceFolderSubpathResult(t *testing.T) {
+	s, file := newEnforceTestServer(t)
+	cfg := s.currentConfig().Clone()
+	cfg.WatchDirs[0].Name = "movies"
+	s.SetConfig(cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/enforce/movies/movie.mkv", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var j job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&j))
+	require.Len(t, j.Folders, 1)
+	assert.Equal(t, "movies", j.Folders[0].Name)
+	assert.Equal(t, file, j.Folders[0].Path)
+	assert.Equal(t, 1, j.Fixed)
+
+	// Partial runs do not replace the folder's last run
+	assert.Empty(t, s.tracker.Snapshot().Folders)
+}
+
+func TestResolveSubpath(t *testing.T) {
+	root := filepath.FromSlash("/data/media")
+
+	tests := []struct {
+		subpath string
+		want    string
+		wantOK  bool
+	}{
+		{"", root, true},
+		{"movies/a.mkv", filepath.Join(root, "movies", "a.mkv"), true},
+		{"movies/../tv", filepath.Join(root, "tv"), true},
+		{"..", "", false},
+		{"../media2", "", false},
+		{"../../etc/passwd", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := resolveSubpath(root, tt.subpath)
+		assert.Equal(t, tt.wantOK, ok, tt.subpath)
+		assert.Equal(t, tt.want, got, tt.subpath)
+	}
+}
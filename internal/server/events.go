@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventsHeartbeat is how often an idle event stream sends a keep-alive comment
+const eventsHeartbeat = 15 * time.Second
+
+// handleEvents streams enforcement activity as server-sent events
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	s.clearWriteDeadline(rc)
+
+	entries, unsubscribe := s.activity.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Announce the subscription so clients know the stream is live
+	if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		s.logger.Warn("Event stream does not support flushing", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-s.streamsCtx.Done():
+			// Streams never finish on their own, so end them on shutdown
+			// rather than holding up the drain
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(entry)
+			if err != nil {
+				s.logger.Error("Failed to encode activity entry", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", entry.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsStream(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	ts := httptest.NewServer(s.httpServer.Handler)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The stream reveals paths, so it requires authentication
+	resp, err := http.Get(ts.URL + "/api/v1/events")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Api-Key", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, resp.Body.Close())
+	}()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+
+	// Wait for the subscription before publishing
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": connected\n", line)
+
+	s.activity.Publish(activity.Entry{Type: activity.TypeFixed, Path: "/data/movie.mkv", NewMode: "-rw-r--r--"})
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	assert.Equal(t, "event: fixed", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "data: {"))
+	assert.Contains(t, lines[1], `"path":"/data/movie.mkv"`)
+}
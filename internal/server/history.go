@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/audit"
+)
+
+// historyResponse lists recorded changes, newest first
+type historyResponse struct {
+	Entries []activity.Entry `json:"entries"`
+}
+
+// handleHistory queries the audit log for changes to a ?path= or within a
+// ?folder=, optionally restricted to the ?since= and ?until= time range
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		writeError(w, http.StatusNotImplemented, "audit log is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit, ok := queryInt(w, query.Get("limit"), "limit", defaultPageSize)
+	if !ok {
+		return
+	}
+	if limit < 1 || limit > maxPageSize {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+		return
+	}
+
+	q := audit.Query{Limit: limit}
+	if path := query.Get("path"); path != "" {
+		if !filepath.IsAbs(path) {
+			writeError(w, http.StatusBadRequest, "path must be absolute")
+			return
+		}
+		q.Path = filepath.Clean(path)
+	}
+	if name := query.Get("folder"); name != "" {
+		watchDir, ok := s.lookupFolder(w, name)
+		if !ok {
+			return
+		}
+		q.Folder = watchDir.Path
+	}
+	if q.Since, ok = queryTime(w, query.Get("since"), "since"); !ok {
+		return
+	}
+	if q.Until, ok = queryTime(w, query.Get("until"), "until"); !ok {
+		return
+	}
+
+	entries, err := s.audit.Query(q)
+	if err != nil {
+		s.logger.Error("Failed to query audit log", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to query audit log")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, historyResponse{Entries: entries})
+}
+
+// queryTime parses an RFC 3339 time query parameter, writing an error
+// response if it is malformed
+func queryTime(w http.ResponseWriter, value, name string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+name+" parameter, expected RFC 3339 time")
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/audit"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyRequest performs an authenticated history request
+func historyRequest(s *Server, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history"+query, nil)
+	req.Header.Set("X-Api-Key", "secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	movie := filepath.Join(dir, "movie.mkv")
+	require.NoError(t, os.WriteFile(movie, []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.mkv"), []byte("x"), 0600))
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: dir, FileMode: "0644", DirMode: "0755"}}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	// Without an audit log there is no history
+	assert.Equal(t, http.StatusNotImplemented, historyRequest(s, "").Code)
+
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), log.New(os.Stderr))
+	require.NoError(t, err)
+	defer func() { _ = auditLog.Close() }()
+	s.activity.AddRecorder(auditLog)
+	s.audit = auditLog
+
+	s.enforcer.Fix(movie, "0644", false)
+	s.enforcer.Fix(filepath.Join(dir, "other.mkv"), "0644", false)
+
+	rec := historyRequest(s, "?folder=media&path="+url.QueryEscape(movie)+"&since=2000-01-01T00:00:00Z")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp historyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Len(t, resp.Entries, 1)
+	assert.Equal(t, movie, resp.Entries[0].Path)
+	assert.Equal(t, "-rw-------", resp.Entries[0].OldMode)
+	assert.Equal(t, "-rw-r--r--", resp.Entries[0].NewMode)
+
+	assert.Equal(t, http.StatusBadRequest, historyRequest(s, "?since=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, historyRequest(s, "?path=relative").Code)
+	assert.Equal(t, http.StatusNotFound, historyRequest(s, "?folder=missing").Code)
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// arrPayload is the part of a Sonarr or Radarr webhook payload that locates
+// imported files. Sonarr sends series and episode files, Radarr a movie and
+// its file.
+type arrPayload struct {
+	EventType string `json:"eventType"`
+	Series    *struct {
+		Path string `json:"path"`
+	} `json:"series"`
+	Movie *struct {
+		FolderPath string `json:"folderPath"`
+	} `json:"movie"`
+	EpisodeFile  *arrFile  `json:"episodeFile"`
+	EpisodeFiles []arrFile `json:"episodeFiles"`
+	MovieFile    *arrFile  `json:"movieFile"`
+}
+
+type arrFile struct {
+	Path string `json:"path"`
+}
+
+// arrImportEvent is the event type of On Import and On Upgrade
+const arrImportEvent = "Download"
+
+// handleArrHook enforces the files imported by Sonarr or Radarr right away,
+// instead of waiting for the watcher. Other events, including the test sent
+// when saving the connection, are acknowledged without doing anything.
+func (s *Server) handleArrHook(w http.ResponseWriter, r *http.Request) {
+	var payload arrPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook payload")
+		return
+	}
+
+	if payload.EventType != arrImportEvent {
+		s.logger.Debug("Ignoring webhook event", "event", payload.EventType)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	roots := payload.importRoots()
+	if len(roots) == 0 {
+		writeError(w, http.StatusBadRequest, "webhook payload has no file path")
+		return
+	}
+
+	var targets []enforceTarget
+	for _, root := range roots {
+		watchDir, err := s.currentConfig().WatchDirFor(root)
+		if err != nil {
+			s.logger.Warn("Imported file is not within a watch directory", "path", escape.Text(root))
+			continue
+		}
+		targets = append(targets, enforceTarget{watchDir: watchDir, root: root})
+	}
+	if len(targets) == 0 {
+		writeError(w, http.StatusUnprocessableEntity, "imported files are not within a watch directory")
+		return
+	}
+
+	s.logger.Info("Import webhook received", "user", identity(r), "paths", len(targets))
+	s.startJob(w, r, targets)
+}
+
+// importRoots returns the folders of the imported files, which may have
+// been created by the import and may hold extras such as subtitles. Without
+// file paths the series or movie folder is used.
+func (p arrPayload) importRoots() []string {
+	files := slices.Clone(p.EpisodeFiles)
+	for _, file := range []*arrFile{p.EpisodeFile, p.MovieFile} {
+		if file != nil {
+			files = append(files, *file)
+		}
+	}
+
+	var roots []string
+	for _, file := range files {
+		if file.Path == "" {
+			continue
+		}
+		if root := filepath.Dir(filepath.Clean(file.Path)); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) > 0 {
+		return roots
+	}
+
+	if p.Series != nil && p.Series.Path != "" {
+		return []string{filepath.Clean(p.Series.Path)}
+	}
+	if p.Movie != nil && p.Movie.FolderPath != "" {
+		return []string{filepath.Clean(p.Movie.FolderPath)}
+	}
+	return nil
+}
+
+// torrentPayload locates a completed download. qBittorrent passes the
+// content path (%F); Transmission passes the download directory and torrent
+// name (TR_TORRENT_DIR and TR_TORRENT_NAME).
+type torrentPayload struct {
+	Path string `json:"path"`
+	Dir  string `json:"dir"`
+	Name string `json:"name"`
+}
+
+// handleTorrentHook enforces a download as soon as the torrent client has
+// completed it, before Sonarr or Radarr import it. The payload is accepted
+// as JSON, a form or query parameters, whichever the client's script can
+// send most easily.
+func (s *Server) handleTorrentHook(w http.ResponseWriter, r *http.Request) {
+	var payload torrentPayload
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid webhook payload")
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid webhook payload")
+			return
+		}
+		payload = torrentPayload{Path: r.Form.Get("path"), Dir: r.Form.Get("dir"), Name: r.Form.Get("name")}
+	}
+
+	path := payload.Path
+	if path == "" && payload.Dir != "" && payload.Name != "" {
+		path = filepath.Join(payload.Dir, payload.Name)
+	}
+	if path == "" || !filepath.IsAbs(path) {
+		writeError(w, http.StatusBadRequest, "an absolute path, or dir and name, is required")
+		return
+	}
+	path = filepath.Clean(path)
+
+	watchDir, err := s.currentConfig().WatchDirFor(path)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "download is not within a watch directory")
+		return
+	}
+
+	s.logger.Info("Torrent completion hook received", "user", identity(r), "path", escape.Text(path))
+	s.startJob(w, r, []enforceTarget{{watchDir: watchDir, root: path}})
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func arrHookRequest(s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/hooks/arr?apikey=secret", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestArrHookImport(t *testing.T) {
+	s, file := newEnforceTestServer(t)
+	root := filepath.Dir(file)
+
+	// A season folder created by the import, next to an untouched file
+	season := filepath.Join(root, "Show", "Season 1")
+	require.NoError(t, os.MkdirAll(season, 0700))
+	episode := filepath.Join(season, "episode.mkv")
+	require.NoError(t, os.WriteFile(episode, []byte("x"), 0600))
+
+	rec := arrHookRequest(s, fmt.Sprintf(`{
+		"eventType": "Download",
+		"isUpgrade": false,
+		"series": {"path": %q},
+		"episodeFile": {"relativePath": "Season 1/episode.mkv", "path": %q}
+	}`, filepath.Join(root, "Show"), episode))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var j job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&j))
+	require.Len(t, j.Folders, 1)
+	assert.Equal(t, season, j.Folders[0].Path)
+	assert.Equal(t, 2, j.Fixed)
+
+	for path, mode := range map[string]os.FileMode{season: 0755, episode: 0644, file: 0600} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), path)
+	}
+}
+
+func TestArrHookIgnoresOtherEvents(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+
+	rec := arrHookRequest(s, `{"eventType": "Test", "movie": {"folderPath": "/movies/Test (2020)"}}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestArrHookRejects(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"invalid json", `{"eventType":`, http.StatusBadRequest},
+		{"no path", `{"eventType": "Download"}`, http.StatusBadRequest},
+		{"outside watch dirs", `{"eventType": "Download", "movieFile": {"path": "/elsewhere/movie.mkv"}}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, arrHookRequest(s, tt.body).Code)
+		})
+	}
+}
+
+func TestArrPayloadImportRoots(t *testing.T) {
+	var payload arrPayload
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"eventType": "Download",
+		"series": {"path": "/tv/Show"},
+		"episodeFiles": [{"path": "/tv/Show/Season 1/a.mkv"}, {"path": "/tv/Show/Season 1/b.mkv"}, {"path": "/tv/Show/Season 2/c.mkv"}]
+	}`), &payload))
+	assert.Equal(t, []string{"/tv/Show/Season 1", "/tv/Show/Season 2"}, payload.importRoots())
+
+	payload = arrPayload{}
+	require.NoError(t, json.Unmarshal([]byte(`{"eventType": "Download", "movie": {"folderPath": "/movies/Film (2020)/"}}`), &payload))
+	assert.Equal(t, []string{"/movies/Film (2020)"}, payload.importRoots())
+}
+
+func TestTorrentHook(t *testing.T) {
+	s, file := newEnforceTestServer(t)
+	root := filepath.Dir(file)
+
+	download := filepath.Join(root, "Release.2024")
+	require.NoError(t, os.Mkdir(download, 0700))
+	video := filepath.Join(download, "video.mkv")
+	require.NoError(t, os.WriteFile(video, []byte("x"), 0600))
+
+	send := func(target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// qBittorrent: the content path as a form
+	rec := send("/api/v1/hooks/torrent", "application/x-www-form-urlencoded", "path="+url.QueryEscape(download))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var j job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&j))
+	assert.Equal(t, 2, j.Fixed)
+
+	info, err := os.Stat(video)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "only the download is enforced")
+
+	// Transmission: directory and name as JSON or query parameters
+	rec = send("/api/v1/hooks/torrent", "application/json", fmt.Sprintf(`{"dir": %q, "name": "Release.2024"}`, root))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = send("/api/v1/hooks/torrent?dir="+url.QueryEscape(root)+"&name=movie.mkv", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, send("/api/v1/hooks/torrent", "application/json", `{"path": "relative/path"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("/api/v1/hooks/torrent", "application/json", `{"dir": "/downloads"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/api/v1/hooks/torrent", "application/json", `{"path": "/downloads/other"}`).Code)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// maxJobs is the number of jobs retained for lookup
+const maxJobs = 100
+
+// Job states
+const (
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobCancelled = "cancelled" // Stopped early by shutdown
+)
+
+// job describes an enforcement run triggered through the API
+type job struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Fixed    int         `json:"fixed"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Folders  []folderRun `json:"folders"`
+}
+
+// folderRun is the result of enforcing a single folder within a job
+type folderRun struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	runStatus
+}
+
+// jobStore keeps the most recent jobs in memory
+type jobStore struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string // Job IDs, oldest first
+}
+
+// newJobStore creates an empty job store
+func newJobStore() *jobStore {
+	return &jobStore{
+		jobs: make(map[string]*job),
+	}
+}
+
+// create registers a new running job and returns its ID
+func (s *jobStore) create() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newJobID()
+	s.jobs[id] = &job{
+		ID:      id,
+		Status:  jobRunning,
+		Started: time.Now(),
+		Folders: []folderRun{},
+	}
+	s.order = append(s.order, id)
+
+	// Evict the oldest jobs once over capacity
+	for len(s.order) > maxJobs {
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return id
+}
+
+// finish marks a job as completed, or cancelled if it stopped early, with
+// the given folder results
+func (s *jobStore) finish(id string, folders []folderRun, cancelled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+
+	finished := time.Now()
+	j.Status = jobCompleted
+	if cancelled {
+		j.Status = jobCancelled
+	}
+	j.Finished = &finished
+	j.Folders = folders
+	for _, folder := range folders {
+		j.Fixed += folder.Fixed
+		j.Skipped += folder.Skipped
+		j.Failed += folder.Failed
+	}
+}
+
+// get returns a copy of a job
+func (s *jobStore) get(id string) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// newJobID returns a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// logLevelBody is the request and response body of /api/v1/loglevel
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// handleGetLogLevel returns the current log level
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, logLevelBody{Level: s.currentConfig().LogLevel})
+}
+
+// handlePutLogLevel changes the log level without a restart, e.g. to debug
+// while reproducing an issue
+func (s *Server) handlePutLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.setLogLevel == nil {
+		writeError(w, http.StatusNotImplemented, "runtime log level changes are not supported")
+		return
+	}
+
+	var body logLevelBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Level == "" {
+		writeError(w, http.StatusBadRequest, `expected a JSON body such as {"level": "debug"}`)
+		return
+	}
+
+	if err := s.setLogLevel(body.Level); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	s.logger.Info("Log level changed via API", "log_level", body.Level, "user", identity(r))
+	writeJSON(w, http.StatusOK, logLevelBody{Level: s.currentConfig().LogLevel})
+}
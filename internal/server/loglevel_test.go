@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevel(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/loglevel", strings.NewReader(body))
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotImplemented, request(http.MethodPut, `{"level": "debug"}`).Code)
+
+	s.setLogLevel = func(level string) error {
+		if level != "debug" && level != "info" {
+			return errors.New("unknown log level: " + level)
+		}
+		updated := *s.currentConfig()
+		updated.LogLevel = level
+		s.SetConfig(&updated)
+		return nil
+	}
+
+	rec := request(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level": "info"}`, rec.Body.String())
+
+	rec = request(http.MethodPut, `{"level": "debug"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level": "debug"}`, rec.Body.String())
+	assert.Equal(t, "debug", s.currentConfig().LogLevel)
+
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPut, `debug`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request(http.MethodPut, `{"level": "verbose"}`).Code)
+}
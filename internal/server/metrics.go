@@ -0,0 +1,15 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/keksiqc/ownarr/internal/metrics"
+)
+
+// handleMetrics serves metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := metrics.Default.WriteTo(w); err != nil {
+		s.logger.Debug("Failed to write metrics", "error", err)
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP server limits
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 2 * time.Minute // Streams and tree walks clear their own deadline
+	idleTimeout       = 2 * time.Minute
+	maxHeaderBytes    = 64 << 10
+	maxBodySize       = 1 << 20
+)
+
+// Per-client request rate limits
+const (
+	rateLimitPerSecond = 10.0
+	rateLimitBurst     = 30
+	rateClientTTL      = 5 * time.Minute // Idle clients are forgotten after this long
+)
+
+// middleware wraps a handler with additional behavior
+type middleware func(http.Handler) http.Handler
+
+// chain applies middlewares so that the first one runs first
+func chain(h http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// securityHeaders sets headers that harden API responses against misuse by
+// browsers
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody caps the size of request bodies
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit rejects clients that exceed the request rate
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := s.limiter.allow(s.clientIP(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clearWriteDeadline exempts a response from the server's write timeout, for
+// streams and for authenticated requests that walk whole directory trees
+func (s *Server) clearWriteDeadline(rc *http.ResponseController) {
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.logger.Warn("Failed to clear write deadline", "error", err)
+	}
+}
+
+// clientIP returns the address of the client. Behind a trusted proxy it is
+// the nearest untrusted address in X-Forwarded-For.
+func (s *Server) clientIP(r *http.Request) string {
+	host := remoteHost(r)
+
+	proxyAuth := s.currentConfig().Server.ProxyAuth
+	if !proxyAuth.Enabled() || !fromTrustedProxy(proxyAuth, r) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		host = addr.String()
+		if !proxyAuth.Trusts(addr) {
+			break
+		}
+	}
+	return host
+}
+
+// remoteHost returns the address of the connecting peer
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter is a token bucket per client
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	clients   map[string]*bucket
+	lastPrune time.Time
+}
+
+// bucket holds the tokens available to a client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing rate requests per second with
+// bursts of up to burst requests
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token for the client, returning how long to wait before
+// retrying if none is available
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// prune forgets clients that have been idle long enough to have a full bucket
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateClientTTL {
+		return
+	}
+	l.lastPrune = now
+
+	for client, b := range l.clients {
+		if now.Sub(b.last) > rateClientTTL {
+			delete(l.clients, client)
+		}
+	}
+}
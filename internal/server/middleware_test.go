@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"), "HSTS is only sent over TLS")
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range rateLimitBurst {
+		require.Equal(t, http.StatusOK, request("192.0.2.1:1234").Code)
+	}
+
+	rec := request("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Other clients are unaffected
+	assert.Equal(t, http.StatusOK, request("192.0.2.2:1234").Code)
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(2, 1)
+	now := time.Now()
+
+	_, ok := l.allow("client", now)
+	assert.True(t, ok)
+
+	wait, ok := l.allow("client", now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	_, ok = l.allow("client", now.Add(wait))
+	assert.True(t, ok)
+
+	// Idle clients are forgotten
+	l.allow("other", now.Add(2*rateClientTTL))
+	assert.NotContains(t, l.clients, "client")
+}
+
+func TestBodyLimit(t *testing.T) {
+	s := newConfigTestServer(t)
+
+	body := "log_level: info\n# " + strings.Repeat("x", maxBodySize) + "\n"
+	rec := configRequest(s, http.MethodPut, body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestServerTimeouts(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	assert.NotZero(t, s.httpServer.ReadHeaderTimeout)
+	assert.NotZero(t, s.httpServer.ReadTimeout)
+	assert.NotZero(t, s.httpServer.WriteTimeout)
+	assert.NotZero(t, s.httpServer.IdleTimeout)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&spec))
+
+	for _, rt := range s.routes() {
+		// OpenAPI has no wildcard segments, so {path...} is documented as {path}
+		path := strings.ReplaceAll(rt.pattern, "...}", "}")
+		operations, ok := spec.Paths[path]
+		if assert.True(t, ok, "route %s missing from openapi.json", rt.pattern) {
+			assert.Contains(t, operations, strings.ToLower(rt.method), "route %s %s", rt.method, rt.pattern)
+		}
+	}
+}
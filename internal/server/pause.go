@@ -0,0 +1,51 @@
+package server
+
+import "net/http"
+
+// pauseResponse reports whether enforcement is paused
+type pauseResponse struct {
+	Folder string `json:"folder,omitempty"`
+	Paused bool   `json:"paused"`
+}
+
+// handlePause stops enforcement from changing permissions. Watching, checks
+// and statistics continue, so other tools can manage permissions meanwhile.
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.enforcer.Pause()
+	s.logger.Warn("Enforcement paused via API", "user", identity(r))
+	writeJSON(w, http.StatusOK, pauseResponse{Paused: true})
+}
+
+// handleResume lets enforcement change permissions again. Paths left
+// unchanged while paused are fixed by their next event or poll.
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.enforcer.Resume()
+	s.logger.Info("Enforcement resumed via API", "user", identity(r))
+	writeJSON(w, http.StatusOK, pauseResponse{Paused: false})
+}
+
+// handlePauseFolder stops enforcement for a single watch directory while
+// the others keep being enforced
+func (s *Server) handlePauseFolder(w http.ResponseWriter, r *http.Request) {
+	watchDir, ok := s.lookupFolder(w, r.PathValue("folder"))
+	if !ok {
+		return
+	}
+
+	s.enforcer.PauseFolder(watchDir.Name)
+	s.logger.Warn("Enforcement paused via API", "folder", watchDir.Name, "user", identity(r))
+	writeJSON(w, http.StatusOK, pauseResponse{Folder: watchDir.Name, Paused: s.enforcer.FolderPaused(watchDir)})
+}
+
+// handleResumeFolder resumes enforcement for a single watch directory. The
+// folder stays paused while enforcement is paused as a whole.
+func (s *Server) handleResumeFolder(w http.ResponseWriter, r *http.Request) {
+	watchDir, ok := s.lookupFolder(w, r.PathValue("folder"))
+	if !ok {
+		return
+	}
+
+	s.enforcer.ResumeFolder(watchDir.Name)
+	s.logger.Info("Enforcement resumed via API", "folder", watchDir.Name, "user", identity(r))
+	writeJSON(w, http.StatusOK, pauseResponse{Folder: watchDir.Name, Paused: s.enforcer.FolderPaused(watchDir)})
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/audit"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/watcher"
+)
+
+// Dependencies are the application components exposed over HTTP
+type Dependencies struct {
+	Enforcer *enforcer.Enforcer
+	Activity *activity.Hub
+	Tracker  *status.Tracker
+	Audit    *audit.Log // Optional, enables the history endpoint
+
+	// ApplyConfig validates and applies a new configuration at runtime
+	ApplyConfig func(*config.Config) error
+
+	// ReloadConfig reloads and applies the configuration file
+	ReloadConfig func() error
+
+	// SetLogLevel changes the log level at runtime
+	SetLogLevel func(level string) error
+
+	// Watches lists the directories registered with the file system watcher
+	Watches func() []watcher.Watch
+
+	// Budget reports the watches and open files in use against their limits
+	Budget func() watcher.Budget
+}
+
+// Server serves the HTTP API
+type Server struct {
+	logger       *log.Logger
+	accessLogger *log.Logger
+	enforcer     *enforcer.Enforcer
+	activity     *activity.Hub
+	tracker      *status.Tracker
+	audit        *audit.Log
+	applyConfig  func(*config.Config) error
+	reloadConfig func() error
+	setLogLevel  func(string) error
+	watches      func() []watcher.Watch
+	budget       func() watcher.Budget
+	jobs         *jobStore
+	limiter      *rateLimiter
+	httpServer   *http.Server
+
+	jobsCtx  context.Context    // Cancelled when running jobs must stop
+	stopJobs context.CancelFunc // Cancels jobsCtx
+	jobsMu   sync.Mutex         // Guards draining and additions to jobsWG
+	jobsWG   sync.WaitGroup     // Background jobs in progress
+	draining bool               // Set once no new jobs may start
+
+	streamsCtx   context.Context    // Cancelled to end event streams on shutdown
+	closeStreams context.CancelFunc // Cancels streamsCtx
+
+	config config.Snapshot // Current configuration, replaced on reload
+}
+
+// New creates a new HTTP server
+func New(cfg *config.Config, deps Dependencies, logger *log.Logger) *Server {
+	s := &Server{
+		logger:       logger,
+		accessLogger: newAccessLogger(),
+		enforcer:     deps.Enforcer,
+		activity:     deps.Activity,
+		tracker:      deps.Tracker,
+		audit:        deps.Audit,
+		applyConfig:  deps.ApplyConfig,
+		reloadConfig: deps.ReloadConfig,
+		setLogLevel:  deps.SetLogLevel,
+		watches:      deps.Watches,
+		budget:       deps.Budget,
+		jobs:         newJobStore(),
+		limiter:      newRateLimiter(rateLimitPerSecond, rateLimitBurst),
+	}
+	s.config.Store(cfg)
+	s.jobsCtx, s.stopJobs = context.WithCancel(context.Background())
+	s.streamsCtx, s.closeStreams = context.WithCancel(context.Background())
+
+	mux := http.NewServeMux()
+	for _, rt := range append(s.routes(), s.debugRoutes()...) {
+		mux.HandleFunc(rt.method+" "+rt.pattern, rt.handler)
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              cfg.Server.ListenAddress(),
+		Handler:           chain(mux, s.accessLog, s.trace, s.securityHeaders, s.rateLimit, limitBody),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ErrorLog:          s.logger.StandardLog(log.StandardLogOptions{ForceLevel: log.WarnLevel}),
+	}
+	s.httpServer.RegisterOnShutdown(s.closeStreams)
+
+	return s
+}
+
+// route is a single HTTP endpoint
+type route struct {
+	method  string
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes returns the endpoints served by the server. Keep openapi.json in
+// sync when changing them.
+func (s *Server) routes() []route {
+	return []route{
+		{http.MethodGet, "/healthz", s.handleHealth},
+		{http.MethodGet, "/readyz", s.handleReady},
+		{http.MethodGet, "/status", s.handleStatus},
+		{http.MethodGet, "/metrics", s.handleMetrics},
+		{http.MethodGet, "/api/openapi.json", s.handleOpenAPI},
+		{http.MethodPost, "/api/v1/enforce", s.requireAuth(s.handleEnforce)},
+		{http.MethodPost, "/api/v1/enforce/{folder}", s.requireAuth(s.handleEnforceFolder)},
+		{http.MethodPost, "/api/v1/enforce/{folder}/{path...}", s.requireAuth(s.handleEnforceFolder)},
+		{http.MethodPost, "/api/v1/hooks/arr", s.requireAuth(s.handleArrHook)},
+		{http.MethodPost, "/api/v1/hooks/torrent", s.requireAuth(s.handleTorrentHook)},
+		{http.MethodGet, "/api/v1/dryrun", s.requireAuth(s.handleDryRun)},
+		{http.MethodPost, "/api/v1/pause", s.requireAuth(s.handlePause)},
+		{http.MethodPost, "/api/v1/resume", s.requireAuth(s.handleResume)},
+		{http.MethodPost, "/api/v1/pause/{folder}", s.requireAuth(s.handlePauseFolder)},
+		{http.MethodPost, "/api/v1/resume/{folder}", s.requireAuth(s.handleResumeFolder)},
+		{http.MethodGet, "/api/v1/history", s.requireAuth(s.handleHistory)},
+		{http.MethodGet, "/api/v1/watches", s.requireAuth(s.handleWatches)},
+		{http.MethodGet, "/api/v1/jobs/{id}", s.handleJob},
+		{http.MethodGet, "/api/v1/events", s.requireAuth(s.handleEvents)},
+		{http.MethodGet, "/api/v1/config", s.requireAuth(s.handleGetConfig)},
+		{http.MethodPut, "/api/v1/config", s.requireAuth(s.handlePutConfig)},
+		{http.MethodPost, "/api/v1/reload", s.requireAuth(s.handleReload)},
+		{http.MethodGet, "/api/v1/loglevel", s.handleGetLogLevel},
+		{http.MethodPut, "/api/v1/loglevel", s.requireAuth(s.handlePutLogLevel)},
+	}
+}
+
+// Start begins serving requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen(listenNetwork(s.httpServer.Addr), s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+	}
+
+	scheme := "http"
+	if s.currentConfig().Server.TLS.Enabled() {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			_ = listener.Close()
+			return err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+		scheme = "https"
+	}
+
+	// The socket serves local clients such as the healthcheck command
+	var socketListener net.Listener
+	if socket := s.currentConfig().Server.Socket; socket != "" {
+		if socketListener, err = listenUnix(socket); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	}
+
+	s.serve(listener)
+	s.logger.Info("Started HTTP server", "address", listener.Addr().String(), "scheme", scheme)
+
+	if socketListener != nil {
+		s.serve(socketListener)
+		s.logger.Info("Started HTTP server", "socket", socketListener.Addr().String())
+	}
+
+	return nil
+}
+
+// serve accepts requests on listener in the background
+func (s *Server) serve(listener net.Listener) {
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("HTTP server error", "error", err)
+		}
+	}()
+}
+
+// listenNetwork picks the network for a listen address. An IPv4 or IPv6
+// address restricts the listener to that protocol, so "0.0.0.0" and "::"
+// listen on IPv4 or IPv6 only while an empty host listens on both.
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+
+	ip, err := netip.ParseAddr(host)
+	switch {
+	case err != nil:
+		return "tcp"
+	case ip.Is4():
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// listenUnix listens on a unix socket, replacing a stale socket left behind
+// by a previous run
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// SetConfig replaces the configuration used by the server after a reload.
+// Listener settings only take effect on restart.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.config.Store(cfg)
+}
+
+// currentConfig returns the configuration in effect
+func (s *Server) currentConfig() *config.Config {
+	return s.config.Load()
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// handleHealth reports that the process is alive
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// writeJSON writes v as a JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer creates a server with quiet logging
+func newTestServer(cfg *config.Config, tracker *status.Tracker) *Server {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+	hub := activity.NewHub()
+	return New(cfg, Dependencies{
+		Enforcer: enforcer.New(hub, logger),
+		Activity: hub,
+		Tracker:  tracker,
+	}, logger)
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestStatus(t *testing.T) {
+	existing := t.TempDir()
+	missing := filepath.Join(existing, "missing")
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{
+		{Path: existing, FileMode: "0644", DirMode: "0755"},
+		{Path: missing, FileMode: "0644", DirMode: "0755"},
+	}
+
+	tracker := status.NewTracker("1.0.0")
+	tracker.RecordRun(existing, status.RunResult{Fixed: 3, Skipped: 10, Failed: 1})
+	tracker.ObserveScan(existing, status.Scan{Root: existing, Started: time.Now().Add(-10 * time.Second), Processed: 100, Total: 400}, false)
+
+	s := newTestServer(cfg, tracker)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp statusResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	assert.Equal(t, "1.0.0", resp.Version)
+	assert.Equal(t, 2, resp.Config.WatchDirs)
+	assert.True(t, resp.Degraded)
+	assert.Equal(t, []string{flagFolderMissing, flagEnforcementFailures}, resp.DegradedFlags)
+
+	require.Len(t, resp.Folders, 2)
+	assert.True(t, resp.Folders[0].Exists)
+	require.NotNil(t, resp.Folders[0].LastRun)
+	assert.Equal(t, 3, resp.Folders[0].LastRun.Fixed)
+	assert.Equal(t, []string{flagEnforcementFailures}, resp.Folders[0].DegradedFlags)
+	require.NotNil(t, resp.Folders[0].Scan)
+	assert.Equal(t, 100, resp.Folders[0].Scan.Processed)
+	assert.InDelta(t, 10, resp.Folders[0].Scan.RatePerSecond, 0.5)
+	require.NotNil(t, resp.Folders[0].Scan.ETASeconds)
+	assert.InDelta(t, 30, *resp.Folders[0].Scan.ETASeconds, 2)
+	assert.False(t, resp.Folders[1].Exists)
+	assert.Nil(t, resp.Folders[1].LastRun)
+	assert.Nil(t, resp.Folders[1].Scan)
+	assert.Nil(t, resp.Budget, "no watcher")
+}
+
+func TestStatusBudget(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+	budget := watcher.Budget{Watches: 100, WatchLimit: 100, OpenFiles: 10, OpenFileLimit: 1024}
+	s.budget = func() watcher.Budget { return budget }
+
+	get := func() statusResponse {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		var resp statusResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := get()
+	require.NotNil(t, resp.Budget)
+	assert.Equal(t, budgetStatus{Watches: 100, WatchLimit: 100, OpenFiles: 10, OpenFileLimit: 1024}, *resp.Budget)
+	assert.False(t, resp.Degraded)
+
+	budget.Unwatched, budget.OpenFiles = 5, 1000
+	resp = get()
+	assert.True(t, resp.Degraded)
+	assert.Equal(t, []string{flagWatchLimit, flagOpenFiles}, resp.DegradedFlags)
+	assert.Contains(t, resp.DegradedReasons[flagWatchLimit], "5 directories")
+	assert.Equal(t, "1000 of 1024 files open", resp.DegradedReasons[flagOpenFiles])
+}
+
+func TestHealthIsOpen(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestReady(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "media", Path: t.TempDir()}}
+	tracker := status.NewTracker("test")
+	s := newTestServer(cfg, tracker)
+
+	ready := func() (int, readyResponse) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp readyResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return rec.Code, resp
+	}
+
+	code, resp := ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"not started"}, resp.Reasons)
+
+	tracker.SetReady(true)
+	code, resp = ready()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", resp.Status)
+
+	cfg.WatchDirs[0].Path = filepath.Join(cfg.WatchDirs[0].Path, "missing")
+	code, resp = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"folder_missing: media"}, resp.Reasons)
+
+	// Remote folders are not looked up locally
+	cfg.WatchDirs[0].Remote = config.Remote{Host: "nas"}
+	code, _ = ready()
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestStartUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ownarr.sock")
+
+	cfg := config.DefaultConfig()
+	cfg.Server.Bind = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Server.Socket = socket
+	s := newTestServer(cfg, status.NewTracker("test"))
+	require.NoError(t, s.Start())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://ownarr/healthz")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, s.Close())
+	assert.NoFileExists(t, socket, "socket is removed on close")
+}
+
+func TestListenNetwork(t *testing.T) {
+	assert.Equal(t, "tcp", listenNetwork(":8080"))
+	assert.Equal(t, "tcp", listenNetwork("nas.lan:8080"))
+	assert.Equal(t, "tcp4", listenNetwork("0.0.0.0:8080"))
+	assert.Equal(t, "tcp4", listenNetwork("192.168.1.10:8080"))
+	assert.Equal(t, "tcp6", listenNetwork("[::]:8080"))
+	assert.Equal(t, "tcp6", listenNetwork("[::1]:8080"))
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), "# TYPE ownarr_scan_duration_seconds histogram")
+	assert.Contains(t, rec.Body.String(), "ownarr_goroutines ")
+}
+
+func TestPauseResume(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	paused := func() bool {
+		var resp statusResponse
+		require.NoError(t, json.NewDecoder(request(http.MethodGet, "/status").Body).Decode(&resp))
+		return resp.Paused
+	}
+
+	rec := request(http.MethodPost, "/api/v1/pause")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"paused": true}`, rec.Body.String())
+	assert.True(t, s.enforcer.Paused())
+	assert.True(t, paused())
+
+	rec = request(http.MethodPost, "/api/v1/resume")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"paused": false}`, rec.Body.String())
+	assert.False(t, paused())
+}
+
+func TestPauseFolder(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{
+		{Name: "movies", Path: t.TempDir()},
+		{Name: "tv", Path: t.TempDir(), Paused: true},
+	}
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	paused := func() []bool {
+		var resp statusResponse
+		require.NoError(t, json.NewDecoder(request(http.MethodGet, "/status").Body).Decode(&resp))
+		var result []bool
+		for _, folder := range resp.Folders {
+			result = append(result, folder.Paused)
+		}
+		return result
+	}
+
+	assert.Equal(t, []bool{false, true}, paused(), "tv is paused by its configuration")
+
+	rec := request(http.MethodPost, "/api/v1/pause/movies")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"folder": "movies", "paused": true}`, rec.Body.String())
+
+	rec = request(http.MethodPost, "/api/v1/resume/tv")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []bool{true, false}, paused())
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/v1/pause/missing").Code)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+)
+
+// Shutdown stops accepting requests and waits for in-flight requests and
+// background enforcement jobs to finish. Once ctx expires, running jobs are
+// cancelled after the file they are working on and remaining connections
+// are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+
+	// No new jobs can start once requests have drained
+	s.jobsMu.Lock()
+	s.draining = true
+	s.jobsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.jobsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	if ctx.Err() == nil {
+		s.stopJobs()
+		return err
+	}
+
+	s.logger.Warn("HTTP server drain timed out, cancelling running jobs")
+	s.stopJobs()
+	_ = s.httpServer.Close()
+	<-done
+	return fmt.Errorf("failed to drain HTTP server: %w", ctx.Err())
+}
+
+// Close stops the server immediately, cancelling running jobs
+func (s *Server) Close() error {
+	s.stopJobs()
+	return s.httpServer.Close()
+}
+
+// goJob runs fn in the background with a context that keeps the values of
+// parent and is cancelled when the server shuts down. It reports false
+// without running fn once the server is shutting down.
+func (s *Server) goJob(parent context.Context, fn func(context.Context)) bool {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	if s.draining {
+		return false
+	}
+
+	ctx, cancel := s.jobContext(parent)
+	s.jobsWG.Add(1)
+	go func() {
+		defer s.jobsWG.Done()
+		defer cancel()
+		fn(ctx)
+	}()
+	return true
+}
+
+// jobContext derives a context from parent that is also cancelled when the
+// server gives up draining
+func (s *Server) jobContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(s.jobsCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
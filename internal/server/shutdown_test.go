@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownWaitsForJobs(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	var finished atomic.Bool
+	require.True(t, s.goJob(context.Background(), func(ctx context.Context) {
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(ctx.Err() == nil)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	assert.True(t, finished.Load(), "job completes before shutdown returns")
+
+	assert.False(t, s.goJob(context.Background(), func(context.Context) {}), "no jobs start after shutdown")
+}
+
+func TestShutdownCancelsJobs(t *testing.T) {
+	s := newTestServer(config.DefaultConfig(), status.NewTracker("test"))
+
+	var cancelled atomic.Bool
+	require.True(t, s.goJob(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled.Store(true)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	assert.True(t, cancelled.Load(), "job is cancelled and waited for")
+}
+
+func TestEnforceAsyncDuringShutdown(t *testing.T) {
+	s, _ := newEnforceTestServer(t)
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/enforce?async=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestShutdownEndsEventStreams(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ownarr.sock")
+
+	cfg := config.DefaultConfig()
+	cfg.Server.Bind = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Server.Socket = socket
+	cfg.Server.APIKey = "secret"
+	s := newTestServer(cfg, status.NewTracker("test"))
+	require.NoError(t, s.Start())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://ownarr/api/v1/events?apikey=secret")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": connected\n", line)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx), "open streams do not hold up the drain")
+}
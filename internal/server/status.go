@@ -0,0 +1,265 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// Folder-level degraded conditions
+const (
+	flagFolderMissing       = "folder_missing"
+	flagEnforcementFailures = "enforcement_failures"
+)
+
+// Degraded conditions of the resource budget
+const (
+	flagWatchLimit = "watch_limit" // Some directories are only polled
+	flagOpenFiles  = "open_files"  // Nearly every file descriptor is in use
+)
+
+// openFilesHigh is the share of the open file limit in use that degrades
+// the status
+const openFilesHigh = 0.9
+
+// statusResponse is the body returned by /status
+type statusResponse struct {
+	Version         string            `json:"version"`
+	Started         time.Time         `json:"started"`
+	UptimeSeconds   int64             `json:"uptime_seconds"`
+	Paused          bool              `json:"paused"`
+	DryRun          bool              `json:"dry_run"`
+	Degraded        bool              `json:"degraded"`
+	DegradedFlags   []string          `json:"degraded_flags"`
+	DegradedReasons map[string]string `json:"degraded_reasons,omitempty"`
+	Config          configSummary     `json:"config"`
+	Budget          *budgetStatus     `json:"budget,omitempty"`
+	Folders         []folderStatus    `json:"folders"`
+}
+
+// budgetStatus describes the watches and open files in use against their
+// limits, where 0 means unlimited or unknown
+type budgetStatus struct {
+	Watches       int `json:"watches"`
+	WatchLimit    int `json:"watch_limit"`
+	Unwatched     int `json:"unwatched"`
+	OpenFiles     int `json:"open_files"` // -1 if unknown
+	OpenFileLimit int `json:"open_file_limit"`
+}
+
+// configSummary describes the effective configuration
+type configSummary struct {
+	LogLevel     string `json:"log_level"`
+	PollInterval int    `json:"poll_interval"`
+	DebounceMS   int    `json:"debounce_ms"`
+	WatchDirs    int    `json:"watch_dirs"`
+}
+
+// folderStatus describes a single watch directory
+type folderStatus struct {
+	Name          string      `json:"name"`
+	Path          string      `json:"path"`
+	Recursive     bool        `json:"recursive"`
+	FileMode      string      `json:"file_mode"`
+	DirMode       string      `json:"dir_mode"`
+	Exists        bool        `json:"exists"`
+	Paused        bool        `json:"paused"`
+	LastEvent     *time.Time  `json:"last_event"`
+	LastRun       *runStatus  `json:"last_run"`
+	Scan          *scanStatus `json:"scan"`
+	DegradedFlags []string    `json:"degraded_flags"`
+}
+
+// runStatus describes the result of the last enforcement run of a folder
+type runStatus struct {
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"duration_ms"`
+	Fixed      int       `json:"fixed"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+}
+
+// scanStatus describes an enforcement run of a folder in flight
+type scanStatus struct {
+	Root          string    `json:"root"`
+	Started       time.Time `json:"started"`
+	Processed     int       `json:"processed"`
+	Fixed         int       `json:"fixed"`
+	Failed        int       `json:"failed"`
+	Total         int       `json:"total"`
+	RatePerSecond float64   `json:"rate_per_second"`
+	ETASeconds    *int64    `json:"eta_seconds"`
+}
+
+// handleStatus reports uptime, configuration and per-folder enforcement state
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.currentConfig()
+	snapshot := s.tracker.Snapshot()
+
+	resp := statusResponse{
+		Version:         snapshot.Version,
+		Started:         snapshot.Started,
+		UptimeSeconds:   int64(time.Since(snapshot.Started).Seconds()),
+		Paused:          s.enforcer.Paused(),
+		DryRun:          s.enforcer.DryRun(),
+		DegradedFlags:   snapshot.DegradedFlags(),
+		DegradedReasons: make(map[string]string, len(snapshot.Degraded)),
+		Config: configSummary{
+			LogLevel:     cfg.LogLevel,
+			PollInterval: cfg.PollInterval,
+			DebounceMS:   cfg.Debounce,
+			WatchDirs:    len(cfg.WatchDirs),
+		},
+		Folders: make([]folderStatus, 0, len(cfg.WatchDirs)),
+	}
+
+	for flag, degraded := range snapshot.Degraded {
+		resp.DegradedReasons[flag] = degraded.Reason
+	}
+
+	if s.budget != nil {
+		resp.Budget = s.budgetStatus(&resp)
+	}
+
+	folderFlags := make(map[string]bool)
+	for _, watchDir := range cfg.WatchDirs {
+		folder := folderStatus{
+			Name:          watchDir.Name,
+			Path:          watchDir.Path,
+			Recursive:     watchDir.Recursive,
+			FileMode:      watchDir.FileMode,
+			DirMode:       watchDir.DirMode,
+			Paused:        s.enforcer.FolderPaused(watchDir),
+			DegradedFlags: []string{},
+		}
+
+		if folderExists(watchDir) {
+			folder.Exists = true
+		} else {
+			folder.DegradedFlags = append(folder.DegradedFlags, flagFolderMissing)
+		}
+
+		if tracked, ok := snapshot.Folders[watchDir.Path]; ok {
+			if !tracked.LastEvent.IsZero() {
+				lastEvent := tracked.LastEvent
+				folder.LastEvent = &lastEvent
+			}
+			if tracked.LastRun != nil {
+				folder.LastRun = newRunStatus(*tracked.LastRun)
+				if tracked.LastRun.Failed > 0 {
+					folder.DegradedFlags = append(folder.DegradedFlags, flagEnforcementFailures)
+				}
+			}
+			if tracked.Scan != nil {
+				folder.Scan = newScanStatus(*tracked.Scan, time.Now())
+			}
+		}
+
+		for _, flag := range folder.DegradedFlags {
+			folderFlags[flag] = true
+		}
+		resp.Folders = append(resp.Folders, folder)
+	}
+
+	for _, flag := range []string{flagFolderMissing, flagEnforcementFailures} {
+		if folderFlags[flag] {
+			resp.DegradedFlags = append(resp.DegradedFlags, flag)
+		}
+	}
+	resp.Degraded = len(resp.DegradedFlags) > 0
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// budgetStatus reports the resource budget, flagging the status as degraded
+// if directories are only polled or file descriptors run out
+func (s *Server) budgetStatus(resp *statusResponse) *budgetStatus {
+	budget := s.budget()
+	if budget.Unwatched > 0 {
+		resp.DegradedFlags = append(resp.DegradedFlags, flagWatchLimit)
+		resp.DegradedReasons[flagWatchLimit] = fmt.Sprintf("%d directories are only polled as the watch limit was reached", budget.Unwatched)
+	}
+	if budget.OpenFileLimit > 0 && float64(budget.OpenFiles) >= openFilesHigh*float64(budget.OpenFileLimit) {
+		resp.DegradedFlags = append(resp.DegradedFlags, flagOpenFiles)
+		resp.DegradedReasons[flagOpenFiles] = fmt.Sprintf("%d of %d files open", budget.OpenFiles, budget.OpenFileLimit)
+	}
+	return &budgetStatus{
+		Watches:       budget.Watches,
+		WatchLimit:    budget.WatchLimit,
+		Unwatched:     budget.Unwatched,
+		OpenFiles:     budget.OpenFiles,
+		OpenFileLimit: budget.OpenFileLimit,
+	}
+}
+
+// readyResponse is the body returned by /readyz
+type readyResponse struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// folderExists reports whether the path of watchDir is a directory. Remote
+// folders are taken to exist rather than reaching their host for every
+// request; their polls fail while it is unreachable.
+func folderExists(watchDir config.WatchDir) bool {
+	if watchDir.Remote.Enabled() {
+		return true
+	}
+	info, err := os.Stat(watchDir.Path)
+	return err == nil && info.IsDir()
+}
+
+// handleReady reports whether the application is enforcing permissions on
+// all watch directories. It answers 503 while starting, shutting down, or
+// while a watch directory is missing.
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	var reasons []string
+	if !s.tracker.Snapshot().Ready {
+		reasons = append(reasons, "not started")
+	}
+	for _, watchDir := range s.currentConfig().WatchDirs {
+		if !folderExists(watchDir) {
+			reasons = append(reasons, flagFolderMissing+": "+watchDir.Name)
+		}
+	}
+
+	if len(reasons) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "not ready", Reasons: reasons})
+		return
+	}
+	writeJSON(w, http.StatusOK, readyResponse{Status: "ready"})
+}
+
+// newRunStatus converts a tracked run result for the response
+func newRunStatus(result status.RunResult) *runStatus {
+	return &runStatus{
+		Started:    result.Started,
+		DurationMS: result.Duration.Milliseconds(),
+		Fixed:      result.Fixed,
+		Skipped:    result.Skipped,
+		Failed:     result.Failed,
+	}
+}
+
+// newScanStatus converts a tracked scan for the response
+func newScanStatus(scan status.Scan, now time.Time) *scanStatus {
+	resp := &scanStatus{
+		Root:          scan.Root,
+		Started:       scan.Started,
+		Processed:     scan.Processed,
+		Fixed:         scan.Fixed,
+		Failed:        scan.Failed,
+		Total:         scan.Total,
+		RatePerSecond: math.Round(scan.Rate(now)*10) / 10,
+	}
+	if eta := scan.ETA(now); eta > 0 {
+		seconds := int64(eta.Seconds())
+		resp.ETASeconds = &seconds
+	}
+	return resp
+}
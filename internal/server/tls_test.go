@@ -0,0 +1,40 @@
+package server
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	cert, err := generateSelfSigned([]string{"localhost", "127.0.0.1", "nas.local"})
+	require.NoError(t, err)
+	require.Len(t, cert.Certificate, 1)
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"localhost", "nas.local"}, parsed.DNSNames)
+	require.Len(t, parsed.IPAddresses, 1)
+	assert.True(t, parsed.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	assert.NoError(t, parsed.VerifyHostname("nas.local"))
+}
+
+func TestTLSConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.TLS.SelfSigned = true
+	s := newTestServer(cfg, status.NewTracker("test"))
+
+	tlsConfig, err := s.tlsConfig()
+	require.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	cfg.Server.TLS = config.TLS{CertFile: "missing.pem", KeyFile: "missing.key"}
+	_, err = s.tlsConfig()
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/keksiqc/ownarr/internal/tracing"
+)
+
+// trace records a span for each request, continuing the caller's trace when
+// it sends a traceparent header
+func (s *Server) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.ContextWithRemoteParent(r.Context(), r.Header.Get("Traceparent"))
+		ctx, span := tracing.Start(ctx, "HTTP "+r.Method,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+		)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.Finish()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		// The mux sets the matched pattern on the request it was given
+		if r.Pattern != "" {
+			span.Name = r.Pattern
+			span.SetAttributes(tracing.String("http.route", r.Pattern))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(rec.status)))
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package server
+
+import "net/http"
+
+// watchesResponse lists the directories known to the file system watcher
+type watchesResponse struct {
+	Watched int           `json:"watched"`
+	Failed  int           `json:"failed"`
+	Watches []watchStatus `json:"watches"`
+}
+
+// watchStatus describes a single watched directory
+type watchStatus struct {
+	Path   string `json:"path"`
+	Folder string `json:"folder"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleWatches lists every directory registered with the file system
+// watcher and every directory that could not be registered, optionally
+// only those of the watch directory selected with ?folder=
+func (s *Server) handleWatches(w http.ResponseWriter, r *http.Request) {
+	if s.watches == nil {
+		writeError(w, http.StatusNotImplemented, "watch listing is not supported")
+		return
+	}
+
+	folder := r.URL.Query().Get("folder")
+	if folder != "" {
+		if _, ok := s.lookupFolder(w, folder); !ok {
+			return
+		}
+	}
+
+	resp := watchesResponse{Watches: []watchStatus{}}
+	for _, watch := range s.watches() {
+		if folder != "" && watch.Folder != folder {
+			continue
+		}
+
+		if watch.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Watched++
+		}
+		resp.Watches = append(resp.Watches, watchStatus{
+			Path:   watch.Path,
+			Folder: watch.Folder,
+			Error:  watch.Error,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
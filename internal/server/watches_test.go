@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/keksiqc/ownarr/internal/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatches(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{
+		{Name: "tv", Path: "/media/tv"},
+		{Name: "movies", Path: "/media/movies"},
+	}
+	s := newTestServer(cfg, status.NewTracker("test"))
+	s.watches = func() []watcher.Watch {
+		return []watcher.Watch{
+			{Path: "/media/movies", Folder: "movies"},
+			{Path: "/media/tv", Folder: "tv"},
+			{Path: "/media/tv/Show", Folder: "tv", Error: "no space left on device"},
+		}
+	}
+
+	request := func(query string) watchesResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/watches"+query, nil)
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp watchesResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := request("")
+	assert.Equal(t, 2, resp.Watched)
+	assert.Equal(t, 1, resp.Failed)
+	assert.Len(t, resp.Watches, 3)
+
+	resp = request("?folder=tv")
+	assert.Equal(t, []watchStatus{
+		{Path: "/media/tv", Folder: "tv"},
+		{Path: "/media/tv/Show", Folder: "tv", Error: "no space left on device"},
+	}, resp.Watches)
+}
@@ -0,0 +1,142 @@
+package status
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+const (
+	// scanReportInterval is how often observers are told about a scan in flight
+	scanReportInterval = time.Second
+
+	// scanLogInterval is how often a scan in flight is logged, so long scans
+	// do not look hung
+	scanLogInterval = 30 * time.Second
+)
+
+// Scan is the progress of an enforcement run in flight
+type Scan struct {
+	Root      string // Directory being scanned, the folder or a path within it
+	Started   time.Time
+	Processed int // Paths checked so far
+	Fixed     int
+	Failed    int
+	Total     int // Paths expected, 0 while unknown
+}
+
+// Rate returns the paths processed per second
+func (s Scan) Rate(now time.Time) float64 {
+	elapsed := now.Sub(s.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Processed) / elapsed
+}
+
+// ETA estimates the time left, or returns 0 while the total or the rate is
+// unknown
+func (s Scan) ETA(now time.Time) time.Duration {
+	rate := s.Rate(now)
+	if s.Total == 0 || rate == 0 {
+		return 0
+	}
+	left := max(s.Total-s.Processed, 0)
+	return time.Duration(float64(left) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// ScanObserver is told about the progress of a scan about once per second,
+// and once more when it ends
+type ScanObserver interface {
+	ObserveScan(folder string, scan Scan, done bool)
+}
+
+// ScanProgress follows a single scan, reporting it to observers and logging
+// it while it runs. It is safe for concurrent use.
+type ScanProgress struct {
+	folder    string
+	logger    *log.Logger
+	observers []ScanObserver
+
+	mu       sync.Mutex
+	scan     Scan
+	reported time.Time // Last report to observers
+	logged   time.Time // Last log entry
+}
+
+// NewScanProgress starts following a scan of root in the watch directory at
+// folder
+func NewScanProgress(folder, root string, logger *log.Logger, observers []ScanObserver) *ScanProgress {
+	now := time.Now()
+	p := &ScanProgress{
+		folder:    folder,
+		logger:    logger,
+		observers: observers,
+		scan:      Scan{Root: root, Started: now},
+		reported:  now,
+		logged:    now,
+	}
+	p.report(p.scan, false)
+	return p
+}
+
+// SetTotal sets the number of paths the scan is expected to process
+func (p *ScanProgress) SetTotal(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scan.Total = total
+}
+
+// Update records the outcomes so far, reporting them if they are due
+func (p *ScanProgress) Update(result RunResult) {
+	now := time.Now()
+
+	p.mu.Lock()
+	p.scan.Processed = result.Fixed + result.Skipped + result.Failed
+	p.scan.Fixed = result.Fixed
+	p.scan.Failed = result.Failed
+	scan := p.scan
+
+	due := now.Sub(p.reported) >= scanReportInterval
+	if due {
+		p.reported = now
+	}
+	logDue := now.Sub(p.logged) >= scanLogInterval
+	if logDue {
+		p.logged = now
+	}
+	p.mu.Unlock()
+
+	if logDue {
+		attrs := []any{"path", escape.Text(scan.Root), "processed", scan.Processed, "fixed", scan.Fixed, "failed", scan.Failed, "rate", int(scan.Rate(now))}
+		if scan.Total > 0 {
+			attrs = append(attrs, "total", scan.Total, "eta", scan.ETA(now))
+		}
+		p.logger.Info("Scan in progress", attrs...)
+	}
+	if due {
+		p.report(scan, false)
+	}
+}
+
+// Finish records the final outcomes and tells observers the scan has ended
+func (p *ScanProgress) Finish(result RunResult) {
+	p.mu.Lock()
+	p.scan.Processed = result.Fixed + result.Skipped + result.Failed
+	p.scan.Fixed = result.Fixed
+	p.scan.Failed = result.Failed
+	scan := p.scan
+	p.mu.Unlock()
+
+	p.report(scan, true)
+}
+
+// report tells every observer about scan
+func (p *ScanProgress) report(scan Scan, done bool) {
+	for _, observer := range p.observers {
+		observer.ObserveScan(p.folder, scan, done)
+	}
+}
@@ -0,0 +1,77 @@
+package status
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanReport is a call of ObserveScan
+type scanReport struct {
+	folder string
+	scan   Scan
+	done   bool
+}
+
+// scanRecorder records scan reports
+type scanRecorder struct {
+	reports []scanReport
+}
+
+func (r *scanRecorder) ObserveScan(folder string, scan Scan, done bool) {
+	r.reports = append(r.reports, scanReport{folder: folder, scan: scan, done: done})
+}
+
+func TestScanRateAndETA(t *testing.T) {
+	started := time.Now()
+	scan := Scan{Started: started, Processed: 100}
+
+	now := started.Add(10 * time.Second)
+	assert.InDelta(t, 10.0, scan.Rate(now), 0.001)
+	assert.Zero(t, scan.ETA(now), "unknown total")
+
+	scan.Total = 400
+	assert.Equal(t, 30*time.Second, scan.ETA(now))
+
+	assert.Zero(t, Scan{Started: started, Total: 10}.ETA(now), "no progress yet")
+}
+
+func TestScanProgress(t *testing.T) {
+	recorder := &scanRecorder{}
+	progress := NewScanProgress("/data/media", "/data/media/new", log.New(io.Discard), []ScanObserver{recorder})
+
+	// The start is reported, later updates at most once per interval
+	progress.SetTotal(10)
+	progress.Update(RunResult{Fixed: 1, Skipped: 2})
+	progress.Finish(RunResult{Fixed: 2, Skipped: 7, Failed: 1})
+
+	require.Len(t, recorder.reports, 2)
+	assert.Equal(t, "/data/media", recorder.reports[0].folder)
+	assert.Equal(t, "/data/media/new", recorder.reports[0].scan.Root)
+	assert.False(t, recorder.reports[0].done)
+
+	final := recorder.reports[1]
+	assert.True(t, final.done)
+	assert.Equal(t, Scan{Root: "/data/media/new", Started: final.scan.Started, Processed: 10, Fixed: 2, Failed: 1, Total: 10}, final.scan)
+}
+
+func TestTrackerObserveScan(t *testing.T) {
+	tracker := NewTracker("test")
+	first := Scan{Root: "/data/media", Started: time.Now(), Processed: 5}
+	second := Scan{Root: "/data/media/new", Started: time.Now().Add(time.Second)}
+
+	tracker.ObserveScan("/data/media", first, false)
+	tracker.ObserveScan("/data/media", second, false)
+	require.NotNil(t, tracker.Snapshot().Folders["/data/media"].Scan)
+
+	// Only the end of the reported scan clears it
+	tracker.ObserveScan("/data/media", first, true)
+	assert.Equal(t, second, *tracker.Snapshot().Folders["/data/media"].Scan)
+
+	tracker.ObserveScan("/data/media", second, true)
+	assert.Nil(t, tracker.Snapshot().Folders["/data/media"].Scan)
+}
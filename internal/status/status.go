@@ -0,0 +1,223 @@
+package status
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// degradedTTL is how long a degraded condition is reported after it was last seen
+const degradedTTL = 10 * time.Minute
+
+// RunResult summarizes a single enforcement run over a watch directory
+type RunResult struct {
+	Started  time.Time
+	Duration time.Duration
+	Fixed    int
+	Skipped  int
+	Failed   int
+}
+
+// Folder holds the runtime state of a single watch directory
+type Folder struct {
+	Path      string
+	LastRun   *RunResult
+	LastEvent time.Time
+	Scan      *Scan // Scan in flight, if any
+}
+
+// Degraded describes a condition that impairs enforcement
+type Degraded struct {
+	Reason   string
+	LastSeen time.Time
+}
+
+// Snapshot is a point-in-time copy of the tracked state
+type Snapshot struct {
+	Version  string
+	Started  time.Time
+	Ready    bool
+	Folders  map[string]Folder
+	Degraded map[string]Degraded
+}
+
+// RunObserver is told about every completed enforcement run
+type RunObserver interface {
+	ObserveRun(folder string, result RunResult)
+}
+
+// DegradedObserver is told when a degraded condition starts
+type DegradedObserver interface {
+	ObserveDegraded(flag, reason string)
+}
+
+// Tracker records runtime state for status reporting. It is safe for
+// concurrent use.
+type Tracker struct {
+	version string
+	started time.Time
+
+	mu       sync.RWMutex
+	ready    bool
+	folders  map[string]*Folder
+	degraded map[string]Degraded
+
+	observers         []RunObserver
+	degradedObservers []DegradedObserver
+}
+
+// NewTracker creates a new status tracker
+func NewTracker(version string) *Tracker {
+	return &Tracker{
+		version:  version,
+		started:  time.Now(),
+		folders:  make(map[string]*Folder),
+		degraded: make(map[string]Degraded),
+	}
+}
+
+// RecordEvent records that a file system event was handled for a folder
+func (t *Tracker) RecordEvent(folder string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.folder(folder).LastEvent = at
+}
+
+// RecordRun records the result of a completed enforcement run for a folder
+func (t *Tracker) RecordRun(folder string, result RunResult) {
+	t.mu.Lock()
+	t.folder(folder).LastRun = &result
+	observers := t.observers
+	t.mu.Unlock()
+
+	for _, observer := range observers {
+		observer.ObserveRun(folder, result)
+	}
+}
+
+// ObserveScan records the progress of a scan for a folder, clearing it once
+// the scan is done. With concurrent scans, the latest report is kept.
+func (t *Tracker) ObserveScan(folder string, scan Scan, done bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked := t.folder(folder)
+	if !done {
+		tracked.Scan = &scan
+		return
+	}
+	if current := tracked.Scan; current != nil && current.Root == scan.Root && current.Started.Equal(scan.Started) {
+		tracked.Scan = nil
+	}
+}
+
+// AddRunObserver registers an observer that is called synchronously for
+// every recorded run
+func (t *Tracker) AddRunObserver(observer RunObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.observers = append(t.observers, observer)
+}
+
+// SetReady records whether the application is ready to enforce permissions
+func (t *Tracker) SetReady(ready bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ready = ready
+}
+
+// AddDegradedObserver registers an observer that is called synchronously
+// when a degraded condition starts
+func (t *Tracker) AddDegradedObserver(observer DegradedObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.degradedObservers = append(t.degradedObservers, observer)
+}
+
+// SetDegraded flags a degraded condition, refreshing it if already set.
+// Observers are told about conditions that were not reported before.
+func (t *Tracker) SetDegraded(flag, reason string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	previous, ok := t.degraded[flag]
+	started := !ok || now.Sub(previous.LastSeen) > degradedTTL
+	t.degraded[flag] = Degraded{Reason: reason, LastSeen: now}
+	observers := t.degradedObservers
+	t.mu.Unlock()
+
+	if !started {
+		return
+	}
+	for _, observer := range observers {
+		observer.ObserveDegraded(flag, reason)
+	}
+}
+
+// ClearDegraded removes a degraded condition
+func (t *Tracker) ClearDegraded(flag string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.degraded, flag)
+}
+
+// Snapshot returns a copy of the current state. Degraded conditions not seen
+// within the reporting window are omitted.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	snapshot := Snapshot{
+		Version:  t.version,
+		Started:  t.started,
+		Ready:    t.ready,
+		Folders:  make(map[string]Folder, len(t.folders)),
+		Degraded: make(map[string]Degraded, len(t.degraded)),
+	}
+
+	for path, folder := range t.folders {
+		copied := *folder
+		if folder.LastRun != nil {
+			run := *folder.LastRun
+			copied.LastRun = &run
+		}
+		if folder.Scan != nil {
+			scan := *folder.Scan
+			copied.Scan = &scan
+		}
+		snapshot.Folders[path] = copied
+	}
+
+	for flag, degraded := range t.degraded {
+		if time.Since(degraded.LastSeen) <= degradedTTL {
+			snapshot.Degraded[flag] = degraded
+		}
+	}
+
+	return snapshot
+}
+
+// DegradedFlags returns the names of the degraded conditions in sorted order
+func (s Snapshot) DegradedFlags() []string {
+	flags := make([]string, 0, len(s.Degraded))
+	for flag := range s.Degraded {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// folder returns the state for path, creating it if needed. Callers must hold mu.
+func (t *Tracker) folder(path string) *Folder {
+	folder, ok := t.folders[path]
+	if !ok {
+		folder = &Folder{Path: path}
+		t.folders[path] = folder
+	}
+	return folder
+}
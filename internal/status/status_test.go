@@ -0,0 +1,92 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerSnapshot(t *testing.T) {
+	tracker := NewTracker("1.2.3")
+
+	eventTime := time.Now()
+	tracker.RecordEvent("/data/media", eventTime)
+	tracker.RecordRun("/data/media", RunResult{Fixed: 2, Skipped: 5, Failed: 1})
+	tracker.SetDegraded("watcher_errors", "queue overflow")
+
+	assert.False(t, tracker.Snapshot().Ready)
+	tracker.SetReady(true)
+
+	snapshot := tracker.Snapshot()
+	assert.Equal(t, "1.2.3", snapshot.Version)
+	assert.True(t, snapshot.Ready)
+	assert.Equal(t, []string{"watcher_errors"}, snapshot.DegradedFlags())
+
+	folder, ok := snapshot.Folders["/data/media"]
+	require.True(t, ok)
+	assert.Equal(t, eventTime, folder.LastEvent)
+	require.NotNil(t, folder.LastRun)
+	assert.Equal(t, 2, folder.LastRun.Fixed)
+
+	// Snapshots are copies and must not change with later updates
+	tracker.RecordRun("/data/media", RunResult{Fixed: 9})
+	tracker.ClearDegraded("watcher_errors")
+	assert.Equal(t, 2, folder.LastRun.Fixed)
+	assert.Empty(t, tracker.Snapshot().DegradedFlags())
+}
+
+func TestTrackerExpiresDegraded(t *testing.T) {
+	tracker := NewTracker("test")
+	tracker.degraded["stale"] = Degraded{Reason: "old", LastSeen: time.Now().Add(-2 * degradedTTL)}
+
+	assert.Empty(t, tracker.Snapshot().Degraded)
+}
+
+// runLog records observed runs
+type runLog []string
+
+func (l *runLog) ObserveRun(folder string, result RunResult) {
+	*l = append(*l, folder)
+}
+
+func TestTrackerRunObservers(t *testing.T) {
+	tracker := NewTracker("test")
+
+	var observed runLog
+	tracker.AddRunObserver(&observed)
+
+	tracker.RecordRun("/data/media", RunResult{Fixed: 1})
+	tracker.RecordRun("/data/tv", RunResult{})
+	assert.Equal(t, runLog{"/data/media", "/data/tv"}, observed)
+}
+
+// degradedLog records observed degraded conditions
+type degradedLog []string
+
+func (l *degradedLog) ObserveDegraded(flag, reason string) {
+	*l = append(*l, flag+": "+reason)
+}
+
+func TestTrackerDegradedObservers(t *testing.T) {
+	tracker := NewTracker("test")
+
+	var observed degradedLog
+	tracker.AddDegradedObserver(&observed)
+
+	tracker.SetDegraded("watcher_errors", "queue overflow")
+	tracker.SetDegraded("watcher_errors", "queue overflow again")
+	assert.Equal(t, degradedLog{"watcher_errors: queue overflow"}, observed, "refreshed conditions are not observed again")
+
+	// A condition that expired or was cleared starts anew
+	tracker.degraded["watcher_errors"] = Degraded{LastSeen: time.Now().Add(-2 * degradedTTL)}
+	tracker.SetDegraded("watcher_errors", "too many open files")
+	tracker.ClearDegraded("watcher_errors")
+	tracker.SetDegraded("watcher_errors", "queue overflow")
+	assert.Equal(t, degradedLog{
+		"watcher_errors: queue overflow",
+		"watcher_errors: too many open files",
+		"watcher_errors: queue overflow",
+	}, observed)
+}
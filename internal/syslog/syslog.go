@@ -0,0 +1,183 @@
+// Package syslog forwards log output to the local syslog daemon or a remote
+// syslog server as RFC 5424 messages
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+)
+
+// writeTimeout bounds how long a log line may wait on a stalled server
+const writeTimeout = 5 * time.Second
+
+// timestampFormat is RFC 3339 with the microsecond precision RFC 5424 allows
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// localSockets are the usual paths of the local syslog daemon's socket
+var localSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Severities by the level names of the text logger
+var severities = map[string]int{
+	"DEBU": 7, // Debug
+	"INFO": 6, // Informational
+	"WARN": 4, // Warning
+	"ERRO": 3, // Error
+	"FATA": 2, // Critical
+}
+
+// Writer sends each line written to it as a syslog message. It is meant as
+// an additional output of the text logger, whose level it maps to the
+// message severity.
+type Writer struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	pid      int
+
+	mu        sync.Mutex
+	conn      net.Conn
+	transport string // Network of conn, which decides the framing
+}
+
+// Dial connects to the syslog daemon or server configured in cfg
+func Dial(cfg config.Syslog) (*Writer, error) {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	w := &Writer{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		facility: config.SyslogFacilities[cfg.Facility],
+		tag:      cfg.Tag,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write sends every complete line of p. A message that cannot be sent is
+// retried once on a new connection.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		msg := w.format(time.Now(), string(line))
+		if err := w.send(msg); err != nil {
+			if err := w.connect(); err != nil {
+				return 0, err
+			}
+			if err := w.send(msg); err != nil {
+				return 0, fmt.Errorf("failed to send to syslog: %w", err)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection. Later writes reconnect.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// connect (re)opens the connection. The local daemon is tried at the
+// configured socket path, or else at the usual ones.
+func (w *Writer) connect() error {
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, writeTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		w.conn = conn
+		w.transport = w.network
+		return nil
+	}
+
+	sockets := localSockets
+	if w.address != "" {
+		sockets = []string{w.address}
+	}
+	var errs []error
+	for _, socket := range sockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.Dial(network, socket)
+			if err == nil {
+				w.conn = conn
+				w.transport = network
+				return nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return fmt.Errorf("failed to connect to the local syslog daemon: %w", errors.Join(errs...))
+}
+
+// send writes one message, framed for the transport
+func (w *Writer) send(msg string) error {
+	if w.conn == nil {
+		return net.ErrClosed
+	}
+
+	switch w.transport {
+	case "tcp":
+		// Octet counting framing (RFC 6587)
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	case "unix":
+		msg += "\n"
+	}
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := w.conn.Write([]byte(msg))
+	return err
+}
+
+// format builds an RFC 5424 message from a line of the text logger. The
+// logger's timestamp is dropped in favour of the header's, and its level
+// sets the severity.
+func (w *Writer) format(now time.Time, line string) string {
+	severity := severities["INFO"]
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) == 3 {
+		if _, err := time.Parse(time.RFC3339, fields[0]); err == nil {
+			if level, ok := severities[fields[1]]; ok {
+				severity = level
+				line = fields[2]
+			}
+		}
+	}
+	line = strings.TrimPrefix(line, w.tag+": ")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		w.facility*8+severity, now.Format(timestampFormat), w.hostname, w.tag, w.pid, line)
+}
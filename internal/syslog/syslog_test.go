@@ -0,0 +1,110 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	w := &Writer{facility: 16, tag: "ownarr", hostname: "nas", pid: 42}
+	now := time.Date(2024, 5, 14, 12, 0, 0, 123456789, time.UTC)
+
+	assert.Equal(t, "<132>1 2024-05-14T12:00:00.123456Z nas ownarr 42 - - Watch limit reached dir=/data",
+		w.format(now, "2024-05-14T14:00:00+02:00 WARN ownarr: Watch limit reached dir=/data"))
+	assert.Equal(t, "<131>1 2024-05-14T12:00:00.123456Z nas ownarr 42 - - Failed to fix path",
+		w.format(now, "2024-05-14T14:00:00+02:00 ERRO ownarr: Failed to fix path"))
+
+	// Lines without a level are sent as they are
+	assert.Equal(t, "<134>1 2024-05-14T12:00:00.123456Z nas ownarr 42 - - continued line",
+		w.format(now, "continued line"))
+}
+
+func TestWriteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	w, err := Dial(config.Syslog{Network: "udp", Address: conn.LocalAddr().String(), Facility: "daemon", Tag: "ownarr"})
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+
+	logger := log.NewWithOptions(w, log.Options{ReportTimestamp: true, TimeFormat: time.RFC3339, Prefix: "ownarr"})
+	logger.Error("Failed to fix path", "path", "/data/a.mkv")
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<27>1 "), msg)
+	assert.True(t, strings.HasSuffix(msg, " ownarr "+strconv.Itoa(os.Getpid())+" - - Failed to fix path path=/data/a.mkv"), msg)
+}
+
+func TestWriteTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	w, err := Dial(config.Syslog{Network: "tcp", Address: ln.Addr().String(), Facility: "daemon", Tag: "ownarr"})
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = w.Write([]byte("2024-05-14T12:00:00Z INFO ownarr: first\n2024-05-14T12:00:01Z DEBU ownarr: second\n"))
+	require.NoError(t, err)
+
+	// Messages are framed by octet counting
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{"<30>1 ", "<31>1 "} {
+		length, err := reader.ReadString(' ')
+		require.NoError(t, err)
+		size, err := strconv.Atoi(strings.TrimSpace(length))
+		require.NoError(t, err)
+		msg := make([]byte, size)
+		_, err = io.ReadFull(reader, msg)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(msg), want), string(msg))
+	}
+}
+
+func TestWriteLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	w, err := Dial(config.Syslog{Address: path, Facility: "local0", Tag: "ownarr"})
+	require.NoError(t, err)
+	defer func() { _ = w.Close() }()
+
+	_, err = w.Write([]byte("2024-05-14T12:00:00Z INFO ownarr: Application started\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<134>1 "), string(buf[:n]))
+	assert.True(t, strings.HasSuffix(string(buf[:n]), " - - Application started"), string(buf[:n]))
+}
+
+func TestDialFails(t *testing.T) {
+	_, err := Dial(config.Syslog{Address: filepath.Join(t.TempDir(), "missing"), Facility: "daemon", Tag: "ownarr"})
+	assert.Error(t, err)
+}
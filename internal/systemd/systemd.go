@@ -0,0 +1,143 @@
+// Package systemd reports readiness, status and liveness to systemd with the
+// sd_notify protocol, so ownarr can run as a Type=notify service with
+// WatchdogSec set
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+)
+
+// Notifier sends state changes to the service manager. Without NOTIFY_SOCKET
+// in the environment every method does nothing.
+type Notifier struct {
+	logger   *log.Logger
+	socket   string        // Notification socket, empty when not run by systemd
+	watchdog time.Duration // Watchdog timeout, zero when disabled
+
+	mu      sync.Mutex
+	folders []config.WatchDir
+	runs    map[string]status.RunResult // Last run by watch directory path
+}
+
+// New creates a notifier for the service manager described by the
+// environment. The variables are removed, so commands started by ownarr do
+// not talk to systemd on its behalf.
+func New(cfg *config.Config, logger *log.Logger) *Notifier {
+	n := &Notifier{
+		logger: logger,
+		socket: os.Getenv("NOTIFY_SOCKET"),
+		runs:   make(map[string]status.RunResult),
+	}
+	n.watchdog = watchdogTimeout(os.Getenv("WATCHDOG_USEC"), os.Getenv("WATCHDOG_PID"))
+	for _, name := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+		_ = os.Unsetenv(name)
+	}
+	n.SetConfig(cfg)
+	return n
+}
+
+// watchdogTimeout parses the watchdog settings, which only apply to the
+// process named by WATCHDOG_PID if it is set
+func watchdogTimeout(usec, pid string) time.Duration {
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	timeout, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || timeout <= 0 {
+		return 0
+	}
+	return time.Duration(timeout) * time.Microsecond
+}
+
+// Enabled reports whether ownarr runs as a notify service
+func (n *Notifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// WatchdogInterval returns how often Watchdog must be called, half the
+// configured timeout, or zero if the watchdog is disabled
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if !n.Enabled() {
+		return 0
+	}
+	return n.watchdog / 2
+}
+
+// SetConfig replaces the watch directories shown in the status after a reload
+func (n *Notifier) SetConfig(cfg *config.Config) {
+	n.mu.Lock()
+	n.folders = cfg.WatchDirs
+	n.mu.Unlock()
+
+	n.send("STATUS=" + n.status())
+}
+
+// Ready tells systemd that startup is complete
+func (n *Notifier) Ready() {
+	n.send("READY=1\nSTATUS=" + n.status())
+}
+
+// Watchdog tells systemd that the instance is alive
+func (n *Notifier) Watchdog() {
+	n.send("WATCHDOG=1")
+}
+
+// Stopping tells systemd that shutdown has begun
+func (n *Notifier) Stopping() {
+	n.send("STOPPING=1\nSTATUS=Shutting down")
+}
+
+// ObserveRun updates the status with the result of a run
+func (n *Notifier) ObserveRun(folder string, result status.RunResult) {
+	n.mu.Lock()
+	n.runs[folder] = result
+	n.mu.Unlock()
+
+	n.send("STATUS=" + n.status())
+}
+
+// status summarizes the last run of each watch directory in one line
+func (n *Notifier) status() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	parts := make([]string, 0, len(n.folders))
+	for _, watchDir := range n.folders {
+		run, ok := n.runs[watchDir.Path]
+		if !ok {
+			parts = append(parts, watchDir.Name+" (no run yet)")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (%d fixed, %d failed)", watchDir.Name, run.Fixed, run.Failed))
+	}
+	return "Watching " + strings.Join(parts, ", ")
+}
+
+// send writes a notification to the socket. Failures are only logged, since
+// systemd acts on missing notifications itself.
+func (n *Notifier) send(state string) {
+	if !n.Enabled() {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		n.logger.Warn("Failed to notify systemd", "error", err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		n.logger.Warn("Failed to notify systemd", "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestNotifier creates a notifier sending to a socket read by the test
+func newTestNotifier(t *testing.T) (*Notifier, *net.UnixConn) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "10000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	cfg := config.DefaultConfig()
+	cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: "/data/tv"}, {Name: "movies", Path: "/data/movies"}}
+
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	return New(cfg, logger), conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	n, conn := newTestNotifier(t)
+	assert.True(t, n.Enabled())
+	assert.Equal(t, 5*time.Second, n.WatchdogInterval())
+	assert.Empty(t, os.Getenv("NOTIFY_SOCKET"), "children do not inherit the socket")
+
+	assert.Equal(t, "STATUS=Watching tv (no run yet), movies (no run yet)", receive(t, conn))
+
+	n.Ready()
+	assert.Equal(t, "READY=1\nSTATUS=Watching tv (no run yet), movies (no run yet)", receive(t, conn))
+
+	n.ObserveRun("/data/movies", status.RunResult{Fixed: 3, Failed: 1})
+	assert.Equal(t, "STATUS=Watching tv (no run yet), movies (3 fixed, 1 failed)", receive(t, conn))
+
+	n.Watchdog()
+	assert.Equal(t, "WATCHDOG=1", receive(t, conn))
+
+	n.Stopping()
+	assert.Equal(t, "STOPPING=1\nSTATUS=Shutting down", receive(t, conn))
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	n := New(config.DefaultConfig(), log.New(os.Stderr))
+	assert.False(t, n.Enabled())
+	assert.Zero(t, n.WatchdogInterval())
+
+	// Nothing is sent without a socket
+	n.Ready()
+	n.Watchdog()
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{name: "own process", usec: "30000000", pid: pid, want: 30 * time.Second},
+		{name: "without pid", usec: "30000000", want: 30 * time.Second},
+		{name: "other process", usec: "30000000", pid: "1", want: 0},
+		{name: "disabled", usec: "", want: 0},
+		{name: "invalid", usec: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, watchdogTimeout(tt.usec, tt.pid))
+		})
+	}
+}
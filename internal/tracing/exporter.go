@@ -0,0 +1,305 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Exporter batching limits
+const (
+	exportInterval = 5 * time.Second
+	maxBatchSize   = 512
+	maxQueueSize   = 2048
+	exportTimeout  = 10 * time.Second
+)
+
+// ExporterOptions configures an OTLP exporter
+type ExporterOptions struct {
+	Endpoint       string            // Base URL of the OTLP/HTTP receiver, e.g. http://localhost:4318
+	Headers        map[string]string // Extra request headers, e.g. for authentication
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Exporter sends finished spans in batches to an OTLP/HTTP receiver using
+// the JSON encoding
+type Exporter struct {
+	logger  *log.Logger
+	client  *http.Client
+	url     string
+	options ExporterOptions
+
+	queue   chan *Span
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closeMu sync.RWMutex // Guards closed
+	closed  bool
+
+	droppedMu sync.Mutex // Guards dropped
+	dropped   int        // Spans dropped since the last export
+}
+
+// NewExporter creates an exporter and starts its background sender
+func NewExporter(options ExporterOptions, logger *log.Logger) *Exporter {
+	e := &Exporter{
+		logger:  logger,
+		client:  &http.Client{Timeout: exportTimeout},
+		url:     strings.TrimSuffix(options.Endpoint, "/") + "/v1/traces",
+		options: options,
+		queue:   make(chan *Span, maxQueueSize),
+		done:    make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	return e
+}
+
+// enqueue queues a span for export, dropping it if the queue is full
+func (e *Exporter) enqueue(span *Span) {
+	e.closeMu.RLock()
+	defer e.closeMu.RUnlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.queue <- span:
+	default:
+		e.droppedMu.Lock()
+		e.dropped++
+		e.droppedMu.Unlock()
+	}
+}
+
+// Shutdown stops the exporter after sending the queued spans
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.closeMu.Lock()
+	if e.closed {
+		e.closeMu.Unlock()
+		return errExporterClosed
+	}
+	e.closed = true
+	close(e.done)
+	e.closeMu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects spans into batches and sends them periodically or when full
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, maxBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.logger.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = batch[:0]
+
+		e.droppedMu.Lock()
+		if e.dropped > 0 {
+			e.logger.Warn("Dropped spans because the export queue was full", "spans", e.dropped)
+			e.dropped = 0
+		}
+		e.droppedMu.Unlock()
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= maxBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch of spans to the receiver
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON payload types, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// OTLP enum values
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// encode converts spans into an OTLP export request
+func (e *Exporter) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(span.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.SpanID[:]),
+			Name:              span.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attrs),
+		}
+		if span.ParentID != ([8]byte{}) {
+			s.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+		}
+		if span.Err != nil {
+			s.Status = &otlpStatus{Code: statusCodeError, Message: span.Err.Error()}
+		}
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes([]Attr{
+			String("service.name", e.options.ServiceName),
+			String("service.version", e.options.ServiceVersion),
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/keksiqc/ownarr", Version: e.options.ServiceVersion},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+// encodeAttributes converts attributes to their OTLP form
+func encodeAttributes(attrs []Attr) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
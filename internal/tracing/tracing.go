@@ -0,0 +1,225 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attr is a span attribute
+type Attr struct {
+	Key   string
+	Value any // string, int64, float64 or bool
+}
+
+// String returns a string attribute
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Span is a timed operation within a trace. A nil span is valid and does
+// nothing, so callers need not check whether tracing is enabled.
+type Span struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Err      error
+
+	sampled bool
+	once    sync.Once
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.Attrs = append(s.Attrs, attrs...)
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.Err = err
+}
+
+// Finish ends the span and hands it to the exporter
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.End = time.Now()
+		if s.sampled {
+			if p := current(); p != nil {
+				p.export(s)
+			}
+		}
+	})
+}
+
+// TraceParent returns the span's context as a W3C traceparent header value
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]), flags)
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// Start begins a span as a child of the span in ctx, returning a context
+// carrying the new span. It returns a nil span while tracing is disabled.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartAt(ctx, name, time.Now(), attrs...)
+}
+
+// StartAt is like Start for an operation that began at start
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...Attr) (context.Context, *Span) {
+	p := current()
+	if p == nil {
+		return ctx, nil
+	}
+
+	span := &Span{Name: name, Start: start, SpanID: newSpanID()}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+		span.sampled = parent.sampled
+	} else {
+		span.TraceID = newTraceID()
+		span.sampled = p.sample(span.TraceID)
+	}
+	span.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span carried by ctx, if any
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a context whose spans continue the trace
+// described by a W3C traceparent header value. Invalid values are ignored.
+func ContextWithRemoteParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+
+	parent := &Span{}
+	if _, err := hex.Decode(parent.TraceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(parent.SpanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || parent.TraceID == [16]byte{} || parent.SpanID == [8]byte{} {
+		return ctx
+	}
+	parent.sampled = flags[0]&1 == 1
+
+	return context.WithValue(ctx, spanKey{}, parent)
+}
+
+// provider holds the active exporter and sampling configuration
+type provider struct {
+	exporter    *Exporter
+	sampleRatio float64
+}
+
+// sample decides whether a new trace is recorded, consistently for a trace ID
+func (p *provider) sample(traceID [16]byte) bool {
+	if p.sampleRatio >= 1 {
+		return true
+	}
+	// Compare the random low bits of the trace ID against the ratio
+	bound := uint64(p.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+}
+
+// export hands a finished span to the exporter
+func (p *provider) export(span *Span) {
+	p.exporter.enqueue(span)
+}
+
+var (
+	providerMu sync.RWMutex
+	active     *provider
+)
+
+// current returns the active provider, or nil while tracing is disabled
+func current() *provider {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+
+	return active
+}
+
+// Enable starts recording spans with exporter, keeping the given ratio of
+// new traces. It returns a function that disables tracing and flushes the
+// remaining spans.
+func Enable(exporter *Exporter, sampleRatio float64) func(context.Context) error {
+	providerMu.Lock()
+	active = &provider{exporter: exporter, sampleRatio: sampleRatio}
+	providerMu.Unlock()
+
+	return func(ctx context.Context) error {
+		providerMu.Lock()
+		active = nil
+		providerMu.Unlock()
+
+		return exporter.Shutdown(ctx)
+	}
+}
+
+// newTraceID returns a random trace ID
+func newTraceID() [16]byte {
+	var id [16]byte
+	for id == ([16]byte{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID returns a random span ID
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == ([8]byte{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// errExporterClosed is returned when spans are exported after shutdown
+var errExporterClosed = errors.New("exporter is shut down")
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReceiver starts an OTLP receiver that collects exported spans
+func newTestReceiver(t *testing.T) (*httptest.Server, func() []otlpSpan) {
+	t.Helper()
+
+	var (
+		mu    sync.Mutex
+		spans []otlpSpan
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+
+		var req otlpRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []otlpSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]otlpSpan(nil), spans...)
+	}
+}
+
+func TestDisabledTracing(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop")
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+
+	// Nil spans are safe to use
+	span.SetAttributes(String("key", "value"))
+	span.RecordError(errors.New("boom"))
+	span.Finish()
+}
+
+func TestExportSpans(t *testing.T) {
+	srv, received := newTestReceiver(t)
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	exporter := NewExporter(ExporterOptions{
+		Endpoint:    srv.URL,
+		Headers:     map[string]string{"X-Token": "secret"},
+		ServiceName: "ownarr",
+	}, logger)
+	shutdown := Enable(exporter, 1)
+
+	ctx, parent := Start(context.Background(), "enforce.job", String("job", "abc"))
+	_, child := Start(ctx, "enforce.tree", Int("paths.fixed", 3))
+	child.RecordError(errors.New("permission denied"))
+	child.Finish()
+	parent.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, shutdown(ctx))
+
+	spans := received()
+	require.Len(t, spans, 2)
+	tree, job := spans[0], spans[1]
+
+	assert.Equal(t, "enforce.job", job.Name)
+	assert.Empty(t, job.ParentSpanID)
+	assert.Equal(t, job.TraceID, tree.TraceID)
+	assert.Equal(t, job.SpanID, tree.ParentSpanID)
+	require.NotNil(t, tree.Status)
+	assert.Equal(t, "permission denied", tree.Status.Message)
+	require.Len(t, tree.Attributes, 1)
+	assert.Equal(t, "3", *tree.Attributes[0].Value.IntValue)
+
+	// Spans finished after shutdown are discarded
+	_, late := Start(context.Background(), "late")
+	assert.Nil(t, late)
+}
+
+func TestSampling(t *testing.T) {
+	p := &provider{sampleRatio: 0}
+	assert.False(t, p.sample(newTraceID()))
+
+	p.sampleRatio = 1
+	assert.True(t, p.sample(newTraceID()))
+
+	p.sampleRatio = 0.5
+	sampled := 0
+	for range 1000 {
+		if p.sample(newTraceID()) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 500, sampled, 100)
+}
+
+func TestContextWithRemoteParent(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	shutdown := Enable(NewExporter(ExporterOptions{Endpoint: "http://127.0.0.1:1"}, logger), 0)
+	defer func() { _ = shutdown(context.Background()) }()
+
+	ctx := ContextWithRemoteParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := Start(ctx, "child")
+	require.NotNil(t, span)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceParent()[3:35])
+	assert.True(t, span.sampled, "the caller's sampling decision is kept")
+
+	_, span = Start(ContextWithRemoteParent(context.Background(), "garbage"), "root")
+	require.NotNil(t, span)
+	assert.False(t, span.sampled, "invalid headers start a new trace")
+}
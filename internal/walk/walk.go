@@ -0,0 +1,81 @@
+// Package walk walks file trees reading directories in batches, so memory
+// stays flat however many entries a directory holds
+package walk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// batchSize is the number of directory entries read at a time
+const batchSize = 256
+
+// Dir walks the tree rooted at root like filepath.WalkDir, calling fn for
+// root and every path below it. Unlike filepath.WalkDir, a directory's
+// entries are visited in the order the file system returns them, a batch at
+// a time, instead of being read and sorted all at once. Directories are
+// visited before their contents. fn may return filepath.SkipDir and
+// filepath.SkipAll as with filepath.WalkDir. Once ctx is cancelled the walk
+// stops before the next path, returning the error of ctx.
+func Dir(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(ctx, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDir visits path and, if it is a directory, the paths below it
+func walkDir(ctx context.Context, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		// Report the directory a second time with the error, as WalkDir does
+		if err := fn(path, d, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			return err
+		}
+		return nil
+	}
+	defer func() { _ = dir.Close() }()
+
+	for {
+		entries, err := dir.ReadDir(batchSize)
+		for _, entry := range entries {
+			if err := walkDir(ctx, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+				if errors.Is(err, filepath.SkipDir) {
+					// Skipping a file skips the rest of its directory
+					return nil
+				}
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if err := fn(path, d, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// visited walks root, returning the paths visited relative to it
+func visited(t *testing.T, root string, fn func(path string, d fs.DirEntry) error) []string {
+	var paths []string
+	err := Dir(context.Background(), root, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		rel, relErr := filepath.Rel(root, path)
+		require.NoError(t, relErr)
+		paths = append(paths, rel)
+		if fn != nil {
+			return fn(path, d)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return paths
+}
+
+func TestDir(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "file"), nil, 0644))
+	require.NoError(t, os.Symlink("a", filepath.Join(root, "link")))
+
+	paths := visited(t, root, nil)
+	assert.ElementsMatch(t, []string{".", "a", filepath.Join("a", "b"), filepath.Join("a", "b", "file"), "link"}, paths)
+	assert.Less(t, slices.Index(paths, "a"), slices.Index(paths, filepath.Join("a", "b")), "directories come before their contents")
+}
+
+func TestDirBatches(t *testing.T) {
+	root := t.TempDir()
+	for i := range batchSize*2 + 1 {
+		require.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("file%d", i)), nil, 0644))
+	}
+
+	assert.Len(t, visited(t, root, nil), batchSize*2+2)
+}
+
+func TestDirSkip(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "skipped", "deep"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "kept"), 0755))
+
+	paths := visited(t, root, func(path string, d fs.DirEntry) error {
+		if d.Name() == "skipped" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	assert.ElementsMatch(t, []string{".", "skipped", "kept"}, paths)
+
+	paths = visited(t, root, func(path string, d fs.DirEntry) error {
+		if path != root {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	assert.Len(t, paths, 2, "the root and the first entry")
+}
+
+func TestDirErrors(t *testing.T) {
+	var missing error
+	err := Dir(context.Background(), filepath.Join(t.TempDir(), "missing"), func(path string, d fs.DirEntry, err error) error {
+		missing = err
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, missing, fs.ErrNotExist)
+
+	stop := errors.New("stop")
+	assert.ErrorIs(t, Dir(context.Background(), t.TempDir(), func(string, fs.DirEntry, error) error { return stop }), stop)
+}
+
+func TestDirCancelled(t *testing.T) {
+	root := t.TempDir()
+	for i := range 10 {
+		require.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("file%d", i)), nil, 0644))
+	}
+
+	// The path being visited is finished, the walk stops before the next
+	ctx, cancel := context.WithCancel(context.Background())
+	var paths []string
+	err := Dir(ctx, root, func(path string, d fs.DirEntry, err error) error {
+		paths = append(paths, path)
+		if len(paths) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, paths, 3)
+}
@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/limits"
+)
+
+// errWatchLimit marks directories left unwatched because the watch limit
+// was reached
+var errWatchLimit = errors.New("watch limit reached, checked by polling only")
+
+// Budget is the use of watches and open files against their limits
+type Budget struct {
+	Watches       int // Directories watched
+	WatchLimit    int // Most directories watched at once, 0 if unlimited
+	Unwatched     int // Directories only polled because the watch limit was reached
+	OpenFiles     int // Files open in the process, -1 if unknown
+	OpenFileLimit int // Most files the process may open, 0 if unlimited or unknown
+}
+
+// Budget returns the watches and open files in use and their limits
+func (w *Watcher) Budget() Budget {
+	budget := Budget{
+		Watches:    int(w.watched.Load()),
+		WatchLimit: int(w.watchLimit.Load()),
+	}
+	budget.OpenFiles, budget.OpenFileLimit = limits.OpenFiles()
+
+	w.failedMu.Lock()
+	for _, watch := range w.failed {
+		if watch.overLimit {
+			budget.Unwatched++
+		}
+	}
+	w.failedMu.Unlock()
+	return budget
+}
+
+// watchLimitOf returns the most directories cfg allows watching: max_watches,
+// capped by the system's inotify limit and, where every watch holds a file
+// open, by half the open file limit
+func watchLimitOf(cfg *config.Config) int {
+	limit := cfg.MaxWatches
+	capAt := func(n int) {
+		if n > 0 && (limit == 0 || n < limit) {
+			limit = n
+		}
+	}
+	capAt(limits.WatchLimit())
+	if runtime.GOOS != "linux" {
+		_, files := limits.OpenFiles()
+		capAt(files / 2)
+	}
+	return limit
+}
+
+// watch registers a directory with the file system watcher. Directories
+// beyond the watch limit, or refused by the system for lack of watches or
+// file descriptors, are recorded as failed and left to polling, returning an
+// error wrapping errWatchLimit.
+func (w *Watcher) watch(path string, watchDir config.WatchDir) error {
+	limit := int(w.watchLimit.Load())
+	if limit > 0 && int(w.watched.Load()) >= limit {
+		return w.overLimit(path, watchDir, errWatchLimit)
+	}
+
+	if err := w.fsWatcher.Add(path); err != nil {
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+			return w.overLimit(path, watchDir, fmt.Errorf("%w: %w", errWatchLimit, err))
+		}
+		return err
+	}
+	w.watched.Add(1)
+	w.clearFailure(path)
+	return nil
+}
+
+// overLimit records a directory left unwatched for the watch limit, warning
+// the first time the limit is reached and reporting it as an error if the
+// system ran out of watches or file descriptors
+func (w *Watcher) overLimit(path string, watchDir config.WatchDir, err error) error {
+	w.failedMu.Lock()
+	w.failed[path] = Watch{Path: path, Folder: watchDir.Name, Error: err.Error(), overLimit: true}
+	w.failedMu.Unlock()
+
+	if w.limitWarned.CompareAndSwap(false, true) {
+		w.logger.Warn("Watch limit reached, further directories are checked by polling only; raise max_watches or fs.inotify.max_user_watches",
+			"limit", w.watchLimit.Load(), "path", escape.Text(path), "error", err)
+		if classified := classify(err, path); classified.Kind != ErrorWatcher {
+			w.report(classified)
+		}
+	}
+	return err
+}
+
+// countWatches recounts the registered watches, which the system drops
+// when directories are removed
+func (w *Watcher) countWatches() {
+	watched := len(w.fsWatcher.WatchList())
+	w.watched.Store(int64(watched))
+	if limit := w.watchLimit.Load(); limit == 0 || int64(watched) < limit {
+		w.limitWarned.Store(false)
+	}
+}
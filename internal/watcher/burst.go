@@ -0,0 +1,144 @@
+package watcher
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+)
+
+// burst is the set of paths changed in one directory since started
+type burst struct {
+	started time.Time
+	paths   map[string]struct{}
+}
+
+// escalate counts a change of path towards a burst of changes in its
+// directory. Once more than limit paths of a directory changed within
+// window, their events are replaced by a single SCAN of the directory, sent
+// once no event below it arrived for window. Removals leave nothing to
+// enforce and permission changes are mostly our own fixes, so neither counts,
+// though both are covered by a scan. It reports whether the event is covered
+// by a scan and must not be sent itself.
+func (w *Watcher) escalate(path, operation string, watchDir config.WatchDir, limit int, window time.Duration) bool {
+	w.burstMu.Lock()
+	defer w.burstMu.Unlock()
+
+	now := time.Now()
+	for root, scan := range w.escalated {
+		if config.Within(path, root) {
+			scan.lastSeen = now
+			return true
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if operation == "REMOVE" || operation == "CHMOD" || !config.Within(dir, watchDir.Path) {
+		return false
+	}
+
+	b, ok := w.bursts[dir]
+	if !ok || now.Sub(b.started) > window {
+		b = &burst{started: now, paths: make(map[string]struct{})}
+		w.bursts[dir] = b
+	}
+	b.paths[path] = struct{}{}
+	if len(b.paths) <= limit {
+		return false
+	}
+
+	// The scan covers the bursts and scans below the directory too
+	for root := range w.bursts {
+		if config.Within(root, dir) {
+			delete(w.bursts, root)
+		}
+	}
+	for root := range w.escalated {
+		if config.Within(root, dir) {
+			delete(w.escalated, root)
+		}
+	}
+	w.discardBufferedBelow(dir)
+
+	w.escalated[dir] = &bufferedEvent{
+		event: Event{
+			Path:      dir,
+			Operation: "SCAN",
+			WatchDir:  watchDir,
+			Timestamp: b.started,
+		},
+		lastSeen: now,
+	}
+	w.logger.Info("Burst of events, scanning the directory once it settles", "path", escape.Text(dir), "paths", len(b.paths))
+	return true
+}
+
+// takeEscalated removes and returns the scans of bursts without events
+// within window, and forgets bursts that ended without escalating
+func (w *Watcher) takeEscalated(window time.Duration) []Event {
+	w.burstMu.Lock()
+	defer w.burstMu.Unlock()
+
+	var settled []Event
+	now := time.Now()
+	for root, scan := range w.escalated {
+		if now.Sub(scan.lastSeen) >= window {
+			settled = append(settled, scan.event)
+			delete(w.escalated, root)
+		}
+	}
+	for dir, b := range w.bursts {
+		if now.Sub(b.started) > window {
+			delete(w.bursts, dir)
+		}
+	}
+	return settled
+}
+
+// flushEscalated periodically sends the scans of bursts that have settled
+func (w *Watcher) flushEscalated(ctx context.Context, window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.done:
+			return
+		case <-ticker.C:
+			for _, event := range w.takeEscalated(window) {
+				if !w.send(event) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// send sends an event to consumers, waiting while the channel is full since
+// the event stands for many others. It returns false if the watcher is
+// shutting down.
+func (w *Watcher) send(event Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// discardBufferedBelow drops the buffered events of root and every path
+// below it
+func (w *Watcher) discardBufferedBelow(root string) {
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+
+	for path := range w.buffer {
+		if config.Within(path, root) {
+			delete(w.buffer, path)
+		}
+	}
+}
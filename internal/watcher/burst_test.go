@@ -0,0 +1,124 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalate(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watcher, err := New(&config.Config{Debounce: 50}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	watchDir := config.WatchDir{Path: "/data"}
+	watcher.coalesce("/data/show/season01/e01.mkv", "CREATE", watchDir)
+	watcher.coalesce("/data/movie.mkv", "CREATE", watchDir)
+
+	// Repeated events for a path count once, permission changes not at all
+	assert.False(t, watcher.escalate("/data/show/season01/e00.mkv", "CHMOD", watchDir, 3, time.Hour))
+	for _, name := range []string{"e01.mkv", "e01.mkv", "e02.mkv", "e03.mkv"} {
+		assert.False(t, watcher.escalate("/data/show/season01/"+name, "CREATE", watchDir, 3, time.Hour), name)
+	}
+	assert.True(t, watcher.escalate("/data/show/season01/e04.mkv", "CREATE", watchDir, 3, time.Hour))
+
+	// Later events below the directory are covered by its scan
+	assert.True(t, watcher.escalate("/data/show/season01/extras/e05.mkv", "CHMOD", watchDir, 3, time.Hour))
+	assert.False(t, watcher.escalate("/data/show/season02/e01.mkv", "CREATE", watchDir, 3, time.Hour))
+
+	// Buffered events below the directory are superseded by the scan
+	settled := watcher.takeSettled(0)
+	require.Len(t, settled, 1)
+	assert.Equal(t, "/data/movie.mkv", settled[0].Path)
+
+	assert.Empty(t, watcher.takeEscalated(time.Hour))
+	scans := watcher.takeEscalated(0)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "/data/show/season01", scans[0].Path)
+	assert.Equal(t, "SCAN", scans[0].Operation)
+	assert.Equal(t, watchDir, scans[0].WatchDir)
+	assert.Empty(t, watcher.bursts, "ended bursts are forgotten")
+}
+
+func TestEscalateMergesSubdirectories(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watcher, err := New(&config.Config{}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	watchDir := config.WatchDir{Path: "/data"}
+	for i := range 3 {
+		watcher.escalate(fmt.Sprintf("/data/show/season01/e%02d.mkv", i), "WRITE", watchDir, 2, time.Hour)
+	}
+	for i := range 3 {
+		watcher.escalate(fmt.Sprintf("/data/show/season%02d", i+2), "CREATE", watchDir, 2, time.Hour)
+	}
+
+	scans := watcher.takeEscalated(0)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "/data/show", scans[0].Path)
+
+	// Bursts never reach above the watch directory
+	for i := range 3 {
+		assert.False(t, watcher.escalate(fmt.Sprintf("/data%d", i), "CREATE", watchDir, 2, time.Hour))
+	}
+}
+
+func TestBurstSendsScan(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watchDir := t.TempDir()
+	cfg := &config.Config{
+		BurstPaths:  5,
+		BurstWindow: 100,
+		WatchDirs:   []config.WatchDir{{Path: watchDir}},
+	}
+
+	watcher, err := New(cfg, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+
+	for i := range 50 {
+		require.NoError(t, os.WriteFile(filepath.Join(watchDir, fmt.Sprintf("track%02d.flac", i)), []byte("audio"), 0644))
+	}
+
+	var single int
+	for {
+		select {
+		case event := <-watcher.Events():
+			if event.Operation != "SCAN" {
+				single++
+				continue
+			}
+			assert.Equal(t, watchDir, event.Path)
+			assert.LessOrEqual(t, single, 5*2, "only the events before the burst are sent")
+			return
+		case <-time.After(2 * time.Second):
+			t.Fatal("no scan after a burst of events")
+		}
+	}
+}
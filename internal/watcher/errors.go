@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/keksiqc/ownarr/internal/recovery"
+)
+
+// Kinds of watcher errors, which are also the degraded conditions they raise
+const (
+	ErrorOverflow         = "event_overflow"    // The system dropped events, folders are scanned to catch up
+	ErrorWatchesExhausted = "watches_exhausted" // The system ran out of watches, further directories are polled
+	ErrorFilesExhausted   = "files_exhausted"   // The process ran out of file descriptors, further directories are polled
+	ErrorWatcher          = "watcher_errors"    // Any other error of the file system watcher
+)
+
+// catchUpInterval is how long after a catch-up scan further overflows are
+// left to polling, so a flood of events does not queue scan after scan
+const catchUpInterval = time.Minute
+
+// Error is a classified error of the file system watcher, sent on Errors
+type Error struct {
+	Kind string // One of the Error kinds
+	Path string // Directory affected, empty if not known
+	Err  error
+}
+
+// Error describes the error with the directory affected
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Hint tells what ownarr does about the error and what fixes it, empty for
+// errors without either
+func (e *Error) Hint() string {
+	switch e.Kind {
+	case ErrorOverflow:
+		return "the system dropped file events, every folder is scanned to catch up; raise fs.inotify.max_queued_events if this recurs"
+	case ErrorWatchesExhausted:
+		return "further directories are checked by polling only; raise fs.inotify.max_user_watches or lower max_watches"
+	case ErrorFilesExhausted:
+		return "further directories are checked by polling only; raise the open file limit (ulimit -n) or lower max_watches"
+	default:
+		return ""
+	}
+}
+
+// classify wraps an error of the file system watcher with its kind
+func classify(err error, path string) *Error {
+	kind := ErrorWatcher
+	switch {
+	case errors.Is(err, fsnotify.ErrEventOverflow):
+		kind = ErrorOverflow
+	case errors.Is(err, syscall.ENOSPC):
+		kind = ErrorWatchesExhausted
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE):
+		kind = ErrorFilesExhausted
+	}
+	return &Error{Kind: kind, Path: path, Err: err}
+}
+
+// report passes an error to consumers, dropping it if the channel is full
+func (w *Watcher) report(err *Error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	default:
+		w.logger.Error("Error channel full, dropping error", "error", err)
+	}
+}
+
+// catchUp scans every folder after the system dropped events, as changes
+// anywhere may have gone unnoticed. Overflows within catchUpInterval of the
+// last catch-up are left to polling.
+func (w *Watcher) catchUp() {
+	w.catchUpMu.Lock()
+	if time.Since(w.lastCatchUp) < catchUpInterval {
+		w.catchUpMu.Unlock()
+		return
+	}
+	w.lastCatchUp = time.Now()
+	w.catchUpMu.Unlock()
+
+	// Scans wait for room in the channel, which must not stall the events
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		recovery.Run(w.logger, "watcher", func() {
+			for _, watchDir := range w.currentConfig().WatchDirs {
+				if watchDir.Remote.Enabled() {
+					continue // Not watched, so no events were lost
+				}
+				if !w.send(Event{Path: watchDir.Path, Operation: "CATCH_UP", WatchDir: watchDir, Timestamp: time.Now()}) {
+					return
+				}
+			}
+		})
+	}()
+}
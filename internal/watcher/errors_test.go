@@ -0,0 +1,74 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{fsnotify.ErrEventOverflow, ErrorOverflow},
+		{fmt.Errorf("%w: %w", errWatchLimit, syscall.ENOSPC), ErrorWatchesExhausted},
+		{syscall.EMFILE, ErrorFilesExhausted},
+		{syscall.ENFILE, ErrorFilesExhausted},
+		{errors.New("unknown"), ErrorWatcher},
+	}
+	for _, tt := range tests {
+		classified := classify(tt.err, "/data")
+		assert.Equal(t, tt.kind, classified.Kind, tt.err.Error())
+		assert.ErrorIs(t, classified, tt.err)
+		assert.Equal(t, "/data: "+tt.err.Error(), classified.Error())
+		assert.Equal(t, tt.kind == ErrorWatcher, classified.Hint() == "")
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	cfg := &config.Config{WatchDirs: []config.WatchDir{{Name: "tv", Path: "/data/tv"}, {Name: "movies", Path: "/data/movies"}}}
+	watcher, err := New(cfg, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// Overflows scan every folder, once within the interval
+	watcher.catchUp()
+	watcher.catchUp()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var scanned []string
+	for range cfg.WatchDirs {
+		select {
+		case event := <-watcher.Events():
+			assert.Equal(t, "CATCH_UP", event.Operation)
+			assert.Equal(t, event.WatchDir.Path, event.Path)
+			scanned = append(scanned, event.WatchDir.Name)
+		case <-ctx.Done():
+			t.Fatal("no catch-up scan after an overflow")
+		}
+	}
+	assert.Equal(t, []string{"tv", "movies"}, scanned)
+
+	select {
+	case event := <-watcher.Events():
+		t.Fatalf("second catch-up within the interval: %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
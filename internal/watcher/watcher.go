@@ -2,16 +2,23 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/fsnotify/fsnotify"
 	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/escape"
+	"github.com/keksiqc/ownarr/internal/recovery"
+	"github.com/keksiqc/ownarr/internal/walk"
 )
 
 // Event represents a file system event with associated metadata
@@ -20,6 +27,18 @@ type Event struct {
 	Operation string          // Type of operation (CREATE, WRITE, REMOVE, etc.)
 	WatchDir  config.WatchDir // Associated watch directory configuration
 	Timestamp time.Time       // When the event occurred
+	Chmodded  bool            // A permission change of the path was merged into the event
+	Failed    int             // Paths a poll could not read, on POLL_COMPLETE
+
+	done func() // Releases pending state held for this event, if any
+}
+
+// Done marks the event as handled. Consumers must call it once processing
+// has finished so the path can be queued again by later poll cycles.
+func (e Event) Done() {
+	if e.done != nil {
+		e.done()
+	}
 }
 
 // Watcher watches directories for file changes
@@ -28,9 +47,51 @@ type Watcher struct {
 	fsWatcher *fsnotify.Watcher
 	events    chan Event
 	errors    chan error
-	config    *config.Config
 	done      chan struct{}  // For coordinating shutdown
 	wg        sync.WaitGroup // Wait for goroutines to finish
+
+	config config.Snapshot // Current configuration, replaced on reload
+
+	reloadMu  sync.Mutex         // Serializes Start and Reload
+	ctx       context.Context    // Context passed to Start
+	stopLoops context.CancelFunc // Stops the polling and flushing goroutines
+	loopWG    sync.WaitGroup     // Wait for polling and flushing goroutines
+
+	pendingMu sync.Mutex          // Guards pending
+	pending   map[string]struct{} // Paths with an unprocessed poll event queued
+
+	bufferMu sync.Mutex                // Guards buffer
+	buffer   map[string]*bufferedEvent // Events held back for coalescing, by path
+
+	burstMu   sync.Mutex                // Guards bursts and escalated
+	bursts    map[string]*burst         // Paths changed recently, by directory
+	escalated map[string]*bufferedEvent // Scans replacing the events of bursts, by directory
+
+	failedMu sync.Mutex       // Guards failed
+	failed   map[string]Watch // Directories that could not be watched, by path
+
+	watched     atomic.Int64 // Registered watches, recounted every poll cycle
+	watchLimit  atomic.Int64 // Most watches registered, 0 for no limit
+	limitWarned atomic.Bool  // Whether reaching the watch limit was logged
+
+	catchUpMu   sync.Mutex // Guards lastCatchUp
+	lastCatchUp time.Time  // When folders were last scanned after an overflow
+}
+
+// Watch is a directory registered with the file system watcher, or one
+// that could not be registered
+type Watch struct {
+	Path   string
+	Folder string // Name of the watch directory it belongs to
+	Error  string // Why the directory is not watched, empty if it is
+
+	overLimit bool // Unwatched for the watch limit
+}
+
+// bufferedEvent is an event held back while further events for the same path are coalesced
+type bufferedEvent struct {
+	event    Event
+	lastSeen time.Time
 }
 
 // New creates a new directory watcher
@@ -40,44 +101,227 @@ func New(cfg *config.Config, logger *log.Logger) (*Watcher, error) {
 		return nil, fmt.Errorf("failed to create fs watcher: %w", err)
 	}
 
-	return &Watcher{
+	w := &Watcher{
 		logger:    logger,
 		fsWatcher: fsWatcher,
 		events:    make(chan Event, 100),
 		errors:    make(chan error, 10),
-		config:    cfg,
 		done:      make(chan struct{}),
-	}, nil
+		pending:   make(map[string]struct{}),
+		buffer:    make(map[string]*bufferedEvent),
+		bursts:    make(map[string]*burst),
+		escalated: make(map[string]*bufferedEvent),
+		failed:    make(map[string]Watch),
+	}
+	w.setConfig(cfg)
+	return w, nil
 }
 
 // Start begins watching the configured directories
 func (w *Watcher) Start(ctx context.Context) error {
-	// Add watches for each configured directory
-	for _, watchDir := range w.config.WatchDirs {
-		if err := w.addWatch(watchDir); err != nil {
-			return fmt.Errorf("failed to add watch for %s: %w", watchDir.Path, err)
-		}
-		w.logger.Info("Started watching directory", "path", watchDir.Path, "recursive", watchDir.Recursive)
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	cfg := w.currentConfig()
+	if err := w.addWatches(ctx, cfg); err != nil {
+		return err
 	}
 
 	// Start event processing goroutine
 	w.wg.Add(1)
 	go func() {
 		defer w.wg.Done()
-		w.processEvents(ctx)
+		recovery.Loop(w.logger, "watcher", w.done, func() { w.processEvents(ctx) })
 	}()
 
+	w.ctx = ctx
+	w.startLoops(cfg)
+
+	return nil
+}
+
+// Reload replaces the configuration of a running watcher. Watches are
+// re-registered and polling restarts with the new settings. If the new
+// directories cannot be watched the previous configuration is restored.
+func (w *Watcher) Reload(cfg *config.Config) error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	previous := w.currentConfig()
+
+	// Not started yet, the new configuration is picked up by Start
+	if w.ctx == nil {
+		w.setConfig(cfg)
+		return nil
+	}
+
+	w.stopLoops()
+	w.loopWG.Wait()
+
+	// Events buffered under the old configuration are still valid
+	for _, event := range w.takeSettled(0) {
+		w.emit(event)
+	}
+	for _, event := range w.takeEscalated(0) {
+		w.send(event)
+	}
+
+	w.removeWatches()
+	w.setConfig(cfg)
+
+	if err := w.addWatches(w.ctx, cfg); err != nil {
+		w.logger.Error("Failed to apply new watch configuration, restoring previous", "error", err)
+		w.removeWatches()
+		w.setConfig(previous)
+		if restoreErr := w.addWatches(w.ctx, previous); restoreErr != nil {
+			w.logger.Error("Failed to restore previous watches", "error", restoreErr)
+		}
+		w.startLoops(previous)
+		return err
+	}
+
+	w.startLoops(cfg)
+	return nil
+}
+
+// addWatches registers watches for every configured directory, up to the
+// watch limit
+func (w *Watcher) addWatches(ctx context.Context, cfg *config.Config) error {
+	w.watchLimit.Store(int64(watchLimitOf(cfg)))
+	w.countWatches()
+
+	for _, watchDir := range cfg.WatchDirs {
+		if watchDir.Remote.Enabled() {
+			// Remote hosts report no events, so only polls see their changes
+			w.logger.Info("Polling remote directory", "path", escape.Text(watchDir.Path), "host", watchDir.Remote.Address())
+			continue
+		}
+		if err := w.addWatch(ctx, watchDir); errors.Is(err, errWatchLimit) {
+			// Left to polling
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to add watch for %s: %w", watchDir.Path, err)
+		}
+		w.logger.Info("Started watching directory", "path", escape.Text(watchDir.Path), "recursive", watchDir.Recursive)
+	}
+	return nil
+}
+
+// removeWatches unregisters all watches
+func (w *Watcher) removeWatches() {
+	for _, path := range w.fsWatcher.WatchList() {
+		if err := w.fsWatcher.Remove(path); err != nil {
+			w.logger.Debug("Failed to remove watch", "path", escape.Text(path), "error", err)
+		}
+	}
+
+	w.failedMu.Lock()
+	clear(w.failed)
+	w.failedMu.Unlock()
+	w.countWatches()
+}
+
+// Watches returns every watched directory and every directory that could
+// not be watched, in path order
+func (w *Watcher) Watches() []Watch {
+	var watches []Watch
+	for _, path := range w.fsWatcher.WatchList() {
+		watch := Watch{Path: path}
+		if watchDir, ok := w.findWatchDir(path); ok {
+			watch.Folder = watchDir.Name
+		}
+		watches = append(watches, watch)
+	}
+
+	w.failedMu.Lock()
+	for _, watch := range w.failed {
+		watches = append(watches, watch)
+	}
+	w.failedMu.Unlock()
+
+	slices.SortFunc(watches, func(a, b Watch) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return watches
+}
+
+// recordFailure remembers that a directory could not be watched
+func (w *Watcher) recordFailure(path string, watchDir config.WatchDir, err error) {
+	w.failedMu.Lock()
+	defer w.failedMu.Unlock()
+
+	w.failed[path] = Watch{Path: path, Folder: watchDir.Name, Error: err.Error()}
+}
+
+// clearFailure forgets an earlier failure to watch a directory
+func (w *Watcher) clearFailure(path string) {
+	w.failedMu.Lock()
+	defer w.failedMu.Unlock()
+
+	delete(w.failed, path)
+}
+
+// WatchCounts returns the number of watched directories per watch
+// directory name
+func (w *Watcher) WatchCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, path := range w.fsWatcher.WatchList() {
+		if watchDir, ok := w.findWatchDir(path); ok {
+			counts[watchDir.Name]++
+		}
+	}
+	return counts
+}
+
+// startLoops starts the polling and flushing goroutines for cfg.
+// Callers must hold reloadMu.
+func (w *Watcher) startLoops(cfg *config.Config) {
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.stopLoops = cancel
+
+	// Start flushing coalesced events if debouncing is configured
+	if cfg.Debounce > 0 {
+		w.loopWG.Add(1)
+		go func() {
+			defer w.loopWG.Done()
+			recovery.Loop(w.logger, "debounce", ctx.Done(), func() {
+				w.flushBuffered(ctx, time.Duration(cfg.Debounce)*time.Millisecond)
+			})
+		}()
+	}
+
+	// Start sending the scans of bursts if escalation is configured
+	if cfg.BurstPaths > 0 {
+		w.loopWG.Add(1)
+		go func() {
+			defer w.loopWG.Done()
+			recovery.Loop(w.logger, "bursts", ctx.Done(), func() {
+				w.flushEscalated(ctx, time.Duration(cfg.BurstWindow)*time.Millisecond)
+			})
+		}()
+	}
+
 	// Start polling goroutine if poll interval is configured
-	if w.config.PollInterval > 0 {
-		w.wg.Add(1)
+	if cfg.PollInterval > 0 {
+		w.loopWG.Add(1)
 		go func() {
-			defer w.wg.Done()
-			w.startPolling(ctx)
+			defer w.loopWG.Done()
+			recovery.Loop(w.logger, "poller", ctx.Done(), func() {
+				w.startPolling(ctx, time.Duration(cfg.PollInterval)*time.Second)
+			})
 		}()
-		w.logger.Info("Started polling", "interval_seconds", w.config.PollInterval)
+		w.logger.Info("Started polling", "interval_seconds", cfg.PollInterval)
 	}
+}
 
-	return nil
+// currentConfig returns the configuration in effect
+func (w *Watcher) currentConfig() *config.Config {
+	return w.config.Load()
+}
+
+// setConfig replaces the configuration in effect
+func (w *Watcher) setConfig(cfg *config.Config) {
+	w.config.Store(cfg)
 }
 
 // Events returns the events channel
@@ -85,12 +329,13 @@ func (w *Watcher) Events() <-chan Event {
 	return w.events
 }
 
-// Errors returns the errors channel
+// Errors returns the errors channel, carrying an *Error for each error
 func (w *Watcher) Errors() <-chan error {
 	return w.errors
 }
 
-// Close closes the watcher and releases resources
+// Close stops watching, hands the events still buffered over and closes the
+// channels, releasing resources
 func (w *Watcher) Close() error {
 	// Signal shutdown to all goroutines
 	select {
@@ -112,6 +357,21 @@ func (w *Watcher) Close() error {
 
 	// Wait for all goroutines to finish
 	w.wg.Wait()
+	w.loopWG.Wait()
+
+	// Events still waiting out their debounce window or burst are handed
+	// over where there is room, so that draining consumers handle them
+	dropped := 0
+	for _, event := range append(w.takeSettled(0), w.takeEscalated(0)...) {
+		select {
+		case w.events <- event:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		w.logger.Warn("Event channel full on shutdown, dropping buffered events", "events", dropped)
+	}
 
 	// Close channels after goroutines are done
 	close(w.events)
@@ -121,11 +381,11 @@ func (w *Watcher) Close() error {
 }
 
 // startPolling starts the periodic polling process
-func (w *Watcher) startPolling(ctx context.Context) {
-	ticker := time.NewTicker(time.Duration(w.config.PollInterval) * time.Second)
+func (w *Watcher) startPolling(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	w.logger.Debug("Polling started", "interval", w.config.PollInterval)
+	w.logger.Debug("Polling started", "interval", interval)
 
 	for {
 		select {
@@ -137,26 +397,48 @@ func (w *Watcher) startPolling(ctx context.Context) {
 			return
 		case <-ticker.C:
 			w.logger.Debug("Starting periodic permissions check")
-			w.performPeriodicCheck()
+			w.performPeriodicCheck(ctx)
 		}
 	}
 }
 
-// performPeriodicCheck walks through all watched directories and checks permissions
-func (w *Watcher) performPeriodicCheck() {
-	for _, watchDir := range w.config.WatchDirs {
-		w.checkDirectoryPermissions(watchDir)
+// performPeriodicCheck walks through all watched directories and checks
+// permissions, stopping once ctx is cancelled
+func (w *Watcher) performPeriodicCheck(ctx context.Context) {
+	w.countWatches()
+	for _, watchDir := range w.currentConfig().WatchDirs {
+		if ctx.Err() != nil {
+			return
+		}
+		w.checkDirectoryPermissions(ctx, watchDir)
 	}
 }
 
-// checkDirectoryPermissions recursively checks permissions in a directory
-func (w *Watcher) checkDirectoryPermissions(watchDir config.WatchDir) {
-	err := filepath.Walk(watchDir.Path, func(path string, info os.FileInfo, err error) error {
+// checkDirectoryPermissions recursively checks permissions in a directory.
+// A walk cut short by ctx does not complete the folder's run. Paths that
+// cannot be read fail the run, so a folder that is gone, such as a dropped
+// mount, is not reported as polled successfully.
+func (w *Watcher) checkDirectoryPermissions(ctx context.Context, watchDir config.WatchDir) {
+	if watchDir.Remote.Enabled() {
+		w.pollRemote(ctx, watchDir)
+		return
+	}
+	started := time.Now()
+	failed := 0
+
+	err := walk.Dir(ctx, watchDir.Path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			w.logger.Warn("Error accessing path during polling", "path", path, "error", err)
+			w.logger.Warn("Error accessing path during polling", "path", escape.Text(path), "error", err)
+			failed++
 			return nil // Continue walking
 		}
 
+		// Skip excluded directories with everything below them, as they
+		// are not watched either
+		if d.IsDir() && path != watchDir.Path && w.shouldExclude(path, watchDir) {
+			return filepath.SkipDir
+		}
+
 		// Skip if file should not be processed based on patterns
 		if !w.shouldProcess(path, watchDir) {
 			return nil
@@ -164,61 +446,142 @@ func (w *Watcher) checkDirectoryPermissions(watchDir config.WatchDir) {
 
 		// Create a synthetic event for the processor
 		operation := "POLL_CHECK"
-		if info.IsDir() {
+		if d.IsDir() {
 			operation = "POLL_CHECK_DIR"
 		}
 
+		// Skip paths whose previous poll event has not been processed yet
+		if !w.markPending(path) {
+			w.logger.Debug("Polling event already pending, skipping", "path", escape.Text(path))
+			return nil
+		}
+
 		select {
 		case w.events <- Event{
 			Path:      path,
 			Operation: operation,
 			WatchDir:  watchDir,
 			Timestamp: time.Now(),
+			done:      func() { w.clearPending(path) },
 		}:
-			w.logger.Debug("Generated polling event", "path", path, "operation", operation)
+			w.logger.Debug("Generated polling event", "path", escape.Text(path), "operation", operation)
 		case <-w.done:
+			w.clearPending(path)
 			return fmt.Errorf("shutdown requested") // Stop walking if shutting down
 		default:
-			w.logger.Warn("Event channel full during polling, skipping", "path", path)
+			w.clearPending(path)
+			w.logger.Warn("Event channel full during polling, skipping", "path", escape.Text(path))
 		}
 
 		return nil
 	})
 
+	if ctx.Err() != nil {
+		w.logger.Debug("Periodic check cancelled", "path", escape.Text(watchDir.Path))
+		return
+	}
 	if err != nil {
-		w.logger.Error("Error during periodic check", "path", watchDir.Path, "error", err)
+		w.logger.Error("Error during periodic check", "path", escape.Text(watchDir.Path), "error", err)
+		return
+	}
+
+	// Mark the end of this folder's run so consumers can summarize it.
+	// Unlike per-path events this must not be dropped.
+	select {
+	case w.events <- Event{
+		Path:      watchDir.Path,
+		Operation: "POLL_COMPLETE",
+		WatchDir:  watchDir,
+		Timestamp: started,
+		Failed:    failed,
+	}:
+	case <-ctx.Done():
+	case <-w.done:
+	}
+}
+
+// pollRemote queues a poll of a directory on a remote host. The walk is left
+// to the enforcer, which reaches the host, so the whole poll is one event.
+// A poll still queued or running is not queued again.
+func (w *Watcher) pollRemote(ctx context.Context, watchDir config.WatchDir) {
+	if !w.markPending(watchDir.Path) {
+		w.logger.Debug("Remote poll still pending, skipping", "path", escape.Text(watchDir.Path))
+		return
+	}
+
+	// Like the end of a local run, this must not be dropped
+	select {
+	case w.events <- Event{
+		Path:      watchDir.Path,
+		Operation: "POLL_REMOTE",
+		WatchDir:  watchDir,
+		Timestamp: time.Now(),
+		done:      func() { w.clearPending(watchDir.Path) },
+	}:
+	case <-ctx.Done():
+		w.clearPending(watchDir.Path)
+	case <-w.done:
+		w.clearPending(watchDir.Path)
+	}
+}
+
+// markPending records path as having a queued poll event.
+// It returns false if the path was already pending.
+func (w *Watcher) markPending(path string) bool {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+
+	if _, ok := w.pending[path]; ok {
+		return false
 	}
+	w.pending[path] = struct{}{}
+	return true
+}
+
+// clearPending removes path from the pending set
+func (w *Watcher) clearPending(path string) {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+
+	delete(w.pending, path)
 }
 
-// addWatch adds a watch for a directory and optionally its subdirectories
-func (w *Watcher) addWatch(watchDir config.WatchDir) error {
+// addWatch adds a watch for a directory and optionally its subdirectories.
+// Subdirectories beyond the watch limit are left to polling; the directory
+// itself returns an error wrapping errWatchLimit.
+func (w *Watcher) addWatch(ctx context.Context, watchDir config.WatchDir) error {
 	if _, err := os.Stat(watchDir.Path); err != nil {
 		if os.IsNotExist(err) {
-			w.logger.Warn("Watch directory does not exist", "path", watchDir.Path)
+			w.logger.Warn("Watch directory does not exist", "path", escape.Text(watchDir.Path))
+			w.recordFailure(watchDir.Path, watchDir, err)
 			return nil
 		}
 		return err
 	}
 
 	// Add watch for the directory itself
-	if err := w.fsWatcher.Add(watchDir.Path); err != nil {
+	if err := w.watch(watchDir.Path, watchDir); err != nil {
 		return err
 	}
 
 	// If recursive, add watches for all subdirectories
 	if watchDir.Recursive {
-		return filepath.Walk(watchDir.Path, func(path string, info os.FileInfo, err error) error {
+		return walk.Dir(ctx, watchDir.Path, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
 
-			if info.IsDir() && path != watchDir.Path {
+			if d.IsDir() && path != watchDir.Path {
 				if w.shouldExclude(path, watchDir) {
 					return filepath.SkipDir
 				}
 
-				if err := w.fsWatcher.Add(path); err != nil {
-					w.logger.Warn("Failed to add watch for subdirectory", "path", path, "error", err)
+				if err := w.watch(path, watchDir); errors.Is(err, errWatchLimit) {
+					// Subdirectories are left unwatched without being listed
+					return filepath.SkipDir
+				} else if err != nil {
+					w.logger.Warn("Failed to add watch for subdirectory", "path", escape.Text(path), "error", err)
+					w.recordFailure(path, watchDir, err)
 				}
 			}
 			return nil
@@ -243,31 +606,45 @@ func (w *Watcher) processEvents(ctx context.Context) {
 			}
 
 			// Find the matching watch directory
-			watchDir := w.findWatchDir(event.Name)
-			if watchDir == nil {
+			watchDir, ok := w.findWatchDir(event.Name)
+			if !ok {
 				continue
 			}
 
+			// Convert fsnotify operation to string
+			operation := w.operationToString(event.Op)
+
+			// Directories created inside recursive trees need their own watches
+			if operation == "CREATE" && watchDir.Recursive {
+				w.watchNewDirectory(ctx, event.Name, watchDir)
+			}
+
 			// Check if the file should be processed
-			if !w.shouldProcess(event.Name, *watchDir) {
+			if !w.shouldProcess(event.Name, watchDir) {
 				continue
 			}
 
-			// Convert fsnotify operation to string
-			operation := w.operationToString(event.Op)
+			// Bursts of changes in one directory are enforced by one scan
+			cfg := w.currentConfig()
+			if cfg.BurstPaths > 0 && w.escalate(event.Name, operation, watchDir, cfg.BurstPaths, time.Duration(cfg.BurstWindow)*time.Millisecond) {
+				continue
+			}
+
+			// Files renamed or hard-linked into place (rsync, ln, mv) arrive
+			// complete with no WRITE to follow, so enforce them right away
+			if cfg.Debounce > 0 && !(operation == "CREATE" && isCompleteFile(event.Name)) {
+				w.coalesce(event.Name, operation, watchDir)
+				continue
+			}
+			w.discardBuffered(event.Name)
 
-			// Send event
-			select {
-			case w.events <- Event{
+			if !w.emit(Event{
 				Path:      event.Name,
 				Operation: operation,
-				WatchDir:  *watchDir,
+				WatchDir:  watchDir,
 				Timestamp: time.Now(),
-			}:
-			case <-w.done:
+			}) {
 				return
-			default:
-				w.logger.Warn("Event channel full, dropping event", "path", event.Name)
 			}
 
 		case err, ok := <-w.fsWatcher.Errors:
@@ -275,61 +652,161 @@ func (w *Watcher) processEvents(ctx context.Context) {
 				return
 			}
 
-			select {
-			case w.errors <- err:
-			case <-w.done:
-				return
-			default:
-				w.logger.Error("Error channel full, dropping error", "error", err)
+			classified := classify(err, "")
+			if classified.Kind == ErrorOverflow {
+				w.catchUp()
 			}
+			w.report(classified)
 		}
 	}
 }
 
-// findWatchDir finds the watch directory configuration for a given path
-func (w *Watcher) findWatchDir(path string) *config.WatchDir {
-	for _, watchDir := range w.config.WatchDirs {
-		if strings.HasPrefix(path, watchDir.Path) {
-			return &watchDir
-		}
+// emit sends an event to consumers, dropping it if the channel is full.
+// It returns false if the watcher is shutting down.
+func (w *Watcher) emit(event Event) bool {
+	select {
+	case w.events <- event:
+	case <-w.done:
+		return false
+	default:
+		w.logger.Warn("Event channel full, dropping event", "path", escape.Text(event.Path))
 	}
-	return nil
+	return true
 }
 
-// shouldProcess determines if a file should be processed based on include/exclude patterns
-func (w *Watcher) shouldProcess(path string, watchDir config.WatchDir) bool {
-	filename := filepath.Base(path)
+// coalesce buffers an event, merging it with any pending event for the same path
+func (w *Watcher) coalesce(path, operation string, watchDir config.WatchDir) {
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+
+	now := time.Now()
+	if buffered, ok := w.buffer[path]; ok {
+		// The permission change is kept when another operation wins, so a
+		// path verified before it is checked again
+		buffered.event.Chmodded = buffered.event.Chmodded || buffered.event.Operation == "CHMOD" || operation == "CHMOD"
+		buffered.event.Operation = coalesceOperation(buffered.event.Operation, operation)
+		buffered.lastSeen = now
+		return
+	}
 
-	// Check exclude patterns first
-	for _, pattern := range watchDir.Exclude {
-		if matched, _ := filepath.Match(pattern, filename); matched {
-			return false
-		}
+	w.buffer[path] = &bufferedEvent{
+		event: Event{
+			Path:      path,
+			Operation: operation,
+			WatchDir:  watchDir,
+			Timestamp: now,
+		},
+		lastSeen: now,
+	}
+}
+
+// coalesceOperation merges two operations seen for the same path, keeping
+// the one with the strongest semantic. Removals and renames always win since
+// the path is gone, and CREATE outranks WRITE and CHMOD so new directories
+// still receive full directory handling.
+func coalesceOperation(prev, next string) string {
+	switch {
+	case next == "REMOVE" || next == "RENAME":
+		return next
+	case prev == "CREATE" || next == "CREATE":
+		return "CREATE"
+	case prev == "WRITE" && next == "CHMOD":
+		return prev
+	default:
+		return next
 	}
+}
+
+// discardBuffered drops any buffered event for path, superseded by an immediate one
+func (w *Watcher) discardBuffered(path string) {
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+
+	delete(w.buffer, path)
+}
+
+// isCompleteFile reports whether path is a regular file that already has
+// content. Freshly created files start out empty, so a non-empty file on
+// CREATE was moved or linked into place rather than written.
+func isCompleteFile(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() > 0
+}
+
+// flushBuffered periodically emits buffered events that have settled
+func (w *Watcher) flushBuffered(ctx context.Context, window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
 
-	// If include patterns are specified, file must match at least one
-	if len(watchDir.Include) > 0 {
-		for _, pattern := range watchDir.Include {
-			if matched, _ := filepath.Match(pattern, filename); matched {
-				return true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.done:
+			return
+		case <-ticker.C:
+			for _, event := range w.takeSettled(window) {
+				if !w.emit(event) {
+					return
+				}
 			}
 		}
-		return false
 	}
+}
 
-	return true
+// takeSettled removes and returns buffered events not updated within window
+func (w *Watcher) takeSettled(window time.Duration) []Event {
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+
+	var settled []Event
+	now := time.Now()
+	for path, buffered := range w.buffer {
+		if now.Sub(buffered.lastSeen) >= window {
+			settled = append(settled, buffered.event)
+			delete(w.buffer, path)
+		}
+	}
+	return settled
 }
 
-// shouldExclude determines if a directory should be excluded from watching
-func (w *Watcher) shouldExclude(path string, watchDir config.WatchDir) bool {
-	dirname := filepath.Base(path)
+// watchNewDirectory registers watches for a directory created inside a
+// recursive watch. Symlinks to directories are not followed.
+func (w *Watcher) watchNewDirectory(ctx context.Context, path string, watchDir config.WatchDir) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() || w.shouldExclude(path, watchDir) {
+		return
+	}
 
-	for _, pattern := range watchDir.Exclude {
-		if matched, _ := filepath.Match(pattern, dirname); matched {
-			return true
-		}
+	subtree := watchDir
+	subtree.Path = path
+	if err := w.addWatch(ctx, subtree); errors.Is(err, errWatchLimit) {
+		return
+	} else if err != nil {
+		w.logger.Warn("Failed to add watch for new directory", "path", escape.Text(path), "error", err)
+		w.recordFailure(path, watchDir, err)
+		return
 	}
-	return false
+	w.logger.Debug("Started watching new directory", "path", escape.Text(path))
+}
+
+// findWatchDir finds the watch directory configuration for a given path.
+// Of nested watch directories, the innermost one containing the path wins;
+// /data/media2 is not within /data/media. It reports false for paths in no
+// watch directory.
+func (w *Watcher) findWatchDir(path string) (config.WatchDir, bool) {
+	watchDir, err := w.currentConfig().WatchDirFor(path)
+	return watchDir, err == nil
+}
+
+// shouldProcess determines if a file should be processed based on include/exclude patterns
+func (w *Watcher) shouldProcess(path string, watchDir config.WatchDir) bool {
+	return watchDir.ShouldProcess(path)
+}
+
+// shouldExclude determines if a directory should be excluded from watching
+func (w *Watcher) shouldExclude(path string, watchDir config.WatchDir) bool {
+	return watchDir.ShouldExclude(path)
 }
 
 // operationToString converts fsnotify operation to string
@@ -160,3 +160,414 @@ func TestOperationToString(t *testing.T) {
 		t.Log("No events received (acceptable in test environment)")
 	}
 }
+
+func TestPollingDeduplicatesPendingPaths(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("b"), 0644))
+
+	cfg := &config.Config{
+		WatchDirs: []config.WatchDir{{Path: tmpDir}},
+	}
+
+	watcher, err := New(cfg, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// Two poll cycles without any consumer must not queue duplicates
+	watcher.performPeriodicCheck(context.Background())
+	watcher.performPeriodicCheck(context.Background())
+	assert.Equal(t, 3, drainPollChecks(watcher))
+
+	// Once processed, paths are eligible again
+	watcher.performPeriodicCheck(context.Background())
+	assert.Equal(t, 3, drainPollChecks(watcher))
+}
+
+func TestPollingSkipsExcludedDirectories(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "movie.mkv"), []byte("a"), 0644))
+	backup := filepath.Join(tmpDir, "backup")
+	require.NoError(t, os.MkdirAll(filepath.Join(backup, "old"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(backup, "old", "movie.mkv"), []byte("b"), 0644))
+
+	cfg := &config.Config{
+		WatchDirs: []config.WatchDir{{Path: tmpDir, Recursive: true, Exclude: []string{"backup"}}},
+	}
+
+	watcher, err := New(cfg, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// Only the watch directory and the file outside the backup are checked
+	watcher.performPeriodicCheck(context.Background())
+	assert.Equal(t, 2, drainPollChecks(watcher))
+}
+
+func TestPollingMissingRoot(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{{Path: missing}}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// A folder that is gone completes its run with a failure, not silently
+	watcher.performPeriodicCheck(context.Background())
+	require.Len(t, watcher.events, 1)
+	event := <-watcher.events
+	assert.Equal(t, "POLL_COMPLETE", event.Operation)
+	assert.Equal(t, 1, event.Failed)
+}
+
+func TestPollingCancelled(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "movie.mkv"), []byte("a"), 0644))
+
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{{Path: tmpDir}}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// A cancelled check walks nothing and does not complete the run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	watcher.performPeriodicCheck(ctx)
+	assert.Empty(t, watcher.events)
+}
+
+func TestCloseHandsOverBuffered(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	tmpDir := t.TempDir()
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{{Path: tmpDir}}}, logger)
+	require.NoError(t, err)
+
+	// Events still in their debounce window are not lost on shutdown
+	file := filepath.Join(tmpDir, "movie.mkv")
+	watcher.coalesce(file, "CREATE", config.WatchDir{Path: tmpDir})
+	require.NoError(t, watcher.Close())
+
+	event, ok := <-watcher.Events()
+	require.True(t, ok)
+	assert.Equal(t, file, event.Path)
+	assert.Equal(t, "CREATE", event.Operation)
+	_, ok = <-watcher.Events()
+	assert.False(t, ok)
+}
+
+// drainPollChecks consumes all queued events, marking them done, and returns
+// the number of per-path poll checks among them
+func drainPollChecks(watcher *Watcher) int {
+	count := 0
+	for len(watcher.events) > 0 {
+		event := <-watcher.events
+		if event.Operation != "POLL_COMPLETE" {
+			count++
+		}
+		event.Done()
+	}
+	return count
+}
+
+func TestCoalesceOperation(t *testing.T) {
+	tests := []struct {
+		prev, next, want string
+	}{
+		{"CREATE", "WRITE", "CREATE"},
+		{"CREATE", "CHMOD", "CREATE"},
+		{"WRITE", "CREATE", "CREATE"},
+		{"WRITE", "CHMOD", "WRITE"},
+		{"CHMOD", "WRITE", "WRITE"},
+		{"CREATE", "REMOVE", "REMOVE"},
+		{"WRITE", "RENAME", "RENAME"},
+		{"REMOVE", "CREATE", "CREATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prev+"+"+tt.next, func(t *testing.T) {
+			assert.Equal(t, tt.want, coalesceOperation(tt.prev, tt.next))
+		})
+	}
+}
+
+func TestCoalesceKeepsCreate(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watcher, err := New(&config.Config{Debounce: 50}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	watchDir := config.WatchDir{Path: "/data"}
+	watcher.coalesce("/data/new", "CREATE", watchDir)
+	watcher.coalesce("/data/new", "WRITE", watchDir)
+	watcher.coalesce("/data/new", "WRITE", watchDir)
+
+	assert.Empty(t, watcher.takeSettled(time.Hour))
+
+	settled := watcher.takeSettled(0)
+	require.Len(t, settled, 1)
+	assert.Equal(t, "CREATE", settled[0].Operation)
+	assert.Equal(t, "/data/new", settled[0].Path)
+	assert.False(t, settled[0].Chmodded)
+
+	// Permission changes merged into a write are kept with it
+	watcher.coalesce("/data/file", "CHMOD", watchDir)
+	watcher.coalesce("/data/file", "WRITE", watchDir)
+	settled = watcher.takeSettled(0)
+	require.Len(t, settled, 1)
+	assert.Equal(t, "WRITE", settled[0].Operation)
+	assert.True(t, settled[0].Chmodded)
+}
+
+func TestRenamedFileSkipsDebounce(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watchDir := t.TempDir()
+	stagingDir := t.TempDir()
+
+	cfg := &config.Config{
+		Debounce: 10000,
+		WatchDirs: []config.WatchDir{
+			{Path: watchDir, Exclude: []string{".*"}},
+		},
+	}
+
+	watcher, err := New(cfg, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+
+	// Simulate an rsync import: content is written to an excluded temp name
+	// elsewhere and renamed to its final name inside the watched directory
+	staged := filepath.Join(stagingDir, ".episode.mkv.Xa1b2c")
+	require.NoError(t, os.WriteFile(staged, []byte("video"), 0600))
+	final := filepath.Join(watchDir, "episode.mkv")
+	require.NoError(t, os.Rename(staged, final))
+
+	select {
+	case event := <-watcher.Events():
+		assert.Equal(t, final, event.Path)
+		assert.Equal(t, "CREATE", event.Operation)
+	case <-time.After(2 * time.Second):
+		t.Fatal("renamed file was not emitted before the debounce window elapsed")
+	}
+}
+
+func TestReload(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{{Path: oldDir}}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+	assert.Equal(t, []string{oldDir}, watcher.fsWatcher.WatchList())
+
+	require.NoError(t, watcher.Reload(&config.Config{WatchDirs: []config.WatchDir{{Path: newDir}}}))
+	assert.Equal(t, []string{newDir}, watcher.fsWatcher.WatchList())
+
+	// Only the new directory produces events
+	require.NoError(t, os.WriteFile(filepath.Join(oldDir, "old.txt"), []byte("x"), 0644))
+	newFile := filepath.Join(newDir, "new.txt")
+	require.NoError(t, os.WriteFile(newFile, []byte("x"), 0644))
+
+	select {
+	case event := <-watcher.Events():
+		assert.Equal(t, newFile, event.Path)
+		assert.Equal(t, newDir, event.WatchDir.Path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event from reloaded directory")
+	}
+}
+
+func TestPollingRemote(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	remote := config.WatchDir{Name: "nas", Path: "/volume1/media", Recursive: true, Remote: config.Remote{Host: "nas.lan", Port: 22}}
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{remote}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	// Remote folders are not watched, and polled as a whole
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+	assert.Empty(t, watcher.Watches())
+
+	watcher.performPeriodicCheck(context.Background())
+	watcher.performPeriodicCheck(context.Background())
+	require.Len(t, watcher.events, 1)
+	event := <-watcher.events
+	assert.Equal(t, "POLL_REMOTE", event.Operation)
+	assert.Equal(t, "/volume1/media", event.Path)
+
+	// Once the poll is done, the folder is polled again
+	event.Done()
+	watcher.performPeriodicCheck(context.Background())
+	assert.Len(t, watcher.events, 1)
+}
+
+func TestWatchCounts(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{{Name: "media", Path: root, Recursive: true}}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+
+	assert.Equal(t, map[string]int{"media": 3}, watcher.WatchCounts())
+}
+
+func TestWatches(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "a"), 0755))
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{
+		{Name: "media", Path: root, Recursive: true},
+		{Name: "backup", Path: missing},
+	}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx))
+
+	watches := watcher.Watches()
+	require.Len(t, watches, 3)
+	byPath := make(map[string]Watch)
+	for _, watch := range watches {
+		byPath[watch.Path] = watch
+	}
+
+	assert.Equal(t, Watch{Path: root, Folder: "media"}, byPath[root])
+	assert.Equal(t, Watch{Path: filepath.Join(root, "a"), Folder: "media"}, byPath[filepath.Join(root, "a")])
+	assert.Equal(t, "backup", byPath[missing].Folder)
+	assert.Contains(t, byPath[missing].Error, "no such file or directory")
+}
+
+func TestWatchLimit(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, dir), 0755))
+	}
+
+	watcher, err := New(&config.Config{
+		MaxWatches: 2,
+		WatchDirs:  []config.WatchDir{{Name: "media", Path: root, Recursive: true}},
+	}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, watcher.Start(ctx), "directories beyond the limit are polled instead")
+
+	budget := watcher.Budget()
+	assert.Equal(t, 2, budget.Watches)
+	assert.Equal(t, 2, budget.WatchLimit)
+	assert.Equal(t, 2, budget.Unwatched)
+
+	var unwatched int
+	for _, watch := range watcher.Watches() {
+		if watch.Error != "" {
+			unwatched++
+			assert.Contains(t, watch.Error, "watch limit reached")
+		}
+	}
+	assert.Equal(t, 2, unwatched)
+}
+
+func TestFindWatchDir(t *testing.T) {
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.ErrorLevel)
+
+	watcher, err := New(&config.Config{WatchDirs: []config.WatchDir{
+		{Name: "media", Path: "/data/media"},
+		{Name: "tv", Path: "/data/media/tv"},
+		{Name: "root", Path: "/"},
+	}}, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, watcher.Close())
+	}()
+
+	for path, want := range map[string]string{
+		"/data/media":                 "media",
+		"/data/media/movie.mkv":       "media",
+		"/data/media/tv":              "tv",
+		"/data/media/tv/show/e01.mkv": "tv",
+		"/data/media/tvshows/e01.mkv": "media",
+		"/data/media2/movie.mkv":      "root",
+		"/data/medi":                  "root",
+	} {
+		watchDir, ok := watcher.findWatchDir(path)
+		require.True(t, ok, path)
+		assert.Equal(t, want, watchDir.Name, path)
+	}
+
+	cfg := watcher.currentConfig().Clone()
+	cfg.WatchDirs = cfg.WatchDirs[:2]
+	watcher.setConfig(cfg)
+	_, ok := watcher.findWatchDir("/data/media2/movie.mkv")
+	assert.False(t, ok)
+}
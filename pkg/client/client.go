@@ -0,0 +1,332 @@
+// Package client is a Go client for the ownarr HTTP API described by
+// /api/openapi.json.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Health is the response of the liveness check
+type Health struct {
+	Status string `json:"status"`
+}
+
+// Run summarizes an enforcement run
+type Run struct {
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"duration_ms"`
+	Fixed      int       `json:"fixed"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+}
+
+// Scan describes an enforcement run in flight
+type Scan struct {
+	Root          string    `json:"root"`
+	Started       time.Time `json:"started"`
+	Processed     int       `json:"processed"`
+	Fixed         int       `json:"fixed"`
+	Failed        int       `json:"failed"`
+	Total         int       `json:"total"` // 0 while unknown
+	RatePerSecond float64   `json:"rate_per_second"`
+	ETASeconds    *int64    `json:"eta_seconds"`
+}
+
+// ConfigSummary describes the effective configuration of the instance
+type ConfigSummary struct {
+	LogLevel     string `json:"log_level"`
+	PollInterval int    `json:"poll_interval"`
+	DebounceMS   int    `json:"debounce_ms"`
+	WatchDirs    int    `json:"watch_dirs"`
+}
+
+// FolderStatus describes a single watch directory
+type FolderStatus struct {
+	Name          string     `json:"name"`
+	Path          string     `json:"path"`
+	Recursive     bool       `json:"recursive"`
+	FileMode      string     `json:"file_mode"`
+	DirMode       string     `json:"dir_mode"`
+	Exists        bool       `json:"exists"`
+	Paused        bool       `json:"paused"`
+	LastEvent     *time.Time `json:"last_event"`
+	LastRun       *Run       `json:"last_run"`
+	Scan          *Scan      `json:"scan"`
+	DegradedFlags []string   `json:"degraded_flags"`
+}
+
+// Budget is the use of watches and open files against their limits
+type Budget struct {
+	Watches       int `json:"watches"`
+	WatchLimit    int `json:"watch_limit"`     // 0 if unlimited
+	Unwatched     int `json:"unwatched"`       // Directories only polled for the watch limit
+	OpenFiles     int `json:"open_files"`      // -1 if unknown
+	OpenFileLimit int `json:"open_file_limit"` // 0 if unlimited or unknown
+}
+
+// Status is the response of the status endpoint
+type Status struct {
+	Version         string            `json:"version"`
+	Started         time.Time         `json:"started"`
+	UptimeSeconds   int64             `json:"uptime_seconds"`
+	Paused          bool              `json:"paused"`
+	Degraded        bool              `json:"degraded"`
+	DegradedFlags   []string          `json:"degraded_flags"`
+	DegradedReasons map[string]string `json:"degraded_reasons"`
+	Budget          *Budget           `json:"budget"`
+	Config          ConfigSummary     `json:"config"`
+	Folders         []FolderStatus    `json:"folders"`
+}
+
+// FolderRun is the result of enforcing a single folder within a job
+type FolderRun struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Run
+}
+
+// Job states
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled" // Stopped early by a server shutdown
+)
+
+// Job describes an enforcement run triggered through the API
+type Job struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Fixed    int         `json:"fixed"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Folders  []FolderRun `json:"folders"`
+}
+
+// ActivityEntry describes a single enforcement action from the event stream
+type ActivityEntry struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Path    string    `json:"path"`
+	Kind    string    `json:"kind,omitempty"`
+	OldMode string    `json:"old_mode,omitempty"`
+	NewMode string    `json:"new_mode,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Error is returned when the API responds with an unsuccessful status code
+type Error struct {
+	StatusCode int
+	Message    string
+	Field      string // Setting at fault of a rejected configuration, if known
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("ownarr API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the ownarr HTTP API
+type Client struct {
+	baseURL string
+	apiKey  string
+
+	// HTTPClient performs requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New creates a client for the instance at baseURL (e.g. "http://localhost:8080").
+// The API key is only needed for endpoints that change files and may be empty.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Health checks that the instance is alive
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Status returns uptime, configuration and per-folder state
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Enforce runs enforcement across all watch directories. With async set the
+// returned job is still running; poll it with Job.
+func (c *Client) Enforce(ctx context.Context, async bool) (*Job, error) {
+	return c.enforce(ctx, "/api/v1/enforce", async)
+}
+
+// EnforceFolder runs enforcement for the named watch directory, or for the
+// path relative to it when subpath is not empty
+func (c *Client) EnforceFolder(ctx context.Context, folder, subpath string, async bool) (*Job, error) {
+	endpoint := "/api/v1/enforce/" + url.PathEscape(folder)
+	if subpath != "" {
+		segments := strings.Split(strings.Trim(subpath, "/"), "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		endpoint += "/" + strings.Join(segments, "/")
+	}
+	return c.enforce(ctx, endpoint, async)
+}
+
+// Pause stops the instance from changing permissions until Resume is called.
+// Watching and checks continue.
+func (c *Client) Pause(ctx context.Context) error {
+	return c.setPaused(ctx, "/api/v1/pause")
+}
+
+// Resume lets the instance change permissions again
+func (c *Client) Resume(ctx context.Context) error {
+	return c.setPaused(ctx, "/api/v1/resume")
+}
+
+// PauseFolder stops the instance from changing permissions in the named
+// watch directory until ResumeFolder is called
+func (c *Client) PauseFolder(ctx context.Context, folder string) error {
+	return c.setPaused(ctx, "/api/v1/pause/"+url.PathEscape(folder))
+}
+
+// ResumeFolder lets the instance change permissions in the named watch
+// directory again
+func (c *Client) ResumeFolder(ctx context.Context, folder string) error {
+	return c.setPaused(ctx, "/api/v1/resume/"+url.PathEscape(folder))
+}
+
+// Job returns the state of an enforcement job
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Events streams enforcement activity, calling fn for each entry until ctx is
+// cancelled, the stream ends, or fn returns an error
+func (c *Client) Events(ctx context.Context, fn func(ActivityEntry) error) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/events", nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var entry ActivityEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// enforce starts an enforcement job at endpoint
+func (c *Client) enforce(ctx context.Context, endpoint string, async bool) (*Job, error) {
+	query := url.Values{}
+	if async {
+		query.Set("async", "true")
+	}
+
+	var job Job
+	if err := c.do(ctx, http.MethodPost, endpoint, query, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// setPaused pauses or resumes enforcement through endpoint
+func (c *Client) setPaused(ctx context.Context, endpoint string) error {
+	var resp struct {
+		Paused bool `json:"paused"`
+	}
+	return c.do(ctx, http.MethodPost, endpoint, nil, &resp)
+}
+
+// do performs a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, endpoint string, query url.Values, out any) error {
+	resp, err := c.send(ctx, method, endpoint, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send performs a request, returning an *Error for unsuccessful responses
+func (c *Client) send(ctx context.Context, method, endpoint string, query url.Values) (*http.Response, error) {
+	target := c.baseURL + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer func() { _ = resp.Body.Close() }()
+
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var body struct {
+			Error string `json:"error"`
+			Field string `json:"field"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &body) == nil && body.Error != "" {
+			apiErr.Message, apiErr.Field = body.Error, body.Field
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
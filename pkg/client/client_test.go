@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/keksiqc/ownarr/internal/activity"
+	"github.com/keksiqc/ownarr/internal/config"
+	"github.com/keksiqc/ownarr/internal/enforcer"
+	"github.com/keksiqc/ownarr/internal/server"
+	"github.com/keksiqc/ownarr/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInstance serves the real API over a temporary watch directory
+func newTestInstance(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Show", "Season 1"), 0755))
+	file := filepath.Join(dir, "Show", "Season 1", "e01.mkv")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0600))
+
+	cfg := config.DefaultConfig()
+	cfg.Server.APIKey = "secret"
+	cfg.WatchDirs = []config.WatchDir{{Name: "tv", Path: dir, FileMode: "0644", DirMode: "0755"}}
+
+	logger := log.New(os.Stderr)
+	logger.SetLevel(log.FatalLevel)
+	hub := activity.NewHub()
+	srv := server.New(cfg, server.Dependencies{
+		Enforcer: enforcer.New(hub, logger),
+		Activity: hub,
+		Tracker:  status.NewTracker("test"),
+	}, logger)
+
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts, file
+}
+
+func TestClient(t *testing.T) {
+	ts, file := newTestInstance(t)
+	ctx := context.Background()
+	c := New(ts.URL+"/", "secret")
+
+	health, err := c.Health(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", health.Status)
+
+	job, err := c.EnforceFolder(ctx, "tv", "Show/Season 1", false)
+	require.NoError(t, err)
+	assert.Equal(t, JobCompleted, job.Status)
+	assert.Equal(t, 1, job.Fixed)
+	require.Len(t, job.Folders, 1)
+	assert.Equal(t, filepath.Dir(file), job.Folders[0].Path)
+
+	job, err = c.Enforce(ctx, true)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		polled, err := c.Job(ctx, job.ID)
+		return err == nil && polled.Status == JobCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+
+	st, err := c.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, st.Folders, 1)
+	assert.Equal(t, "tv", st.Folders[0].Name)
+	require.NotNil(t, st.Folders[0].LastRun)
+
+	require.NoError(t, c.Pause(ctx))
+	st, err = c.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, st.Paused)
+	require.NoError(t, c.Resume(ctx))
+
+	require.NoError(t, c.PauseFolder(ctx, "tv"))
+	st, err = c.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, st.Folders[0].Paused)
+	require.NoError(t, c.ResumeFolder(ctx, "tv"))
+}
+
+func TestClientErrors(t *testing.T) {
+	ts, _ := newTestInstance(t)
+	ctx := context.Background()
+
+	_, err := New(ts.URL, "wrong").Enforce(ctx, false)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "unauthorized", apiErr.Message)
+
+	_, err = New(ts.URL, "secret").EnforceFolder(ctx, "movies", "", false)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}